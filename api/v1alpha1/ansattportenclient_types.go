@@ -0,0 +1,92 @@
+package v1alpha1
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+
+	naisiov1 "github.com/nais/liberator/pkg/apis/nais.io/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func init() {
+	SchemeBuilder.Register(&AnsattportenClient{}, &AnsattportenClientList{})
+}
+
+// AnsattportenClient is a client for logging in employees with Ansattporten.
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:resource:shortName=ansattporten
+// +kubebuilder:printcolumn:name="Secret Ref",type=string,JSONPath=`.spec.secretName`
+// +kubebuilder:printcolumn:name="ClientID",type=string,JSONPath=`.status.clientID`
+// +kubebuilder:printcolumn:name="Ready",type=string,JSONPath=`.status.conditions[?(@.type=="Ready")].status`
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+type AnsattportenClient struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   AnsattportenClientSpec    `json:"spec,omitempty"`
+	Status naisiov1.DigdiratorStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// AnsattportenClientList contains a list of AnsattportenClient.
+type AnsattportenClientList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []AnsattportenClient `json:"items"`
+}
+
+// AnsattportenClientSpec defines the desired state of an AnsattportenClient.
+type AnsattportenClientSpec struct {
+	// ClientName is the name of the client, shown in the login prompt. Defaults to the client name configured in digdirator.
+	ClientName string `json:"clientName,omitempty"`
+	// ClientURI is the URL shown to users in the login prompt for navigating back to the application.
+	// Defaults to the client URI configured in digdirator.
+	ClientURI naisiov1.IDPortenURI `json:"clientURI,omitempty"`
+	// RedirectURIs are the valid URLs that Ansattporten may redirect back to after a login.
+	// The first URI is written to the secret.
+	// +kubebuilder:validation:MinItems=1
+	RedirectURIs []naisiov1.IDPortenURI `json:"redirectURIs"`
+	// FrontchannelLogoutURI is the URL that Ansattporten calls when the user logs out in another application.
+	FrontchannelLogoutURI naisiov1.IDPortenURI `json:"frontchannelLogoutURI,omitempty"`
+	// PostLogoutRedirectURIs are the valid URLs that Ansattporten may redirect back to after a logout.
+	// Defaults to the client URI.
+	PostLogoutRedirectURIs []naisiov1.IDPortenURI `json:"postLogoutRedirectURIs,omitempty"`
+	// Scopes are the scopes that the client may request. Defaults to `openid` and `profile`.
+	Scopes []string `json:"scopes,omitempty"`
+	// SecretName is the name of the secret that the client credentials are written to.
+	// +kubebuilder:validation:MinLength=1
+	SecretName string `json:"secretName"`
+	// AccessTokenLifetime is the lifetime of access tokens, in seconds. Defaults to the lifetime configured in digdirator.
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=3600
+	AccessTokenLifetime *int `json:"accessTokenLifetime,omitempty"`
+	// SessionLifetime is the maximum lifetime of sessions, in seconds. Defaults to the lifetime configured in digdirator.
+	// +kubebuilder:validation:Minimum=3600
+	// +kubebuilder:validation:Maximum=7200
+	SessionLifetime *int `json:"sessionLifetime,omitempty"`
+	// SSODisabled disables single sign-on, so that users must log in again even if they are logged in to other applications.
+	SSODisabled *bool `json:"ssoDisabled,omitempty"`
+}
+
+// Hash returns a hash of the spec, used to detect changes that have not yet been synchronized to DigDir.
+func (in *AnsattportenClient) Hash() (string, error) {
+	data, err := json.Marshal(in.Spec)
+	if err != nil {
+		return "", fmt.Errorf("marshalling spec: %w", err)
+	}
+
+	h := fnv.New64a()
+	_, _ = h.Write(data)
+	return fmt.Sprintf("%x", h.Sum64()), nil
+}
+
+func (in *AnsattportenClient) GetStatus() *naisiov1.DigdiratorStatus {
+	return &in.Status
+}
+
+func (in *AnsattportenClient) SetStatus(status naisiov1.DigdiratorStatus) {
+	in.Status = status
+}
@@ -0,0 +1,132 @@
+package v1alpha1
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+
+	naisiov1 "github.com/nais/liberator/pkg/apis/nais.io/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func init() {
+	SchemeBuilder.Register(&MaskinportenScope{}, &MaskinportenScopeList{})
+}
+
+// MaskinportenScope is a scope exposed in Maskinporten, independent of any MaskinportenClient.
+// The fully qualified name of the scope is `<prefix>:<product><separator><name>`, where the prefix is set by digdirator.
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:resource:shortName=mpscope
+// +kubebuilder:printcolumn:name="Scope",type=string,JSONPath=`.status.scope`
+// +kubebuilder:printcolumn:name="Enabled",type=boolean,JSONPath=`.spec.enabled`
+// +kubebuilder:printcolumn:name="Ready",type=string,JSONPath=`.status.conditions[?(@.type=="Ready")].status`
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+type MaskinportenScope struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   MaskinportenScopeSpec   `json:"spec,omitempty"`
+	Status MaskinportenScopeStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// MaskinportenScopeList contains a list of MaskinportenScope.
+type MaskinportenScopeList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []MaskinportenScope `json:"items"`
+}
+
+// MaskinportenScopeSpec defines the desired state of a MaskinportenScope.
+type MaskinportenScopeSpec struct {
+	// Product is the product area that the scope belongs to. It is the first part of the subscope.
+	// +kubebuilder:validation:MinLength=1
+	Product string `json:"product"`
+	// Name is the name of the scope within the product. It is the last part of the subscope.
+	// +kubebuilder:validation:MinLength=1
+	Name string `json:"name"`
+	// Separator between the product and the name in the subscope.
+	// Defaults to `:`, or `/` if the name contains `/`.
+	// +kubebuilder:validation:Enum=":";"/"
+	Separator *string `json:"separator,omitempty"`
+	// Description is shown to consumers browsing scopes in DigDir.
+	// Defaults to the scope description configured in digdirator, which is the product unless configured otherwise.
+	// +kubebuilder:validation:MaxLength=128
+	Description string `json:"description,omitempty"`
+	// LongDescription is shown to consumers viewing the scope in DigDir.
+	// Defaults to the long scope description configured in digdirator, if any.
+	// A last line identifying the MaskinportenScope is always appended.
+	LongDescription string `json:"longDescription,omitempty"`
+	// Enabled controls whether the scope is active in DigDir.
+	// Disabling the scope deactivates it, which revokes access for all consumers until it is enabled again.
+	// +kubebuilder:default=true
+	Enabled bool `json:"enabled"`
+	// AccessibleForAll allows any organization to consume the scope, regardless of the consumers listed.
+	AccessibleForAll *bool `json:"accessibleForAll,omitempty"`
+	// AllowedIntegrations are the integration types allowed to use the scope. Defaults to `maskinporten`.
+	AllowedIntegrations []string `json:"allowedIntegrations,omitempty"`
+	// AtMaxAge is the maximum lifetime of access tokens for the scope, in seconds. Defaults to 30.
+	// +kubebuilder:validation:Minimum=30
+	// +kubebuilder:validation:Maximum=680
+	AtMaxAge *int `json:"atMaxAge,omitempty"`
+	// DelegationSource is the name of a delegation source configured in digdirator, e.g. `altinn`.
+	DelegationSource *string `json:"delegationSource,omitempty"`
+	// Visibility of the scope for organizations browsing scopes in DigDir. Defaults to `public`.
+	// +kubebuilder:validation:Enum=public;private
+	Visibility *string `json:"visibility,omitempty"`
+	// Consumers are the organizations that are granted access to the scope.
+	Consumers []naisiov1.ExposedScopeConsumer `json:"consumers,omitempty"`
+}
+
+// MaskinportenScopeStatus defines the observed state of a MaskinportenScope.
+type MaskinportenScopeStatus struct {
+	naisiov1.DigdiratorStatus `json:",inline"`
+	// Scope is the fully qualified name of the scope in DigDir.
+	Scope string `json:"scope,omitempty"`
+	// Consumers is the state of each consumer in the scope's access control list in DigDir.
+	Consumers []ConsumerStatus `json:"consumers,omitempty"`
+}
+
+// ConsumerStatus is the state of a consumer in the access control list of a scope.
+type ConsumerStatus struct {
+	Orgno string `json:"orgno"`
+	State string `json:"state"`
+}
+
+// ExposedScope returns the scope as if it was exposed by a MaskinportenClient.
+func (in *MaskinportenScope) ExposedScope() naisiov1.ExposedScope {
+	return naisiov1.ExposedScope{
+		AccessibleForAll:    in.Spec.AccessibleForAll,
+		AllowedIntegrations: in.Spec.AllowedIntegrations,
+		AtMaxAge:            in.Spec.AtMaxAge,
+		Consumers:           in.Spec.Consumers,
+		DelegationSource:    in.Spec.DelegationSource,
+		Enabled:             in.Spec.Enabled,
+		Name:                in.Spec.Name,
+		Product:             in.Spec.Product,
+		Separator:           in.Spec.Separator,
+		Visibility:          in.Spec.Visibility,
+	}
+}
+
+// Hash returns a hash of the spec, used to detect changes that have not yet been synchronized to DigDir.
+func (in *MaskinportenScope) Hash() (string, error) {
+	data, err := json.Marshal(in.Spec)
+	if err != nil {
+		return "", fmt.Errorf("marshalling spec: %w", err)
+	}
+
+	h := fnv.New64a()
+	_, _ = h.Write(data)
+	return fmt.Sprintf("%x", h.Sum64()), nil
+}
+
+func (in *MaskinportenScope) GetStatus() *naisiov1.DigdiratorStatus {
+	return &in.Status.DigdiratorStatus
+}
+
+func (in *MaskinportenScope) SetStatus(status naisiov1.DigdiratorStatus) {
+	in.Status.DigdiratorStatus = status
+}
@@ -0,0 +1,95 @@
+// Command digdir-fake serves an in-memory fake of the DigDir self-service API and the Maskinporten token endpoint.
+// Point digdirator's admin base URL and well-known URLs at it to run it without access to DigDir, e.g. in a local kind cluster.
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	flag "github.com/spf13/pflag"
+
+	"github.com/nais/digdirator/pkg/digdir/fake"
+	"github.com/nais/digdirator/pkg/digdir/types"
+)
+
+func main() {
+	slog.SetDefault(slog.New(slog.NewJSONHandler(os.Stdout, nil)))
+
+	if err := run(); err != nil {
+		slog.Error("Run loop errored", "error", err)
+		os.Exit(1)
+	}
+}
+
+func run() error {
+	bindAddress := flag.String("bind-address", ":8080", "The address the fake API binds to.")
+	orgno := flag.String("orgno", fake.DefaultOrgno, "Organization number of the authenticated admin client.")
+	maxJwksSize := flag.Int("max-jwks-size", fake.DefaultMaxJwksSize, "Maximum number of keys in a client's JWKS.")
+	keyLifetime := flag.Duration("key-lifetime", fake.DefaultKeyLifetime, "Lifetime of registered client keys.")
+	tokenLifetime := flag.Duration("token-lifetime", fake.DefaultTokenLifetime, "Lifetime of issued access tokens.")
+	openScopes := flag.StringSlice("open-scopes", []string{"nav:test/api"}, "Scopes owned by another organization that are accessible to all organizations, in the format '<prefix>:<subscope>'.")
+	delegationSources := flag.StringToString("delegation-sources", map[string]string{"altinn": "https://tt02.altinn.no/"}, "Delegation sources, in the format '<name>=<issuer>'.")
+	flag.Parse()
+
+	srv := fake.New(fake.Options{
+		Orgno:             *orgno,
+		MaxJwksSize:       *maxJwksSize,
+		KeyLifetime:       *keyLifetime,
+		TokenLifetime:     *tokenLifetime,
+		DelegationSources: toDelegationSources(*delegationSources),
+	})
+
+	for _, scope := range *openScopes {
+		prefix, subscope, found := strings.Cut(scope, ":")
+		if !found {
+			return fmt.Errorf("invalid open scope %q: must be in the format '<prefix>:<subscope>'", scope)
+		}
+
+		srv.AddScope(types.ScopeRegistration{
+			AccessibleForAll: true,
+			Active:           true,
+			Name:             scope,
+			OwnerOrgno:       "000000000",
+			Prefix:           prefix,
+			Subscope:         subscope,
+		})
+	}
+
+	server := &http.Server{
+		Addr:              *bindAddress,
+		Handler:           srv,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = server.Shutdown(shutdownCtx)
+	}()
+
+	slog.Info("serving fake DigDir API", "address", *bindAddress, "orgno", *orgno)
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("serving: %w", err)
+	}
+	return nil
+}
+
+func toDelegationSources(sources map[string]string) []types.DelegationSource {
+	result := make([]types.DelegationSource, 0, len(sources))
+	for name, issuer := range sources {
+		result = append(result, types.DelegationSource{Name: name, Issuer: issuer})
+	}
+	return result
+}
@@ -6,16 +6,24 @@ import (
 	"log/slog"
 	"net/http"
 	"os"
+	"strings"
 	"time"
 
+	"github.com/go-jose/go-jose/v4"
 	"github.com/go-logr/logr"
+	digdirv1alpha1 "github.com/nais/digdirator/api/v1alpha1"
+	"github.com/nais/digdirator/controllers/ansattportenclient"
 	"github.com/nais/digdirator/controllers/common"
+	"github.com/nais/digdirator/controllers/garbagecollector"
 	"github.com/nais/digdirator/controllers/idportenclient"
 	"github.com/nais/digdirator/controllers/maskinportenclient"
+	"github.com/nais/digdirator/controllers/maskinportenscope"
 	"github.com/nais/digdirator/internal/crypto/signer"
 	"github.com/nais/digdirator/pkg/config"
 	"github.com/nais/digdirator/pkg/digdir"
 	"github.com/nais/digdirator/pkg/metrics"
+	"github.com/nais/digdirator/pkg/secrets"
+	"github.com/nais/digdirator/pkg/tracing"
 	nais_io_v1 "github.com/nais/liberator/pkg/apis/nais.io/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
@@ -34,6 +42,7 @@ func init() {
 
 	_ = clientgoscheme.AddToScheme(scheme)
 	_ = nais_io_v1.AddToScheme(scheme)
+	_ = digdirv1alpha1.AddToScheme(scheme)
 	// +kubebuilder:scaffold:scheme
 }
 
@@ -52,8 +61,27 @@ func run() error {
 		return err
 	}
 
+	shutdownTracing, err := tracing.Setup(ctx, cfg.Tracing.Enabled)
+	if err != nil {
+		return fmt.Errorf("setting up tracing: %w", err)
+	}
+	defer func() {
+		// the signal handler context is done at this point, so the remaining spans are flushed with a fresh context
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := shutdownTracing(ctx); err != nil {
+			slog.Error("shutting down tracing", "error", err)
+		}
+	}()
+
+	cacheOptions, err := common.CacheOptions()
+	if err != nil {
+		return err
+	}
+
 	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
 		Scheme: scheme,
+		Cache:  cacheOptions,
 		Metrics: ctrlmetricsserver.Options{
 			BindAddress: cfg.MetricsAddr,
 		},
@@ -65,16 +93,21 @@ func run() error {
 		return fmt.Errorf("starting manager: %w", err)
 	}
 
-	kmsSigner, err := signer.NewKmsSigner(ctx, cfg.DigDir.Admin.KMSKeyPath, []byte(cfg.DigDir.Admin.CertChain))
+	adminSigner, err := newSigner(ctx, cfg.DigDir.Admin)
 	if err != nil {
-		return fmt.Errorf("setting up kms signer: %w", err)
+		return fmt.Errorf("setting up %s signer: %w", cfg.DigDir.Admin.Signer, err)
 	}
 
-	digdirClient, err := digdir.NewClient(cfg, http.DefaultClient, kmsSigner)
+	digdirClient, err := digdir.NewClient(cfg, http.DefaultClient, adminSigner)
 	if err != nil {
 		return fmt.Errorf("setting up digdir client: %w", err)
 	}
 
+	identities, err := newIdentityClients(ctx, cfg, digdirClient)
+	if err != nil {
+		return err
+	}
+
 	reconciler := common.NewReconciler(
 		mgr.GetClient(),
 		mgr.GetAPIReader(),
@@ -82,21 +115,47 @@ func run() error {
 		mgr.GetEventRecorder("digdirator"),
 		cfg,
 		digdirClient,
+		identities...,
 	)
 
+	if cfg.DryRun {
+		slog.Info("dry-run mode enabled; changes to DigDir are planned, not performed")
+		if err := mgr.AddMetricsServerExtraHandler("/debug/plan", reconciler.Plans); err != nil {
+			return fmt.Errorf("adding plan handler: %w", err)
+		}
+	}
+
 	if cfg.Features.IDPorten {
 		if err = idportenclient.NewReconciler(reconciler).SetupWithManager(mgr); err != nil {
 			return fmt.Errorf("creating idportenclient controller: %w", err)
 		}
 	}
 
+	if cfg.Features.Ansattporten {
+		if err = ansattportenclient.NewReconciler(reconciler).SetupWithManager(mgr); err != nil {
+			return fmt.Errorf("creating ansattportenclient controller: %w", err)
+		}
+	}
+
 	if cfg.Features.Maskinporten {
 		if err = maskinportenclient.NewReconciler(reconciler).SetupWithManager(mgr); err != nil {
 			return fmt.Errorf("creating maskinportenclient controller: %w", err)
 		}
 	}
 
-	clusterMetrics := metrics.New(mgr.GetClient())
+	if cfg.Features.MaskinportenScopes {
+		if err = maskinportenscope.NewReconciler(reconciler).SetupWithManager(mgr); err != nil {
+			return fmt.Errorf("creating maskinportenscope controller: %w", err)
+		}
+	}
+
+	if cfg.GarbageCollector.Enabled {
+		if err = garbagecollector.NewGarbageCollector(reconciler).SetupWithManager(mgr); err != nil {
+			return fmt.Errorf("creating garbage collector: %w", err)
+		}
+	}
+
+	clusterMetrics := metrics.New(mgr.GetClient(), cfg.Features)
 	go clusterMetrics.Refresh(ctx)
 
 	if err := mgr.Start(ctx); err != nil {
@@ -116,31 +175,108 @@ func setup(ctx context.Context) (*config.Config, error) {
 		return nil, err
 	}
 
-	cfg.Print([]string{
+	redacted := []string{
 		config.DigDirAdminCertChain,
-	})
+		config.DigDirAdminKeyFilePassword,
+	}
+	for name := range cfg.DigDir.Identities {
+		redacted = append(redacted,
+			fmt.Sprintf("digdir.identities.%s.cert-chain", name),
+			fmt.Sprintf("digdir.identities.%s.key-file-password", name),
+		)
+	}
+	cfg.Print(redacted)
 
 	required := []string{
 		config.ClusterName,
 		config.DigDirAdminBaseURL,
 		config.DigDirAdminClientID,
-		config.DigDirAdminCertChain,
-		config.DigDirAdminKmsKeyPath,
 		config.DigDirAdminScopes,
+		config.DigDirAdminSigner,
 		config.DigDirIDPortenWellKnownURL,
 		config.DigDirMaskinportenWellKnownURL,
 	}
 
+	if cfg.Features.Ansattporten {
+		required = append(required, config.DigDirAnsattportenWellKnownURL)
+	}
+
+	switch cfg.DigDir.Admin.Signer {
+	case config.AdminSignerKMS:
+		required = append(required, config.DigDirAdminCertChain, config.DigDirAdminKmsKeyPath)
+	case config.AdminSignerFile:
+		required = append(required, config.DigDirAdminKeyFile)
+	}
+
 	if err = cfg.Validate(required); err != nil {
 		return nil, err
 	}
 
+	// the secret templates are not validated with the rest of the config, as they refer to keys defined by the secrets package,
+	// which imports the config package
+	if err = secrets.ParseTemplates(&cfg.SecretTemplates); err != nil {
+		return nil, fmt.Errorf("parsing secret templates: %w", err)
+	}
+
+	if cfg.GarbageCollector.Namespace == "" {
+		cfg.GarbageCollector.Namespace = inClusterNamespace()
+	}
+
+	if cfg.GarbageCollector.Enabled && cfg.GarbageCollector.Delete && cfg.GarbageCollector.Namespace == "" {
+		return nil, fmt.Errorf("%q must be set when deleting orphaned clients outside a cluster", config.GarbageCollectorNamespace)
+	}
+
 	ctx, cancel := context.WithTimeout(ctx, 1*time.Minute)
 	defer cancel()
 
 	return cfg.WithProviderMetadata(ctx)
 }
 
+func newSigner(ctx context.Context, admin config.Admin) (jose.Signer, error) {
+	switch admin.Signer {
+	case config.AdminSignerFile:
+		return signer.NewFileSigner(admin.KeyFile, admin.KeyFilePassword, []byte(admin.CertChain), jose.SignatureAlgorithm(admin.KeyFileAlgorithm))
+	default:
+		return signer.NewKmsSigner(ctx, admin.KMSKeyPath, []byte(admin.CertChain))
+	}
+}
+
+// newIdentityClients returns a client for each additional admin identity, derived from the client for the default identity.
+func newIdentityClients(ctx context.Context, cfg *config.Config, digdirClient digdir.Client) ([]digdir.Client, error) {
+	identities := make([]digdir.Client, 0, len(cfg.DigDir.Identities))
+
+	for _, name := range cfg.DigDir.IdentityNames() {
+		if name == config.DefaultIdentity {
+			continue
+		}
+
+		admin, _ := cfg.DigDir.Identity(name)
+		identitySigner, err := newSigner(ctx, admin)
+		if err != nil {
+			return nil, fmt.Errorf("setting up %s signer for identity %q: %w", admin.Signer, name, err)
+		}
+
+		identity, err := digdirClient.ForIdentity(name, identitySigner)
+		if err != nil {
+			return nil, fmt.Errorf("setting up digdir client for identity %q: %w", name, err)
+		}
+
+		slog.Info("configured admin identity", "identity", name, "client_id", admin.ClientID)
+		identities = append(identities, identity)
+	}
+
+	return identities, nil
+}
+
+// inClusterNamespace returns the namespace of the running application, or an empty string if not running in a cluster.
+func inClusterNamespace() string {
+	namespace, err := os.ReadFile("/var/run/secrets/kubernetes.io/serviceaccount/namespace")
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(namespace))
+}
+
 func setupLogger(logLevel string) error {
 	var level slog.Level
 	if err := level.UnmarshalText([]byte(logLevel)); err != nil {
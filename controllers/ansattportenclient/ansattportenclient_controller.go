@@ -0,0 +1,51 @@
+package ansattportenclient
+
+import (
+	"context"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+
+	digdirv1alpha1 "github.com/nais/digdirator/api/v1alpha1"
+	"github.com/nais/digdirator/controllers/common"
+	"github.com/nais/digdirator/pkg/clients"
+)
+
+type AnsattportenReconciler struct {
+	common.Reconciler
+}
+
+func NewReconciler(reconciler common.Reconciler) *AnsattportenReconciler {
+	return &AnsattportenReconciler{Reconciler: reconciler}
+}
+
+// +kubebuilder:rbac:groups=digdir.nais.io,resources=ansattportenclients,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=digdir.nais.io,resources=ansattportenclients/status,verbs=get;update;patch;create
+// +kubebuilder:rbac:groups=*,resources=events,verbs=get;list;watch;create;update
+
+func (r *AnsattportenReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	return r.Reconciler.Reconcile(ctx, req, &digdirv1alpha1.AnsattportenClient{})
+}
+
+func (r *AnsattportenReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&digdirv1alpha1.AnsattportenClient{}, builder.WithPredicates(predicate.Or[client.Object](
+			predicate.GenerationChangedPredicate{},
+			predicate.AnnotationChangedPredicate{},
+			predicate.LabelChangedPredicate{},
+		))).
+		Owns(&corev1.Secret{}, builder.WithPredicates(common.OwnedSecretPredicate())).
+		Watches(&corev1.Pod{}, common.EnqueueForApplication(mgr.GetClient(), newInstance), builder.WithPredicates(common.RolloutPredicate())).
+		Watches(&appsv1.ReplicaSet{}, common.EnqueueForApplication(mgr.GetClient(), newInstance), builder.WithPredicates(common.RolloutPredicate())).
+		WithOptions(controller.Options{MaxConcurrentReconciles: r.Config.MaxConcurrentReconciles}).
+		Complete(r)
+}
+
+func newInstance() clients.Instance {
+	return &digdirv1alpha1.AnsattportenClient{}
+}
@@ -0,0 +1,192 @@
+package ansattportenclient_test
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-jose/go-jose/v4"
+	naisiov1 "github.com/nais/liberator/pkg/apis/nais.io/v1"
+	"github.com/nais/liberator/pkg/oauth"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/events"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	fakeclient "sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	digdirv1alpha1 "github.com/nais/digdirator/api/v1alpha1"
+	"github.com/nais/digdirator/controllers/ansattportenclient"
+	"github.com/nais/digdirator/controllers/common"
+	"github.com/nais/digdirator/pkg/clients"
+	"github.com/nais/digdirator/pkg/config"
+	"github.com/nais/digdirator/pkg/crypto"
+	"github.com/nais/digdirator/pkg/digdir"
+	"github.com/nais/digdirator/pkg/digdir/fake"
+	"github.com/nais/digdirator/pkg/digdir/types"
+	"github.com/nais/digdirator/pkg/secrets"
+)
+
+const (
+	namespace  = "test-namespace"
+	name       = "my-app"
+	secretName = "my-secret"
+)
+
+type testEnv struct {
+	server     *fake.Server
+	k8s        client.Client
+	recorder   *events.FakeRecorder
+	reconciler *ansattportenclient.AnsattportenReconciler
+}
+
+func TestAnsattportenReconciler(t *testing.T) {
+	t.Run("registers client and writes secret", func(t *testing.T) {
+		env := setup(t)
+
+		env.reconcile(t)
+
+		require.Len(t, env.server.Clients(), 1)
+		registration := env.server.Clients()[0]
+		assert.Equal(t, types.IntegrationTypeAnsattporten, registration.IntegrationType)
+		assert.Equal(t, "test-cluster:test-namespace:my-app", registration.Description)
+		assert.Equal(t, []string{"https://my-app.example.com/oauth2/callback"}, registration.RedirectURIs)
+		assert.Equal(t, []string{"openid", "profile"}, registration.Scopes)
+
+		instance := env.get(t)
+		assert.Equal(t, registration.ClientID, instance.Status.ClientID)
+		assert.True(t, common.IsStatusConditionTrue(instance.Status.Conditions, common.ConditionTypeReady))
+
+		secret := &corev1.Secret{}
+		require.NoError(t, env.k8s.Get(t.Context(), client.ObjectKey{Namespace: namespace, Name: secretName}, secret))
+		assert.Equal(t, clients.AnsattportenTypeLabelValue, secret.GetLabels()[clients.TypeLabelKey])
+		assert.Equal(t, registration.ClientID, string(secret.Data[secrets.AnsattportenClientIDKey]))
+		assert.Equal(t, "https://my-app.example.com/oauth2/callback", string(secret.Data[secrets.AnsattportenRedirectURIKey]))
+		assert.NotEmpty(t, secret.Data[secrets.AnsattportenJwkKey])
+		assert.NotEmpty(t, secret.Data[secrets.AnsattportenWellKnownURLKey])
+		assert.NotEmpty(t, secret.Data[secrets.AnsattportenIssuerKey])
+		assert.NotEmpty(t, secret.Data[secrets.AnsattportenJwksUriKey])
+		assert.NotEmpty(t, secret.Data[secrets.AnsattportenTokenEndpointKey])
+	})
+
+	t.Run("deletes client when resource is deleted", func(t *testing.T) {
+		env := setup(t)
+		env.reconcile(t)
+		require.Len(t, env.server.Clients(), 1)
+
+		require.NoError(t, env.k8s.Delete(t.Context(), env.get(t)))
+		env.reconcile(t)
+
+		assert.Empty(t, env.server.Clients())
+		err := env.k8s.Get(t.Context(), client.ObjectKey{Namespace: namespace, Name: name}, &digdirv1alpha1.AnsattportenClient{})
+		assert.True(t, apierrors.IsNotFound(err), "AnsattportenClient should not exist")
+	})
+
+	t.Run("registers client with the identity assigned to the namespace", func(t *testing.T) {
+		env := setup(t, func(cfg *config.Config) {
+			cfg.DigDir.Identities = map[string]config.Identity{
+				"sister": {
+					Admin:      config.Admin{ClientID: "sister-admin"},
+					Namespaces: []string{namespace},
+				},
+			}
+		})
+		env.server.SetOrgno("sister-admin", "999999999")
+
+		env.reconcile(t)
+
+		require.Len(t, env.server.Clients(), 1)
+		registration := env.server.Clients()[0]
+		assert.Equal(t, "999999999", registration.ClientOrgno)
+		assert.Equal(t, registration.ClientID, env.get(t).Status.ClientID)
+		assert.Contains(t, <-env.recorder.Events, "Client is registered (identity: sister)")
+	})
+}
+
+func setup(t *testing.T, opts ...func(cfg *config.Config)) *testEnv {
+	srv := fake.New(fake.Options{})
+	httpServer := httptest.NewServer(srv)
+	t.Cleanup(httpServer.Close)
+
+	wellKnownURL := httpServer.URL + "/.well-known/openid-configuration"
+	metadata, err := oauth.NewMetadataOpenID(t.Context(), wellKnownURL)
+	require.NoError(t, err)
+
+	// the admin client authenticates with Maskinporten
+	adminMetadata, err := oauth.NewMetadataOAuth(t.Context(), httpServer.URL+"/.well-known/oauth-authorization-server")
+	require.NoError(t, err)
+
+	cfg := &config.Config{ClusterName: "test-cluster", MaxConcurrentReconciles: 1}
+	cfg.DigDir.Admin.BaseURL = httpServer.URL
+	cfg.DigDir.Admin.ClientID = "admin"
+	cfg.DigDir.Ansattporten.WellKnownURL = wellKnownURL
+	cfg.DigDir.Ansattporten.Metadata = *metadata
+	cfg.DigDir.Common.AccessTokenLifetime = 3600
+	cfg.DigDir.Common.ClientName = "test-client"
+	cfg.DigDir.Common.ClientURI = "https://example.com"
+	cfg.DigDir.Common.KeyType = string(crypto.DefaultKeyType)
+	cfg.DigDir.Common.SessionLifetime = 7200
+	cfg.DigDir.Maskinporten.Metadata = *adminMetadata
+	cfg.Features.Ansattporten = true
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	privateKey, err := crypto.GenerateRSAKey()
+	require.NoError(t, err)
+	signer, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.RS256, Key: privateKey}, nil)
+	require.NoError(t, err)
+
+	digdirClient, err := digdir.NewClient(cfg, httpServer.Client(), signer)
+	require.NoError(t, err)
+
+	identities := make([]digdir.Client, 0)
+	for name := range cfg.DigDir.Identities {
+		identity, err := digdirClient.ForIdentity(name, signer)
+		require.NoError(t, err)
+		identities = append(identities, identity)
+	}
+
+	scheme := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(scheme))
+	require.NoError(t, naisiov1.AddToScheme(scheme))
+	require.NoError(t, digdirv1alpha1.AddToScheme(scheme))
+
+	k8s := fakeclient.NewClientBuilder().
+		WithScheme(scheme).
+		WithStatusSubresource(&digdirv1alpha1.AnsattportenClient{}).
+		WithObjects(&digdirv1alpha1.AnsattportenClient{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace, Generation: 1},
+			Spec: digdirv1alpha1.AnsattportenClientSpec{
+				RedirectURIs: []naisiov1.IDPortenURI{"https://my-app.example.com/oauth2/callback"},
+				SecretName:   secretName,
+			},
+		}).
+		Build()
+
+	recorder := events.NewFakeRecorder(100)
+	reconciler := common.NewReconciler(k8s, k8s, scheme, recorder, cfg, digdirClient, identities...)
+
+	return &testEnv{
+		server:     srv,
+		k8s:        k8s,
+		recorder:   recorder,
+		reconciler: ansattportenclient.NewReconciler(reconciler),
+	}
+}
+
+func (e *testEnv) reconcile(t *testing.T) {
+	req := ctrl.Request{NamespacedName: client.ObjectKey{Namespace: namespace, Name: name}}
+	_, err := e.reconciler.Reconcile(t.Context(), req)
+	require.NoError(t, err)
+}
+
+func (e *testEnv) get(t *testing.T) *digdirv1alpha1.AnsattportenClient {
+	instance := &digdirv1alpha1.AnsattportenClient{}
+	require.NoError(t, e.k8s.Get(t.Context(), client.ObjectKey{Namespace: namespace, Name: name}, instance))
+	return instance
+}
@@ -0,0 +1,123 @@
+package common
+
+import (
+	"fmt"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/selection"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/nais/digdirator/pkg/clients"
+)
+
+// CacheOptions restricts the cache of the manager to the objects that digdirator reads, so that the other objects in the
+// cluster are neither watched nor kept in memory:
+//   - secrets that are labelled with a digdirator client type.
+//   - pods and replica sets that are labelled with an application, stripped down to the secrets that they reference,
+//     see EnqueueForApplication and secretsClient.Pruning.
+func CacheOptions() (cache.Options, error) {
+	managed, err := labels.NewRequirement(clients.TypeLabelKey, selection.In, []string{
+		clients.IDPortenTypeLabelValue,
+		clients.MaskinportenTypeLabelValue,
+		clients.AnsattportenTypeLabelValue,
+	})
+	if err != nil {
+		return cache.Options{}, fmt.Errorf("creating label selector for secrets: %w", err)
+	}
+
+	application, err := labels.NewRequirement(clients.AppLabelKey, selection.Exists, nil)
+	if err != nil {
+		return cache.Options{}, fmt.Errorf("creating label selector for workloads: %w", err)
+	}
+
+	return cache.Options{
+		ByObject: map[client.Object]cache.ByObject{
+			&corev1.Secret{}: {Label: labels.NewSelector().Add(*managed)},
+			&corev1.Pod{}: {
+				Label:     labels.NewSelector().Add(*application),
+				Transform: stripPod,
+			},
+			&appsv1.ReplicaSet{}: {
+				Label:     labels.NewSelector().Add(*application),
+				Transform: stripReplicaSet,
+			},
+		},
+	}, nil
+}
+
+// stripPod keeps the fields of a pod that are needed to find the secrets that it uses, and whether it has terminated.
+func stripPod(obj any) (any, error) {
+	pod, ok := obj.(*corev1.Pod)
+	if !ok {
+		return obj, nil
+	}
+
+	return &corev1.Pod{
+		TypeMeta:   pod.TypeMeta,
+		ObjectMeta: stripObjectMeta(pod.ObjectMeta),
+		Spec:       stripPodSpec(pod.Spec),
+		Status:     corev1.PodStatus{Phase: pod.Status.Phase},
+	}, nil
+}
+
+// stripReplicaSet keeps the fields of a replica set that are needed to find the secrets that its pods use, and whether it is scaled down.
+func stripReplicaSet(obj any) (any, error) {
+	rs, ok := obj.(*appsv1.ReplicaSet)
+	if !ok {
+		return obj, nil
+	}
+
+	return &appsv1.ReplicaSet{
+		TypeMeta:   rs.TypeMeta,
+		ObjectMeta: stripObjectMeta(rs.ObjectMeta),
+		Spec: appsv1.ReplicaSetSpec{
+			Replicas: rs.Spec.Replicas,
+			Selector: rs.Spec.Selector,
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{Labels: rs.Spec.Template.Labels},
+				Spec:       stripPodSpec(rs.Spec.Template.Spec),
+			},
+		},
+		Status: rs.Status,
+	}, nil
+}
+
+func stripObjectMeta(meta metav1.ObjectMeta) metav1.ObjectMeta {
+	return metav1.ObjectMeta{
+		Name:              meta.Name,
+		Namespace:         meta.Namespace,
+		UID:               meta.UID,
+		ResourceVersion:   meta.ResourceVersion,
+		Generation:        meta.Generation,
+		CreationTimestamp: meta.CreationTimestamp,
+		DeletionTimestamp: meta.DeletionTimestamp,
+		Labels:            meta.Labels,
+		OwnerReferences:   meta.OwnerReferences,
+	}
+}
+
+func stripPodSpec(spec corev1.PodSpec) corev1.PodSpec {
+	stripContainers := func(containers []corev1.Container) []corev1.Container {
+		result := make([]corev1.Container, 0, len(containers))
+		for _, c := range containers {
+			env := make([]corev1.EnvVar, 0)
+			for _, e := range c.Env {
+				if e.ValueFrom != nil && e.ValueFrom.SecretKeyRef != nil {
+					env = append(env, corev1.EnvVar{Name: e.Name, ValueFrom: &corev1.EnvVarSource{SecretKeyRef: e.ValueFrom.SecretKeyRef}})
+				}
+			}
+			result = append(result, corev1.Container{Name: c.Name, Env: env, EnvFrom: c.EnvFrom})
+		}
+		return result
+	}
+
+	return corev1.PodSpec{
+		Volumes:        spec.Volumes,
+		InitContainers: stripContainers(spec.InitContainers),
+		Containers:     stripContainers(spec.Containers),
+	}
+}
@@ -0,0 +1,99 @@
+package common
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+
+	"github.com/nais/digdirator/pkg/clients"
+)
+
+func TestCacheTransforms(t *testing.T) {
+	podSpec := corev1.PodSpec{
+		Volumes: []corev1.Volume{
+			{Name: "secret", VolumeSource: corev1.VolumeSource{Secret: &corev1.SecretVolumeSource{SecretName: "volume-secret"}}},
+		},
+		Containers: []corev1.Container{{
+			Name:  "main",
+			Image: "image",
+			Args:  []string{"--verbose"},
+			Env: []corev1.EnvVar{
+				{Name: "PLAIN", Value: "value"},
+				{Name: "FROM_SECRET", ValueFrom: &corev1.EnvVarSource{SecretKeyRef: &corev1.SecretKeySelector{
+					LocalObjectReference: corev1.LocalObjectReference{Name: "env-secret"},
+					Key:                  "key",
+				}}},
+			},
+			EnvFrom: []corev1.EnvFromSource{{SecretRef: &corev1.SecretEnvSource{LocalObjectReference: corev1.LocalObjectReference{Name: "envfrom-secret"}}}},
+		}},
+	}
+	meta := metav1.ObjectMeta{
+		Name:          "app-abc",
+		Namespace:     "test-namespace",
+		Labels:        map[string]string{clients.AppLabelKey: "app"},
+		Annotations:   map[string]string{"kubectl.kubernetes.io/last-applied-configuration": "{}"},
+		ManagedFields: []metav1.ManagedFieldsEntry{{Manager: "kubectl"}},
+	}
+
+	assertStripped := func(t *testing.T, actualMeta metav1.ObjectMeta, actualSpec corev1.PodSpec) {
+		assert.Equal(t, meta.Labels, actualMeta.Labels)
+		assert.Empty(t, actualMeta.Annotations)
+		assert.Empty(t, actualMeta.ManagedFields)
+
+		assert.Equal(t, podSpec.Volumes, actualSpec.Volumes)
+		require.Len(t, actualSpec.Containers, 1)
+		container := actualSpec.Containers[0]
+		assert.Equal(t, "main", container.Name)
+		assert.Empty(t, container.Image)
+		assert.Empty(t, container.Args)
+		assert.Equal(t, podSpec.Containers[0].Env[1:], container.Env, "only secret references should be kept")
+		assert.Equal(t, podSpec.Containers[0].EnvFrom, container.EnvFrom)
+	}
+
+	t.Run("pod", func(t *testing.T) {
+		obj, err := stripPod(&corev1.Pod{
+			ObjectMeta: meta,
+			Spec:       podSpec,
+			Status:     corev1.PodStatus{Phase: corev1.PodRunning, Message: "running"},
+		})
+		require.NoError(t, err)
+
+		pod := obj.(*corev1.Pod)
+		assertStripped(t, pod.ObjectMeta, pod.Spec)
+		assert.Equal(t, corev1.PodStatus{Phase: corev1.PodRunning}, pod.Status)
+	})
+
+	t.Run("replica set", func(t *testing.T) {
+		obj, err := stripReplicaSet(&appsv1.ReplicaSet{
+			ObjectMeta: meta,
+			Spec: appsv1.ReplicaSetSpec{
+				Replicas: ptr.To[int32](2),
+				Template: corev1.PodTemplateSpec{
+					ObjectMeta: metav1.ObjectMeta{Labels: meta.Labels, Annotations: meta.Annotations},
+					Spec:       podSpec,
+				},
+			},
+			Status: appsv1.ReplicaSetStatus{Replicas: 2},
+		})
+		require.NoError(t, err)
+
+		rs := obj.(*appsv1.ReplicaSet)
+		assertStripped(t, rs.ObjectMeta, rs.Spec.Template.Spec)
+		assert.Equal(t, meta.Labels, rs.Spec.Template.Labels)
+		assert.Empty(t, rs.Spec.Template.Annotations)
+		assert.Equal(t, ptr.To[int32](2), rs.Spec.Replicas)
+		assert.Equal(t, int32(2), rs.Status.Replicas)
+	})
+
+	t.Run("other objects are unchanged", func(t *testing.T) {
+		secret := &corev1.Secret{ObjectMeta: meta}
+		obj, err := stripPod(secret)
+		require.NoError(t, err)
+		assert.Same(t, secret, obj)
+	})
+}
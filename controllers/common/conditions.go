@@ -12,14 +12,19 @@ const (
 	ConditionTypeError                         ConditionType = "Error"
 	ConditionTypeInvalidConsumedScopes         ConditionType = "InvalidConsumedScopes"
 	ConditionTypeInvalidExposedScopesConsumers ConditionType = "InvalidExposedScopesConsumers"
+	ConditionTypePlannedChanges                ConditionType = "PlannedChanges"
+	ConditionTypeDigDirUnavailable             ConditionType = "DigDirUnavailable"
+	ConditionTypeScopeConflict                 ConditionType = "ScopeConflict"
 )
 
 type ConditionReason string
 
 const (
+	ConditionReasonDryRun       ConditionReason = "DryRun"
 	ConditionReasonFailed       ConditionReason = "Failed"
 	ConditionReasonProcessing   ConditionReason = "Processing"
 	ConditionReasonSynchronized ConditionReason = "Synchronized"
+	ConditionReasonUnavailable  ConditionReason = "Unavailable"
 	ConditionReasonValidated    ConditionReason = "Validated"
 )
 
@@ -63,6 +68,36 @@ func InvalidExposedScopesConsumersCondition(status metav1.ConditionStatus, reaso
 	}
 }
 
+func PlannedChangesCondition(status metav1.ConditionStatus, reason ConditionReason, message string, generation int64) metav1.Condition {
+	return metav1.Condition{
+		Type:               string(ConditionTypePlannedChanges),
+		Status:             status,
+		Reason:             string(reason),
+		Message:            message,
+		ObservedGeneration: generation,
+	}
+}
+
+func DigDirUnavailableCondition(status metav1.ConditionStatus, reason ConditionReason, message string, generation int64) metav1.Condition {
+	return metav1.Condition{
+		Type:               string(ConditionTypeDigDirUnavailable),
+		Status:             status,
+		Reason:             string(reason),
+		Message:            message,
+		ObservedGeneration: generation,
+	}
+}
+
+func ScopeConflictCondition(status metav1.ConditionStatus, reason ConditionReason, message string, generation int64) metav1.Condition {
+	return metav1.Condition{
+		Type:               string(ConditionTypeScopeConflict),
+		Status:             status,
+		Reason:             string(reason),
+		Message:            message,
+		ObservedGeneration: generation,
+	}
+}
+
 func HasRetryableStatusCondition(conditions *[]metav1.Condition) bool {
 	if conditions == nil {
 		return false
@@ -70,8 +105,10 @@ func HasRetryableStatusCondition(conditions *[]metav1.Condition) bool {
 
 	isError := IsStatusConditionTrue(conditions, ConditionTypeError)
 	isInvalidConsumedScopes := IsStatusConditionTrue(conditions, ConditionTypeInvalidConsumedScopes)
+	isDigDirUnavailable := IsStatusConditionTrue(conditions, ConditionTypeDigDirUnavailable)
+	isScopeConflict := IsStatusConditionTrue(conditions, ConditionTypeScopeConflict)
 
-	return isError || isInvalidConsumedScopes
+	return isError || isInvalidConsumedScopes || isDigDirUnavailable || isScopeConflict
 }
 
 func IsStatusConditionTrue(conditions *[]metav1.Condition, conditionType ConditionType) bool {
@@ -81,3 +118,12 @@ func IsStatusConditionTrue(conditions *[]metav1.Condition, conditionType Conditi
 
 	return meta.IsStatusConditionTrue(*conditions, string(conditionType))
 }
+
+// HasPlannedChangesCondition returns true if the resource was last processed in dry-run mode.
+func HasPlannedChangesCondition(conditions *[]metav1.Condition) bool {
+	if conditions == nil {
+		return false
+	}
+
+	return meta.FindStatusCondition(*conditions, string(ConditionTypePlannedChanges)) != nil
+}
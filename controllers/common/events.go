@@ -7,9 +7,19 @@ const (
 	EventCreatedInDigDir            = "CreatedInDigDir"
 	EventUpdatedInDigDir            = "UpdatedInDigDir"
 	EventRotatedInDigDir            = "RotatedInDigDir"
+	EventRestoredSecret             = "RestoredSecret"
+	EventRevokedKeysInDigDir        = "RevokedKeysInDigDir"
+	EventDeletedSecret              = "DeletedSecret"
 	EventActivatedScopeInDigDir     = "ActivatedScopeInDigDir"
 	EventDeactivatedScopeInDigDir   = "DeactivatedScopeInDigDir"
 	EventCreatedScopeInDigDir       = "CreatedScopeInDigDir"
 	EventUpdatedScopeInDigDir       = "UpdatedScopeInDigDir"
 	EventUpdatedACLForScopeInDigDir = "UpdatedACLForScopeInDigDir"
+	EventOrphanedInDigDir           = "OrphanedInDigDir"
+	EventDeletedOrphanInDigDir      = "DeletedOrphanInDigDir"
+	EventOrphanedScopeInDigDir      = "OrphanedScopeInDigDir"
+	EventPlannedInDigDir            = "PlannedInDigDir"
+	EventAdoptedScopeInDigDir       = "AdoptedScopeInDigDir"
+	EventScopeConflict              = "ScopeConflict"
+	EventScopeManagedElsewhere      = "ScopeManagedElsewhere"
 )
@@ -3,8 +3,10 @@ package common
 import (
 	"fmt"
 
+	digdirv1alpha1 "github.com/nais/digdirator/api/v1alpha1"
 	"github.com/nais/digdirator/pkg/metrics"
 	naisiov1 "github.com/nais/liberator/pkg/apis/nais.io/v1"
+	"github.com/nais/liberator/pkg/kubernetes"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
@@ -16,13 +18,20 @@ const (
 	PreserveAnnotation string = "digdir.nais.io/preserve"
 )
 
-func (r *Reconciler) finalize(tx *Transaction) (ctrl.Result, error) {
+func (r *Reconciler) finalize(tx *Transaction) (_ ctrl.Result, err error) {
+	if instance, ok := tx.Instance.(*digdirv1alpha1.MaskinportenScope); ok {
+		return r.finalizeScope(tx, instance)
+	}
+
+	defer tx.span("Reconciler.finalize")(&err)
+
 	if !controllerutil.ContainsFinalizer(tx.Instance, FinalizerName) && !controllerutil.ContainsFinalizer(tx.Instance, OldFinalizerName) {
 		return ctrl.Result{}, nil
 	}
 
 	log := ctrl.LoggerFrom(tx.Ctx).WithValues("subsystem", "finalizer")
-	exists, err := r.DigDirClient.Exists(tx.Ctx, tx.Instance, r.Config.ClusterName)
+	original := tx.Instance.GetStatus().DeepCopy()
+	exists, err := tx.DigDirClient.Exists(tx.Ctx, tx.Instance, r.Config.ClusterName)
 	if err != nil {
 		return ctrl.Result{}, fmt.Errorf("finalizer: checking client existence: %w", err)
 	}
@@ -33,8 +42,14 @@ func (r *Reconciler) finalize(tx *Transaction) (ctrl.Result, error) {
 		log.Info("client does not exist in DigDir, skipping external deletion...")
 	case shouldPreserve(tx.Instance):
 		log.Info("preserve annotation set, skipping external deletion...")
+		if tx.DryRun() {
+			break
+		}
+		if err := r.preserve(tx); err != nil {
+			return ctrl.Result{}, fmt.Errorf("finalizer: recording preserved client: %w", err)
+		}
 	default:
-		if err := r.DigDirClient.Delete(tx.Ctx, tx.Instance.GetStatus().ClientID); err != nil {
+		if err := tx.DigDirClient.Delete(tx.Ctx, tx.Instance.GetStatus().ClientID); err != nil {
 			return ctrl.Result{}, fmt.Errorf("deleting client: %w", err)
 		}
 		log.Info("deleted client from DigDir")
@@ -46,6 +61,11 @@ func (r *Reconciler) finalize(tx *Transaction) (ctrl.Result, error) {
 		}
 	}
 
+	// the resource is kept until dry-run mode is disabled, as deleting it would leave the client in DigDir without a trace
+	if tx.DryRun() {
+		return ctrl.Result{RequeueAfter: DryRunRequeueInterval}, r.reportPlan(tx, *original)
+	}
+
 	controllerutil.RemoveFinalizer(tx.Instance, FinalizerName)
 	controllerutil.RemoveFinalizer(tx.Instance, OldFinalizerName)
 	err = r.Client.Update(tx.Ctx, tx.Instance)
@@ -53,10 +73,22 @@ func (r *Reconciler) finalize(tx *Transaction) (ctrl.Result, error) {
 		return ctrl.Result{}, fmt.Errorf("removing finalizer: %w", err)
 	}
 
-	metrics.IncClientsDeleted(tx.Instance)
+	tx.observe(metrics.IncClientsDeleted)
 	return ctrl.Result{}, nil
 }
 
+// preserve records the client so that it isn't treated as an orphan after the resource is gone.
+// The client is usually recorded already when the annotation is added, see syncPreserved.
+func (r *Reconciler) preserve(tx *Transaction) error {
+	preserved := r.PreservedClients()
+	if !preserved.Enabled() {
+		return nil
+	}
+
+	resourceName := kubernetes.UniformResourceName(tx.Instance, r.Config.ClusterName)
+	return preserved.Add(tx.Ctx, tx.Instance.GetStatus().ClientID, resourceName)
+}
+
 func markedForDeletion(o client.Object) bool {
 	return !o.GetDeletionTimestamp().IsZero()
 }
@@ -0,0 +1,273 @@
+package common
+
+import (
+	"fmt"
+
+	naisiov1 "github.com/nais/liberator/pkg/apis/nais.io/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	digdirv1alpha1 "github.com/nais/digdirator/api/v1alpha1"
+	"github.com/nais/digdirator/pkg/clients"
+	"github.com/nais/digdirator/pkg/digdir"
+	"github.com/nais/digdirator/pkg/digdir/scopes"
+	"github.com/nais/digdirator/pkg/digdir/types"
+	"github.com/nais/digdirator/pkg/tracing"
+)
+
+// processScope creates or updates the scope of a MaskinportenScope in DigDir.
+// Scopes that were registered for a MaskinportenClient in the same namespace are adopted, which lets teams move exposed
+// scopes out of their clients without recreating them. Scopes owned by anything else are left untouched.
+func (r *Reconciler) processScope(tx *Transaction, instance *digdirv1alpha1.MaskinportenScope) (err error) {
+	exposedScope := instance.ExposedScope()
+	name := fmt.Sprintf("%s:%s", r.Config.DigDir.Maskinporten.Default.ScopePrefix, scopes.Subscope(exposedScope))
+	defer tx.span("Reconciler.processScope", tracing.AttributeScope.String(name))(&err)
+
+	if err := r.requireDefaultIdentity(tx); err != nil {
+		return err
+	}
+
+	original := instance.Status.DeepCopy()
+	generation := instance.GetGeneration()
+
+	if !tx.DryRun() {
+		instance.Status.SetCondition(
+			ReadyCondition(
+				metav1.ConditionFalse,
+				ConditionReasonProcessing,
+				"Started processing resource",
+				generation,
+			),
+		)
+		if err := r.Client.Status().Update(tx.Ctx, instance); err != nil {
+			return fmt.Errorf("updating status: %w", err)
+		}
+	}
+
+	registration, err := r.getScope(tx, exposedScope)
+	if err != nil {
+		return err
+	}
+
+	var owner client.ObjectKey
+	if registration != nil {
+		var conflict string
+		owner, conflict, err = r.scopeOwnership(tx, instance, *registration)
+		if err != nil {
+			return err
+		}
+		if conflict != "" {
+			return r.observeScopeConflict(tx, instance, *original, conflict)
+		}
+	}
+
+	acls, err := r.scopes(tx).Process([]naisiov1.ExposedScope{exposedScope})
+	if err != nil {
+		return fmt.Errorf("processing scope: %w", err)
+	}
+
+	// disabled scopes are only deactivated, so the owner is unchanged until the scope is enabled
+	if exposedScope.Enabled && owner != (client.ObjectKey{}) && owner != client.ObjectKeyFromObject(instance) {
+		msg := fmt.Sprintf("Adopted scope %q from %q", name, owner)
+		ctrl.LoggerFrom(tx.Ctx).Info(msg)
+		r.reportEvent(tx, corev1.EventTypeNormal, EventAdoptedScopeInDigDir, msg)
+	}
+
+	if tx.DryRun() {
+		return r.reportPlan(tx, original.DigdiratorStatus)
+	}
+
+	// object is overwritten with response from apiserver after Update, so status is unset
+	// preserve copy for update of status subresource later on
+	status := instance.Status.DeepCopy()
+	status.Scope = name
+	status.Consumers = nil
+	for _, acl := range acls {
+		for _, consumer := range acl.Consumers {
+			status.Consumers = append(status.Consumers, digdirv1alpha1.ConsumerStatus{Orgno: consumer.Orgno, State: string(consumer.State)})
+		}
+	}
+
+	if _, hasResync := instance.GetAnnotations()[clients.AnnotationResynchronize]; hasResync {
+		delete(instance.GetAnnotations(), clients.AnnotationResynchronize)
+		if err := r.Client.Update(tx.Ctx, instance); err != nil {
+			return fmt.Errorf("updating object: %w", err)
+		}
+	}
+
+	hash, err := instance.Hash()
+	if err != nil {
+		return err
+	}
+	status.CorrelationID = string(controller.ReconcileIDFromContext(tx.Ctx))
+	status.ObservedGeneration = new(generation)
+	status.SynchronizationHash = hash
+	status.SetStateSynchronized()
+	if status.Conditions != nil {
+		meta.RemoveStatusCondition(status.Conditions, string(ConditionTypePlannedChanges))
+		meta.RemoveStatusCondition(status.Conditions, string(ConditionTypeDigDirUnavailable))
+		meta.RemoveStatusCondition(status.Conditions, string(ConditionTypeScopeConflict))
+	}
+	status.SetCondition(
+		ReadyCondition(
+			metav1.ConditionTrue,
+			ConditionReasonSynchronized,
+			"Resource is up-to-date with DigDir",
+			generation,
+		),
+	)
+	status.SetCondition(
+		ErrorCondition(
+			metav1.ConditionFalse,
+			ConditionReasonSynchronized,
+			"Processing completed without errors",
+			generation,
+		),
+	)
+
+	r.reportEvent(tx, corev1.EventTypeNormal, EventSynchronized, "Resource is up-to-date")
+
+	instance.Status = *status
+	if err := r.Client.Status().Update(tx.Ctx, instance); err != nil {
+		return fmt.Errorf("updating status: %w", err)
+	}
+	return nil
+}
+
+// finalizeScope deactivates the scope of a MaskinportenScope that is being deleted, unless it is preserved or owned by another resource.
+func (r *Reconciler) finalizeScope(tx *Transaction, instance *digdirv1alpha1.MaskinportenScope) (_ ctrl.Result, err error) {
+	defer tx.span("Reconciler.finalizeScope")(&err)
+
+	if !controllerutil.ContainsFinalizer(instance, FinalizerName) {
+		return ctrl.Result{}, nil
+	}
+
+	log := ctrl.LoggerFrom(tx.Ctx).WithValues("subsystem", "finalizer")
+	original := instance.Status.DigdiratorStatus.DeepCopy()
+	exposedScope := instance.ExposedScope()
+
+	registration, err := r.getScope(tx, exposedScope)
+	if err != nil {
+		return ctrl.Result{}, fmt.Errorf("finalizer: %w", err)
+	}
+
+	var owner client.ObjectKey
+	var owned bool
+	if registration != nil {
+		owner, owned = clients.ScopeOwnerKey(*registration, r.Config.ClusterName)
+	}
+
+	switch {
+	case registration == nil || !registration.Active:
+		log.Info("scope does not exist or is inactive in DigDir, skipping external deletion...")
+	case !owned || owner != client.ObjectKeyFromObject(instance):
+		log.Info("scope is owned by another resource, skipping external deletion...")
+	case shouldPreserve(instance):
+		log.Info("preserve annotation set, skipping external deletion...")
+	default:
+		if err := r.scopes(tx).deactivate(scopes.CurrentScopeInfo(*registration, exposedScope)); err != nil {
+			return ctrl.Result{}, fmt.Errorf("finalizer: %w", err)
+		}
+	}
+
+	// the resource is kept until dry-run mode is disabled, as deleting it would leave the scope in DigDir without a trace
+	if tx.DryRun() {
+		return ctrl.Result{RequeueAfter: DryRunRequeueInterval}, r.reportPlan(tx, *original)
+	}
+
+	controllerutil.RemoveFinalizer(instance, FinalizerName)
+	if err := r.Client.Update(tx.Ctx, instance); err != nil {
+		return ctrl.Result{}, fmt.Errorf("removing finalizer: %w", err)
+	}
+	return ctrl.Result{}, nil
+}
+
+// getScope returns the registration of the given scope in DigDir, including inactive ones, or nil if it is not registered.
+func (r *Reconciler) getScope(tx *Transaction, exposedScope naisiov1.ExposedScope) (*types.ScopeRegistration, error) {
+	prefix := r.Config.DigDir.Maskinporten.Default.ScopePrefix
+	subscope := scopes.Subscope(exposedScope)
+
+	registrations, err := r.DigDirClient.ListScopes(tx.Ctx, digdir.ScopeFilter{IncludeInactive: true, Subscopes: []string{subscope}})
+	if err != nil {
+		return nil, fmt.Errorf("getting scopes: %w", err)
+	}
+
+	for _, registration := range registrations {
+		if registration.Prefix == prefix && registration.Subscope == subscope {
+			return &registration, nil
+		}
+	}
+	return nil, nil
+}
+
+// scopeOwnership returns the resource that the existing scope was registered for, and a message describing why the
+// MaskinportenScope cannot manage the scope, if any.
+func (r *Reconciler) scopeOwnership(tx *Transaction, instance *digdirv1alpha1.MaskinportenScope, registration types.ScopeRegistration) (client.ObjectKey, string, error) {
+	name := fmt.Sprintf("%s:%s", registration.Prefix, registration.Subscope)
+
+	owner, ok := clients.ScopeOwnerKey(registration, r.Config.ClusterName)
+	switch {
+	case !ok:
+		return owner, fmt.Sprintf("Scope %q was not registered by digdirator in this cluster", name), nil
+	case owner == client.ObjectKeyFromObject(instance):
+		return owner, "", nil
+	case owner.Namespace != instance.GetNamespace():
+		return owner, fmt.Sprintf("Scope %q is owned by %q in another namespace", name, owner), nil
+	}
+
+	// a MaskinportenScope with the owner's name has priority, unless it manages another scope
+	other := &digdirv1alpha1.MaskinportenScope{}
+	err := r.Reader.Get(tx.Ctx, owner, other)
+	if apierrors.IsNotFound(err) {
+		return owner, "", nil
+	}
+	if err != nil {
+		return owner, "", fmt.Errorf("getting MaskinportenScope %q: %w", owner, err)
+	}
+	if scopes.Subscope(other.ExposedScope()) == registration.Subscope {
+		return owner, fmt.Sprintf("Scope %q is owned by MaskinportenScope %q", name, owner.Name), nil
+	}
+	return owner, "", nil
+}
+
+// observeScopeConflict marks the MaskinportenScope as conflicting with the owner of the existing scope. No changes are made in DigDir.
+func (r *Reconciler) observeScopeConflict(tx *Transaction, instance *digdirv1alpha1.MaskinportenScope, original digdirv1alpha1.MaskinportenScopeStatus, message string) error {
+	ctrl.LoggerFrom(tx.Ctx).Info(message)
+	if tx.DryRun() {
+		return r.reportPlan(tx, original.DigdiratorStatus)
+	}
+
+	generation := instance.GetGeneration()
+	r.Recorder.Eventf(instance, nil, corev1.EventTypeWarning, EventScopeConflict, EventScopeConflict, "%s", message)
+	instance.Status.SetCondition(ScopeConflictCondition(metav1.ConditionTrue, ConditionReasonFailed, message, generation))
+	instance.Status.SetCondition(ReadyCondition(metav1.ConditionFalse, ConditionReasonFailed, message, generation))
+	if err := r.Client.Status().Update(tx.Ctx, instance); err != nil {
+		return fmt.Errorf("updating status: %w", err)
+	}
+	return nil
+}
+
+// claimedScopes returns the subscopes in the namespace that are managed by a MaskinportenScope, which take precedence
+// over scopes exposed by MaskinportenClients.
+func (r *Reconciler) claimedScopes(tx *Transaction) (map[string]string, error) {
+	if !r.Config.Features.MaskinportenScopes {
+		return nil, nil
+	}
+
+	list := &digdirv1alpha1.MaskinportenScopeList{}
+	if err := r.Client.List(tx.Ctx, list, client.InNamespace(tx.Instance.GetNamespace())); err != nil {
+		return nil, fmt.Errorf("listing MaskinportenScopes: %w", err)
+	}
+
+	claimed := make(map[string]string, len(list.Items))
+	for _, item := range list.Items {
+		claimed[scopes.Subscope(item.ExposedScope())] = item.GetName()
+	}
+	return claimed, nil
+}
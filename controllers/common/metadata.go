@@ -0,0 +1,91 @@
+package common
+
+import (
+	"fmt"
+	"maps"
+
+	nais_io_v1 "github.com/nais/liberator/pkg/apis/nais.io/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/retry"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	digdirv1alpha1 "github.com/nais/digdirator/api/v1alpha1"
+	"github.com/nais/digdirator/pkg/clients"
+	"github.com/nais/digdirator/pkg/config"
+	"github.com/nais/digdirator/pkg/secrets"
+)
+
+// CreateOrUpdateMetadata writes the client's public metadata to an owned ConfigMap, which is garbage collected along with the client.
+// The ConfigMap is read with the uncached reader, so that the manager does not need to watch all ConfigMaps in the cluster.
+func (r *Reconciler) CreateOrUpdateMetadata(tx *Transaction) (err error) {
+	defer tx.span("metadata.CreateOrUpdate")(&err)
+
+	name := clients.GetMetadataConfigMapName(tx.Instance)
+	log := ctrl.LoggerFrom(tx.Ctx).WithValues("subsystem", "metadata")
+	log.V(4).Info(fmt.Sprintf("processing configmap %q...", name))
+
+	data, err := metadata(tx.Instance, r.Config)
+	if err != nil {
+		return fmt.Errorf("creating metadata: %w", err)
+	}
+
+	key := client.ObjectKey{Namespace: tx.Instance.GetNamespace(), Name: name}
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		existing := &corev1.ConfigMap{}
+		err := r.Reader.Get(tx.Ctx, key, existing)
+		if errors.IsNotFound(err) {
+			target := &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      key.Name,
+					Namespace: key.Namespace,
+					Labels:    clients.MakeLabels(tx.Instance),
+				},
+				Data: data,
+			}
+			if err := ctrl.SetControllerReference(tx.Instance, target, r.Scheme); err != nil {
+				return err
+			}
+			if err := r.Client.Create(tx.Ctx, target); err != nil {
+				return fmt.Errorf("creating configmap %q: %w", name, err)
+			}
+			log.Info(fmt.Sprintf("configmap %q created", name))
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("getting configmap %q: %w", name, err)
+		}
+
+		target := existing.DeepCopy()
+		target.SetLabels(clients.MakeLabels(tx.Instance))
+		target.Data = data
+		if err := ctrl.SetControllerReference(tx.Instance, target, r.Scheme); err != nil {
+			return err
+		}
+
+		if maps.Equal(existing.Data, target.Data) && maps.Equal(existing.Labels, target.Labels) && len(existing.OwnerReferences) == len(target.OwnerReferences) {
+			log.V(4).Info(fmt.Sprintf("configmap %q unchanged", name))
+			return nil
+		}
+
+		if err := r.Client.Update(tx.Ctx, target); err != nil {
+			return fmt.Errorf("updating configmap %q: %w", name, err)
+		}
+		log.Info(fmt.Sprintf("configmap %q updated", name))
+		return nil
+	})
+}
+
+func metadata(instance clients.Instance, config *config.Config) (map[string]string, error) {
+	switch v := instance.(type) {
+	case *nais_io_v1.IDPortenClient:
+		return secrets.IDPortenClientMetadata(v, config)
+	case *nais_io_v1.MaskinportenClient:
+		return secrets.MaskinportenClientMetadata(v, config)
+	case *digdirv1alpha1.AnsattportenClient:
+		return secrets.AnsattportenClientMetadata(v, config)
+	}
+	return nil, fmt.Errorf("unsupported client type %T", instance)
+}
@@ -0,0 +1,86 @@
+package common
+
+import (
+	"encoding/json"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/nais/digdirator/pkg/clients"
+	"github.com/nais/digdirator/pkg/digdir"
+	"github.com/nais/digdirator/pkg/metrics"
+)
+
+// Plans holds the latest dry-run plan for each resource with pending changes.
+// It serves the plans as JSON, e.g. on the metrics server's debug endpoint.
+type Plans struct {
+	mu        sync.RWMutex
+	resources map[string]ResourcePlan
+}
+
+type ResourcePlan struct {
+	Kind       string             `json:"kind"`
+	Namespace  string             `json:"namespace"`
+	Name       string             `json:"name"`
+	PlannedAt  time.Time          `json:"plannedAt"`
+	Operations []digdir.Operation `json:"operations"`
+}
+
+func NewPlans() *Plans {
+	return &Plans{
+		resources: make(map[string]ResourcePlan),
+	}
+}
+
+// Set replaces the plan for the given resource. Resources without any planned operations are removed.
+func (p *Plans) Set(instance clients.Instance, operations []digdir.Operation) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	kind := clients.GetKind(instance)
+	key := kind + "/" + client.ObjectKeyFromObject(instance).String()
+	if len(operations) == 0 {
+		delete(p.resources, key)
+	} else {
+		p.resources[key] = ResourcePlan{
+			Kind:       kind,
+			Namespace:  instance.GetNamespace(),
+			Name:       instance.GetName(),
+			PlannedAt:  time.Now(),
+			Operations: operations,
+		}
+	}
+
+	counts := make(map[string]int)
+	for _, plan := range p.resources {
+		for _, op := range plan.Operations {
+			counts[op.Name]++
+		}
+	}
+	metrics.SetPlannedOperations(counts)
+}
+
+// List returns all plans, sorted by kind, namespace and name.
+func (p *Plans) List() []ResourcePlan {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	result := make([]ResourcePlan, 0, len(p.resources))
+	for _, plan := range p.resources {
+		result = append(result, plan)
+	}
+
+	slices.SortFunc(result, func(a, b ResourcePlan) int {
+		return strings.Compare(a.Kind+"/"+a.Namespace+"/"+a.Name, b.Kind+"/"+b.Namespace+"/"+b.Name)
+	})
+	return result
+}
+
+func (p *Plans) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(p.List())
+}
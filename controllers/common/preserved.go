@@ -0,0 +1,137 @@
+package common
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/nais/liberator/pkg/kubernetes"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// +kubebuilder:rbac:groups=*,resources=configmaps,verbs=get;create;update
+
+const (
+	PreservedClientsConfigMapName = "digdirator-preserved-clients"
+)
+
+// PreservedClients tracks clients that are kept in DigDir due to the preserve annotation when their resource is deleted.
+// The clients are stored in a ConfigMap, keyed by client ID with the resource's uniform resource name as the value.
+type PreservedClients struct {
+	Client    client.Client
+	Reader    client.Reader
+	Namespace string
+}
+
+func (r *Reconciler) PreservedClients() PreservedClients {
+	return PreservedClients{
+		Client:    r.Client,
+		Reader:    r.Reader,
+		Namespace: r.Config.GarbageCollector.Namespace,
+	}
+}
+
+func (p PreservedClients) Enabled() bool {
+	return p.Namespace != ""
+}
+
+// List returns the preserved clients, keyed by client ID.
+func (p PreservedClients) List(ctx context.Context) (map[string]string, error) {
+	cm, err := p.get(ctx)
+	if errors.IsNotFound(err) {
+		return map[string]string{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if cm.Data == nil {
+		return map[string]string{}, nil
+	}
+	return cm.Data, nil
+}
+
+func (p PreservedClients) Add(ctx context.Context, clientID, resourceName string) error {
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		cm, err := p.get(ctx)
+		if errors.IsNotFound(err) {
+			cm := &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      PreservedClientsConfigMapName,
+					Namespace: p.Namespace,
+				},
+				Data: map[string]string{clientID: resourceName},
+			}
+			return p.Client.Create(ctx, cm)
+		}
+		if err != nil {
+			return err
+		}
+
+		if cm.Data == nil {
+			cm.Data = make(map[string]string)
+		}
+		cm.Data[clientID] = resourceName
+		return p.Client.Update(ctx, cm)
+	})
+}
+
+func (p PreservedClients) Remove(ctx context.Context, clientIDs ...string) error {
+	if len(clientIDs) == 0 {
+		return nil
+	}
+
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		cm, err := p.get(ctx)
+		if errors.IsNotFound(err) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		for _, clientID := range clientIDs {
+			delete(cm.Data, clientID)
+		}
+		return p.Client.Update(ctx, cm)
+	})
+}
+
+// syncPreserved records or forgets the client when the preserve annotation is added or removed, so that the client is
+// kept even if the resource is gone without its finalizer running, e.g. when the namespace is force-deleted.
+func (r *Reconciler) syncPreserved(tx *Transaction) error {
+	preserved := r.PreservedClients()
+	clientID := tx.Instance.GetStatus().ClientID
+	if !preserved.Enabled() || clientID == "" || tx.DryRun() {
+		return nil
+	}
+
+	clientIDs, err := preserved.List(tx.Ctx)
+	if err != nil {
+		return err
+	}
+
+	resourceName, found := clientIDs[clientID]
+	switch {
+	case shouldPreserve(tx.Instance) && resourceName != kubernetes.UniformResourceName(tx.Instance, r.Config.ClusterName):
+		return r.preserve(tx)
+	case !shouldPreserve(tx.Instance) && found:
+		return preserved.Remove(tx.Ctx, clientID)
+	}
+	return nil
+}
+
+func (p PreservedClients) get(ctx context.Context) (*corev1.ConfigMap, error) {
+	cm := &corev1.ConfigMap{}
+	key := client.ObjectKey{Namespace: p.Namespace, Name: PreservedClientsConfigMapName}
+	if err := p.Reader.Get(ctx, key, cm); err != nil {
+		if errors.IsNotFound(err) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("getting configmap %q: %w", key, err)
+	}
+	return cm, nil
+}
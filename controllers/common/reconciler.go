@@ -4,13 +4,18 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"reflect"
+	"slices"
 	"strings"
 	"time"
 
 	"github.com/go-jose/go-jose/v4"
 	naisiov1 "github.com/nais/liberator/pkg/apis/nais.io/v1"
 	"github.com/nais/liberator/pkg/kubernetes"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.41.0"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/client-go/tools/events"
@@ -19,23 +24,39 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/controller"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 
+	digdirv1alpha1 "github.com/nais/digdirator/api/v1alpha1"
 	"github.com/nais/digdirator/pkg/clients"
 	"github.com/nais/digdirator/pkg/config"
 	"github.com/nais/digdirator/pkg/crypto"
 	"github.com/nais/digdirator/pkg/digdir"
 	"github.com/nais/digdirator/pkg/digdir/types"
 	"github.com/nais/digdirator/pkg/metrics"
+	"github.com/nais/digdirator/pkg/secrets"
+	"github.com/nais/digdirator/pkg/tracing"
+)
+
+const (
+	// DryRunRequeueInterval is how often resources are planned again in dry-run mode.
+	DryRunRequeueInterval = 1 * time.Hour
+	// minUnavailableRequeueInterval is the shortest delay before a resource is reconciled again while DigDir is unavailable.
+	minUnavailableRequeueInterval = 5 * time.Second
 )
 
 type Reconciler struct {
-	Client       client.Client
-	Reader       client.Reader
-	Scheme       *runtime.Scheme
-	Recorder     events.EventRecorder
-	Config       *config.Config
+	Client   client.Client
+	Reader   client.Reader
+	Scheme   *runtime.Scheme
+	Recorder events.EventRecorder
+	Config   *config.Config
+	// DigDirClient authenticates as the default admin identity.
 	DigDirClient digdir.Client
+	// Identities holds the clients for all admin identities keyed by identity name, including the default identity.
+	Identities map[string]digdir.Client
+	Plans      *Plans
 }
 
+// NewReconciler returns a reconciler that registers clients with the default admin identity, or with any of the given
+// additional identities.
 func NewReconciler(
 	client client.Client,
 	reader client.Reader,
@@ -43,7 +64,13 @@ func NewReconciler(
 	recorder events.EventRecorder,
 	config *config.Config,
 	digdirClient digdir.Client,
+	identities ...digdir.Client,
 ) Reconciler {
+	clientsByIdentity := map[string]digdir.Client{digdirClient.Identity: digdirClient}
+	for _, identity := range identities {
+		clientsByIdentity[identity.Identity] = identity
+	}
+
 	return Reconciler{
 		Client:       client,
 		Reader:       reader,
@@ -51,18 +78,32 @@ func NewReconciler(
 		Recorder:     recorder,
 		Config:       config,
 		DigDirClient: digdirClient,
+		Identities:   clientsByIdentity,
+		Plans:        NewPlans(),
 	}
 }
 
-func (r *Reconciler) Reconcile(ctx context.Context, req ctrl.Request, instance clients.Instance) (ctrl.Result, error) {
+func (r *Reconciler) Reconcile(ctx context.Context, req ctrl.Request, instance clients.Instance) (_ ctrl.Result, err error) {
+	ctx, span := tracing.Start(ctx, "Reconciler.Reconcile",
+		attribute.String("digdirator.kind", reflect.TypeOf(instance).Elem().Name()),
+		semconv.K8SNamespaceName(req.Namespace),
+		attribute.String("digdirator.name", req.Name),
+		tracing.AttributeCorrelationID.String(string(controller.ReconcileIDFromContext(ctx))),
+	)
+	defer tracing.End(span, &err)
+
 	tx, err := r.prepare(ctx, req, instance)
 	if err != nil {
 		return ctrl.Result{}, client.IgnoreNotFound(err)
 	}
+	span.SetAttributes(tracing.AttributeClientID.String(tx.Instance.GetStatus().ClientID))
 
 	log := ctrl.LoggerFrom(tx.Ctx)
 
 	if markedForDeletion(tx.Instance) {
+		if retryAfter, unavailable := r.DigDirClient.Unavailable(); unavailable {
+			return r.observeUnavailable(tx, retryAfter)
+		}
 		return r.finalize(tx)
 	}
 
@@ -73,13 +114,33 @@ func (r *Reconciler) Reconcile(ctx context.Context, req ctrl.Request, instance c
 		}
 	}
 
-	if clients.IsUpToDate(tx.Instance) && !HasRetryableStatusCondition(tx.Instance.GetStatus().Conditions) {
-		log.Info("resource is up-to-date; skipping reconciliation")
-		// requeue later to re-evaluate and prevent resource drift
-		return ctrl.Result{RequeueAfter: 8 * time.Hour}, nil
+	if err := r.syncPreserved(tx); err != nil {
+		return ctrl.Result{}, fmt.Errorf("synchronizing preserved client: %w", err)
+	}
+
+	conditions := tx.Instance.GetStatus().Conditions
+	if !tx.DryRun() && clients.IsUpToDate(tx.Instance) && !HasRetryableStatusCondition(conditions) && !HasPlannedChangesCondition(conditions) {
+		reason, requeueAfter, err := r.pendingSecretChanges(tx)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		if reason == "" {
+			log.Info("resource is up-to-date; skipping reconciliation")
+			// requeue later to re-evaluate and prevent resource drift
+			return ctrl.Result{RequeueAfter: requeueAfter}, nil
+		}
+		log.Info(fmt.Sprintf("resource is up-to-date, but %s; reconciling", reason))
+	}
+
+	if retryAfter, unavailable := r.DigDirClient.Unavailable(); unavailable {
+		return r.observeUnavailable(tx, retryAfter)
 	}
 
 	if err = r.process(tx); err != nil {
+		if retryAfter, unavailable := r.DigDirClient.Unavailable(); unavailable || errors.Is(err, digdir.ErrCircuitOpen) {
+			return r.observeUnavailable(tx, retryAfter)
+		}
+
 		if err := r.observeError(tx, err); err != nil {
 			return ctrl.Result{}, fmt.Errorf("observing error: %w", err)
 		}
@@ -87,7 +148,11 @@ func (r *Reconciler) Reconcile(ctx context.Context, req ctrl.Request, instance c
 		return ctrl.Result{RequeueAfter: time.Second}, nil
 	}
 
-	conditions := tx.Instance.GetStatus().Conditions
+	if tx.DryRun() {
+		return ctrl.Result{RequeueAfter: DryRunRequeueInterval}, nil
+	}
+
+	conditions = tx.Instance.GetStatus().Conditions
 	switch {
 	case IsStatusConditionTrue(conditions, ConditionTypeInvalidConsumedScopes):
 		requeueAfter := 1 * time.Hour
@@ -95,6 +160,11 @@ func (r *Reconciler) Reconcile(ctx context.Context, req ctrl.Request, instance c
 		metrics.IncClientsFailedInvalidConfig(tx.Instance)
 		return ctrl.Result{RequeueAfter: requeueAfter}, nil
 
+	case IsStatusConditionTrue(conditions, ConditionTypeScopeConflict):
+		requeueAfter := 1 * time.Hour
+		log.Info(fmt.Sprintf("resource conflicts with an existing scope; requeuing reconciliation after %s", requeueAfter))
+		return ctrl.Result{RequeueAfter: requeueAfter}, nil
+
 	case IsStatusConditionTrue(conditions, ConditionTypeInvalidExposedScopesConsumers):
 		log.Info("resource has invalid consumers in exposed scopes; will not requeue reconciliation")
 		return ctrl.Result{}, nil
@@ -107,6 +177,40 @@ func (r *Reconciler) Reconcile(ctx context.Context, req ctrl.Request, instance c
 	}
 }
 
+// pendingSecretChanges returns why the secrets of an up-to-date resource must be processed, if they have drifted or
+// previous secrets can be pruned. Otherwise, it returns when the resource should be reconciled again.
+func (r *Reconciler) pendingSecretChanges(tx *Transaction) (string, time.Duration, error) {
+	secretsClient := r.secrets(tx)
+
+	drift, err := secretsClient.Drift()
+	if err != nil {
+		return "", 0, fmt.Errorf("checking secret: %w", err)
+	}
+	if drift != "" {
+		return drift, 0, nil
+	}
+
+	// the secrets are listed from the cache, as this runs for every up-to-date resource and rollout event
+	pruning, err := secretsClient.Pruning(r.Client)
+	if err != nil {
+		return "", 0, fmt.Errorf("getting managed secrets: %w", err)
+	}
+	if unused := len(pruning.Unused); unused > 0 {
+		return fmt.Sprintf("%d previous secret(s) can be deleted", unused), 0, nil
+	}
+	// expired secrets are kept while they are used, so they are only pending until their keys are revoked
+	secretKey := clients.GetSecretJwkKey(tx.Instance)
+	if expired := revocableKeyIDs(pruning.Expired, keyIDs(pruning.Keep, secretKey), tx.Instance.GetStatus().KeyIDs, secretKey); len(expired) > 0 {
+		return fmt.Sprintf("%d expired key(s) can be revoked", len(expired)), 0, nil
+	}
+
+	requeueAfter := 8 * time.Hour
+	if !pruning.NextExpiry.IsZero() {
+		requeueAfter = max(min(requeueAfter, time.Until(pruning.NextExpiry)), time.Second)
+	}
+	return "", requeueAfter, nil
+}
+
 func (r *Reconciler) prepare(ctx context.Context, req ctrl.Request, instance clients.Instance) (*Transaction, error) {
 	if err := r.Reader.Get(ctx, req.NamespacedName, instance); err != nil {
 		return nil, err
@@ -121,21 +225,48 @@ func (r *Reconciler) prepare(ctx context.Context, req ctrl.Request, instance cli
 		"key_ids", strings.Join(status.KeyIDs, ", "),
 	).Info("starting reconciliation")
 
-	return NewTransaction(ctx, instance), nil
+	// an invalid identity is reported when the resource is processed, see process
+	digdirClient, _ := r.digdirClientFor(instance)
+
+	tx := NewTransaction(ctx, instance, digdirClient)
+	if r.Config.DryRun {
+		tx = tx.WithPlan(digdir.NewPlan())
+	}
+	return tx, nil
 }
 
-func (r *Reconciler) process(tx *Transaction) error {
+func (r *Reconciler) process(tx *Transaction) (err error) {
+	if instance, ok := tx.Instance.(*digdirv1alpha1.MaskinportenScope); ok {
+		return r.processScope(tx, instance)
+	}
+
+	defer tx.span("Reconciler.process")(&err)
+
+	if _, err := r.digdirClientFor(tx.Instance); err != nil {
+		return err
+	}
+
+	// the formats are resolved again when the secret is written, but must be valid before any changes are made in DigDir
+	if _, err := clients.GetSecretFormats(tx.Instance, r.Config); err != nil {
+		return fmt.Errorf("resolving secret formats: %w", err)
+	}
+
+	original := tx.Instance.GetStatus().DeepCopy()
+	originalACL := tx.Instance.GetAnnotations()[clients.AnnotationExposedScopesACL]
 	status := tx.Instance.GetStatus()
-	status.SetCondition(
-		ReadyCondition(
-			metav1.ConditionFalse,
-			ConditionReasonProcessing,
-			"Started processing resource",
-			tx.Instance.GetGeneration(),
-		),
-	)
-	if err := r.Client.Status().Update(tx.Ctx, tx.Instance); err != nil {
-		return fmt.Errorf("updating status: %w", err)
+
+	if !tx.DryRun() {
+		status.SetCondition(
+			ReadyCondition(
+				metav1.ConditionFalse,
+				ConditionReasonProcessing,
+				"Started processing resource",
+				tx.Instance.GetGeneration(),
+			),
+		)
+		if err := r.Client.Status().Update(tx.Ctx, tx.Instance); err != nil {
+			return fmt.Errorf("updating status: %w", err)
+		}
 	}
 
 	registration, err := r.createOrUpdateClient(tx)
@@ -146,17 +277,23 @@ func (r *Reconciler) process(tx *Transaction) error {
 	status.ClientID = registration.ClientID
 
 	secretsClient := r.secrets(tx)
-	managedSecrets, err := secretsClient.GetManaged()
+	// the secrets are listed without the cache, as keys are revoked and secrets are deleted based on them
+	pruning, err := secretsClient.Pruning(r.Reader)
 	if err != nil {
 		return fmt.Errorf("getting managed secrets: %w", err)
 	}
+	// expired secrets are considered unused, so that their keys are left out when keys are registered
+	managedSecrets := pruning.SecretLists()
 
-	var jwk *jose.JSONWebKey
+	jwk, rotate, err := r.restorableJwk(tx, secretsClient, managedSecrets)
+	if err != nil {
+		return err
+	}
 
-	if clients.NeedsSecretRotation(tx.Instance) {
-		jwk, err = crypto.GenerateJwk()
+	if rotate || clients.NeedsSecretRotation(tx.Instance) {
+		jwk, err = r.generateJwk(tx)
 		if err != nil {
-			return fmt.Errorf("generating jwk: %w", err)
+			return err
 		}
 
 		if err := r.registerJwk(tx, *jwk, managedSecrets, registration.ClientID); err != nil {
@@ -164,18 +301,22 @@ func (r *Reconciler) process(tx *Transaction) error {
 		}
 
 		r.reportEvent(tx, corev1.EventTypeNormal, EventRotatedInDigDir, "Client credentials is rotated")
-		metrics.IncClientsRotated(tx.Instance)
+		tx.observe(metrics.IncClientsRotated)
 	} else {
-		jwk, err = crypto.GetPreviousJwkFromSecret(managedSecrets, clients.GetSecretJwkKey(tx.Instance))
-		if err != nil {
-			if errors.Is(err, crypto.ErrNoPreviousJwkFound) {
-				ctrl.LoggerFrom(tx.Ctx).V(0).Info("no previous JWK found in secrets, generating one...")
-				jwk, err = crypto.GenerateJwk()
-				if err != nil {
-					return fmt.Errorf("generating new JWK: %w", err)
+		if jwk != nil {
+			r.reportEvent(tx, corev1.EventTypeNormal, EventRestoredSecret, fmt.Sprintf("Secret is restored with registered key %q", jwk.KeyID))
+		} else {
+			jwk, err = crypto.GetPreviousJwkFromSecret(managedSecrets, clients.GetSecretJwkKey(tx.Instance))
+			if err != nil {
+				if errors.Is(err, crypto.ErrNoPreviousJwkFound) {
+					ctrl.LoggerFrom(tx.Ctx).V(0).Info("no previous JWK found in secrets, generating one...")
+					jwk, err = r.generateJwk(tx)
+					if err != nil {
+						return err
+					}
+				} else {
+					return err
 				}
-			} else {
-				return err
 			}
 		}
 
@@ -184,14 +325,27 @@ func (r *Reconciler) process(tx *Transaction) error {
 		}
 	}
 
+	if err := r.revokeKeys(tx, *jwk, pruning, registration.ClientID); err != nil {
+		return err
+	}
+
+	// secrets must not be changed without the corresponding changes in DigDir
+	if tx.DryRun() {
+		return r.reportPlan(tx, *original)
+	}
+
 	if err := secretsClient.CreateOrUpdate(*jwk); err != nil {
 		return fmt.Errorf("creating or updating secret: %w", err)
 	}
 
-	if err := secretsClient.DeleteUnused(managedSecrets.Unused); err != nil {
+	if err := secretsClient.DeleteUnused(pruning); err != nil {
 		return err
 	}
 
+	if err := r.CreateOrUpdateMetadata(tx); err != nil {
+		return fmt.Errorf("creating or updating metadata: %w", err)
+	}
+
 	// object is overwritten with response from apiserver after Update, so status is unset
 	// preserve copy for update of status subresource later on
 	status = tx.Instance.GetStatus().DeepCopy()
@@ -200,8 +354,9 @@ func (r *Reconciler) process(tx *Transaction) error {
 	a := tx.Instance.GetAnnotations()
 	_, hasResync := a[clients.AnnotationResynchronize]
 	_, hasRotate := a[clients.AnnotationRotate]
+	aclChanged := a[clients.AnnotationExposedScopesACL] != originalACL
 
-	if hasResync || hasRotate {
+	if hasResync || hasRotate || aclChanged {
 		delete(a, clients.AnnotationResynchronize)
 		delete(a, clients.AnnotationRotate)
 
@@ -221,6 +376,10 @@ func (r *Reconciler) process(tx *Transaction) error {
 	status.SynchronizationHash = hash
 	status.SynchronizationSecretName = clients.GetSecretName(tx.Instance)
 	status.SetStateSynchronized()
+	if status.Conditions != nil {
+		meta.RemoveStatusCondition(status.Conditions, string(ConditionTypePlannedChanges))
+		meta.RemoveStatusCondition(status.Conditions, string(ConditionTypeDigDirUnavailable))
+	}
 	status.SetCondition(
 		ReadyCondition(
 			metav1.ConditionTrue,
@@ -275,8 +434,33 @@ func (r *Reconciler) observeError(tx *Transaction, reconcileErr error) error {
 	return r.Client.Status().Update(tx.Ctx, tx.Instance)
 }
 
+// observeUnavailable marks the resource as waiting for DigDir to become available, and delays the next reconciliation until
+// the circuit breaker lets requests through again. This avoids a storm of failing requests and events during an outage.
+func (r *Reconciler) observeUnavailable(tx *Transaction, retryAfter time.Duration) (ctrl.Result, error) {
+	retryAfter = max(retryAfter, minUnavailableRequeueInterval)
+	ctrl.LoggerFrom(tx.Ctx).Info(fmt.Sprintf("DigDir is unavailable; requeuing reconciliation after %s", retryAfter))
+
+	conditions := tx.Instance.GetStatus().Conditions
+	if IsStatusConditionTrue(conditions, ConditionTypeDigDirUnavailable) {
+		return ctrl.Result{RequeueAfter: retryAfter}, nil
+	}
+
+	tx.Instance.GetStatus().SetCondition(
+		DigDirUnavailableCondition(
+			metav1.ConditionTrue,
+			ConditionReasonUnavailable,
+			"DigDir is unavailable; requests are suspended until it recovers",
+			tx.Instance.GetGeneration(),
+		),
+	)
+	if err := r.Client.Status().Update(tx.Ctx, tx.Instance); err != nil {
+		return ctrl.Result{}, fmt.Errorf("updating status: %w", err)
+	}
+	return ctrl.Result{RequeueAfter: retryAfter}, nil
+}
+
 func (r *Reconciler) createOrUpdateClient(tx *Transaction) (*types.ClientRegistration, error) {
-	registration, err := r.DigDirClient.GetRegistration(tx.Instance, tx.Ctx, r.Config.ClusterName)
+	registration, err := tx.DigDirClient.GetRegistration(tx.Instance, tx.Ctx, r.Config.ClusterName)
 	if err != nil {
 		return nil, fmt.Errorf("getting client registration: %w", err)
 	}
@@ -285,13 +469,30 @@ func (r *Reconciler) createOrUpdateClient(tx *Transaction) (*types.ClientRegistr
 
 	switch instance := tx.Instance.(type) {
 	case *naisiov1.MaskinportenClient:
+		if _, err := clients.GetExposedScopesDescriptions(instance); err != nil {
+			return nil, err
+		}
+
+		if len(instance.Spec.Scopes.ExposedScopes) > 0 {
+			if err := r.requireDefaultIdentity(tx); err != nil {
+				return nil, err
+			}
+		}
+
 		scopes := r.scopes(tx)
 
-		err := scopes.Process(instance.Spec.Scopes.ExposedScopes)
+		acls, err := scopes.Process(instance.Spec.Scopes.ExposedScopes)
 		if err != nil {
 			return nil, fmt.Errorf("processing scopes: %w", err)
 		}
 
+		// the annotation is persisted along with the removal of processed annotations, see process
+		if !tx.DryRun() {
+			if err := clients.SetExposedScopesACL(instance, acls); err != nil {
+				return nil, err
+			}
+		}
+
 		consumedScopes, err := r.filterConsumedScopes(tx, instance)
 		if err != nil {
 			return nil, err
@@ -309,13 +510,19 @@ func (r *Reconciler) createOrUpdateClient(tx *Transaction) (*types.ClientRegistr
 			return nil, fmt.Errorf("cannot update immutable integration type (existing: %s, desired: %s)", existingType, desiredType)
 		}
 
+		changed := registration.Diff(registrationPayload)
+		if len(changed) == 0 {
+			ctrl.LoggerFrom(tx.Ctx).WithValues("client_id", registration.ClientID).V(4).Info("client is up-to-date in DigDir, skipping update")
+			return registration, nil
+		}
+
 		_, err = r.updateClient(tx, registrationPayload, registration.ClientID)
 		if err != nil {
 			return nil, fmt.Errorf("updating client: %w", err)
 		}
 
-		r.reportEvent(tx, corev1.EventTypeNormal, EventUpdatedInDigDir, "Client is updated")
-		metrics.IncClientsUpdated(tx.Instance)
+		r.reportEvent(tx, corev1.EventTypeNormal, EventUpdatedInDigDir, fmt.Sprintf("Client is updated; changed fields: [%s]", strings.Join(changed, ", ")))
+		tx.observe(metrics.IncClientsUpdated)
 	} else {
 		registration, err = r.createClient(tx, registrationPayload)
 		if err != nil {
@@ -323,7 +530,7 @@ func (r *Reconciler) createOrUpdateClient(tx *Transaction) (*types.ClientRegistr
 		}
 
 		r.reportEvent(tx, corev1.EventTypeNormal, EventCreatedInDigDir, "Client is registered")
-		metrics.IncClientsCreated(tx.Instance)
+		tx.observe(metrics.IncClientsCreated)
 	}
 
 	return registration, nil
@@ -333,7 +540,7 @@ func (r *Reconciler) createClient(tx *Transaction, payload types.ClientRegistrat
 	log := ctrl.LoggerFrom(tx.Ctx)
 	log.V(4).Info("client does not exist in Digdir, registering...")
 
-	registrationResponse, err := r.DigDirClient.Register(tx.Ctx, payload)
+	registrationResponse, err := tx.DigDirClient.Register(tx.Ctx, payload)
 	if err != nil {
 		return nil, fmt.Errorf("registering client: %w", err)
 	}
@@ -346,7 +553,7 @@ func (r *Reconciler) updateClient(tx *Transaction, payload types.ClientRegistrat
 	log := ctrl.LoggerFrom(tx.Ctx).WithValues("client_id", clientID)
 	log.V(4).Info("client already exists, updating...")
 
-	registrationResponse, err := r.DigDirClient.Update(tx.Ctx, payload, clientID)
+	registrationResponse, err := tx.DigDirClient.Update(tx.Ctx, payload, clientID)
 	if err != nil {
 		return nil, fmt.Errorf("updating client: %w", err)
 	}
@@ -367,7 +574,7 @@ func (r *Reconciler) filterConsumedScopes(tx *Transaction, client *naisiov1.Mask
 	valid := make([]string, 0)
 	invalid := make([]string, 0)
 	for _, scp := range desired {
-		canAccess, err := r.DigDirClient.CanAccessScope(tx.Ctx, scp)
+		canAccess, err := tx.DigDirClient.CanAccessScope(tx.Ctx, scp)
 		if err != nil {
 			return nil, err
 		}
@@ -405,6 +612,100 @@ func (r *Reconciler) filterConsumedScopes(tx *Transaction, client *naisiov1.Mask
 	return valid, nil
 }
 
+// restorableJwk returns the key to restore the secret with if it no longer holds a valid key, see secretsClient.KeyDrift.
+// The key is the newest key in the managed secrets that is registered for the client. If there is none, the credentials must be rotated instead.
+// A nil key and no rotation is returned if the secret holds a valid key, or the client has no registered keys yet.
+func (r *Reconciler) restorableJwk(tx *Transaction, secretsClient secretsClient, managedSecrets kubernetes.SecretLists) (*jose.JSONWebKey, bool, error) {
+	if clients.NeedsSecretRotation(tx.Instance) {
+		return nil, false, nil
+	}
+
+	drift, err := secretsClient.KeyDrift()
+	if err != nil {
+		return nil, false, fmt.Errorf("checking secret: %w", err)
+	}
+	if drift == "" {
+		return nil, false, nil
+	}
+
+	log := ctrl.LoggerFrom(tx.Ctx)
+	jwk, err := crypto.GetRegisteredJwkFromSecrets(managedSecrets, clients.GetSecretJwkKey(tx.Instance), tx.Instance.GetStatus().KeyIDs)
+	if errors.Is(err, crypto.ErrNoPreviousJwkFound) {
+		log.Info(fmt.Sprintf("%s and no registered key was found in secrets; rotating...", drift))
+		return nil, true, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	log.Info(fmt.Sprintf("%s; restoring with registered key %q...", drift, jwk.KeyID))
+	return jwk, false, nil
+}
+
+// revokeKeys registers the keys again without the keys in unused and expired secrets, if any of them are still registered.
+// The keys are revoked before the unused secrets are deleted, see secretsClient.DeleteUnused.
+func (r *Reconciler) revokeKeys(tx *Transaction, jwk jose.JSONWebKey, pruning secrets.Pruning, clientID string) error {
+	secretKey := clients.GetSecretJwkKey(tx.Instance)
+	kept := append(keyIDs(pruning.Keep, secretKey), jwk.KeyID)
+	registered := tx.Instance.GetStatus().KeyIDs
+
+	unused := revocableKeyIDs(pruning.Unused, kept, registered, secretKey)
+	expired := revocableKeyIDs(pruning.Expired, kept, registered, secretKey)
+	if len(unused) == 0 && len(expired) == 0 {
+		return nil
+	}
+
+	if err := r.registerJwk(tx, jwk, pruning.SecretLists(), clientID); err != nil {
+		return fmt.Errorf("revoking keys: %w", err)
+	}
+
+	if len(unused) > 0 {
+		r.reportEvent(tx, corev1.EventTypeNormal, EventRevokedKeysInDigDir, fmt.Sprintf("Revoked keys that are no longer used: [%s]", strings.Join(unused, ", ")))
+	}
+	if len(expired) > 0 {
+		r.reportEvent(tx, corev1.EventTypeWarning, EventRevokedKeysInDigDir, fmt.Sprintf("Revoked keys that are still used, as the maximum key overlap of %s has passed: [%s]", r.Config.DigDir.Common.MaxKeyOverlap, strings.Join(expired, ", ")))
+	}
+	return nil
+}
+
+// revocableKeyIDs returns the IDs of the keys in the given secrets that are still registered, except for the kept keys.
+func revocableKeyIDs(prunable []corev1.Secret, kept, registered []string, secretKey string) []string {
+	revocable := make([]string, 0)
+	for _, id := range keyIDs(prunable, secretKey) {
+		if slices.Contains(registered, id) && !slices.Contains(kept, id) {
+			revocable = append(revocable, id)
+		}
+	}
+	return revocable
+}
+
+// keyIDs returns the unique IDs of the keys in the given secrets, skipping secrets that do not hold a valid JWK.
+func keyIDs(managed []corev1.Secret, secretKey string) []string {
+	ids := make([]string, 0)
+	for _, secret := range managed {
+		key, err := crypto.GetJwkFromSecret(secret, secretKey)
+		if err == nil && !slices.Contains(ids, key.KeyID) {
+			ids = append(ids, key.KeyID)
+		}
+	}
+	return ids
+}
+
+func (r *Reconciler) generateJwk(tx *Transaction) (*jose.JSONWebKey, error) {
+	keyType, err := clients.GetKeyType(tx.Instance, r.Config)
+	if err != nil {
+		return nil, fmt.Errorf("resolving key type: %w", err)
+	}
+
+	jwk, err := crypto.GenerateJwk(keyType)
+	if err != nil {
+		return nil, fmt.Errorf("generating jwk: %w", err)
+	}
+
+	ctrl.LoggerFrom(tx.Ctx).Info(fmt.Sprintf("generated new %s JWK with key ID %q", keyType, jwk.KeyID))
+	return jwk, nil
+}
+
 func (r *Reconciler) registerJwk(tx *Transaction, jwk jose.JSONWebKey, managedSecrets kubernetes.SecretLists, clientID string) error {
 	log := ctrl.LoggerFrom(tx.Ctx)
 	jwks, err := crypto.MergeJwks(jwk, managedSecrets.Used, clients.GetSecretJwkKey(tx.Instance))
@@ -414,7 +715,7 @@ func (r *Reconciler) registerJwk(tx *Transaction, jwk jose.JSONWebKey, managedSe
 
 	log.V(4).Info("generated new JWKS for client, registering...")
 
-	jwksResponse, err := r.DigDirClient.RegisterKeys(tx.Ctx, clientID, jwks)
+	jwksResponse, err := tx.DigDirClient.RegisterKeys(tx.Ctx, clientID, jwks)
 	if err != nil {
 		return fmt.Errorf("registering JWKS: %w", err)
 	}
@@ -431,7 +732,7 @@ func (r *Reconciler) registerJwk(tx *Transaction, jwk jose.JSONWebKey, managedSe
 // ensureJwkValidExternalState ensures that the JWK is registered in DigDir and is not expiring soon.
 func (r *Reconciler) ensureJwkValidExternalState(tx *Transaction, registration *types.ClientRegistration, jwk *jose.JSONWebKey, managedSecrets kubernetes.SecretLists) error {
 	log := ctrl.LoggerFrom(tx.Ctx)
-	resp, err := r.DigDirClient.GetKeys(tx.Ctx, registration.ClientID)
+	resp, err := tx.DigDirClient.GetKeys(tx.Ctx, registration.ClientID)
 	if err != nil {
 		return fmt.Errorf("getting keys: %w", err)
 	}
@@ -468,8 +769,75 @@ func (r *Reconciler) ensureJwkValidExternalState(tx *Transaction, registration *
 	return nil
 }
 
+// digdirClientFor returns the client for the admin identity that the instance is registered with.
+// If the identity is invalid, the client for the default identity is returned along with the error.
+func (r *Reconciler) digdirClientFor(instance clients.Instance) (digdir.Client, error) {
+	name, err := clients.GetIdentity(instance, r.Config)
+	if err != nil {
+		return r.DigDirClient, err
+	}
+
+	digdirClient, found := r.Identities[name]
+	if !found {
+		return r.DigDirClient, fmt.Errorf("no client configured for identity %q", name)
+	}
+	return digdirClient, nil
+}
+
+// requireDefaultIdentity returns an error unless the instance uses the default identity. Scopes are only exposed with the
+// default identity, as the scope prefix belongs to its organization.
+func (r *Reconciler) requireDefaultIdentity(tx *Transaction) error {
+	digdirClient, err := r.digdirClientFor(tx.Instance)
+	if err != nil {
+		return err
+	}
+
+	if digdirClient.Identity != config.DefaultIdentity {
+		return fmt.Errorf("exposing scopes is only supported with the %q identity, got %q", config.DefaultIdentity, digdirClient.Identity)
+	}
+	return nil
+}
+
+// reportEvent reports an event for changes that were performed. Dry runs report their planned changes with reportPlan instead.
+// If additional admin identities are configured, the message names the identity that the changes were performed with.
 func (r *Reconciler) reportEvent(tx *Transaction, eventType, event, message string) {
+	if tx.DryRun() {
+		return
+	}
+
+	if len(r.Identities) > 1 {
+		message = fmt.Sprintf("%s (identity: %s)", message, tx.DigDirClient.Identity)
+	}
+
 	status := tx.Instance.GetStatus()
 	status.SynchronizationState = event
 	r.Recorder.Eventf(tx.Instance, nil, eventType, event, event, message)
 }
+
+// reportPlan reports the changes planned in a dry run as events and a status condition.
+// Any other status changes made while planning are discarded in favor of the given status.
+func (r *Reconciler) reportPlan(tx *Transaction, status naisiov1.DigdiratorStatus) error {
+	operations := tx.Plan.Operations()
+	r.Plans.Set(tx.Instance, operations)
+
+	summaries := make([]string, 0, len(operations))
+	for _, op := range operations {
+		summaries = append(summaries, op.Summary)
+		r.Recorder.Eventf(tx.Instance, nil, corev1.EventTypeNormal, EventPlannedInDigDir, EventPlannedInDigDir, "Dry run: would %s", op.Summary)
+	}
+
+	generation := tx.Instance.GetGeneration()
+	condition := PlannedChangesCondition(metav1.ConditionFalse, ConditionReasonDryRun, "Dry run: no changes to DigDir", generation)
+	if len(operations) > 0 {
+		condition = PlannedChangesCondition(metav1.ConditionTrue, ConditionReasonDryRun, fmt.Sprintf("Dry run: would %s", strings.Join(summaries, "; ")), generation)
+	}
+
+	ctrl.LoggerFrom(tx.Ctx).Info(fmt.Sprintf("dry run: planned %d change(s) to DigDir", len(operations)), "operations", summaries)
+
+	status.SetCondition(condition)
+	tx.Instance.SetStatus(status)
+	if err := r.Client.Status().Update(tx.Ctx, tx.Instance); err != nil {
+		return fmt.Errorf("updating status: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,193 @@
+package common
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-jose/go-jose/v4"
+	naisiov1 "github.com/nais/liberator/pkg/apis/nais.io/v1"
+	"github.com/nais/liberator/pkg/oauth"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/events"
+	fakeclient "sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/nais/digdirator/pkg/config"
+	"github.com/nais/digdirator/pkg/crypto"
+	"github.com/nais/digdirator/pkg/digdir"
+	"github.com/nais/digdirator/pkg/digdir/fake"
+	"github.com/nais/digdirator/pkg/digdir/types"
+	"github.com/nais/digdirator/pkg/secrets"
+)
+
+func TestRevokeKeys(t *testing.T) {
+	type testEnv struct {
+		server     *fake.Server
+		reconciler *Reconciler
+		recorder   *events.FakeRecorder
+		tx         *Transaction
+		keys       map[string]*jose.JSONWebKey
+		secrets    map[string]corev1.Secret
+	}
+
+	setup := func(t *testing.T) *testEnv {
+		srv := fake.New(fake.Options{})
+		httpServer := httptest.NewServer(srv)
+		t.Cleanup(httpServer.Close)
+
+		metadata, err := oauth.NewMetadataOAuth(t.Context(), httpServer.URL+"/.well-known/oauth-authorization-server")
+		require.NoError(t, err)
+
+		cfg := &config.Config{ClusterName: "test-cluster"}
+		cfg.DigDir.Admin.BaseURL = httpServer.URL
+		cfg.DigDir.Admin.ClientID = "admin"
+		cfg.DigDir.Maskinporten.Metadata = *metadata
+		cfg.DigDir.Common.MaxKeyOverlap = time.Hour
+
+		privateKey, err := crypto.GenerateRSAKey()
+		require.NoError(t, err)
+		signer, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.RS256, Key: privateKey}, nil)
+		require.NoError(t, err)
+
+		digdirClient, err := digdir.NewClient(cfg, httpServer.Client(), signer)
+		require.NoError(t, err)
+
+		registration, err := digdirClient.Register(t.Context(), types.ClientRegistration{
+			ClientName:      "test",
+			Description:     "test-cluster:test-namespace:test",
+			IntegrationType: types.IntegrationTypeMaskinporten,
+		})
+		require.NoError(t, err)
+
+		env := &testEnv{
+			server:   srv,
+			recorder: events.NewFakeRecorder(10),
+			keys:     make(map[string]*jose.JSONWebKey),
+			secrets:  make(map[string]corev1.Secret),
+		}
+
+		registered := &jose.JSONWebKeySet{}
+		for _, name := range []string{"current", "previous", "unused", "expired", "unregistered"} {
+			jwk, err := crypto.GenerateJwk(crypto.DefaultKeyType)
+			require.NoError(t, err)
+			data, err := json.Marshal(jwk)
+			require.NoError(t, err)
+
+			env.keys[name] = jwk
+			env.secrets[name] = corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "test-namespace"},
+				Data:       map[string][]byte{secrets.MaskinportenJwkKey: data},
+			}
+			if name != "unregistered" {
+				registered.Keys = append(registered.Keys, jwk.Public())
+			}
+		}
+
+		response, err := digdirClient.RegisterKeys(t.Context(), registration.ClientID, registered)
+		require.NoError(t, err)
+
+		instance := &naisiov1.MaskinportenClient{
+			ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "test-namespace"},
+			Status: naisiov1.DigdiratorStatus{
+				ClientID: registration.ClientID,
+				KeyIDs:   response.KeyIDs(),
+			},
+		}
+
+		scheme := runtime.NewScheme()
+		require.NoError(t, clientgoscheme.AddToScheme(scheme))
+		require.NoError(t, naisiov1.AddToScheme(scheme))
+		k8s := fakeclient.NewClientBuilder().WithScheme(scheme).Build()
+
+		reconciler := NewReconciler(k8s, k8s, scheme, env.recorder, cfg, digdirClient)
+		env.reconciler = &reconciler
+		env.tx = NewTransaction(t.Context(), instance, digdirClient)
+		return env
+	}
+
+	registeredKeyIDs := func(env *testEnv) []string {
+		ids := make([]string, 0)
+		for _, key := range env.server.Keys(env.tx.Instance.GetStatus().ClientID) {
+			ids = append(ids, key.KeyID)
+		}
+		return ids
+	}
+
+	reported := func(env *testEnv) []string {
+		result := make([]string, 0)
+		for {
+			select {
+			case event := <-env.recorder.Events:
+				result = append(result, event)
+			default:
+				return result
+			}
+		}
+	}
+
+	t.Run("revokes the keys in unused and expired secrets", func(t *testing.T) {
+		env := setup(t)
+		pruning := secrets.Pruning{
+			Keep:    []corev1.Secret{env.secrets["current"], env.secrets["previous"]},
+			Unused:  []corev1.Secret{env.secrets["unused"]},
+			Expired: []corev1.Secret{env.secrets["expired"]},
+		}
+
+		err := env.reconciler.revokeKeys(env.tx, *env.keys["current"], pruning, env.tx.Instance.GetStatus().ClientID)
+		require.NoError(t, err)
+
+		expected := []string{env.keys["current"].KeyID, env.keys["previous"].KeyID}
+		assert.ElementsMatch(t, expected, env.tx.Instance.GetStatus().KeyIDs)
+		assert.ElementsMatch(t, expected, registeredKeyIDs(env))
+		assert.Equal(t, []string{
+			"Normal " + EventRevokedKeysInDigDir + " Revoked keys that are no longer used: [" + env.keys["unused"].KeyID + "]",
+			"Warning " + EventRevokedKeysInDigDir + " Revoked keys that are still used, as the maximum key overlap of 1h0m0s has passed: [" + env.keys["expired"].KeyID + "]",
+		}, reported(env))
+	})
+
+	t.Run("skips keys that are kept or not registered", func(t *testing.T) {
+		env := setup(t)
+		before := registeredKeyIDs(env)
+
+		// the unused secret holds the same key as a secret that is kept, e.g. after the current secret was restored
+		unused := env.secrets["previous"]
+		unused.Name = "restored-from"
+		pruning := secrets.Pruning{
+			Keep:    []corev1.Secret{env.secrets["current"], env.secrets["previous"]},
+			Unused:  []corev1.Secret{unused},
+			Expired: []corev1.Secret{env.secrets["unregistered"]},
+		}
+
+		err := env.reconciler.revokeKeys(env.tx, *env.keys["current"], pruning, env.tx.Instance.GetStatus().ClientID)
+		require.NoError(t, err)
+
+		assert.ElementsMatch(t, before, env.tx.Instance.GetStatus().KeyIDs)
+		assert.ElementsMatch(t, before, registeredKeyIDs(env))
+		assert.Empty(t, reported(env))
+	})
+
+	t.Run("keeps a new key that is not in any secret yet", func(t *testing.T) {
+		env := setup(t)
+		jwk, err := crypto.GenerateJwk(crypto.DefaultKeyType)
+		require.NoError(t, err)
+
+		pruning := secrets.Pruning{
+			Keep:   []corev1.Secret{env.secrets["previous"]},
+			Unused: []corev1.Secret{env.secrets["current"], env.secrets["unused"]},
+		}
+
+		err = env.reconciler.revokeKeys(env.tx, *jwk, pruning, env.tx.Instance.GetStatus().ClientID)
+		require.NoError(t, err)
+
+		expected := []string{jwk.KeyID, env.keys["previous"].KeyID}
+		assert.ElementsMatch(t, expected, env.tx.Instance.GetStatus().KeyIDs)
+		assert.ElementsMatch(t, expected, registeredKeyIDs(env))
+		assert.Len(t, reported(env), 1)
+	})
+}
@@ -0,0 +1,74 @@
+package common
+
+import (
+	"context"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/nais/digdirator/pkg/clients"
+)
+
+// +kubebuilder:rbac:groups="",resources=pods,verbs=get;list;watch
+// +kubebuilder:rbac:groups=apps,resources=replicasets,verbs=get;list;watch
+
+// EnqueueForApplication maps pods and replica sets to the resource named by their app label, so that previous secrets and
+// their keys are pruned as soon as they are no longer used. The resource is looked up in the cache of the controller, as
+// most applications do not have a resource of the given type. Other errors than the resource not being found are logged,
+// as the resource is reconciled again when it is requeued.
+func EnqueueForApplication(reader client.Reader, newInstance func() clients.Instance) handler.EventHandler {
+	return handler.EnqueueRequestsFromMapFunc(func(ctx context.Context, obj client.Object) []reconcile.Request {
+		app := obj.GetLabels()[clients.AppLabelKey]
+		if app == "" {
+			return nil
+		}
+
+		key := types.NamespacedName{Namespace: obj.GetNamespace(), Name: app}
+		if err := reader.Get(ctx, key, newInstance()); err != nil {
+			if !errors.IsNotFound(err) {
+				ctrl.LoggerFrom(ctx).Error(err, "getting resource for application", "namespace", key.Namespace, "name", key.Name)
+			}
+			return nil
+		}
+		return []reconcile.Request{{NamespacedName: key}}
+	})
+}
+
+// RolloutPredicate filters events for pods and replica sets to those that may leave a previous secret unused,
+// i.e. when they are deleted, a pod terminates, or a replica set is scaled down to zero.
+func RolloutPredicate() predicate.Predicate {
+	return predicate.Funcs{
+		CreateFunc: func(event.CreateEvent) bool {
+			return false
+		},
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			switch newObj := e.ObjectNew.(type) {
+			case *corev1.Pod:
+				oldObj, ok := e.ObjectOld.(*corev1.Pod)
+				return ok && !podTerminated(oldObj) && podTerminated(newObj)
+			case *appsv1.ReplicaSet:
+				oldObj, ok := e.ObjectOld.(*appsv1.ReplicaSet)
+				return ok && oldObj.Status.Replicas > 0 && newObj.Status.Replicas == 0
+			}
+			return false
+		},
+		DeleteFunc: func(event.DeleteEvent) bool {
+			return true
+		},
+		GenericFunc: func(event.GenericEvent) bool {
+			return false
+		},
+	}
+}
+
+func podTerminated(pod *corev1.Pod) bool {
+	return pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed
+}
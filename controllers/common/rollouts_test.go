@@ -0,0 +1,144 @@
+package common_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/go-logr/logr/funcr"
+	naisiov1 "github.com/nais/liberator/pkg/apis/nais.io/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/util/workqueue"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	fakeclient "sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/nais/digdirator/controllers/common"
+	"github.com/nais/digdirator/pkg/clients"
+)
+
+func TestRolloutPredicate(t *testing.T) {
+	pod := func(phase corev1.PodPhase) *corev1.Pod {
+		return &corev1.Pod{Status: corev1.PodStatus{Phase: phase}}
+	}
+	replicaSet := func(replicas int32) *appsv1.ReplicaSet {
+		return &appsv1.ReplicaSet{Status: appsv1.ReplicaSetStatus{Replicas: replicas}}
+	}
+
+	p := common.RolloutPredicate()
+
+	t.Run("create and generic events are ignored", func(t *testing.T) {
+		assert.False(t, p.Create(event.CreateEvent{Object: pod(corev1.PodRunning)}))
+		assert.False(t, p.Generic(event.GenericEvent{Object: pod(corev1.PodRunning)}))
+	})
+
+	t.Run("delete events are accepted", func(t *testing.T) {
+		assert.True(t, p.Delete(event.DeleteEvent{Object: pod(corev1.PodRunning)}))
+		assert.True(t, p.Delete(event.DeleteEvent{Object: replicaSet(1)}))
+	})
+
+	for _, tt := range []struct {
+		name     string
+		old, new client.Object
+		expected bool
+	}{
+		{name: "pod succeeds", old: pod(corev1.PodRunning), new: pod(corev1.PodSucceeded), expected: true},
+		{name: "pod fails", old: pod(corev1.PodRunning), new: pod(corev1.PodFailed), expected: true},
+		{name: "pod starts running", old: pod(corev1.PodPending), new: pod(corev1.PodRunning), expected: false},
+		{name: "terminated pod is updated", old: pod(corev1.PodFailed), new: pod(corev1.PodFailed), expected: false},
+		{name: "replica set is scaled down to zero", old: replicaSet(2), new: replicaSet(0), expected: true},
+		{name: "replica set is scaled down", old: replicaSet(2), new: replicaSet(1), expected: false},
+		{name: "replica set is scaled up", old: replicaSet(0), new: replicaSet(1), expected: false},
+		{name: "replica set without replicas is updated", old: replicaSet(0), new: replicaSet(0), expected: false},
+		{name: "other objects", old: &corev1.Secret{}, new: &corev1.Secret{}, expected: false},
+	} {
+		t.Run("update: "+tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, p.Update(event.UpdateEvent{ObjectOld: tt.old, ObjectNew: tt.new}))
+		})
+	}
+}
+
+func TestEnqueueForApplication(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(scheme))
+	require.NoError(t, naisiov1.AddToScheme(scheme))
+
+	newInstance := func() clients.Instance {
+		return &naisiov1.IDPortenClient{}
+	}
+	pod := func(app string) *corev1.Pod {
+		labels := map[string]string{}
+		if app != "" {
+			labels[clients.AppLabelKey] = app
+		}
+		return &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "pod", Namespace: "test-namespace", Labels: labels}}
+	}
+	enqueued := func(ctx context.Context, reader client.Reader, obj client.Object) []reconcile.Request {
+		q := workqueue.NewTypedRateLimitingQueue(workqueue.DefaultTypedControllerRateLimiter[reconcile.Request]())
+		defer q.ShutDown()
+
+		common.EnqueueForApplication(reader, newInstance).Delete(ctx, event.DeleteEvent{Object: obj}, q)
+
+		requests := make([]reconcile.Request, 0)
+		for q.Len() > 0 {
+			req, _ := q.Get()
+			requests = append(requests, req)
+			q.Done(req)
+		}
+		return requests
+	}
+
+	reader := fakeclient.NewClientBuilder().
+		WithScheme(scheme).
+		WithObjects(&naisiov1.IDPortenClient{ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "test-namespace"}}).
+		Build()
+
+	t.Run("enqueues the resource named by the app label", func(t *testing.T) {
+		expected := reconcile.Request{NamespacedName: types.NamespacedName{Namespace: "test-namespace", Name: "app"}}
+		assert.Equal(t, []reconcile.Request{expected}, enqueued(t.Context(), reader, pod("app")))
+	})
+
+	t.Run("ignores objects without app label", func(t *testing.T) {
+		assert.Empty(t, enqueued(t.Context(), reader, pod("")))
+	})
+
+	t.Run("ignores applications without a resource", func(t *testing.T) {
+		assert.Empty(t, enqueued(t.Context(), reader, pod("other")))
+	})
+
+	t.Run("logs other errors", func(t *testing.T) {
+		failing := fakeclient.NewClientBuilder().
+			WithScheme(scheme).
+			WithInterceptorFuncs(interceptor.Funcs{
+				Get: func(context.Context, client.WithWatch, client.ObjectKey, client.Object, ...client.GetOption) error {
+					return errors.New("cache is not synced")
+				},
+			}).
+			Build()
+
+		logs := make([]string, 0)
+		logger := funcr.New(func(_, args string) {
+			logs = append(logs, args)
+		}, funcr.Options{})
+		ctx := ctrl.LoggerInto(t.Context(), logger)
+
+		assert.Empty(t, enqueued(ctx, failing, pod("app")))
+		require.Len(t, logs, 1)
+		assert.Contains(t, logs[0], "cache is not synced")
+		assert.Contains(t, logs[0], `"name"="app"`)
+
+		logs = logs[:0]
+		assert.Empty(t, enqueued(ctx, reader, pod("other")))
+		assert.Empty(t, logs, "not found errors should not be logged")
+	})
+}
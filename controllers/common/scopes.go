@@ -1,6 +1,7 @@
 package common
 
 import (
+	"cmp"
 	"errors"
 	"fmt"
 	"net/http"
@@ -33,27 +34,31 @@ func (r *Reconciler) scopes(tx *Transaction) scope {
 	}
 }
 
-func (s scope) Process(exposedScopes []naisiov1.ExposedScope) error {
+// Process creates or updates the exposed scopes, and returns the resulting ACLs for all active scopes.
+func (s scope) Process(exposedScopes []naisiov1.ExposedScope) ([]scopes.ACL, error) {
 	if len(exposedScopes) == 0 {
-		return nil
+		return nil, nil
 	}
 
 	filtered, err := s.filtered(exposedScopes)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	if filtered == nil {
+		return nil, nil
 	}
 
-	err = s.createScopes(filtered.ToCreate)
+	created, err := s.createScopes(filtered.ToCreate)
 	if err != nil {
-		return fmt.Errorf("creating scopes: %w", err)
+		return nil, fmt.Errorf("creating scopes: %w", err)
 	}
 
-	err = s.updateScopes(filtered.ToUpdate)
+	updated, err := s.updateScopes(filtered.ToUpdate)
 	if err != nil {
-		return fmt.Errorf("updating scopes: %w", err)
+		return nil, fmt.Errorf("updating scopes: %w", err)
 	}
 
-	return nil
+	return append(created, updated...), nil
 }
 
 func (s scope) Finalize(exposedScopes []naisiov1.ExposedScope) error {
@@ -83,28 +88,31 @@ func (s scope) Finalize(exposedScopes []naisiov1.ExposedScope) error {
 	return nil
 }
 
-func (s scope) createScopes(toCreate []naisiov1.ExposedScope) error {
+func (s scope) createScopes(toCreate []naisiov1.ExposedScope) ([]scopes.ACL, error) {
+	acls := make([]scopes.ACL, 0, len(toCreate))
 	for _, newScope := range toCreate {
 		s.log.V(4).Info(fmt.Sprintf("Subscope %q does not exist in Digdir, creating...", newScope.Name))
 
 		scope, err := s.create(newScope)
 		if err != nil {
-			return err
+			return nil, err
 		}
 		s.reportEvent(s.Tx, corev1.EventTypeNormal, EventCreatedScopeInDigDir, fmt.Sprintf("Created scope %q", scope.Name))
-		metrics.IncScopesCreated(s.Tx.Instance)
+		s.Tx.observe(metrics.IncScopesCreated)
 
 		// add consumers
-		err = s.updateACL(scopes.CurrentScopeInfo(*scope, newScope))
+		acl, err := s.updateACL(scopes.CurrentScopeInfo(*scope, newScope))
 		if err != nil {
-			return fmt.Errorf("updating ACL: %w", err)
+			return nil, fmt.Errorf("updating ACL: %w", err)
 		}
+		acls = append(acls, *acl)
 	}
 
-	return nil
+	return acls, nil
 }
 
-func (s scope) updateScopes(toUpdate []scopes.Scope) error {
+func (s scope) updateScopes(toUpdate []scopes.Scope) ([]scopes.ACL, error) {
+	acls := make([]scopes.ACL, 0, len(toUpdate))
 	for _, scope := range toUpdate {
 		log := s.log.WithValues("scope", scope.ToString())
 		log.V(4).Info(fmt.Sprintf("updating existing scope %q...", scope.ToString()))
@@ -122,39 +130,85 @@ func (s scope) updateScopes(toUpdate []scopes.Scope) error {
 			err = s.deactivate(scope)
 		}
 		if err != nil {
-			return err
+			return nil, err
 		}
 
 		if wantEnabled {
-			err = s.updateACL(scope)
+			acl, err := s.updateACL(scope)
 			if err != nil {
-				return fmt.Errorf("updating ACL: %w", err)
+				return nil, fmt.Errorf("updating ACL: %w", err)
 			}
+			acls = append(acls, *acl)
 		}
 	}
 
-	return nil
+	return acls, nil
 }
 
 func (s scope) filtered(exposedScopes []naisiov1.ExposedScope) (*scopes.Operations, error) {
-	allScopes, err := s.DigDirClient.GetScopes(s.Tx.Ctx)
+	exposedScopes, err := s.unclaimed(exposedScopes)
+	if err != nil {
+		return nil, err
+	}
+	if len(exposedScopes) == 0 {
+		return nil, nil
+	}
+
+	subscopes := make([]string, 0, len(exposedScopes))
+	for _, exposedScope := range exposedScopes {
+		subscopes = append(subscopes, scopes.Subscope(exposedScope))
+	}
+
+	// inactive scopes are included so that they're reactivated rather than registered again
+	actualScopes, err := s.DigDirClient.ListScopes(s.Tx.Ctx, digdir.ScopeFilter{IncludeInactive: true, Subscopes: subscopes})
 	if err != nil {
 		return nil, fmt.Errorf("getting scopes: %w", err)
 	}
 
-	return scopes.Generate(allScopes, exposedScopes), nil
+	return scopes.Generate(actualScopes, exposedScopes), nil
 }
 
-func (s scope) updateACL(scope scopes.Scope) error {
+// unclaimed returns the scopes exposed by a MaskinportenClient that are not managed by a MaskinportenScope in the same namespace.
+// Claimed scopes are left to the MaskinportenScope, so that both resources don't fight over the scope while the client is migrated.
+func (s scope) unclaimed(exposedScopes []naisiov1.ExposedScope) ([]naisiov1.ExposedScope, error) {
+	if _, ok := s.Tx.Instance.(*naisiov1.MaskinportenClient); !ok || len(exposedScopes) == 0 {
+		return exposedScopes, nil
+	}
+
+	claimed, err := s.claimedScopes(s.Tx)
+	if err != nil {
+		return nil, err
+	}
+	if len(claimed) == 0 {
+		return exposedScopes, nil
+	}
+
+	unclaimed := make([]naisiov1.ExposedScope, 0, len(exposedScopes))
+	for _, exposedScope := range exposedScopes {
+		subscope := scopes.Subscope(exposedScope)
+		if name, ok := claimed[subscope]; ok {
+			msg := fmt.Sprintf("Scope %q is managed by MaskinportenScope %q and can be removed from spec.scopes.exposes", subscope, name)
+			s.log.Info(msg)
+			s.reportEvent(s.Tx, corev1.EventTypeNormal, EventScopeManagedElsewhere, msg)
+			continue
+		}
+		unclaimed = append(unclaimed, exposedScope)
+	}
+	return unclaimed, nil
+}
+
+// updateACL adds and removes consumers of the scope in DigDir, and returns the resulting state of each consumer.
+func (s scope) updateACL(scope scopes.Scope) (*scopes.ACL, error) {
 	scopeName := scope.ToString()
 	log := s.log.WithValues("scope", scopeName)
 
-	acl, err := s.DigDirClient.GetScopeACL(s.Tx.Ctx, scopeName)
+	registrations, err := s.DigDirClient.GetScopeACL(s.Tx.Ctx, scopeName)
 	if err != nil {
-		return fmt.Errorf("getting ACL: %w", err)
+		return nil, fmt.Errorf("getting ACL: %w", err)
 	}
 
-	consumerStatus, consumerList := scope.FilterConsumers(acl)
+	acl := scopes.NewACL(scopeName, *registrations)
+	_, consumerList := scope.FilterConsumers(registrations)
 	setValidCondition := func() {
 		s.Tx.Instance.GetStatus().SetCondition(
 			InvalidExposedScopesConsumersCondition(
@@ -171,7 +225,7 @@ func (s scope) updateACL(scope scopes.Scope) error {
 		log.Info(msg)
 		s.reportEvent(s.Tx, corev1.EventTypeNormal, EventUpdatedACLForScopeInDigDir, msg)
 		setValidCondition()
-		return nil
+		return &acl, nil
 	}
 
 	invalidConsumers := make([]string, 0)
@@ -189,35 +243,39 @@ func (s scope) updateACL(scope scopes.Scope) error {
 		if consumer.ShouldBeAdded {
 			log.Info(fmt.Sprintf("ACL: adding consumer %q...", consumer.Orgno))
 
-			_, err := s.DigDirClient.AddToScopeACL(s.Tx.Ctx, scopeName, consumer.Orgno)
+			registration, err := s.DigDirClient.AddToScopeACL(s.Tx.Ctx, scopeName, consumer.Orgno)
 			if err != nil {
 				if digdirErr, ok := asInvalidConsumerError(err); ok {
 					log.Error(digdirErr, fmt.Sprintf("ACL: consumer %q is invalid; skipping...", consumer.Orgno))
 					invalidConsumers = append(invalidConsumers, consumer.Orgno)
+					acl.Set(consumer.Orgno, scopes.ConsumerStateInvalid)
 					continue
 				}
-				return fmt.Errorf("adding consumer: %w", err)
+				return nil, fmt.Errorf("adding consumer: %w", err)
 			}
+			acl.Set(consumer.Orgno, cmp.Or(registration.State, types.ScopeStateApproved))
 
 			msg := fmt.Sprintf("ACL: granted access to scope %q for consumer %q", scopeName, consumer.Orgno)
 			log.Info(msg)
 			s.reportEvent(s.Tx, corev1.EventTypeNormal, EventUpdatedACLForScopeInDigDir, msg)
 
-			consumerStatus = append(consumerStatus, consumer.Orgno)
-			metrics.IncScopesConsumersCreatedOrUpdated(s.Tx.Instance, consumer.State)
+			s.Tx.observe(func(instance clients.Instance) {
+				metrics.IncScopesConsumersCreatedOrUpdated(instance, consumer.State)
+			})
 		} else {
 			log.Info(fmt.Sprintf("ACL: removing consumer %q...", consumer.Orgno))
 
-			_, err := s.DigDirClient.DeactivateConsumer(s.Tx.Ctx, scopeName, consumer.Orgno)
+			registration, err := s.DigDirClient.DeactivateConsumer(s.Tx.Ctx, scopeName, consumer.Orgno)
 			if err != nil {
-				return fmt.Errorf("deactivating consumer: %w", err)
+				return nil, fmt.Errorf("deactivating consumer: %w", err)
 			}
+			acl.Set(consumer.Orgno, cmp.Or(registration.State, types.ScopeStateDenied))
 
 			msg := fmt.Sprintf("ACL: revoked access to scope %q for consumer %q", scopeName, consumer.Orgno)
 			log.Info(msg)
 			s.reportEvent(s.Tx, corev1.EventTypeNormal, EventUpdatedACLForScopeInDigDir, msg)
 
-			metrics.IncScopesConsumersDeleted(s.Tx.Instance)
+			s.Tx.observe(metrics.IncScopesConsumersDeleted)
 		}
 	}
 
@@ -234,11 +292,15 @@ func (s scope) updateACL(scope scopes.Scope) error {
 		setValidCondition()
 	}
 
-	return nil
+	return &acl, nil
 }
 
 func (s scope) create(newScope naisiov1.ExposedScope) (*types.ScopeRegistration, error) {
-	payload := clients.ToScopeRegistration(s.Tx.Instance, newScope, s.Config)
+	payload, err := clients.ToScopeRegistration(s.Tx.Instance, newScope, s.Config)
+	if err != nil {
+		return nil, err
+	}
+
 	response, err := s.DigDirClient.RegisterScope(s.Tx.Ctx, payload)
 	if err != nil {
 		return nil, fmt.Errorf("registering scope: %w", err)
@@ -249,7 +311,11 @@ func (s scope) create(newScope naisiov1.ExposedScope) (*types.ScopeRegistration,
 }
 
 func (s scope) update(scope scopes.Scope) error {
-	payload := clients.ToScopeRegistration(s.Tx.Instance, scope.CurrentScope, s.Config)
+	payload, err := clients.ToScopeRegistration(s.Tx.Instance, scope.CurrentScope, s.Config)
+	if err != nil {
+		return err
+	}
+
 	registration, err := s.DigDirClient.UpdateScope(s.Tx.Ctx, payload, scope.ToString())
 	if err != nil {
 		return fmt.Errorf("updating scope: %w", err)
@@ -258,13 +324,17 @@ func (s scope) update(scope scopes.Scope) error {
 	msg := fmt.Sprintf("Updated scope %q", registration.Name)
 	s.log.WithValues("scope", registration.Name).Info(msg)
 	s.reportEvent(s.Tx, corev1.EventTypeNormal, EventUpdatedScopeInDigDir, msg)
-	metrics.IncScopesUpdated(s.Tx.Instance)
+	s.Tx.observe(metrics.IncScopesUpdated)
 
 	return nil
 }
 
 func (s scope) activate(scope scopes.Scope) error {
-	payload := clients.ToScopeRegistration(s.Tx.Instance, scope.CurrentScope, s.Config)
+	payload, err := clients.ToScopeRegistration(s.Tx.Instance, scope.CurrentScope, s.Config)
+	if err != nil {
+		return err
+	}
+
 	registration, err := s.DigDirClient.UpdateScope(s.Tx.Ctx, payload, scope.ToString())
 	if err != nil {
 		return fmt.Errorf("activating scope: %w", err)
@@ -273,7 +343,7 @@ func (s scope) activate(scope scopes.Scope) error {
 	msg := fmt.Sprintf("Activated scope %q", registration.Name)
 	s.log.WithValues("scope", registration.Name).Info(msg)
 	s.reportEvent(s.Tx, corev1.EventTypeNormal, EventActivatedScopeInDigDir, msg)
-	metrics.IncScopesReactivated(s.Tx.Instance)
+	s.Tx.observe(metrics.IncScopesReactivated)
 
 	return nil
 }
@@ -287,7 +357,7 @@ func (s scope) deactivate(scope scopes.Scope) error {
 	msg := fmt.Sprintf("Deactivated scope %q; consumers no longer have access", registration.Name)
 	s.log.WithValues("scope", registration.Name).Info(msg)
 	s.reportEvent(s.Tx, corev1.EventTypeWarning, EventDeactivatedScopeInDigDir, msg)
-	metrics.IncScopesDeleted(s.Tx.Instance)
+	s.Tx.observe(metrics.IncScopesDeleted)
 
 	return nil
 }
@@ -1,20 +1,30 @@
 package common
 
 import (
+	"context"
 	"fmt"
+	"maps"
+	"reflect"
+	"slices"
+	"time"
 
 	"github.com/go-jose/go-jose/v4"
 	"github.com/go-logr/logr"
 	nais_io_v1 "github.com/nais/liberator/pkg/apis/nais.io/v1"
 	"github.com/nais/liberator/pkg/kubernetes"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
 
+	digdirv1alpha1 "github.com/nais/digdirator/api/v1alpha1"
 	"github.com/nais/digdirator/pkg/clients"
 	"github.com/nais/digdirator/pkg/config"
+	"github.com/nais/digdirator/pkg/crypto"
 	"github.com/nais/digdirator/pkg/secrets"
 )
 
@@ -24,6 +34,33 @@ const (
 	StakaterReloaderKeyAnnotation = "reloader.stakater.com/match"
 )
 
+// OwnedSecretPredicate filters events for owned secrets to those that may require the secret to be restored,
+// i.e. when it is deleted or its data is changed. Reconciliations for secrets that are intact are skipped, see secretsClient.Drift.
+func OwnedSecretPredicate() predicate.Predicate {
+	return predicate.Funcs{
+		CreateFunc: func(event.CreateEvent) bool {
+			return false
+		},
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			oldSecret, ok := e.ObjectOld.(*corev1.Secret)
+			if !ok {
+				return false
+			}
+			newSecret, ok := e.ObjectNew.(*corev1.Secret)
+			if !ok {
+				return false
+			}
+			return !reflect.DeepEqual(oldSecret.Data, newSecret.Data)
+		},
+		DeleteFunc: func(event.DeleteEvent) bool {
+			return true
+		},
+		GenericFunc: func(event.GenericEvent) bool {
+			return false
+		},
+	}
+}
+
 type secretsClient struct {
 	*Transaction
 	*Reconciler
@@ -40,19 +77,16 @@ func (r *Reconciler) secrets(transaction *Transaction) secretsClient {
 	}
 }
 
-func (s secretsClient) CreateOrUpdate(jwk jose.JSONWebKey) error {
+func (s secretsClient) CreateOrUpdate(jwk jose.JSONWebKey) (err error) {
+	defer s.span("secrets.CreateOrUpdate")(&err)
+
 	name := s.secretName
 	namespace := s.Instance.GetNamespace()
 	s.log.V(4).Info(fmt.Sprintf("processing secret %q...", name))
 
-	stringData, err := secretData(s.Instance, jwk, s.Reconciler.Config)
+	formats, err := clients.GetSecretFormats(s.Instance, s.Reconciler.Config)
 	if err != nil {
-		return fmt.Errorf("creating secret data: %w", err)
-	}
-
-	data := make(map[string][]byte)
-	for key, value := range stringData {
-		data[key] = []byte(value)
+		return fmt.Errorf("resolving secret formats: %w", err)
 	}
 
 	target := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{
@@ -60,7 +94,22 @@ func (s secretsClient) CreateOrUpdate(jwk jose.JSONWebKey) error {
 		Namespace: namespace,
 	}}
 
-	res, err := controllerutil.CreateOrUpdate(s.Ctx, s.Client, target, func() error {
+	// the secret is read without the cache, as it is not cached if its labels were changed or it was created by someone else
+	res, err := controllerutil.CreateOrUpdate(s.Ctx, uncachedReads{Client: s.Client, reader: s.Reader}, target, func() error {
+		// the data is created from the existing secret, so that a keystore for the same key is kept
+		stringData, err := secretData(s.Instance, jwk, s.Reconciler.Config, secrets.KeyFormats{
+			Formats:  formats,
+			Existing: target.Data,
+		})
+		if err != nil {
+			return fmt.Errorf("creating secret data: %w", err)
+		}
+
+		data := make(map[string][]byte)
+		for key, value := range stringData {
+			data[key] = []byte(value)
+		}
+
 		target.SetAnnotations(map[string]string{
 			StakaterReloaderKeyAnnotation: "true",
 		})
@@ -77,44 +126,152 @@ func (s secretsClient) CreateOrUpdate(jwk jose.JSONWebKey) error {
 	return nil
 }
 
-func (s secretsClient) GetManaged() (kubernetes.SecretLists, error) {
+// uncachedReads is a client that reads objects with the reader instead of the client.
+type uncachedReads struct {
+	client.Client
+	reader client.Reader
+}
+
+func (c uncachedReads) Get(ctx context.Context, key client.ObjectKey, obj client.Object, opts ...client.GetOption) error {
+	return c.reader.Get(ctx, key, obj, opts...)
+}
+
+// Drift returns why the secret differs from what would be written with the JWK that it holds,
+// or an empty string if it is intact or the instance has no secret.
+func (s secretsClient) Drift() (_ string, err error) {
+	defer s.span("secrets.Drift")(&err)
+
+	secret, jwk, drift, err := s.currentJwk()
+	if err != nil || drift != "" || jwk == nil {
+		return drift, err
+	}
+
+	formats, err := clients.GetSecretFormats(s.Instance, s.Reconciler.Config)
+	if err != nil {
+		return "", fmt.Errorf("resolving secret formats: %w", err)
+	}
+
+	expected, err := secretData(s.Instance, *jwk, s.Reconciler.Config, secrets.KeyFormats{
+		Formats:  formats,
+		Existing: secret.Data,
+	})
+	if err != nil {
+		return "", fmt.Errorf("creating secret data: %w", err)
+	}
+
+	for _, k := range slices.Sorted(maps.Keys(expected)) {
+		if actual, found := secret.Data[k]; !found || string(actual) != expected[k] {
+			return fmt.Sprintf("key %q in secret is missing or changed", k), nil
+		}
+	}
+	for _, k := range slices.Sorted(maps.Keys(secret.Data)) {
+		if _, found := expected[k]; !found {
+			return fmt.Sprintf("key %q in secret is unexpected", k), nil
+		}
+	}
+	return "", nil
+}
+
+// KeyDrift is like Drift, but only checks that the secret holds a valid JWK.
+// Other changes to the secret are expected when the instance has changed, and are overwritten when the secret is written.
+func (s secretsClient) KeyDrift() (_ string, err error) {
+	defer s.span("secrets.KeyDrift")(&err)
+
+	_, _, drift, err := s.currentJwk()
+	return drift, err
+}
+
+// currentJwk returns the secret and the JWK that it holds, or why it does not hold one.
+// A JWK that is not among the key IDs registered for the instance is drift, as the client cannot authenticate with it.
+// Nothing is returned for instances that have not been registered with keys yet, as their secret is not expected to exist.
+func (s secretsClient) currentJwk() (*corev1.Secret, *jose.JSONWebKey, string, error) {
+	if s.secretName == "" || len(s.Instance.GetStatus().KeyIDs) == 0 {
+		return nil, nil, "", nil
+	}
+
+	// the secret is read from the cache, which holds the secrets managed by digdirator, see CacheOptions
+	key := client.ObjectKey{Name: s.secretName, Namespace: s.Instance.GetNamespace()}
+	secret := &corev1.Secret{}
+	if err := s.Client.Get(s.Ctx, key, secret); err != nil {
+		if errors.IsNotFound(err) {
+			return nil, nil, "secret is deleted", nil
+		}
+		return nil, nil, "", fmt.Errorf("getting secret %q: %w", s.secretName, err)
+	}
+
+	jwk, err := crypto.GetJwkFromSecret(*secret, clients.GetSecretJwkKey(s.Instance))
+	if err != nil {
+		return secret, nil, "secret does not hold a valid JWK", nil
+	}
+	if !slices.Contains(s.Instance.GetStatus().KeyIDs, jwk.KeyID) {
+		return secret, nil, fmt.Sprintf("key %q in secret is not registered in DigDir", jwk.KeyID), nil
+	}
+	return secret, jwk, "", nil
+}
+
+// GetManaged lists the managed secrets of the instance, sorted by whether they are used by the pods and replica sets of the application.
+func (s secretsClient) GetManaged(reader client.Reader) (_ kubernetes.SecretLists, err error) {
+	defer s.span("secrets.GetManaged")(&err)
+
 	objectKey := client.ObjectKey{
 		Name:      s.Instance.GetName(),
 		Namespace: s.Instance.GetNamespace(),
 	}
 	secretLabels := clients.MakeLabels(s.Instance)
-	return kubernetes.ListSecretsForApplication(s.Ctx, s.Reader, objectKey, secretLabels)
+	return kubernetes.ListSecretsForApplication(s.Ctx, reader, objectKey, secretLabels)
 }
 
-func (s secretsClient) DeleteUnused(unused corev1.SecretList) error {
-	for i, oldSecret := range unused.Items {
-		if oldSecret.Name == s.secretName {
-			continue
-		}
+// Pruning returns the managed secrets sorted into those to keep and those to prune, see secrets.Prune.
+// The secrets, pods and replica sets are listed with the given reader; the cached client suffices to check whether there is
+// anything to prune, while the uncached reader must be used before keys are revoked and secrets are deleted.
+func (s secretsClient) Pruning(reader client.Reader) (secrets.Pruning, error) {
+	if s.secretName == "" {
+		return secrets.Prune(kubernetes.SecretLists{}, "", 0, time.Now()), nil
+	}
+
+	managed, err := s.GetManaged(reader)
+	if err != nil {
+		return secrets.Pruning{}, err
+	}
+	return secrets.Prune(managed, s.secretName, s.Reconciler.Config.DigDir.Common.MaxKeyOverlap, time.Now()), nil
+}
+
+// DeleteUnused deletes the unused secrets. Their keys must be revoked first, see Reconciler.revokeKeys.
+// Expired secrets are kept until they are no longer used, as pods that reference them cannot start without them.
+func (s secretsClient) DeleteUnused(pruning secrets.Pruning) (err error) {
+	defer s.span("secrets.DeleteUnused")(&err)
 
-		s.log.V(4).Info(fmt.Sprintf("deleting unused secret %q...", oldSecret.Name))
-		if err := s.Client.Delete(s.Ctx, &unused.Items[i]); err != nil {
+	for _, secret := range pruning.Unused {
+		s.log.V(4).Info(fmt.Sprintf("deleting unused secret %q...", secret.Name))
+		if err := s.Client.Delete(s.Ctx, &secret); err != nil {
 			return fmt.Errorf("deleting unused secret: %w", err)
 		}
-		s.log.Info(fmt.Sprintf("deleted unused secret %q", oldSecret.Name))
+		s.log.Info(fmt.Sprintf("deleted unused secret %q", secret.Name))
+		s.reportEvent(s.Transaction, corev1.EventTypeNormal, EventDeletedSecret, fmt.Sprintf("Deleted secret %q that is no longer used", secret.Name))
 	}
 	return nil
 }
 
-func secretData(instance clients.Instance, jwk jose.JSONWebKey, config *config.Config) (map[string]string, error) {
+func secretData(instance clients.Instance, jwk jose.JSONWebKey, config *config.Config, formats secrets.KeyFormats) (map[string]string, error) {
 	var stringData map[string]string
+	var keys secrets.Keys
 	var err error
 
 	switch v := instance.(type) {
 	case *nais_io_v1.IDPortenClient:
-		stringData, err = secrets.IDPortenClientSecretData(v, jwk, config)
+		stringData, err = secrets.IDPortenClientSecretData(v, jwk, config, formats)
+		keys = secrets.IDPortenKeys
 	case *nais_io_v1.MaskinportenClient:
-		stringData, err = secrets.MaskinportenClientSecretData(v, jwk, config)
+		stringData, err = secrets.MaskinportenClientSecretData(v, jwk, config, formats)
+		keys = secrets.MaskinportenKeys
+	case *digdirv1alpha1.AnsattportenClient:
+		stringData, err = secrets.AnsattportenClientSecretData(v, jwk, config, formats)
+		keys = secrets.AnsattportenKeys
 	}
 
 	if err != nil {
 		return nil, err
 	}
 
-	return stringData, nil
+	return secrets.ApplyTemplates(config.SecretTemplates.For(instance.GetNamespace()), keys, jwk, stringData)
 }
@@ -11,6 +11,7 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	"github.com/nais/digdirator/pkg/clients"
+	"github.com/nais/digdirator/pkg/crypto"
 )
 
 func ResourceExists(cli client.Client, key client.ObjectKey, instance client.Object) func() bool {
@@ -59,3 +60,36 @@ func AssertSecretExists(t *testing.T, cli client.Client, name string, namespace
 
 	assertions(a, instance)
 }
+
+func AssertConfigMapExists(t *testing.T, cli client.Client, name string, namespace string, instance clients.Instance, assertions func(*corev1.ConfigMap, clients.Instance)) {
+	key := client.ObjectKey{
+		Namespace: namespace,
+		Name:      name,
+	}
+	a := &corev1.ConfigMap{}
+	err := cli.Get(context.Background(), key, a)
+	assert.NoError(t, err)
+
+	assert.True(t, ContainsOwnerRef(a.GetOwnerReferences(), instance), "ConfigMap should contain ownerReference")
+
+	assertions(a, instance)
+}
+
+// AssertKeyRegistered asserts that the JWK in the secret is registered for the instance, and returns its key ID.
+func AssertKeyRegistered(t *testing.T, cli client.Client, name string, namespace string, instance clients.Instance) string {
+	key := client.ObjectKey{
+		Namespace: namespace,
+		Name:      name,
+	}
+	a := &corev1.Secret{}
+	err := cli.Get(context.Background(), key, a)
+	assert.NoError(t, err)
+
+	jwk, err := crypto.GetJwkFromSecret(*a, clients.GetSecretJwkKey(instance))
+	if !assert.NoError(t, err, "Secret should contain a JWK") {
+		return ""
+	}
+
+	assert.Contains(t, instance.GetStatus().KeyIDs, jwk.KeyID, "JWK in Secret should be registered")
+	return jwk.KeyID
+}
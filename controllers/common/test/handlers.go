@@ -2,6 +2,7 @@ package test
 
 import (
 	"fmt"
+	"io"
 	"net/http"
 	"os"
 	"path"
@@ -47,7 +48,7 @@ func handler(clientID, orgno, clientType string) http.HandlerFunc {
                 "token_endpoint": "http://%[1]s/token"
 			}`, r.Host))
 		case matchesPath(r, "/token"):
-			respond(w, `{ "access_token": "token" }`)
+			respond(w, `{ "access_token": "token", "expires_in": 120 }`)
 		case matchesPath(r, "/api/v1/delegationsources"):
 			respondFile(w, "delegationsources.json")
 		case matchesPath(r, "/api/v1/clients"):
@@ -74,11 +75,13 @@ func handler(clientID, orgno, clientType string) http.HandlerFunc {
 			}
 		// POST (register) JWKS for client
 		case matchesMethodPath(r, http.MethodPost, "/api/v1/clients/"+clientID+"/jwks"):
-			if clientExists {
-				respondFile(w, "register-jwks-response-exists.json")
+			// DigDir registers the posted keys with their key IDs
+			jwks, err := io.ReadAll(r.Body)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
 				return
 			}
-			respondFile(w, "register-jwks-response.json")
+			respond(w, string(jwks))
 			clientExists = true
 		case matchesPath(r, "/api/v1/scopes"):
 			switch r.Method {
@@ -22,6 +22,7 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/envtest"
 	ctrlmetricsserver "sigs.k8s.io/controller-runtime/pkg/metrics/server"
 
+	digdirv1alpha1 "github.com/nais/digdirator/api/v1alpha1"
 	"github.com/nais/digdirator/controllers/common"
 	"github.com/nais/digdirator/controllers/idportenclient"
 	"github.com/nais/digdirator/controllers/maskinportenclient"
@@ -45,7 +46,7 @@ func SetupTestEnv(handler http.HandlerFunc) (*envtest.Environment, *client.Clien
 
 	crdPath := crd.YamlDirectory()
 	testEnv := &envtest.Environment{
-		CRDDirectoryPaths: []string{crdPath},
+		CRDDirectoryPaths: []string{crdPath, filepath.Join("..", "..", "charts", "crds")},
 	}
 
 	// Retrieve the first found binary directory to allow running tests from IDEs
@@ -63,10 +64,21 @@ func SetupTestEnv(handler http.HandlerFunc) (*envtest.Environment, *client.Clien
 		return nil, nil, fmt.Errorf("adding nais.io v1 to scheme: %v", err)
 	}
 
+	err = digdirv1alpha1.AddToScheme(scheme.Scheme)
+	if err != nil {
+		return nil, nil, fmt.Errorf("adding digdir.nais.io v1alpha1 to scheme: %v", err)
+	}
+
 	// +kubebuilder:scaffold:scheme
 
+	cacheOptions, err := common.CacheOptions()
+	if err != nil {
+		return nil, nil, fmt.Errorf("creating cache options: %v", err)
+	}
+
 	mgr, err := ctrl.NewManager(cfg, ctrl.Options{
 		Scheme: scheme.Scheme,
+		Cache:  cacheOptions,
 		Metrics: ctrlmetricsserver.Options{
 			BindAddress: "0",
 		},
@@ -3,17 +3,57 @@ package common
 import (
 	"context"
 
+	"go.opentelemetry.io/otel/attribute"
+
 	"github.com/nais/digdirator/pkg/clients"
+	"github.com/nais/digdirator/pkg/digdir"
+	"github.com/nais/digdirator/pkg/tracing"
 )
 
 type Transaction struct {
 	Ctx      context.Context
 	Instance clients.Instance
+	// DigDirClient authenticates as the admin identity that the instance is registered with.
+	DigDirClient digdir.Client
+	// Plan records the changes to DigDir in dry-run mode. It is nil otherwise.
+	Plan *digdir.Plan
 }
 
-func NewTransaction(ctx context.Context, instance clients.Instance) *Transaction {
+func NewTransaction(ctx context.Context, instance clients.Instance, digdirClient digdir.Client) *Transaction {
 	return &Transaction{
-		Ctx:      ctx,
-		Instance: instance,
+		Ctx:          ctx,
+		Instance:     instance,
+		DigDirClient: digdirClient,
+	}
+}
+
+// WithPlan enables dry-run mode for the transaction.
+func (t *Transaction) WithPlan(plan *digdir.Plan) *Transaction {
+	t.Ctx = digdir.WithPlan(t.Ctx, plan)
+	t.Plan = plan
+	return t
+}
+
+func (t *Transaction) DryRun() bool {
+	return t.Plan != nil
+}
+
+// span starts a span that the transaction's context is bound to until the returned function is called.
+// The returned function ends the span, and is meant to be deferred with a pointer to a named error result.
+func (t *Transaction) span(name string, attrs ...attribute.KeyValue) func(err *error) {
+	parent := t.Ctx
+	ctx, span := tracing.Start(parent, name, attrs...)
+	t.Ctx = ctx
+	return func(err *error) {
+		t.Ctx = parent
+		tracing.End(span, err)
+	}
+}
+
+// observe records the given metric for the instance, unless the transaction is a dry run.
+func (t *Transaction) observe(metric func(instance clients.Instance)) {
+	if t.DryRun() {
+		return
 	}
+	metric(t.Instance)
 }
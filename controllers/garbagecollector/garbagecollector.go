@@ -0,0 +1,337 @@
+package garbagecollector
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"time"
+
+	naisiov1 "github.com/nais/liberator/pkg/apis/nais.io/v1"
+	"github.com/nais/liberator/pkg/kubernetes"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	digdirv1alpha1 "github.com/nais/digdirator/api/v1alpha1"
+	"github.com/nais/digdirator/controllers/common"
+	"github.com/nais/digdirator/pkg/clients"
+	"github.com/nais/digdirator/pkg/digdir"
+	"github.com/nais/digdirator/pkg/digdir/types"
+	"github.com/nais/digdirator/pkg/metrics"
+)
+
+// +kubebuilder:rbac:groups=nais.io,resources=IDPortenClients;MaskinportenClients,verbs=get;list;watch
+// +kubebuilder:rbac:groups=digdir.nais.io,resources=ansattportenclients;maskinportenscopes,verbs=get;list;watch
+// +kubebuilder:rbac:groups=*,resources=events,verbs=get;list;watch;create;update
+
+// GarbageCollector periodically looks for orphans, i.e. clients in DigDir that were registered for a resource in this cluster
+// that no longer exists. This happens if the resource is removed without its finalizer running, e.g. when a namespace is force-deleted.
+// Scopes that are no longer exposed by the resource they were registered for are also orphans, see collectScopes.
+type GarbageCollector struct {
+	common.Reconciler
+	// firstSeen holds the time that each currently orphaned client was first detected, keyed by client ID.
+	firstSeen map[string]time.Time
+	// scopesFirstSeen holds the time that each currently orphaned scope was first detected, keyed by scope name.
+	scopesFirstSeen map[string]time.Time
+	now             func() time.Time
+}
+
+type orphan struct {
+	registration types.ClientRegistration
+	instance     clients.Instance
+	firstSeen    time.Time
+	preserved    bool
+	// digdirClient authenticates as the admin identity that the client is registered with.
+	digdirClient digdir.Client
+}
+
+func NewGarbageCollector(reconciler common.Reconciler) *GarbageCollector {
+	return &GarbageCollector{
+		Reconciler:      reconciler,
+		firstSeen:       make(map[string]time.Time),
+		scopesFirstSeen: make(map[string]time.Time),
+		now:             time.Now,
+	}
+}
+
+func (g *GarbageCollector) SetupWithManager(mgr ctrl.Manager) error {
+	return mgr.Add(g)
+}
+
+// NeedLeaderElection ensures that only the leader deletes clients.
+func (g *GarbageCollector) NeedLeaderElection() bool {
+	return true
+}
+
+func (g *GarbageCollector) Start(ctx context.Context) error {
+	log := ctrl.LoggerFrom(ctx).WithValues("subsystem", "garbage-collector")
+	ctx = ctrl.LoggerInto(ctx, log)
+
+	ticker := time.NewTicker(g.Config.GarbageCollector.Interval)
+	defer ticker.Stop()
+
+	for {
+		if err := g.Collect(ctx); err != nil {
+			log.Error(err, "collecting orphans")
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// Collect reports all orphaned clients and scopes, and removes those that have been orphaned for longer than their grace period if deletion is enabled.
+// Scopes are collected even if collecting clients fails, and vice versa.
+func (g *GarbageCollector) Collect(ctx context.Context) error {
+	var errs []error
+	if err := g.collectClients(ctx); err != nil {
+		errs = append(errs, err)
+	}
+
+	if g.Config.Features.Maskinporten {
+		if err := g.collectScopes(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("collecting orphaned scopes: %w", err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// collectClients reports all orphaned clients of all admin identities, and deletes those that have been orphaned for longer than
+// the grace period if deletion is enabled. Clients that were kept in DigDir due to the preserve annotation are reported, but never deleted.
+func (g *GarbageCollector) collectClients(ctx context.Context) error {
+	log := ctrl.LoggerFrom(ctx)
+	cfg := g.Config.GarbageCollector
+
+	registrations, owners, err := g.listClients(ctx)
+	if err != nil {
+		return err
+	}
+
+	preserved, err := g.preservedClients(ctx, registrations)
+	if err != nil {
+		return fmt.Errorf("listing preserved clients: %w", err)
+	}
+
+	orphans, err := g.orphans(ctx, registrations, owners, preserved)
+	if err != nil {
+		return err
+	}
+
+	instances := make([]clients.Instance, 0, len(orphans))
+	for _, o := range orphans {
+		instances = append(instances, o.instance)
+	}
+	metrics.SetOrphans(instances)
+
+	if len(orphans) > 0 {
+		log.Info(fmt.Sprintf("found %d orphaned client(s) in DigDir", len(orphans)))
+	}
+
+	if !cfg.Delete {
+		return nil
+	}
+
+	now := g.now()
+	for _, o := range orphans {
+		if o.preserved || now.Sub(o.firstSeen) < cfg.GracePeriod {
+			continue
+		}
+
+		if err := g.delete(ctx, o); err != nil {
+			log.Error(err, "deleting orphaned client", "client_id", o.registration.ClientID)
+		}
+	}
+
+	return nil
+}
+
+// listClients returns the clients of all admin identities, along with the client for the identity that each is registered with,
+// keyed by client ID.
+func (g *GarbageCollector) listClients(ctx context.Context) ([]types.ClientRegistration, map[string]digdir.Client, error) {
+	registrations := make([]types.ClientRegistration, 0)
+	owners := make(map[string]digdir.Client)
+
+	for _, name := range slices.Sorted(maps.Keys(g.Identities)) {
+		digdirClient := g.Identities[name]
+
+		listed, err := digdirClient.List(ctx)
+		if err != nil {
+			return nil, nil, fmt.Errorf("listing clients for identity %q: %w", name, err)
+		}
+
+		for _, registration := range listed {
+			owners[registration.ClientID] = digdirClient
+		}
+		registrations = append(registrations, listed...)
+	}
+
+	return registrations, owners, nil
+}
+
+// orphans returns the orphaned clients in the given registrations, and reports those that haven't been seen before.
+func (g *GarbageCollector) orphans(ctx context.Context, registrations []types.ClientRegistration, owners map[string]digdir.Client, preserved map[string]string) ([]orphan, error) {
+	now := g.now()
+	firstSeen := make(map[string]time.Time)
+	orphans := make([]orphan, 0)
+
+	for _, registration := range registrations {
+		instance, err := g.orphanedInstance(ctx, g.Client, registration)
+		if err != nil {
+			return nil, err
+		}
+		if instance == nil {
+			continue
+		}
+
+		o := orphan{
+			registration: registration,
+			instance:     instance,
+			firstSeen:    now,
+			digdirClient: owners[registration.ClientID],
+		}
+		_, o.preserved = preserved[registration.ClientID]
+
+		if seen, ok := g.firstSeen[registration.ClientID]; ok {
+			o.firstSeen = seen
+		} else {
+			g.reportOrphan(ctx, o)
+		}
+
+		firstSeen[registration.ClientID] = o.firstSeen
+		orphans = append(orphans, o)
+	}
+
+	g.firstSeen = firstSeen
+	return orphans, nil
+}
+
+// orphanedInstance returns an instance referencing the resource that the registration was created for, if the registration is orphaned.
+// Registrations that belong to other clusters, or that are matched by an existing resource, return nil.
+func (g *GarbageCollector) orphanedInstance(ctx context.Context, reader client.Reader, registration types.ClientRegistration) (clients.Instance, error) {
+	key, ok := clients.OwnerKey(registration, g.Config.ClusterName)
+	if !ok {
+		return nil, nil
+	}
+
+	instance, ok := clients.NewInstanceFor(registration.IntegrationType)
+	if !ok || !g.featureEnabled(instance) {
+		return nil, nil
+	}
+
+	err := reader.Get(ctx, key, instance)
+	if apierrors.IsNotFound(err) {
+		instance.SetName(key.Name)
+		instance.SetNamespace(key.Namespace)
+		return instance, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("getting resource %q for client %q: %w", key, registration.ClientID, err)
+	}
+
+	// the resource exists, but is bound to another client
+	if clientID := instance.GetStatus().ClientID; clientID != "" && clientID != registration.ClientID {
+		return instance, nil
+	}
+
+	return nil, nil
+}
+
+func (g *GarbageCollector) delete(ctx context.Context, o orphan) error {
+	log := ctrl.LoggerFrom(ctx).WithValues("client_id", o.registration.ClientID, "resource", client.ObjectKeyFromObject(o.instance), "identity", o.digdirClient.Identity)
+
+	// the cached client may be lagging behind, so we verify against the API server before deleting
+	instance, err := g.orphanedInstance(ctx, g.Reader, o.registration)
+	if err != nil {
+		return err
+	}
+	if instance == nil {
+		log.Info("client is no longer orphaned, skipping deletion")
+		delete(g.firstSeen, o.registration.ClientID)
+		return nil
+	}
+
+	if g.Config.DryRun {
+		log.Info("dry run: would delete orphaned client from DigDir")
+		return nil
+	}
+
+	if err := o.digdirClient.Delete(ctx, o.registration.ClientID); err != nil {
+		return err
+	}
+
+	delete(g.firstSeen, o.registration.ClientID)
+	metrics.IncOrphansDeleted(o.instance)
+	log.Info("deleted orphaned client from DigDir")
+
+	message := fmt.Sprintf("Deleted orphaned client %q from DigDir after %s", o.registration.ClientID, g.now().Sub(o.firstSeen).Round(time.Second))
+	if len(g.Identities) > 1 {
+		message = fmt.Sprintf("%s (identity: %s)", message, o.digdirClient.Identity)
+	}
+	g.Recorder.Eventf(o.instance, nil, corev1.EventTypeNormal, common.EventDeletedOrphanInDigDir, common.EventDeletedOrphanInDigDir, "%s", message)
+	return nil
+}
+
+func (g *GarbageCollector) reportOrphan(ctx context.Context, o orphan) {
+	log := ctrl.LoggerFrom(ctx).WithValues("client_id", o.registration.ClientID, "resource", client.ObjectKeyFromObject(o.instance), "identity", o.digdirClient.Identity)
+	resourceName := kubernetes.UniformResourceName(o.instance, g.Config.ClusterName)
+
+	if o.preserved {
+		log.V(4).Info("found orphaned client that was preserved on deletion")
+		g.Recorder.Eventf(o.instance, nil, corev1.EventTypeNormal, common.EventOrphanedInDigDir, common.EventOrphanedInDigDir,
+			"Client %q in DigDir was preserved when %q was deleted", o.registration.ClientID, resourceName)
+		return
+	}
+
+	log.Info("found orphaned client")
+	g.Recorder.Eventf(o.instance, nil, corev1.EventTypeWarning, common.EventOrphanedInDigDir, common.EventOrphanedInDigDir,
+		"Client %q in DigDir has no matching resource for %q", o.registration.ClientID, resourceName)
+}
+
+// preservedClients returns the clients that were preserved on deletion, and forgets those that no longer exist in DigDir.
+func (g *GarbageCollector) preservedClients(ctx context.Context, registrations []types.ClientRegistration) (map[string]string, error) {
+	preserved := g.PreservedClients()
+	if !preserved.Enabled() {
+		return map[string]string{}, nil
+	}
+
+	clientIDs, err := preserved.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	existing := make(map[string]bool)
+	for _, registration := range registrations {
+		existing[registration.ClientID] = true
+	}
+
+	stale := make([]string, 0)
+	for clientID := range clientIDs {
+		if !existing[clientID] {
+			stale = append(stale, clientID)
+		}
+	}
+
+	if err := preserved.Remove(ctx, stale...); err != nil {
+		ctrl.LoggerFrom(ctx).Error(err, "removing deleted clients from preserved clients")
+	}
+
+	return clientIDs, nil
+}
+
+func (g *GarbageCollector) featureEnabled(instance clients.Instance) bool {
+	switch instance.(type) {
+	case *naisiov1.IDPortenClient:
+		return g.Config.Features.IDPorten
+	case *naisiov1.MaskinportenClient:
+		return g.Config.Features.Maskinporten
+	case *digdirv1alpha1.AnsattportenClient:
+		return g.Config.Features.Ansattporten
+	}
+	return false
+}
@@ -0,0 +1,458 @@
+package garbagecollector_test
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-jose/go-jose/v4"
+	naisiov1 "github.com/nais/liberator/pkg/apis/nais.io/v1"
+	"github.com/nais/liberator/pkg/oauth"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/events"
+	"k8s.io/utils/ptr"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	fakeclient "sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+
+	digdirv1alpha1 "github.com/nais/digdirator/api/v1alpha1"
+	"github.com/nais/digdirator/controllers/common"
+	"github.com/nais/digdirator/controllers/garbagecollector"
+	"github.com/nais/digdirator/pkg/config"
+	"github.com/nais/digdirator/pkg/crypto"
+	"github.com/nais/digdirator/pkg/digdir"
+	"github.com/nais/digdirator/pkg/digdir/fake"
+	"github.com/nais/digdirator/pkg/digdir/types"
+	"github.com/nais/digdirator/pkg/metrics"
+)
+
+const (
+	clusterName    = "test-cluster"
+	namespace      = "test-namespace"
+	sisterAdmin    = "sister-admin"
+	sisterOrgno    = "999999999"
+	sisterIdentity = "sister"
+)
+
+type testEnv struct {
+	server     *fake.Server
+	digdir     digdir.Client
+	identities []digdir.Client
+	k8s        client.Client
+	recorder   *events.FakeRecorder
+	cfg        *config.Config
+	clientIDs  map[string]string
+}
+
+func TestCollect(t *testing.T) {
+	t.Run("reports orphans without deleting them", func(t *testing.T) {
+		env := setup(t)
+
+		gc := env.garbageCollector(config.GarbageCollector{})
+		require.NoError(t, gc.Collect(t.Context()))
+
+		assert.Len(t, env.server.Clients(), 6)
+		assert.Equal(t, float64(1), testutil.ToFloat64(metrics.IDPortenClientsOrphanedTotal))
+		assert.Equal(t, float64(2), testutil.ToFloat64(metrics.MaskinportenClientsOrphanedTotal))
+
+		reported := env.events()
+		assert.Len(t, reported, 3)
+		assert.Contains(t, reported, "Warning "+common.EventOrphanedInDigDir+` Client "`+env.clientIDs["deleted"]+`" in DigDir has no matching resource for "test-cluster:test-namespace:deleted"`)
+		assert.Contains(t, reported, "Warning "+common.EventOrphanedInDigDir+` Client "`+env.clientIDs["duplicate"]+`" in DigDir has no matching resource for "test-cluster:test-namespace:existing-maskinporten"`)
+		assert.Contains(t, reported, "Normal "+common.EventOrphanedInDigDir+` Client "`+env.clientIDs["preserved"]+`" in DigDir was preserved when "test-cluster:test-namespace:preserved" was deleted`)
+
+		// orphans are only reported once
+		require.NoError(t, gc.Collect(t.Context()))
+		assert.Empty(t, env.events())
+	})
+
+	t.Run("does not delete orphans within grace period", func(t *testing.T) {
+		env := setup(t)
+
+		gc := env.garbageCollector(config.GarbageCollector{Delete: true, GracePeriod: time.Hour})
+		require.NoError(t, gc.Collect(t.Context()))
+		require.NoError(t, gc.Collect(t.Context()))
+
+		assert.Len(t, env.server.Clients(), 6)
+	})
+
+	t.Run("deletes orphans after grace period", func(t *testing.T) {
+		env := setup(t)
+
+		gc := env.garbageCollector(config.GarbageCollector{Delete: true})
+		require.NoError(t, gc.Collect(t.Context()))
+
+		remaining := make([]string, 0)
+		for _, c := range env.server.Clients() {
+			remaining = append(remaining, c.ClientID)
+		}
+		assert.ElementsMatch(t, []string{
+			env.clientIDs["existing-idporten"],
+			env.clientIDs["existing-maskinporten"],
+			env.clientIDs["other-cluster"],
+			env.clientIDs["preserved"],
+		}, remaining)
+
+		reported := env.events()
+		assert.Contains(t, reported, "Normal "+common.EventDeletedOrphanInDigDir+` Deleted orphaned client "`+env.clientIDs["deleted"]+`" from DigDir after 0s`)
+		assert.Contains(t, reported, "Normal "+common.EventDeletedOrphanInDigDir+` Deleted orphaned client "`+env.clientIDs["duplicate"]+`" from DigDir after 0s`)
+	})
+
+	t.Run("does not delete orphans in dry-run mode", func(t *testing.T) {
+		env := setup(t)
+		env.cfg.DryRun = true
+
+		gc := env.garbageCollector(config.GarbageCollector{Delete: true})
+		require.NoError(t, gc.Collect(t.Context()))
+
+		assert.Len(t, env.server.Clients(), 6)
+	})
+
+	t.Run("deletes orphans of other identities with the identity that registered them", func(t *testing.T) {
+		env := setup(t)
+		env.cfg.DigDir.Identities = map[string]config.Identity{
+			sisterIdentity: {Admin: config.Admin{ClientID: sisterAdmin}},
+		}
+
+		sister, err := env.digdir.ForIdentity(sisterIdentity, env.digdir.Signer)
+		require.NoError(t, err)
+		env.identities = append(env.identities, sister)
+
+		registration, err := sister.Register(t.Context(), types.ClientRegistration{
+			ClientName:      "sister",
+			Description:     "test-cluster:test-namespace:sister",
+			IntegrationType: types.IntegrationTypeMaskinporten,
+		})
+		require.NoError(t, err)
+		assert.Equal(t, sisterOrgno, registration.ClientOrgno)
+
+		gc := env.garbageCollector(config.GarbageCollector{Delete: true})
+		require.NoError(t, gc.Collect(t.Context()))
+
+		for _, c := range env.server.Clients() {
+			assert.NotEqual(t, registration.ClientID, c.ClientID, "orphan of other identity should be deleted")
+		}
+		assert.Contains(t, env.events(), "Normal "+common.EventDeletedOrphanInDigDir+` Deleted orphaned client "`+registration.ClientID+`" from DigDir after 0s (identity: sister)`)
+	})
+
+	t.Run("forgets preserved clients that no longer exist", func(t *testing.T) {
+		env := setup(t)
+		preserved := common.PreservedClients{Client: env.k8s, Reader: env.k8s, Namespace: "digdirator"}
+		require.NoError(t, preserved.Add(t.Context(), "deleted-client-id", "test-cluster:test-namespace:some-app"))
+
+		gc := env.garbageCollector(config.GarbageCollector{})
+		require.NoError(t, gc.Collect(t.Context()))
+
+		clientIDs, err := preserved.List(t.Context())
+		require.NoError(t, err)
+		assert.Equal(t, map[string]string{
+			env.clientIDs["preserved"]: "test-cluster:test-namespace:preserved",
+		}, clientIDs)
+	})
+
+	t.Run("keeps clients with the preserve annotation when the resource is deleted without its finalizer", func(t *testing.T) {
+		env := setup(t)
+		env.register(t, "force-deleted", "test-cluster:test-namespace:force-deleted", types.IntegrationTypeMaskinporten)
+
+		instance := env.reconcile(t, &naisiov1.MaskinportenClient{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        "force-deleted",
+				Namespace:   namespace,
+				Annotations: map[string]string{common.PreserveAnnotation: "true"},
+			},
+			Status: naisiov1.DigdiratorStatus{ClientID: env.clientIDs["force-deleted"]},
+		})
+
+		// e.g. the namespace is force-deleted, or its finalizers are removed
+		instance.SetFinalizers(nil)
+		require.NoError(t, env.k8s.Update(t.Context(), instance))
+		require.NoError(t, env.k8s.Delete(t.Context(), instance))
+
+		gc := env.garbageCollector(config.GarbageCollector{Delete: true})
+		require.NoError(t, gc.Collect(t.Context()))
+
+		remaining := make([]string, 0)
+		for _, c := range env.server.Clients() {
+			remaining = append(remaining, c.ClientID)
+		}
+		assert.Contains(t, remaining, env.clientIDs["force-deleted"])
+		assert.Contains(t, env.events(), "Normal "+common.EventOrphanedInDigDir+` Client "`+env.clientIDs["force-deleted"]+`" in DigDir was preserved when "test-cluster:test-namespace:force-deleted" was deleted`)
+	})
+
+	t.Run("forgets preserved clients when the preserve annotation is removed", func(t *testing.T) {
+		env := setup(t)
+
+		env.reconcile(t, &naisiov1.MaskinportenClient{
+			ObjectMeta: metav1.ObjectMeta{Name: "preserved", Namespace: namespace},
+			Status:     naisiov1.DigdiratorStatus{ClientID: env.clientIDs["preserved"]},
+		})
+
+		preserved := common.PreservedClients{Client: env.k8s, Reader: env.k8s, Namespace: "digdirator"}
+		clientIDs, err := preserved.List(t.Context())
+		require.NoError(t, err)
+		assert.Empty(t, clientIDs)
+	})
+}
+
+func setup(t *testing.T) *testEnv {
+	srv := fake.New(fake.Options{})
+	srv.SetOrgno(sisterAdmin, sisterOrgno)
+	httpServer := httptest.NewServer(srv)
+	t.Cleanup(httpServer.Close)
+
+	metadata, err := oauth.NewMetadataOAuth(t.Context(), httpServer.URL+"/.well-known/oauth-authorization-server")
+	require.NoError(t, err)
+
+	cfg := &config.Config{ClusterName: clusterName}
+	cfg.DigDir.Admin.BaseURL = httpServer.URL
+	cfg.DigDir.Admin.ClientID = "admin"
+	cfg.DigDir.Maskinporten.Metadata = *metadata
+	cfg.Features.IDPorten = true
+	cfg.Features.Maskinporten = true
+	cfg.GarbageCollector.Namespace = "digdirator"
+
+	privateKey, err := crypto.GenerateRSAKey()
+	require.NoError(t, err)
+	signer, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.RS256, Key: privateKey}, nil)
+	require.NoError(t, err)
+
+	digdirClient, err := digdir.NewClient(cfg, httpServer.Client(), signer)
+	require.NoError(t, err)
+
+	env := &testEnv{
+		server:    srv,
+		digdir:    digdirClient,
+		recorder:  events.NewFakeRecorder(10),
+		cfg:       cfg,
+		clientIDs: make(map[string]string),
+	}
+
+	env.register(t, "existing-idporten", "test-cluster:test-namespace:existing-idporten", types.IntegrationTypeIDPorten)
+	env.register(t, "existing-maskinporten", "test-cluster:test-namespace:existing-maskinporten", types.IntegrationTypeMaskinporten)
+	env.register(t, "duplicate", "test-cluster:test-namespace:existing-maskinporten", types.IntegrationTypeMaskinporten)
+	env.register(t, "deleted", "test-cluster:test-namespace:deleted", types.IntegrationTypeIDPorten)
+	env.register(t, "other-cluster", "other-cluster:test-namespace:deleted", types.IntegrationTypeIDPorten)
+	env.register(t, "preserved", "test-cluster:test-namespace:preserved", types.IntegrationTypeMaskinporten)
+
+	scheme := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(scheme))
+	require.NoError(t, naisiov1.AddToScheme(scheme))
+	require.NoError(t, digdirv1alpha1.AddToScheme(scheme))
+
+	env.k8s = fakeclient.NewClientBuilder().
+		WithScheme(scheme).
+		WithObjects(
+			&naisiov1.IDPortenClient{
+				ObjectMeta: metav1.ObjectMeta{Name: "existing-idporten", Namespace: namespace},
+				Status:     naisiov1.DigdiratorStatus{ClientID: env.clientIDs["existing-idporten"]},
+			},
+			&naisiov1.MaskinportenClient{
+				ObjectMeta: metav1.ObjectMeta{Name: "existing-maskinporten", Namespace: namespace},
+				Status:     naisiov1.DigdiratorStatus{ClientID: env.clientIDs["existing-maskinporten"]},
+			},
+			&corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Name: common.PreservedClientsConfigMapName, Namespace: "digdirator"},
+				Data: map[string]string{
+					env.clientIDs["preserved"]: "test-cluster:test-namespace:preserved",
+				},
+			},
+		).
+		Build()
+
+	return env
+}
+
+func (e *testEnv) register(t *testing.T, key, description string, integrationType types.IntegrationType) {
+	payload := types.ClientRegistration{
+		ClientName:      key,
+		Description:     description,
+		IntegrationType: integrationType,
+	}
+	if integrationType == types.IntegrationTypeIDPorten {
+		payload.RedirectURIs = []string{"https://example.com/callback"}
+	}
+
+	registration, err := e.digdir.Register(t.Context(), payload)
+	require.NoError(t, err)
+	e.clientIDs[key] = registration.ClientID
+}
+
+func (e *testEnv) garbageCollector(cfg config.GarbageCollector) *garbagecollector.GarbageCollector {
+	cfg.Enabled = true
+	cfg.Interval = time.Minute
+	cfg.Namespace = e.cfg.GarbageCollector.Namespace
+	e.cfg.GarbageCollector = cfg
+
+	reconciler := common.NewReconciler(e.k8s, e.k8s, e.k8s.Scheme(), e.recorder, e.cfg, e.digdir, e.identities...)
+	return garbagecollector.NewGarbageCollector(reconciler)
+}
+
+// reconcile creates the resource as already up-to-date, so that it is reconciled without changes in DigDir.
+func (e *testEnv) reconcile(t *testing.T, instance *naisiov1.MaskinportenClient) *naisiov1.MaskinportenClient {
+	instance.SetGeneration(1)
+	instance.Status.ObservedGeneration = ptr.To[int64](1)
+	require.NoError(t, e.k8s.Create(t.Context(), instance))
+
+	reconciler := common.NewReconciler(e.k8s, e.k8s, e.k8s.Scheme(), e.recorder, e.cfg, e.digdir, e.identities...)
+	_, err := reconciler.Reconcile(t.Context(), ctrl.Request{NamespacedName: client.ObjectKeyFromObject(instance)}, &naisiov1.MaskinportenClient{})
+	require.NoError(t, err)
+
+	actual := &naisiov1.MaskinportenClient{}
+	require.NoError(t, e.k8s.Get(t.Context(), client.ObjectKeyFromObject(instance), actual))
+	return actual
+}
+
+func (e *testEnv) events() []string {
+	result := make([]string, 0)
+	for {
+		select {
+		case event := <-e.recorder.Events:
+			result = append(result, event)
+		default:
+			return result
+		}
+	}
+}
+
+func TestCollectScopes(t *testing.T) {
+	setupScopes := func(t *testing.T) *testEnv {
+		env := setup(t)
+		env.cfg.DigDir.Maskinporten.Default.ScopePrefix = "nav"
+
+		instance := &naisiov1.MaskinportenClient{}
+		require.NoError(t, env.k8s.Get(t.Context(), client.ObjectKey{Namespace: namespace, Name: "existing-maskinporten"}, instance))
+		instance.Spec.Scopes.ExposedScopes = []naisiov1.ExposedScope{{Product: "product", Name: "exposed", Enabled: true}}
+		require.NoError(t, env.k8s.Update(t.Context(), instance))
+
+		for _, scope := range []types.ScopeRegistration{
+			{Subscope: "product:exposed", Description: "product - test-cluster:test-namespace:existing-maskinporten", Active: true},
+			{Subscope: "product:removed", Description: "product - test-cluster:test-namespace:existing-maskinporten", Active: true},
+			{Subscope: "product:deleted", Description: "product - test-cluster:test-namespace:deleted", Active: true},
+			{Subscope: "product:inactive", Description: "product - test-cluster:test-namespace:deleted", Active: false},
+			{Subscope: "product:other-cluster", Description: "product - other-cluster:test-namespace:deleted", Active: true},
+			{Subscope: "product:manual", Description: "some manually registered scope", Active: true},
+		} {
+			scope.Prefix = "nav"
+			env.server.AddScope(scope)
+		}
+		return env
+	}
+
+	activeScopes := func(env *testEnv) []string {
+		active := make([]string, 0)
+		for _, scope := range env.server.Scopes() {
+			if scope.Active {
+				active = append(active, scope.Name)
+			}
+		}
+		return active
+	}
+
+	t.Run("reports orphaned scopes without deactivating them", func(t *testing.T) {
+		env := setupScopes(t)
+
+		gc := env.garbageCollector(config.GarbageCollector{})
+		require.NoError(t, gc.Collect(t.Context()))
+
+		assert.Len(t, activeScopes(env), 5)
+		assert.Equal(t, float64(2), testutil.ToFloat64(metrics.MaskinportenScopesOrphanedTotal))
+
+		reported := env.events()
+		assert.Contains(t, reported, "Warning "+common.EventOrphanedScopeInDigDir+` Scope "nav:product:removed" in DigDir is active, but no longer exposed by "test-cluster:test-namespace:existing-maskinporten"`)
+		assert.Contains(t, reported, "Warning "+common.EventOrphanedScopeInDigDir+` Scope "nav:product:deleted" in DigDir is active, but no longer exposed by "test-cluster:test-namespace:deleted"`)
+
+		// orphans are only reported once
+		require.NoError(t, gc.Collect(t.Context()))
+		assert.Empty(t, env.events())
+	})
+
+	t.Run("does not deactivate orphaned scopes within grace period", func(t *testing.T) {
+		env := setupScopes(t)
+
+		gc := env.garbageCollector(config.GarbageCollector{Delete: true, ScopeGracePeriod: time.Hour})
+		require.NoError(t, gc.Collect(t.Context()))
+		require.NoError(t, gc.Collect(t.Context()))
+
+		assert.Len(t, activeScopes(env), 5)
+	})
+
+	t.Run("deactivates orphaned scopes after the scope grace period, regardless of the client grace period", func(t *testing.T) {
+		env := setupScopes(t)
+
+		gc := env.garbageCollector(config.GarbageCollector{Delete: true, GracePeriod: time.Hour})
+		require.NoError(t, gc.Collect(t.Context()))
+
+		assert.ElementsMatch(t, []string{"nav:product:exposed", "nav:product:other-cluster", "nav:product:manual"}, activeScopes(env))
+		assert.Len(t, env.server.Clients(), 6, "orphaned clients should be kept within their grace period")
+	})
+
+	t.Run("collects scopes when collecting clients fails", func(t *testing.T) {
+		env := setupScopes(t)
+		env.k8s = interceptor.NewClient(env.k8s.(client.WithWatch), interceptor.Funcs{
+			Get: func(ctx context.Context, c client.WithWatch, key client.ObjectKey, obj client.Object, opts ...client.GetOption) error {
+				if _, ok := obj.(*corev1.ConfigMap); ok {
+					return errors.New("connection refused")
+				}
+				return c.Get(ctx, key, obj, opts...)
+			},
+		})
+
+		gc := env.garbageCollector(config.GarbageCollector{Delete: true})
+		err := gc.Collect(t.Context())
+		require.ErrorContains(t, err, "connection refused")
+
+		assert.ElementsMatch(t, []string{"nav:product:exposed", "nav:product:other-cluster", "nav:product:manual"}, activeScopes(env))
+		assert.Len(t, env.server.Clients(), 6, "orphaned clients should not be deleted when preserved clients cannot be listed")
+	})
+
+	t.Run("deactivates orphaned scopes after grace period", func(t *testing.T) {
+		env := setupScopes(t)
+
+		gc := env.garbageCollector(config.GarbageCollector{Delete: true})
+		require.NoError(t, gc.Collect(t.Context()))
+
+		assert.ElementsMatch(t, []string{"nav:product:exposed", "nav:product:other-cluster", "nav:product:manual"}, activeScopes(env))
+
+		reported := env.events()
+		assert.Contains(t, reported, "Warning "+common.EventDeactivatedScopeInDigDir+` Deactivated orphaned scope "nav:product:removed" after 0s; consumers no longer have access`)
+		assert.Contains(t, reported, "Warning "+common.EventDeactivatedScopeInDigDir+` Deactivated orphaned scope "nav:product:deleted" after 0s; consumers no longer have access`)
+	})
+
+	t.Run("does not deactivate orphaned scopes in dry-run mode", func(t *testing.T) {
+		env := setupScopes(t)
+		env.cfg.DryRun = true
+
+		gc := env.garbageCollector(config.GarbageCollector{Delete: true})
+		require.NoError(t, gc.Collect(t.Context()))
+
+		assert.Len(t, activeScopes(env), 5)
+	})
+	t.Run("ignores scopes managed by a MaskinportenScope", func(t *testing.T) {
+		env := setupScopes(t)
+		env.cfg.Features.MaskinportenScopes = true
+		env.server.AddScope(types.ScopeRegistration{
+			Prefix:      "nav",
+			Subscope:    "product:managed",
+			Description: "product - test-cluster:test-namespace:managed",
+			Active:      true,
+		})
+		require.NoError(t, env.k8s.Create(t.Context(), &digdirv1alpha1.MaskinportenScope{
+			ObjectMeta: metav1.ObjectMeta{Name: "managed", Namespace: namespace},
+			Spec:       digdirv1alpha1.MaskinportenScopeSpec{Product: "product", Name: "managed", Enabled: true},
+		}))
+
+		gc := env.garbageCollector(config.GarbageCollector{Delete: true})
+		require.NoError(t, gc.Collect(t.Context()))
+
+		assert.ElementsMatch(t, []string{"nav:product:exposed", "nav:product:managed", "nav:product:other-cluster", "nav:product:manual"}, activeScopes(env))
+	})
+}
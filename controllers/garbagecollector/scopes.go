@@ -0,0 +1,207 @@
+package garbagecollector
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	naisiov1 "github.com/nais/liberator/pkg/apis/nais.io/v1"
+	"github.com/nais/liberator/pkg/kubernetes"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	digdirv1alpha1 "github.com/nais/digdirator/api/v1alpha1"
+	"github.com/nais/digdirator/controllers/common"
+	"github.com/nais/digdirator/pkg/clients"
+	"github.com/nais/digdirator/pkg/digdir"
+	"github.com/nais/digdirator/pkg/digdir/scopes"
+	"github.com/nais/digdirator/pkg/digdir/types"
+	"github.com/nais/digdirator/pkg/metrics"
+)
+
+type orphanedScope struct {
+	registration types.ScopeRegistration
+	instance     *naisiov1.MaskinportenClient
+	firstSeen    time.Time
+}
+
+func (o orphanedScope) name() string {
+	return fmt.Sprintf("%s:%s", o.registration.Prefix, o.registration.Subscope)
+}
+
+// collectScopes reports active scopes that were registered for a MaskinportenClient in this cluster, but are no longer
+// exposed by it, e.g. after the scope was renamed or removed from the spec, or the resource was removed without its finalizer running.
+// Orphaned scopes are deactivated once they have been orphaned for longer than the scope grace period if deletion is enabled.
+// Deactivation revokes access for all consumers, but is reversible by exposing the scope again.
+func (g *GarbageCollector) collectScopes(ctx context.Context) error {
+	log := ctrl.LoggerFrom(ctx)
+	cfg := g.Config.GarbageCollector
+
+	registrations, err := g.DigDirClient.ListScopes(ctx, digdir.ScopeFilter{})
+	if err != nil {
+		return fmt.Errorf("listing scopes: %w", err)
+	}
+
+	orphans, err := g.orphanedScopes(ctx, registrations)
+	if err != nil {
+		return err
+	}
+
+	metrics.SetOrphanedScopes(len(orphans))
+	if len(orphans) > 0 {
+		log.Info(fmt.Sprintf("found %d orphaned scope(s) in DigDir", len(orphans)))
+	}
+
+	if !cfg.Delete {
+		return nil
+	}
+
+	now := g.now()
+	for _, o := range orphans {
+		if now.Sub(o.firstSeen) < cfg.ScopeGracePeriod {
+			continue
+		}
+
+		if err := g.deactivateScope(ctx, o); err != nil {
+			log.Error(err, "deactivating orphaned scope", "scope", o.name())
+		}
+	}
+
+	return nil
+}
+
+// orphanedScopes returns the orphaned scopes in the given registrations, and reports those that haven't been seen before.
+func (g *GarbageCollector) orphanedScopes(ctx context.Context, registrations []types.ScopeRegistration) ([]orphanedScope, error) {
+	now := g.now()
+	firstSeen := make(map[string]time.Time)
+	orphans := make([]orphanedScope, 0)
+
+	for _, registration := range registrations {
+		instance, err := g.scopeOwner(ctx, g.Client, registration)
+		if err != nil {
+			return nil, err
+		}
+		if instance == nil {
+			continue
+		}
+
+		o := orphanedScope{
+			registration: registration,
+			instance:     instance,
+			firstSeen:    now,
+		}
+
+		if seen, ok := g.scopesFirstSeen[o.name()]; ok {
+			o.firstSeen = seen
+		} else {
+			g.reportOrphanedScope(ctx, o)
+		}
+
+		firstSeen[o.name()] = o.firstSeen
+		orphans = append(orphans, o)
+	}
+
+	g.scopesFirstSeen = firstSeen
+	return orphans, nil
+}
+
+// scopeOwner returns an instance referencing the resource that the scope was registered for, if the scope is orphaned.
+// Inactive scopes, scopes that belong to other clusters, and scopes that are still exposed by an existing resource return nil.
+func (g *GarbageCollector) scopeOwner(ctx context.Context, reader client.Reader, registration types.ScopeRegistration) (*naisiov1.MaskinportenClient, error) {
+	if !registration.Active || registration.Prefix != g.Config.DigDir.Maskinporten.Default.ScopePrefix {
+		return nil, nil
+	}
+
+	key, ok := clients.ScopeOwnerKey(registration, g.Config.ClusterName)
+	if !ok {
+		return nil, nil
+	}
+
+	if g.Config.Features.MaskinportenScopes {
+		managed, err := managedByScope(ctx, reader, key, registration)
+		if err != nil || managed {
+			return nil, err
+		}
+	}
+
+	instance := &naisiov1.MaskinportenClient{}
+	err := reader.Get(ctx, key, instance)
+	if errors.IsNotFound(err) {
+		instance.SetName(key.Name)
+		instance.SetNamespace(key.Namespace)
+		return instance, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("getting resource %q for scope %q: %w", key, registration.Subscope, err)
+	}
+
+	// the finalizer deactivates the exposed scopes of resources that are being deleted
+	if instance.GetDeletionTimestamp() != nil {
+		return nil, nil
+	}
+
+	// scopes that are exposed, but disabled, are deactivated by the reconciler
+	for _, exposed := range instance.Spec.Scopes.ExposedScopes {
+		if scopes.Subscope(exposed) == registration.Subscope {
+			return nil, nil
+		}
+	}
+
+	return instance, nil
+}
+
+// managedByScope returns true if the scope belongs to a MaskinportenScope, which deactivates the scope when it is disabled or deleted.
+func managedByScope(ctx context.Context, reader client.Reader, key client.ObjectKey, registration types.ScopeRegistration) (bool, error) {
+	instance := &digdirv1alpha1.MaskinportenScope{}
+	err := reader.Get(ctx, key, instance)
+	if errors.IsNotFound(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("getting MaskinportenScope %q for scope %q: %w", key, registration.Subscope, err)
+	}
+
+	return scopes.Subscope(instance.ExposedScope()) == registration.Subscope, nil
+}
+
+func (g *GarbageCollector) deactivateScope(ctx context.Context, o orphanedScope) error {
+	log := ctrl.LoggerFrom(ctx).WithValues("scope", o.name(), "resource", client.ObjectKeyFromObject(o.instance))
+
+	// the cached client may be lagging behind, so we verify against the API server before deactivating
+	instance, err := g.scopeOwner(ctx, g.Reader, o.registration)
+	if err != nil {
+		return err
+	}
+	if instance == nil {
+		log.Info("scope is no longer orphaned, skipping deactivation")
+		delete(g.scopesFirstSeen, o.name())
+		return nil
+	}
+
+	if g.Config.DryRun {
+		log.Info("dry run: would deactivate orphaned scope in DigDir")
+		return nil
+	}
+
+	if _, err := g.DigDirClient.DeleteScope(ctx, o.name()); err != nil {
+		return err
+	}
+
+	delete(g.scopesFirstSeen, o.name())
+	metrics.IncScopesDeleted(o.instance)
+	log.Info("deactivated orphaned scope in DigDir")
+
+	g.Recorder.Eventf(o.instance, nil, corev1.EventTypeWarning, common.EventDeactivatedScopeInDigDir, common.EventDeactivatedScopeInDigDir,
+		"Deactivated orphaned scope %q after %s; consumers no longer have access", o.name(), g.now().Sub(o.firstSeen).Round(time.Second))
+	return nil
+}
+
+func (g *GarbageCollector) reportOrphanedScope(ctx context.Context, o orphanedScope) {
+	resourceName := kubernetes.UniformResourceName(o.instance, g.Config.ClusterName)
+	ctrl.LoggerFrom(ctx).WithValues("scope", o.name(), "resource", client.ObjectKeyFromObject(o.instance)).Info("found orphaned scope")
+
+	g.Recorder.Eventf(o.instance, nil, corev1.EventTypeWarning, common.EventOrphanedScopeInDigDir, common.EventOrphanedScopeInDigDir,
+		"Scope %q in DigDir is active, but no longer exposed by %q", o.name(), resourceName)
+}
@@ -4,9 +4,14 @@ import (
 	"context"
 
 	"github.com/nais/digdirator/controllers/common"
+	"github.com/nais/digdirator/pkg/clients"
 	nais_io_v1 "github.com/nais/liberator/pkg/apis/nais.io/v1"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
 	"sigs.k8s.io/controller-runtime/pkg/predicate"
 )
 
@@ -28,11 +33,18 @@ func (r *IDPortenReconciler) Reconcile(ctx context.Context, req ctrl.Request) (c
 
 func (r *IDPortenReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	return ctrl.NewControllerManagedBy(mgr).
-		For(&nais_io_v1.IDPortenClient{}).
-		WithEventFilter(predicate.Or[client.Object](
+		For(&nais_io_v1.IDPortenClient{}, builder.WithPredicates(predicate.Or[client.Object](
 			predicate.GenerationChangedPredicate{},
 			predicate.AnnotationChangedPredicate{},
 			predicate.LabelChangedPredicate{},
-		)).
+		))).
+		Owns(&corev1.Secret{}, builder.WithPredicates(common.OwnedSecretPredicate())).
+		Watches(&corev1.Pod{}, common.EnqueueForApplication(mgr.GetClient(), newInstance), builder.WithPredicates(common.RolloutPredicate())).
+		Watches(&appsv1.ReplicaSet{}, common.EnqueueForApplication(mgr.GetClient(), newInstance), builder.WithPredicates(common.RolloutPredicate())).
+		WithOptions(controller.Options{MaxConcurrentReconciles: r.Config.MaxConcurrentReconciles}).
 		Complete(r)
 }
+
+func newInstance() clients.Instance {
+	return &nais_io_v1.IDPortenClient{}
+}
@@ -4,16 +4,19 @@ import (
 	"context"
 	"log/slog"
 	"os"
+	"slices"
 	"testing"
 
 	nais_io_v1 "github.com/nais/liberator/pkg/apis/nais.io/v1"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	corev1 "k8s.io/api/core/v1"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	"github.com/nais/digdirator/controllers/common"
 	"github.com/nais/digdirator/controllers/common/test"
 	"github.com/nais/digdirator/pkg/clients"
+	"github.com/nais/digdirator/pkg/crypto"
 	"github.com/nais/digdirator/pkg/fixtures"
 	"github.com/nais/digdirator/pkg/secrets"
 	// +kubebuilder:scaffold:imports
@@ -68,11 +71,11 @@ func TestIDPortenController(t *testing.T) {
 	assert.Equal(t, common.EventSynchronized, instance.Status.SynchronizationState)
 
 	assert.Equal(t, test.ClientID, instance.Status.ClientID)
-	assert.Contains(t, instance.Status.KeyIDs, "some-keyid")
 	assert.Len(t, instance.Status.KeyIDs, 1)
 
 	secretAssertions := secretAssertions(t)
 	test.AssertSecretExists(t, cli, cfg.SecretName, cfg.NamespaceName, instance, secretAssertions)
+	previousKeyID := test.AssertKeyRegistered(t, cli, cfg.SecretName, cfg.NamespaceName, instance)
 
 	assert.Eventually(t, test.ResourceDoesNotExist(cli, client.ObjectKey{
 		Namespace: cfg.NamespaceName,
@@ -98,8 +101,7 @@ func TestIDPortenController(t *testing.T) {
 
 	assert.Equal(t, test.ClientID, instance.Status.ClientID, "client ID should still match")
 	assert.Len(t, instance.Status.KeyIDs, 2, "should contain two key IDs")
-	assert.Contains(t, instance.Status.KeyIDs, "some-keyid", "previous key should still be valid")
-	assert.Contains(t, instance.Status.KeyIDs, "some-new-keyid", "new key should be valid")
+	assert.Contains(t, instance.Status.KeyIDs, previousKeyID, "previous key should still be valid")
 	assert.NotEqual(t, previousCorrelationID, instance.Status.CorrelationID, "should generate new correlation ID")
 	assert.NotEmpty(t, instance.Status.SynchronizationHash)
 	assert.NotEmpty(t, instance.Status.SynchronizationTime)
@@ -107,10 +109,63 @@ func TestIDPortenController(t *testing.T) {
 
 	// new secret should exist
 	test.AssertSecretExists(t, cli, instance.Spec.SecretName, cfg.NamespaceName, instance, secretAssertions)
+	newKeyID := test.AssertKeyRegistered(t, cli, instance.Spec.SecretName, cfg.NamespaceName, instance)
+	assert.NotEqual(t, previousKeyID, newKeyID, "new key should be valid")
 
 	// old secret should still exist
 	test.AssertSecretExists(t, cli, previousSecretName, cfg.NamespaceName, instance, secretAssertions)
 
+	// deleted secret should be restored
+	secretKey := client.ObjectKey{Namespace: cfg.NamespaceName, Name: instance.Spec.SecretName}
+	deleted := &corev1.Secret{}
+	require.NoError(t, cli.Get(context.Background(), secretKey, deleted))
+	require.NoError(t, cli.Delete(context.Background(), deleted))
+	assert.Eventually(t, func() bool {
+		restored := &corev1.Secret{}
+		err := cli.Get(context.Background(), secretKey, restored)
+		return err == nil && restored.UID != deleted.UID
+	}, test.Timeout, test.Interval, "deleted Secret should be restored")
+	test.AssertSecretExists(t, cli, instance.Spec.SecretName, cfg.NamespaceName, instance, secretAssertions)
+
+	// old secret and its key should be pruned once the pods referencing it are deleted
+	require.NoError(t, cli.DeleteAllOf(context.Background(), &corev1.Pod{},
+		client.InNamespace(cfg.NamespaceName),
+		client.MatchingLabels{clients.AppLabelKey: instance.GetName()},
+	))
+	assert.Eventually(t, test.ResourceDoesNotExist(cli, client.ObjectKey{
+		Namespace: cfg.NamespaceName,
+		Name:      previousSecretName,
+	}, &corev1.Secret{}), test.Timeout, test.Interval, "old Secret should be deleted")
+	assert.Eventually(t, func() bool {
+		err := cli.Get(context.Background(), key, instance)
+		assert.NoError(t, err)
+		return slices.Equal([]string{newKeyID}, instance.Status.KeyIDs)
+	}, test.Timeout, test.Interval, "previous key should be revoked")
+
+	// secret without the type label should be restored, even though it is no longer cached
+	unlabelled := &corev1.Secret{}
+	require.NoError(t, cli.Get(context.Background(), secretKey, unlabelled))
+	delete(unlabelled.Labels, clients.TypeLabelKey)
+	require.NoError(t, cli.Update(context.Background(), unlabelled))
+	assert.Eventually(t, func() bool {
+		restored := &corev1.Secret{}
+		err := cli.Get(context.Background(), secretKey, restored)
+		return err == nil && restored.Labels[clients.TypeLabelKey] == clients.IDPortenTypeLabelValue
+	}, test.Timeout, test.Interval, "unlabelled Secret should be restored")
+	test.AssertSecretExists(t, cli, instance.Spec.SecretName, cfg.NamespaceName, instance, secretAssertions)
+	assert.Eventually(t, func() bool {
+		restored := &corev1.Secret{}
+		if err := cli.Get(context.Background(), secretKey, restored); err != nil {
+			return false
+		}
+		jwk, err := crypto.GetJwkFromSecret(*restored, clients.GetSecretJwkKey(instance))
+		if err != nil {
+			return false
+		}
+		err = cli.Get(context.Background(), key, instance)
+		return err == nil && slices.Contains(instance.Status.KeyIDs, jwk.KeyID)
+	}, test.Timeout, test.Interval, "key in restored Secret should be registered")
+
 	// delete IDPortenClient
 	err = cli.Delete(context.Background(), instance)
 
@@ -69,11 +69,17 @@ func TestMaskinportenController(t *testing.T) {
 	assert.Equal(t, common.EventSynchronized, instance.Status.SynchronizationState)
 
 	assert.Equal(t, test.ClientID, instance.Status.ClientID)
-	assert.Contains(t, instance.Status.KeyIDs, "some-keyid")
 	assert.Len(t, instance.Status.KeyIDs, 1)
 
 	secretAssertions := secretAssertions(t)
 	test.AssertSecretExists(t, cli, cfg.SecretName, cfg.NamespaceName, instance, secretAssertions)
+	previousKeyID := test.AssertKeyRegistered(t, cli, cfg.SecretName, cfg.NamespaceName, instance)
+	test.AssertConfigMapExists(t, cli, clients.GetMetadataConfigMapName(instance), cfg.NamespaceName, instance, func(actual *corev1.ConfigMap, instance clients.Instance) {
+		assert.Equal(t, clients.MakeLabels(instance), actual.GetLabels(), "Labels should be set")
+		assert.Contains(t, actual.Data, secrets.MaskinportenTokenEndpointKey)
+		assert.Contains(t, actual.Data, secrets.MaskinportenExposedScopesKey)
+		assert.NotContains(t, actual.Data, secrets.MaskinportenJwkKey, "ConfigMap should not contain the client key")
+	})
 
 	assert.Eventually(t, test.ResourceDoesNotExist(cli, client.ObjectKey{
 		Namespace: cfg.NamespaceName,
@@ -99,8 +105,7 @@ func TestMaskinportenController(t *testing.T) {
 
 	assert.Equal(t, test.ClientID, instance.Status.ClientID, "client ID should still match")
 	assert.Len(t, instance.Status.KeyIDs, 2, "should contain two key IDs")
-	assert.Contains(t, instance.Status.KeyIDs, "some-keyid", "previous key should still be valid")
-	assert.Contains(t, instance.Status.KeyIDs, "some-new-keyid", "new key should be valid")
+	assert.Contains(t, instance.Status.KeyIDs, previousKeyID, "previous key should still be valid")
 	assert.NotEqual(t, previousCorrelationID, instance.Status.CorrelationID, "should generate new correlation ID")
 	assert.NotEmpty(t, instance.Status.SynchronizationHash)
 	assert.NotEmpty(t, instance.Status.SynchronizationTime)
@@ -108,6 +113,8 @@ func TestMaskinportenController(t *testing.T) {
 
 	// new secret should exist
 	test.AssertSecretExists(t, cli, instance.Spec.SecretName, cfg.NamespaceName, instance, secretAssertions)
+	newKeyID := test.AssertKeyRegistered(t, cli, instance.Spec.SecretName, cfg.NamespaceName, instance)
+	assert.NotEqual(t, previousKeyID, newKeyID, "new key should be valid")
 
 	// old secret should still exist
 	test.AssertSecretExists(t, cli, previousSecretName, cfg.NamespaceName, instance, secretAssertions)
@@ -198,7 +205,11 @@ func TestMaskinportenControllerWithNewExternalScope(t *testing.T) {
 	assert.Equal(t, test.ClientID, instance.Status.ClientID, "client ID should still match")
 	assert.Equal(t, existingScope, scope.Name, "Scope name should match")
 	assert.ElementsMatch(t, expectedConsumers, scope.Consumers, "Consumers should match expected consumers")
-	assert.Len(t, instance.Status.KeyIDs, 2, "should contain 2 key IDs")
+	acl := instance.GetAnnotations()[clients.AnnotationExposedScopesACL]
+	assert.Contains(t, acl, `{"orgno":"101010101","state":"APPROVED"}`, "ACL annotation should contain existing consumer")
+	assert.Contains(t, acl, `"orgno":"111111111"`, "ACL annotation should contain added consumer")
+	assert.Len(t, instance.Status.KeyIDs, 1, "should contain 1 key ID")
+	test.AssertKeyRegistered(t, cli, cfg.SecretName, cfg.NamespaceName, instance)
 	assert.NotEmpty(t, instance.Status.SynchronizationHash)
 	assert.NotEmpty(t, instance.Status.SynchronizationTime)
 	assert.Equal(t, common.EventSynchronized, instance.Status.SynchronizationState)
@@ -0,0 +1,41 @@
+package maskinportenscope
+
+import (
+	"context"
+
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+
+	digdirv1alpha1 "github.com/nais/digdirator/api/v1alpha1"
+	"github.com/nais/digdirator/controllers/common"
+)
+
+type MaskinportenScopeReconciler struct {
+	common.Reconciler
+}
+
+func NewReconciler(reconciler common.Reconciler) *MaskinportenScopeReconciler {
+	return &MaskinportenScopeReconciler{Reconciler: reconciler}
+}
+
+// +kubebuilder:rbac:groups=digdir.nais.io,resources=maskinportenscopes,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=digdir.nais.io,resources=maskinportenscopes/status,verbs=get;update;patch;create
+// +kubebuilder:rbac:groups=*,resources=events,verbs=get;list;watch;create;update
+
+func (r *MaskinportenScopeReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	return r.Reconciler.Reconcile(ctx, req, &digdirv1alpha1.MaskinportenScope{})
+}
+
+func (r *MaskinportenScopeReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&digdirv1alpha1.MaskinportenScope{}).
+		WithOptions(controller.Options{MaxConcurrentReconciles: r.Config.MaxConcurrentReconciles}).
+		WithEventFilter(predicate.Or[client.Object](
+			predicate.GenerationChangedPredicate{},
+			predicate.AnnotationChangedPredicate{},
+			predicate.LabelChangedPredicate{},
+		)).
+		Complete(r)
+}
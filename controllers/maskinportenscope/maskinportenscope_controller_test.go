@@ -0,0 +1,213 @@
+package maskinportenscope_test
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-jose/go-jose/v4"
+	naisiov1 "github.com/nais/liberator/pkg/apis/nais.io/v1"
+	"github.com/nais/liberator/pkg/oauth"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/events"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	fakeclient "sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	digdirv1alpha1 "github.com/nais/digdirator/api/v1alpha1"
+	"github.com/nais/digdirator/controllers/common"
+	"github.com/nais/digdirator/controllers/maskinportenscope"
+	"github.com/nais/digdirator/pkg/config"
+	"github.com/nais/digdirator/pkg/crypto"
+	"github.com/nais/digdirator/pkg/digdir"
+	"github.com/nais/digdirator/pkg/digdir/fake"
+	"github.com/nais/digdirator/pkg/digdir/types"
+)
+
+const (
+	namespace = "test-namespace"
+	orgno     = "111111111"
+	scopeName = "nav:arbeid:test.read"
+)
+
+type testEnv struct {
+	server     *fake.Server
+	k8s        client.Client
+	recorder   *events.FakeRecorder
+	reconciler *maskinportenscope.MaskinportenScopeReconciler
+}
+
+func TestMaskinportenScopeReconciler(t *testing.T) {
+	t.Run("registers scope and grants access to consumers", func(t *testing.T) {
+		env := setup(t)
+
+		env.reconcile(t)
+
+		scope := env.scope(t)
+		assert.True(t, scope.Active)
+		assert.Equal(t, "Test scope", scope.Description)
+		assert.Equal(t, "Managed by digdirator: test-cluster:test-namespace:my-scope", scope.LongDescription)
+		require.Len(t, env.server.ScopeACL(scopeName), 1)
+		assert.Equal(t, types.ScopeStateApproved, env.server.ScopeACL(scopeName)[0].State)
+
+		instance := env.get(t)
+		assert.Equal(t, scopeName, instance.Status.Scope)
+		assert.Equal(t, []digdirv1alpha1.ConsumerStatus{{Orgno: orgno, State: string(types.ScopeStateApproved)}}, instance.Status.Consumers)
+		assert.Equal(t, common.EventSynchronized, instance.Status.SynchronizationState)
+		assert.NotEmpty(t, instance.Status.SynchronizationHash)
+		assert.True(t, common.IsStatusConditionTrue(instance.Status.Conditions, common.ConditionTypeReady))
+	})
+
+	t.Run("adopts scope exposed by a MaskinportenClient in the same namespace", func(t *testing.T) {
+		env := setup(t)
+		env.server.AddScope(types.ScopeRegistration{
+			Prefix:      "nav",
+			Subscope:    "arbeid:test.read",
+			Description: "arbeid - test-cluster:test-namespace:my-app",
+			Active:      true,
+		})
+
+		env.reconcile(t)
+
+		assert.Equal(t, "Test scope", env.scope(t).Description)
+		assert.Equal(t, "Managed by digdirator: test-cluster:test-namespace:my-scope", env.scope(t).LongDescription)
+		assert.Contains(t, env.events(), "Normal "+common.EventAdoptedScopeInDigDir+` Adopted scope "nav:arbeid:test.read" from "test-namespace/my-app"`)
+	})
+
+	t.Run("does not change scope owned by another namespace", func(t *testing.T) {
+		env := setup(t)
+		env.server.AddScope(types.ScopeRegistration{
+			Prefix:      "nav",
+			Subscope:    "arbeid:test.read",
+			Description: "arbeid - test-cluster:other-namespace:my-app",
+			Active:      true,
+		})
+
+		env.reconcile(t)
+
+		assert.Equal(t, "arbeid - test-cluster:other-namespace:my-app", env.scope(t).Description)
+		assert.Empty(t, env.server.ScopeACL(scopeName))
+
+		instance := env.get(t)
+		assert.True(t, common.IsStatusConditionTrue(instance.Status.Conditions, common.ConditionTypeScopeConflict))
+		assert.False(t, common.IsStatusConditionTrue(instance.Status.Conditions, common.ConditionTypeReady))
+	})
+
+	t.Run("deactivates scope when resource is deleted", func(t *testing.T) {
+		env := setup(t)
+		env.reconcile(t)
+
+		require.NoError(t, env.k8s.Delete(t.Context(), env.get(t)))
+		env.reconcile(t)
+
+		assert.False(t, env.scope(t).Active)
+		err := env.k8s.Get(t.Context(), client.ObjectKey{Namespace: namespace, Name: "my-scope"}, &digdirv1alpha1.MaskinportenScope{})
+		assert.True(t, apierrors.IsNotFound(err), "MaskinportenScope should not exist")
+	})
+
+	t.Run("keeps preserved scope when resource is deleted", func(t *testing.T) {
+		env := setup(t)
+		env.reconcile(t)
+
+		instance := env.get(t)
+		instance.SetAnnotations(map[string]string{common.PreserveAnnotation: "true"})
+		require.NoError(t, env.k8s.Update(t.Context(), instance))
+		require.NoError(t, env.k8s.Delete(t.Context(), instance))
+		env.reconcile(t)
+
+		assert.True(t, env.scope(t).Active)
+	})
+}
+
+func setup(t *testing.T) *testEnv {
+	srv := fake.New(fake.Options{})
+	httpServer := httptest.NewServer(srv)
+	t.Cleanup(httpServer.Close)
+
+	metadata, err := oauth.NewMetadataOAuth(t.Context(), httpServer.URL+"/.well-known/oauth-authorization-server")
+	require.NoError(t, err)
+
+	cfg := &config.Config{ClusterName: "test-cluster"}
+	cfg.DigDir.Admin.BaseURL = httpServer.URL
+	cfg.DigDir.Admin.ClientID = "admin"
+	cfg.DigDir.Maskinporten.Metadata = *metadata
+	cfg.DigDir.Maskinporten.Default.ScopePrefix = "nav"
+	cfg.Features.Maskinporten = true
+	cfg.Features.MaskinportenScopes = true
+
+	privateKey, err := crypto.GenerateRSAKey()
+	require.NoError(t, err)
+	signer, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.RS256, Key: privateKey}, nil)
+	require.NoError(t, err)
+
+	digdirClient, err := digdir.NewClient(cfg, httpServer.Client(), signer)
+	require.NoError(t, err)
+
+	scheme := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(scheme))
+	require.NoError(t, naisiov1.AddToScheme(scheme))
+	require.NoError(t, digdirv1alpha1.AddToScheme(scheme))
+
+	k8s := fakeclient.NewClientBuilder().
+		WithScheme(scheme).
+		WithStatusSubresource(&digdirv1alpha1.MaskinportenScope{}).
+		WithObjects(&digdirv1alpha1.MaskinportenScope{
+			ObjectMeta: metav1.ObjectMeta{Name: "my-scope", Namespace: namespace, Generation: 1},
+			Spec: digdirv1alpha1.MaskinportenScopeSpec{
+				Product:     "arbeid",
+				Name:        "test.read",
+				Description: "Test scope",
+				Enabled:     true,
+				Consumers:   []naisiov1.ExposedScopeConsumer{{Orgno: orgno}},
+			},
+		}).
+		Build()
+
+	recorder := events.NewFakeRecorder(100)
+	reconciler := common.NewReconciler(k8s, k8s, scheme, recorder, cfg, digdirClient)
+
+	return &testEnv{
+		server:     srv,
+		k8s:        k8s,
+		recorder:   recorder,
+		reconciler: maskinportenscope.NewReconciler(reconciler),
+	}
+}
+
+func (e *testEnv) reconcile(t *testing.T) {
+	req := ctrl.Request{NamespacedName: client.ObjectKey{Namespace: namespace, Name: "my-scope"}}
+	_, err := e.reconciler.Reconcile(t.Context(), req)
+	require.NoError(t, err)
+}
+
+func (e *testEnv) get(t *testing.T) *digdirv1alpha1.MaskinportenScope {
+	instance := &digdirv1alpha1.MaskinportenScope{}
+	require.NoError(t, e.k8s.Get(t.Context(), client.ObjectKey{Namespace: namespace, Name: "my-scope"}, instance))
+	return instance
+}
+
+func (e *testEnv) scope(t *testing.T) types.ScopeRegistration {
+	for _, scope := range e.server.Scopes() {
+		if scope.Name == scopeName {
+			return scope
+		}
+	}
+	t.Fatalf("scope %q not found", scopeName)
+	return types.ScopeRegistration{}
+}
+
+func (e *testEnv) events() []string {
+	result := make([]string, 0)
+	for {
+		select {
+		case event := <-e.recorder.Events:
+			result = append(result, event)
+		default:
+			return result
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package signer
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/go-jose/go-jose/v4"
+)
+
+// DefaultSigningAlg is used by signers that do not specify an algorithm.
+const DefaultSigningAlg = jose.RS256
+
+// hashFor returns the hash function used to create the digest for the given algorithm.
+func hashFor(alg jose.SignatureAlgorithm) (crypto.Hash, error) {
+	switch alg {
+	case jose.RS256, jose.PS256, jose.ES256:
+		return crypto.SHA256, nil
+	case jose.RS384, jose.PS384, jose.ES384:
+		return crypto.SHA384, nil
+	case jose.RS512, jose.PS512, jose.ES512:
+		return crypto.SHA512, nil
+	default:
+		return 0, fmt.Errorf("unsupported signing algorithm %q", alg)
+	}
+}
+
+// digest hashes the payload with the hash function used by the given algorithm.
+func digest(alg jose.SignatureAlgorithm, payload []byte) ([]byte, crypto.Hash, error) {
+	hash, err := hashFor(alg)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	h := hash.New()
+	if _, err := h.Write(payload); err != nil {
+		return nil, 0, fmt.Errorf("failed to create digest of content: %w", err)
+	}
+	return h.Sum(nil), hash, nil
+}
+
+// signerOpts returns the options for crypto.Signer.Sign matching the given algorithm.
+func signerOpts(alg jose.SignatureAlgorithm, hash crypto.Hash) crypto.SignerOpts {
+	if isPSS(alg) {
+		return &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash, Hash: hash}
+	}
+	return hash
+}
+
+func isPSS(alg jose.SignatureAlgorithm) bool {
+	return alg == jose.PS256 || alg == jose.PS384 || alg == jose.PS512
+}
+
+// ecdsaKeySize returns the size in bytes of each of the r and s values in a JWS ECDSA signature.
+func ecdsaKeySize(alg jose.SignatureAlgorithm) (int, bool) {
+	switch alg {
+	case jose.ES256:
+		return 32, true
+	case jose.ES384:
+		return 48, true
+	case jose.ES512:
+		return 66, true
+	default:
+		return 0, false
+	}
+}
+
+// rawSignature converts the signature to the format expected by JWS for the given algorithm.
+// ECDSA signers return ASN.1 DER-encoded signatures, while JWS expects the fixed-size r||s concatenation (RFC 7518, section 3.4).
+func rawSignature(alg jose.SignatureAlgorithm, signature []byte) ([]byte, error) {
+	size, ok := ecdsaKeySize(alg)
+	if !ok {
+		return signature, nil
+	}
+
+	var sig struct {
+		R, S *big.Int
+	}
+	rest, err := asn1.Unmarshal(signature, &sig)
+	if err != nil {
+		return nil, fmt.Errorf("parsing ECDSA signature: %w", err)
+	}
+	if len(rest) > 0 {
+		return nil, errors.New("parsing ECDSA signature: trailing data")
+	}
+	if sig.R.Sign() <= 0 || sig.S.Sign() <= 0 || sig.R.BitLen() > size*8 || sig.S.BitLen() > size*8 {
+		return nil, errors.New("parsing ECDSA signature: invalid r or s value")
+	}
+
+	out := make([]byte, 2*size)
+	sig.R.FillBytes(out[:size])
+	sig.S.FillBytes(out[size:])
+	return out, nil
+}
+
+// algorithmFor returns the given JWS algorithm if it is compatible with the private key,
+// or the default algorithm for the key if none is given.
+func algorithmFor(key crypto.Signer, alg jose.SignatureAlgorithm) (jose.SignatureAlgorithm, error) {
+	def, err := algorithmForKey(key)
+	if err != nil || alg == "" {
+		return def, err
+	}
+
+	if _, err := hashFor(alg); err != nil {
+		return "", err
+	}
+
+	switch key.(type) {
+	case *rsa.PrivateKey:
+		if _, isEC := ecdsaKeySize(alg); !isEC {
+			return alg, nil
+		}
+	case *ecdsa.PrivateKey:
+		// the curve determines the hash, so an EC key only signs with a single algorithm
+		if alg == def {
+			return alg, nil
+		}
+	}
+	return "", fmt.Errorf("signing algorithm %q is not compatible with private key of type %T", alg, key)
+}
+
+// algorithmForKey returns the default JWS algorithm for the given private key.
+func algorithmForKey(key crypto.Signer) (jose.SignatureAlgorithm, error) {
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		return jose.RS256, nil
+	case *ecdsa.PrivateKey:
+		switch k.Curve {
+		case elliptic.P256():
+			return jose.ES256, nil
+		case elliptic.P384():
+			return jose.ES384, nil
+		case elliptic.P521():
+			return jose.ES512, nil
+		}
+		return "", fmt.Errorf("unsupported elliptic curve %q", k.Curve.Params().Name)
+	default:
+		return "", fmt.Errorf("unsupported private key type %T", key)
+	}
+}
@@ -0,0 +1,182 @@
+package signer
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/go-jose/go-jose/v4"
+	"software.sslmate.com/src/go-pkcs12"
+
+	internalcrypto "github.com/nais/digdirator/internal/crypto"
+)
+
+var _ ByteSigner = (*FileByteSigner)(nil)
+
+// FileByteSigner signs payloads with a private key loaded from a file, e.g. on local disk or a mounted Secret.
+type FileByteSigner struct {
+	PrivateKey crypto.Signer
+	// SigningAlgorithm must be compatible with the private key. Defaults to DefaultSigningAlg.
+	SigningAlgorithm jose.SignatureAlgorithm
+}
+
+// NewFileSigner returns a signer using the business certificate and private key found in the file at the given path.
+// The file must either be PEM-encoded or a PKCS#12 keystore protected by the given password.
+// If pemChain is non-empty, it overrides any certificates found in the file.
+// If alg is empty, the algorithm is derived from the private key.
+func NewFileSigner(path, password string, pemChain []byte, alg jose.SignatureAlgorithm) (jose.Signer, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading key file: %w", err)
+	}
+
+	key, certs, err := parseKeyFile(data, password)
+	if err != nil {
+		return nil, fmt.Errorf("parsing key file %q: %w", path, err)
+	}
+
+	if len(pemChain) > 0 {
+		certs, err = internalcrypto.ConvertPEMChainToX509Chain(pemChain)
+		if err != nil {
+			return nil, fmt.Errorf("converting PEM cert chain to X509 cert chain: %w", err)
+		}
+	}
+
+	if len(certs) > 0 && !publicKeyMatches(certs[0], key) {
+		return nil, fmt.Errorf("private key does not match public key in leaf certificate")
+	}
+
+	alg, err = algorithmFor(key, alg)
+	if err != nil {
+		return nil, err
+	}
+
+	return newConfigurableSigner(FileByteSigner{
+		PrivateKey:       key,
+		SigningAlgorithm: alg,
+	}, certs)
+}
+
+func (f FileByteSigner) Algorithm() jose.SignatureAlgorithm {
+	if f.SigningAlgorithm == "" {
+		return DefaultSigningAlg
+	}
+	return f.SigningAlgorithm
+}
+
+func (f FileByteSigner) SignBytes(payload []byte) ([]byte, error) {
+	alg := f.Algorithm()
+
+	sum, hash, err := digest(alg, payload)
+	if err != nil {
+		return nil, err
+	}
+
+	signature, err := f.PrivateKey.Sign(rand.Reader, sum, signerOpts(alg, hash))
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign digest: %w", err)
+	}
+
+	return rawSignature(alg, signature)
+}
+
+func parseKeyFile(data []byte, password string) (crypto.Signer, []*x509.Certificate, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return parsePKCS12(data, password)
+	}
+	return parsePEM(data)
+}
+
+func parsePEM(data []byte) (crypto.Signer, []*x509.Certificate, error) {
+	var key crypto.Signer
+	certs := make([]*x509.Certificate, 0)
+
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+
+		switch block.Type {
+		case "CERTIFICATE":
+			cert, err := x509.ParseCertificate(block.Bytes)
+			if err != nil {
+				return nil, nil, fmt.Errorf("parsing certificate: %w", err)
+			}
+			certs = append(certs, cert)
+		case "PRIVATE KEY", "RSA PRIVATE KEY", "EC PRIVATE KEY":
+			if key != nil {
+				return nil, nil, errors.New("found more than one private key")
+			}
+
+			parsed, err := parsePrivateKey(block)
+			if err != nil {
+				return nil, nil, err
+			}
+			key = parsed
+		}
+	}
+
+	if key == nil {
+		return nil, nil, errors.New("no private key found")
+	}
+
+	return key, certs, nil
+}
+
+func parsePKCS12(data []byte, password string) (crypto.Signer, []*x509.Certificate, error) {
+	privateKey, cert, caCerts, err := pkcs12.DecodeChain(data, password)
+	if err != nil {
+		return nil, nil, fmt.Errorf("decoding PKCS#12 keystore: %w", err)
+	}
+
+	key, err := asSupportedKey(privateKey)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return key, append([]*x509.Certificate{cert}, caCerts...), nil
+}
+
+func parsePrivateKey(block *pem.Block) (crypto.Signer, error) {
+	var key any
+	var err error
+
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		key, err = x509.ParseECPrivateKey(block.Bytes)
+	default:
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("parsing private key: %w", err)
+	}
+
+	return asSupportedKey(key)
+}
+
+func asSupportedKey(key any) (crypto.Signer, error) {
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		return k, nil
+	case *ecdsa.PrivateKey:
+		return k, nil
+	default:
+		return nil, fmt.Errorf("unsupported private key type %T", key)
+	}
+}
+
+func publicKeyMatches(cert *x509.Certificate, key crypto.Signer) bool {
+	pub, ok := key.Public().(interface{ Equal(crypto.PublicKey) bool })
+	return ok && pub.Equal(cert.PublicKey)
+}
@@ -0,0 +1,223 @@
+package signer_test
+
+import (
+	libcrypto "crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-jose/go-jose/v4"
+	"github.com/go-jose/go-jose/v4/jwt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"software.sslmate.com/src/go-pkcs12"
+
+	"github.com/nais/digdirator/internal/crypto/signer"
+)
+
+func TestNewFileSigner(t *testing.T) {
+	key, cert := generateKeyAndCertificate(t)
+
+	pkcs8, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+
+	certPem := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})
+	pkcs8Pem := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: pkcs8})
+	pkcs1Pem := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+
+	keystore, err := pkcs12.Modern.Encode(key, cert, nil, "changeit")
+	require.NoError(t, err)
+
+	for _, test := range []struct {
+		name      string
+		content   []byte
+		password  string
+		certChain []byte
+	}{
+		{
+			name:    "PEM with PKCS#8 key and certificate",
+			content: append(certPem, pkcs8Pem...),
+		},
+		{
+			name:    "PEM with PKCS#1 key and certificate",
+			content: append(pkcs1Pem, certPem...),
+		},
+		{
+			name:      "PEM with key only and separate certificate chain",
+			content:   pkcs8Pem,
+			certChain: certPem,
+		},
+		{
+			name:     "PKCS#12 keystore",
+			content:  keystore,
+			password: "changeit",
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			path := writeFile(t, test.content)
+
+			s, err := signer.NewFileSigner(path, test.password, test.certChain, "")
+			require.NoError(t, err)
+
+			assertSignedWith(t, s, jose.RS256, key, cert)
+		})
+	}
+
+	t.Run("EC keys", func(t *testing.T) {
+		for _, test := range []struct {
+			name  string
+			curve elliptic.Curve
+			alg   jose.SignatureAlgorithm
+		}{
+			{name: "P-256", curve: elliptic.P256(), alg: jose.ES256},
+			{name: "P-384", curve: elliptic.P384(), alg: jose.ES384},
+		} {
+			t.Run(test.name, func(t *testing.T) {
+				ecKey, err := ecdsa.GenerateKey(test.curve, rand.Reader)
+				require.NoError(t, err)
+				ecCert := generateCertificate(t, ecKey)
+
+				sec1, err := x509.MarshalECPrivateKey(ecKey)
+				require.NoError(t, err)
+				ecKeyPem := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: sec1})
+				ecCertPem := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ecCert.Raw})
+				path := writeFile(t, append(ecKeyPem, ecCertPem...))
+
+				s, err := signer.NewFileSigner(path, "", nil, "")
+				require.NoError(t, err)
+
+				assertSignedWith(t, s, test.alg, ecKey, ecCert)
+			})
+		}
+	})
+
+	t.Run("configured algorithm", func(t *testing.T) {
+		path := writeFile(t, append(certPem, pkcs8Pem...))
+
+		for _, alg := range []jose.SignatureAlgorithm{jose.PS256, jose.PS512, jose.RS384} {
+			t.Run(string(alg), func(t *testing.T) {
+				s, err := signer.NewFileSigner(path, "", nil, alg)
+				require.NoError(t, err)
+
+				assertSignedWith(t, s, alg, key, cert)
+			})
+		}
+
+		_, err := signer.NewFileSigner(path, "", nil, jose.ES256)
+		assert.ErrorContains(t, err, "not compatible")
+
+		_, err = signer.NewFileSigner(path, "", nil, jose.HS256)
+		assert.ErrorContains(t, err, "unsupported signing algorithm")
+	})
+
+	t.Run("PKCS#12 keystore with wrong password", func(t *testing.T) {
+		path := writeFile(t, keystore)
+
+		_, err := signer.NewFileSigner(path, "wrong", nil, "")
+		assert.Error(t, err)
+	})
+
+	t.Run("PEM without certificate chain", func(t *testing.T) {
+		path := writeFile(t, pkcs8Pem)
+
+		_, err := signer.NewFileSigner(path, "", nil, "")
+		assert.ErrorContains(t, err, "no certificates found")
+	})
+
+	t.Run("PEM without private key", func(t *testing.T) {
+		path := writeFile(t, certPem)
+
+		_, err := signer.NewFileSigner(path, "", nil, "")
+		assert.ErrorContains(t, err, "no private key found")
+	})
+
+	t.Run("private key not matching certificate", func(t *testing.T) {
+		_, otherCert := generateKeyAndCertificate(t)
+		otherCertPem := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: otherCert.Raw})
+		path := writeFile(t, append(otherCertPem, pkcs8Pem...))
+
+		_, err := signer.NewFileSigner(path, "", nil, "")
+		assert.ErrorContains(t, err, "does not match")
+	})
+
+	t.Run("missing file", func(t *testing.T) {
+		_, err := signer.NewFileSigner(filepath.Join(t.TempDir(), "missing"), "", nil, "")
+		assert.Error(t, err)
+	})
+}
+
+func assertSignedWith(t *testing.T, s jose.Signer, alg jose.SignatureAlgorithm, key libcrypto.Signer, cert *x509.Certificate) {
+	claims := jwt.Claims{
+		Issuer:   "iss",
+		Audience: []string{"aud"},
+		IssuedAt: jwt.NewNumericDate(time.Now()),
+	}
+
+	serialized, err := jwt.Signed(s).Claims(claims).Serialize()
+	require.NoError(t, err)
+
+	parsed, err := jwt.ParseSigned(serialized, []jose.SignatureAlgorithm{alg})
+	require.NoError(t, err)
+
+	actual := jwt.Claims{}
+	err = parsed.Claims(key.Public(), &actual)
+	require.NoError(t, err)
+	assert.Equal(t, claims.Issuer, actual.Issuer)
+
+	rawHeader, err := base64.RawURLEncoding.DecodeString(strings.Split(serialized, ".")[0])
+	require.NoError(t, err)
+
+	header := make(map[string]any)
+	err = json.Unmarshal(rawHeader, &header)
+	require.NoError(t, err)
+	assert.Equal(t, string(alg), header["alg"])
+	assert.Equal(t, "JWT", header["typ"])
+	assert.Equal(t, []any{base64.StdEncoding.EncodeToString(cert.Raw)}, header["x5c"])
+}
+
+func generateKeyAndCertificate(t *testing.T) (*rsa.PrivateKey, *x509.Certificate) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	return key, generateCertificate(t, key)
+}
+
+func generateCertificate(t *testing.T, key libcrypto.Signer) *x509.Certificate {
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject: pkix.Name{
+			Country:      []string{"NO"},
+			Organization: []string{"NAIS Price AS 987654321"},
+			CommonName:   "NAIS Price AS",
+		},
+		NotBefore: time.Now(),
+		NotAfter:  time.Now().Add(time.Hour),
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	require.NoError(t, err)
+
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	return cert
+}
+
+func writeFile(t *testing.T, content []byte) string {
+	path := filepath.Join(t.TempDir(), "key")
+	err := os.WriteFile(path, content, 0o600)
+	require.NoError(t, err)
+	return path
+}
@@ -2,7 +2,10 @@ package signer
 
 import (
 	"context"
-	"crypto/sha256"
+	"crypto"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
 	"fmt"
 	"os"
 	"slices"
@@ -11,7 +14,10 @@ import (
 	kms "cloud.google.com/go/kms/apiv1"
 	"cloud.google.com/go/kms/apiv1/kmspb"
 	"github.com/go-jose/go-jose/v4"
-	"github.com/nais/digdirator/internal/crypto"
+	"go.opentelemetry.io/otel/attribute"
+
+	internalcrypto "github.com/nais/digdirator/internal/crypto"
+	"github.com/nais/digdirator/pkg/tracing"
 )
 
 var _ ByteSigner = (*KmsByteSigner)(nil)
@@ -19,52 +25,70 @@ var _ ByteSigner = (*KmsByteSigner)(nil)
 type KmsByteSigner struct {
 	Client     *kms.KeyManagementClient
 	KmsKeyPath string
+	// SigningAlgorithm must match the algorithm of the key version. Defaults to DefaultSigningAlg.
+	SigningAlgorithm jose.SignatureAlgorithm
 }
 
 func NewKmsSigner(ctx context.Context, kmsKeyPath string, pemChain []byte) (jose.Signer, error) {
-	certs, err := crypto.ConvertPEMChainToX509Chain(pemChain)
+	certs, err := internalcrypto.ConvertPEMChainToX509Chain(pemChain)
 	if err != nil {
 		return nil, fmt.Errorf("converting PEM cert chain to X509 cert chain: %w", err)
 	}
 
-	if len(certs) == 0 {
-		return nil, fmt.Errorf("no certificates found in PEM chain")
+	kmsClient, err := newKmsClient(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error creating key management client: %v", err)
 	}
 
-	opts := &jose.SignerOptions{}
-	opts.WithType("JWT")
-	opts.WithHeader("x5c", crypto.ConvertX509CertificatesToX5c(certs))
+	publicKey, err := kmsClient.GetPublicKey(ctx, &kmspb.GetPublicKeyRequest{Name: kmsKeyPath})
+	if err != nil {
+		return nil, fmt.Errorf("fetching public key for KMS key version: %w", err)
+	}
 
-	kmsClient, err := newKmsClient(ctx)
+	alg, err := kmsSigningAlgorithm(publicKey.GetAlgorithm())
 	if err != nil {
-		return nil, fmt.Errorf("error creating key management client: %v", err)
+		return nil, err
 	}
 
-	return ConfigurableSigner{
-		SignerOptions: opts,
-		ByteSigner: KmsByteSigner{
-			Client:     kmsClient,
-			KmsKeyPath: kmsKeyPath,
-		},
-	}, nil
+	if len(certs) > 0 {
+		if err := kmsPublicKeyMatches(certs[0], publicKey.GetPem()); err != nil {
+			return nil, err
+		}
+	}
+
+	return newConfigurableSigner(KmsByteSigner{
+		Client:           kmsClient,
+		KmsKeyPath:       kmsKeyPath,
+		SigningAlgorithm: alg,
+	}, certs)
 }
 
-func (k KmsByteSigner) SignBytes(payload []byte) ([]byte, error) {
-	digest := sha256.New()
-	if _, err := digest.Write(payload); err != nil {
-		return nil, fmt.Errorf("failed to create digest of content: %v", err)
+func (k KmsByteSigner) Algorithm() jose.SignatureAlgorithm {
+	if k.SigningAlgorithm == "" {
+		return DefaultSigningAlg
+	}
+	return k.SigningAlgorithm
+}
+
+// SignBytes signs the payload with the KMS key version.
+// The signer interface carries no context, so the span for the signing request is the root of its own trace.
+func (k KmsByteSigner) SignBytes(payload []byte) (_ []byte, err error) {
+	ctx, span := tracing.Start(context.Background(), "KmsByteSigner.SignBytes", attribute.String("kms.key_path", k.KmsKeyPath))
+	defer tracing.End(span, &err)
+
+	alg := k.Algorithm()
+
+	sum, hash, err := digest(alg, payload)
+	if err != nil {
+		return nil, err
 	}
 
 	req := &kmspb.AsymmetricSignRequest{
-		Name: k.KmsKeyPath,
-		Digest: &kmspb.Digest{
-			Digest: &kmspb.Digest_Sha256{
-				Sha256: digest.Sum(nil),
-			},
-		},
+		Name:   k.KmsKeyPath,
+		Digest: kmsDigest(hash, sum),
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Minute)
+	ctx, cancel := context.WithTimeout(ctx, 1*time.Minute)
 	defer cancel()
 
 	response, err := k.Client.AsymmetricSign(ctx, req)
@@ -72,7 +96,60 @@ func (k KmsByteSigner) SignBytes(payload []byte) ([]byte, error) {
 		return nil, fmt.Errorf("failed to sign digest: %w", err)
 	}
 
-	return response.Signature, nil
+	return rawSignature(alg, response.Signature)
+}
+
+func kmsDigest(hash crypto.Hash, sum []byte) *kmspb.Digest {
+	switch hash {
+	case crypto.SHA384:
+		return &kmspb.Digest{Digest: &kmspb.Digest_Sha384{Sha384: sum}}
+	case crypto.SHA512:
+		return &kmspb.Digest{Digest: &kmspb.Digest_Sha512{Sha512: sum}}
+	default:
+		return &kmspb.Digest{Digest: &kmspb.Digest_Sha256{Sha256: sum}}
+	}
+}
+
+// kmsSigningAlgorithm maps the algorithm of a KMS key version to the corresponding JWS algorithm.
+func kmsSigningAlgorithm(alg kmspb.CryptoKeyVersion_CryptoKeyVersionAlgorithm) (jose.SignatureAlgorithm, error) {
+	switch alg {
+	case kmspb.CryptoKeyVersion_RSA_SIGN_PKCS1_2048_SHA256,
+		kmspb.CryptoKeyVersion_RSA_SIGN_PKCS1_3072_SHA256,
+		kmspb.CryptoKeyVersion_RSA_SIGN_PKCS1_4096_SHA256:
+		return jose.RS256, nil
+	case kmspb.CryptoKeyVersion_RSA_SIGN_PKCS1_4096_SHA512:
+		return jose.RS512, nil
+	case kmspb.CryptoKeyVersion_RSA_SIGN_PSS_2048_SHA256,
+		kmspb.CryptoKeyVersion_RSA_SIGN_PSS_3072_SHA256,
+		kmspb.CryptoKeyVersion_RSA_SIGN_PSS_4096_SHA256:
+		return jose.PS256, nil
+	case kmspb.CryptoKeyVersion_RSA_SIGN_PSS_4096_SHA512:
+		return jose.PS512, nil
+	case kmspb.CryptoKeyVersion_EC_SIGN_P256_SHA256:
+		return jose.ES256, nil
+	case kmspb.CryptoKeyVersion_EC_SIGN_P384_SHA384:
+		return jose.ES384, nil
+	default:
+		return "", fmt.Errorf("unsupported KMS key algorithm %s", alg)
+	}
+}
+
+func kmsPublicKeyMatches(cert *x509.Certificate, publicKeyPem string) error {
+	block, _ := pem.Decode([]byte(publicKeyPem))
+	if block == nil {
+		return errors.New("decoding PEM public key for KMS key version")
+	}
+
+	publicKey, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return fmt.Errorf("parsing public key for KMS key version: %w", err)
+	}
+
+	pub, ok := publicKey.(interface{ Equal(crypto.PublicKey) bool })
+	if !ok || !pub.Equal(cert.PublicKey) {
+		return errors.New("KMS key version does not match public key in leaf certificate")
+	}
+	return nil
 }
 
 func newKmsClient(ctx context.Context) (*kms.KeyManagementClient, error) {
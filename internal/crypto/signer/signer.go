@@ -2,22 +2,22 @@ package signer
 
 import (
 	"bytes"
+	"crypto/x509"
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"maps"
 
 	"github.com/go-jose/go-jose/v4"
-)
-
-const (
-	SigningAlg = jose.RS256
+	"github.com/nais/digdirator/internal/crypto"
 )
 
 var _ jose.Signer = (*ConfigurableSigner)(nil)
 
 type (
 	ByteSigner interface {
+		// Algorithm returns the JWS algorithm of the signatures produced by SignBytes.
+		Algorithm() jose.SignatureAlgorithm
 		SignBytes(payload []byte) ([]byte, error)
 	}
 
@@ -27,13 +27,30 @@ type (
 	}
 )
 
+// newConfigurableSigner returns a ConfigurableSigner that includes the given certificate chain in the x5c header.
+func newConfigurableSigner(byteSigner ByteSigner, certs []*x509.Certificate) (jose.Signer, error) {
+	if len(certs) == 0 {
+		return nil, fmt.Errorf("no certificates found in certificate chain")
+	}
+
+	opts := &jose.SignerOptions{}
+	opts.WithType("JWT")
+	opts.WithHeader("x5c", crypto.ConvertX509CertificatesToX5c(certs))
+
+	return ConfigurableSigner{
+		SignerOptions: opts,
+		ByteSigner:    byteSigner,
+	}, nil
+}
+
 func (s ConfigurableSigner) Options() jose.SignerOptions {
 	return *s.SignerOptions
 }
 
 func (s ConfigurableSigner) Sign(payload []byte) (*jose.JSONWebSignature, error) {
+	alg := s.ByteSigner.Algorithm()
 	header := map[jose.HeaderKey]any{
-		"alg": SigningAlg,
+		"alg": alg,
 	}
 	maps.Copy(header, s.SignerOptions.ExtraHeaders)
 
@@ -60,5 +77,5 @@ func (s ConfigurableSigner) Sign(payload []byte) (*jose.JSONWebSignature, error)
 	out.WriteByte('.')
 	out.WriteString(base64.RawURLEncoding.EncodeToString(signature))
 
-	return jose.ParseSigned(out.String(), []jose.SignatureAlgorithm{SigningAlg})
+	return jose.ParseSigned(out.String(), []jose.SignatureAlgorithm{alg})
 }
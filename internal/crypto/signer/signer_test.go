@@ -2,6 +2,8 @@ package signer_test
 
 import (
 	libcrypto "crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
 	"crypto/rand"
 	"crypto/rsa"
 	"crypto/sha256"
@@ -63,13 +65,17 @@ func NewRsaSigner(key jose.SigningKey, opts *jose.SignerOptions) jose.Signer {
 		SignerOptions: opts,
 		ByteSigner: &rsaSigner{
 			signingKey:   key,
-			signatureAlg: signer.SigningAlg,
+			signatureAlg: jose.RS256,
 			opts:         opts,
 		},
 	}
 	return sign
 }
 
+func (ctx *rsaSigner) Algorithm() jose.SignatureAlgorithm {
+	return ctx.signatureAlg
+}
+
 func (ctx *rsaSigner) SignBytes(payload []byte) ([]byte, error) {
 	rng := rand.Reader
 	key := ctx.signingKey.Key.(*rsa.PrivateKey)
@@ -80,3 +86,44 @@ func (ctx *rsaSigner) SignBytes(payload []byte) ([]byte, error) {
 	}
 	return signature, nil
 }
+
+func TestSignWithAlgorithms(t *testing.T) {
+	rsaKey, err := crypto.GenerateRSAKey()
+	require.NoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	for _, test := range []struct {
+		alg jose.SignatureAlgorithm
+		key libcrypto.Signer
+	}{
+		{alg: jose.RS256, key: rsaKey},
+		{alg: jose.PS256, key: rsaKey},
+		{alg: jose.ES256, key: ecKey},
+	} {
+		t.Run(string(test.alg), func(t *testing.T) {
+			opts := &jose.SignerOptions{}
+			opts.WithType("JWT")
+			s := signer.ConfigurableSigner{
+				SignerOptions: opts,
+				ByteSigner: signer.FileByteSigner{
+					PrivateKey:       test.key,
+					SigningAlgorithm: test.alg,
+				},
+			}
+
+			claims := jwt.Claims{Issuer: "iss"}
+			serialized, err := jwt.Signed(s).Claims(claims).Serialize()
+			require.NoError(t, err)
+
+			parsed, err := jwt.ParseSigned(serialized, []jose.SignatureAlgorithm{test.alg})
+			require.NoError(t, err)
+			assert.Equal(t, string(test.alg), parsed.Headers[0].Algorithm)
+
+			actual := jwt.Claims{}
+			err = parsed.Claims(test.key.Public(), &actual)
+			require.NoError(t, err)
+			assert.Equal(t, claims.Issuer, actual.Issuer)
+		})
+	}
+}
@@ -0,0 +1,21 @@
+package templates
+
+import (
+	"io"
+	"text/template"
+)
+
+// Parse parses a text/template from the configuration, which is rendered with data of type T.
+// The template is executed once with empty data, so that references to unknown fields fail at startup rather than when rendered.
+func Parse[T any](name, text string, funcs template.FuncMap) (*template.Template, error) {
+	tmpl, err := template.New(name).Funcs(funcs).Parse(text)
+	if err != nil {
+		return nil, err
+	}
+
+	var empty T
+	if err := tmpl.Execute(io.Discard, empty); err != nil {
+		return nil, err
+	}
+	return tmpl, nil
+}
@@ -1,6 +1,11 @@
 package clients
 
 import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
 	"time"
 
 	naisiov1 "github.com/nais/liberator/pkg/apis/nais.io/v1"
@@ -10,15 +15,28 @@ import (
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/utils/ptr"
 
+	digdirv1alpha1 "github.com/nais/digdirator/api/v1alpha1"
 	"github.com/nais/digdirator/pkg/config"
+	"github.com/nais/digdirator/pkg/crypto"
 	"github.com/nais/digdirator/pkg/digdir/scopes"
 	"github.com/nais/digdirator/pkg/digdir/types"
 	"github.com/nais/digdirator/pkg/secrets"
 )
 
 const (
+	// AnnotationExposedScopesACL is set by digdirator on MaskinportenClients, and lists the consumers of each exposed scope along with their state.
+	AnnotationExposedScopesACL = "digdir.nais.io/exposed-scopes-acl"
+	// AnnotationExposedScopesDescriptions is set by users on MaskinportenClients to override the descriptions of exposed scopes.
+	// The value is a JSON object of ScopeDescriptions keyed by subscope.
+	AnnotationExposedScopesDescriptions = "digdir.nais.io/exposed-scopes-descriptions"
+	// AnnotationIdentity is set by users to register the client with another admin identity than the one assigned to the namespace.
+	AnnotationIdentity      = "digdir.nais.io/identity"
+	AnnotationKeyType       = "digdir.nais.io/key-type"
 	AnnotationResynchronize = "digdir.nais.io/resync"
 	AnnotationRotate        = "digdir.nais.io/rotate"
+	// AnnotationSecretFormats is set by users to select additional formats for the client key in the secret, see crypto.SecretFormats.
+	// The value is a comma-separated list of formats, and may be empty to disable the formats enabled in the config.
+	AnnotationSecretFormats = "digdir.nais.io/secret-formats"
 
 	MaskinportenDefaultAllowedIntegrationType   = "maskinporten"
 	MaskinportenDefaultAtAgeMax                 = 30
@@ -37,16 +55,32 @@ type Instance interface {
 	SetStatus(status naisiov1.DigdiratorStatus)
 }
 
-func ToScopeRegistration(instance Instance, scope naisiov1.ExposedScope, cfg *config.Config) types.ScopeRegistration {
+func ToScopeRegistration(instance Instance, scope naisiov1.ExposedScope, cfg *config.Config) (types.ScopeRegistration, error) {
+	var override ScopeDescription
 	switch v := instance.(type) {
 	case *naisiov1.MaskinportenClient:
-		return toMaskinPortenScopeRegistration(*v, scope, cfg)
+		// the annotation is validated before the exposed scopes are processed
+		descriptions, _ := GetExposedScopesDescriptions(v)
+		override = descriptions[scopes.Subscope(scope)]
+	case *digdirv1alpha1.MaskinportenScope:
+		override = ScopeDescription{Description: v.Spec.Description, LongDescription: v.Spec.LongDescription}
+	default:
+		return types.ScopeRegistration{}, nil
 	}
-	return types.ScopeRegistration{}
+
+	var err error
+	registration := toMaskinPortenScopeRegistration(scope, cfg)
+	registration.Description, registration.LongDescription, err = scopeDescriptions(instance, scope, override, cfg)
+	if err != nil {
+		return types.ScopeRegistration{}, fmt.Errorf("rendering descriptions for scope %q: %w", scopes.Subscope(scope), err)
+	}
+	return registration, nil
 }
 
 func ToClientRegistration(instance Instance, cfg *config.Config) types.ClientRegistration {
 	switch v := instance.(type) {
+	case *digdirv1alpha1.AnsattportenClient:
+		return toAnsattportenClientRegistration(*v, cfg)
 	case *naisiov1.IDPortenClient:
 		return toIDPortenClientRegistration(*v, cfg)
 	case *naisiov1.MaskinportenClient:
@@ -64,16 +98,48 @@ func GetIntegrationType(instance Instance) types.IntegrationType {
 		return types.IntegrationTypeIDPorten
 	case *naisiov1.MaskinportenClient:
 		return types.IntegrationTypeMaskinporten
+	case *digdirv1alpha1.AnsattportenClient:
+		return types.IntegrationTypeAnsattporten
 	}
 	return types.IntegrationTypeUnknown
 }
 
+func GetKind(instance Instance) string {
+	switch instance.(type) {
+	case *naisiov1.IDPortenClient:
+		return "IDPortenClient"
+	case *naisiov1.MaskinportenClient:
+		return "MaskinportenClient"
+	case *digdirv1alpha1.MaskinportenScope:
+		return "MaskinportenScope"
+	case *digdirv1alpha1.AnsattportenClient:
+		return "AnsattportenClient"
+	}
+	return ""
+}
+
 func GetSecretName(instance Instance) string {
 	switch v := instance.(type) {
 	case *naisiov1.IDPortenClient:
 		return v.Spec.SecretName
 	case *naisiov1.MaskinportenClient:
 		return v.Spec.SecretName
+	case *digdirv1alpha1.AnsattportenClient:
+		return v.Spec.SecretName
+	}
+	return ""
+}
+
+// GetMetadataConfigMapName returns the name of the ConfigMap with the client's public metadata.
+// Unlike the secret name, it does not change between deployments, and includes the type of client as an application may have several.
+func GetMetadataConfigMapName(instance Instance) string {
+	switch instance.(type) {
+	case *naisiov1.IDPortenClient:
+		return instance.GetName() + "-idporten-metadata"
+	case *naisiov1.MaskinportenClient:
+		return instance.GetName() + "-maskinporten-metadata"
+	case *digdirv1alpha1.AnsattportenClient:
+		return instance.GetName() + "-ansattporten-metadata"
 	}
 	return ""
 }
@@ -84,10 +150,53 @@ func GetSecretJwkKey(instance Instance) string {
 		return secrets.IDPortenJwkKey
 	case *naisiov1.MaskinportenClient:
 		return secrets.MaskinportenJwkKey
+	case *digdirv1alpha1.AnsattportenClient:
+		return secrets.AnsattportenJwkKey
 	}
 	return ""
 }
 
+// GetKeyType returns the key type for new JWKs, preferring the annotation on the instance over the cluster default.
+func GetKeyType(instance Instance, cfg *config.Config) (crypto.KeyType, error) {
+	if keyType, found := instance.GetAnnotations()[AnnotationKeyType]; found {
+		return crypto.ParseKeyType(keyType)
+	}
+	return crypto.ParseKeyType(cfg.DigDir.Common.KeyType)
+}
+
+// GetSecretFormats returns the additional formats for the client key in the secret, preferring the annotation on the instance over
+// the cluster default.
+func GetSecretFormats(instance Instance, cfg *config.Config) ([]crypto.SecretFormat, error) {
+	if formats, found := instance.GetAnnotations()[AnnotationSecretFormats]; found {
+		return crypto.ParseSecretFormats(formats)
+	}
+	return crypto.ParseSecretFormats(cfg.DigDir.Common.SecretFormats)
+}
+
+// GetIdentity returns the name of the admin identity that the client is registered with, i.e. the identity selected with the
+// AnnotationIdentity annotation, or the identity assigned to the namespace. The default identity may be selected in any namespace.
+func GetIdentity(instance Instance, cfg *config.Config) (string, error) {
+	name, found := instance.GetAnnotations()[AnnotationIdentity]
+	if !found {
+		return cfg.DigDir.IdentityFor(instance.GetNamespace()), nil
+	}
+
+	if name == config.DefaultIdentity {
+		return name, nil
+	}
+
+	identity, found := cfg.DigDir.Identities[name]
+	if !found {
+		return "", fmt.Errorf("unknown identity %q in annotation %q", name, AnnotationIdentity)
+	}
+
+	if !identity.Allows(instance.GetNamespace()) {
+		return "", fmt.Errorf("identity %q is not allowed in namespace %q", name, instance.GetNamespace())
+	}
+
+	return name, nil
+}
+
 func IsUpToDate(instance Instance) bool {
 	status := instance.GetStatus()
 	if status == nil {
@@ -114,6 +223,53 @@ func NeedsSecretRotation(instance Instance) bool {
 	return nameChanged || hasRotateAnnotation
 }
 
+// SetExposedScopesACL records the ACLs of the exposed scopes in the AnnotationExposedScopesACL annotation.
+// The annotation is removed if there are no ACLs.
+func SetExposedScopesACL(instance Instance, acls []scopes.ACL) error {
+	annotations := instance.GetAnnotations()
+
+	if len(acls) == 0 {
+		delete(annotations, AnnotationExposedScopesACL)
+		instance.SetAnnotations(annotations)
+		return nil
+	}
+
+	acls = slices.SortedFunc(slices.Values(acls), func(a, b scopes.ACL) int {
+		return strings.Compare(a.Scope, b.Scope)
+	})
+	value, err := json.Marshal(acls)
+	if err != nil {
+		return fmt.Errorf("marshalling ACLs: %w", err)
+	}
+
+	if annotations == nil {
+		annotations = make(map[string]string)
+	}
+	annotations[AnnotationExposedScopesACL] = string(value)
+	instance.SetAnnotations(annotations)
+	return nil
+}
+
+// ScopeDescription overrides the description and long description of an exposed scope.
+type ScopeDescription struct {
+	Description     string `json:"description,omitempty"`
+	LongDescription string `json:"longDescription,omitempty"`
+}
+
+// GetExposedScopesDescriptions returns the descriptions in the AnnotationExposedScopesDescriptions annotation, keyed by subscope.
+func GetExposedScopesDescriptions(instance Instance) (map[string]ScopeDescription, error) {
+	value, ok := instance.GetAnnotations()[AnnotationExposedScopesDescriptions]
+	if !ok {
+		return nil, nil
+	}
+
+	descriptions := make(map[string]ScopeDescription)
+	if err := json.Unmarshal([]byte(value), &descriptions); err != nil {
+		return nil, fmt.Errorf("parsing annotation %q: %w", AnnotationExposedScopesDescriptions, err)
+	}
+	return descriptions, nil
+}
+
 func GetIDPortenDefaultScopes(integrationType string) []string {
 	switch integrationType {
 	case string(types.IntegrationTypeIDPorten), string(types.IntegrationTypeApiKlient):
@@ -191,6 +347,58 @@ func toIDPortenClientRegistration(in naisiov1.IDPortenClient, cfg *config.Config
 	}
 }
 
+func SetAnsattportenClientDefaultValues(in *digdirv1alpha1.AnsattportenClient, cfg *config.Config) {
+	if in.Spec.AccessTokenLifetime == nil {
+		lifetime := cfg.DigDir.Common.AccessTokenLifetime
+		in.Spec.AccessTokenLifetime = &lifetime
+	}
+	if in.Spec.SessionLifetime == nil {
+		lifetime := cfg.DigDir.Common.SessionLifetime
+		in.Spec.SessionLifetime = &lifetime
+	}
+	if len(in.Spec.ClientURI) == 0 {
+		in.Spec.ClientURI = naisiov1.IDPortenURI(cfg.DigDir.Common.ClientURI)
+	}
+	if len(in.Spec.PostLogoutRedirectURIs) == 0 {
+		in.Spec.PostLogoutRedirectURIs = []naisiov1.IDPortenURI{in.Spec.ClientURI}
+	}
+	if len(in.Spec.Scopes) == 0 {
+		in.Spec.Scopes = []string{"openid", "profile"}
+	}
+}
+
+func toAnsattportenClientRegistration(in digdirv1alpha1.AnsattportenClient, cfg *config.Config) types.ClientRegistration {
+	SetAnsattportenClientDefaultValues(&in, cfg)
+
+	redirectURIs := make([]string, 0, len(in.Spec.RedirectURIs))
+	for _, uri := range in.Spec.RedirectURIs {
+		redirectURIs = append(redirectURIs, string(uri))
+	}
+
+	return types.ClientRegistration{
+		AccessTokenLifetime:               *in.Spec.AccessTokenLifetime,
+		ApplicationType:                   types.ApplicationTypeWeb,
+		AuthorizationLifeTime:             *in.Spec.SessionLifetime,
+		ClientName:                        cmp.Or(in.Spec.ClientName, cfg.DigDir.Common.ClientName),
+		ClientURI:                         string(in.Spec.ClientURI),
+		Description:                       kubernetes.UniformResourceName(&in.ObjectMeta, cfg.ClusterName),
+		FrontchannelLogoutSessionRequired: true,
+		FrontchannelLogoutURI:             string(in.Spec.FrontchannelLogoutURI),
+		GrantTypes: []types.GrantType{
+			types.GrantTypeAuthorizationCode,
+			types.GrantTypeRefreshToken,
+		},
+		IntegrationType:         types.IntegrationTypeAnsattporten,
+		PostLogoutRedirectURIs:  postLogoutRedirectURIs(in.Spec.PostLogoutRedirectURIs),
+		RedirectURIs:            redirectURIs,
+		RefreshTokenLifetime:    *in.Spec.SessionLifetime,
+		RefreshTokenUsage:       types.RefreshTokenUsageOneTime,
+		Scopes:                  in.Spec.Scopes,
+		SSODisabled:             ptr.Deref(in.Spec.SSODisabled, false),
+		TokenEndpointAuthMethod: types.TokenEndpointAuthMethodPrivateKeyJwt,
+	}
+}
+
 func toMaskinPortenClientRegistration(in naisiov1.MaskinportenClient, cfg *config.Config) types.ClientRegistration {
 	clientName := in.Spec.ClientName
 	if clientName == "" {
@@ -223,7 +431,7 @@ func toMaskinPortenClientRegistration(in naisiov1.MaskinportenClient, cfg *confi
 	}
 }
 
-func toMaskinPortenScopeRegistration(in naisiov1.MaskinportenClient, exposedScope naisiov1.ExposedScope, cfg *config.Config) types.ScopeRegistration {
+func toMaskinPortenScopeRegistration(exposedScope naisiov1.ExposedScope, cfg *config.Config) types.ScopeRegistration {
 	allowedIntegrations := []string{MaskinportenDefaultAllowedIntegrationType}
 	if len(exposedScope.AllowedIntegrations) > 0 {
 		allowedIntegrations = exposedScope.AllowedIntegrations
@@ -261,7 +469,6 @@ func toMaskinPortenScopeRegistration(in naisiov1.MaskinportenClient, exposedScop
 		DelegationSource:           delegationSource,
 		Name:                       "",
 		AuthorizationMaxLifetime:   MaskinportenDefaultAuthorizationMaxLifetime,
-		Description:                scopes.Description(&in.ObjectMeta, cfg.ClusterName, exposedScope.Product),
 		Prefix:                     cfg.DigDir.Maskinporten.Default.ScopePrefix,
 		Subscope:                   scopes.Subscope(exposedScope),
 		TokenType:                  types.TokenTypeSelfContained,
@@ -272,6 +479,39 @@ func toMaskinPortenScopeRegistration(in naisiov1.MaskinportenClient, exposedScop
 	}
 }
 
+// scopeDescriptions returns the description and long description of the exposed scope.
+// Descriptions set for the scope take precedence over the templates in the config, and the description defaults to the product.
+func scopeDescriptions(instance Instance, scope naisiov1.ExposedScope, override ScopeDescription, cfg *config.Config) (string, string, error) {
+	data := scopes.DescriptionData{
+		Product:   scope.Product,
+		Name:      scope.Name,
+		Subscope:  scopes.Subscope(scope),
+		Cluster:   cfg.ClusterName,
+		Namespace: instance.GetNamespace(),
+		Resource:  instance.GetName(),
+	}
+
+	defaults := cfg.DigDir.Maskinporten.Default
+	description := override.Description
+	if description == "" {
+		rendered, err := scopes.RenderDescription(defaults.ScopeDescriptionTemplate, data)
+		if err != nil {
+			return "", "", err
+		}
+		description = rendered
+	}
+	longDescription := override.LongDescription
+	if longDescription == "" {
+		rendered, err := scopes.RenderDescription(defaults.ScopeLongDescriptionTemplate, data)
+		if err != nil {
+			return "", "", err
+		}
+		longDescription = rendered
+	}
+
+	return cmp.Or(description, scope.Product), scopes.LongDescription(instance, cfg.ClusterName, longDescription), nil
+}
+
 func postLogoutRedirectURIs(uris []naisiov1.IDPortenURI) []string {
 	result := make([]string, 0)
 
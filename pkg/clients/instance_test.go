@@ -2,14 +2,20 @@ package clients_test
 
 import (
 	"testing"
+	"text/template"
 	"time"
 
 	naisiov1 "github.com/nais/liberator/pkg/apis/nais.io/v1"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 
+	digdirv1alpha1 "github.com/nais/digdirator/api/v1alpha1"
 	"github.com/nais/digdirator/pkg/clients"
 	"github.com/nais/digdirator/pkg/config"
+	"github.com/nais/digdirator/pkg/crypto"
+	"github.com/nais/digdirator/pkg/digdir/scopes"
 	"github.com/nais/digdirator/pkg/digdir/types"
 	"github.com/nais/digdirator/pkg/fixtures"
 	"github.com/nais/digdirator/pkg/secrets"
@@ -24,6 +30,9 @@ func TestGetIntegrationType(t *testing.T) {
 
 	maskinportenClient := fixtures.MinimalMaskinportenClient()
 	assert.Equal(t, types.IntegrationTypeMaskinporten, clients.GetIntegrationType(maskinportenClient))
+
+	ansattportenClient := fixtures.MinimalAnsattportenClient()
+	assert.Equal(t, types.IntegrationTypeAnsattporten, clients.GetIntegrationType(ansattportenClient))
 }
 
 func TestGetSecretName(t *testing.T) {
@@ -34,6 +43,21 @@ func TestGetSecretName(t *testing.T) {
 	maskinportenClient := fixtures.MinimalMaskinportenClient()
 	maskinportenClient.Spec.SecretName = "maskinporten-secret"
 	assert.Equal(t, "maskinporten-secret", clients.GetSecretName(maskinportenClient))
+
+	ansattportenClient := fixtures.MinimalAnsattportenClient()
+	ansattportenClient.Spec.SecretName = "ansattporten-secret"
+	assert.Equal(t, "ansattporten-secret", clients.GetSecretName(ansattportenClient))
+}
+
+func TestGetMetadataConfigMapName(t *testing.T) {
+	idPortenClient := fixtures.MinimalIDPortenClient()
+	assert.Equal(t, idPortenClient.GetName()+"-idporten-metadata", clients.GetMetadataConfigMapName(idPortenClient))
+
+	maskinportenClient := fixtures.MinimalMaskinportenClient()
+	assert.Equal(t, maskinportenClient.GetName()+"-maskinporten-metadata", clients.GetMetadataConfigMapName(maskinportenClient))
+
+	ansattportenClient := fixtures.MinimalAnsattportenClient()
+	assert.Equal(t, ansattportenClient.GetName()+"-ansattporten-metadata", clients.GetMetadataConfigMapName(ansattportenClient))
 }
 
 func TestGetSecretJwkKey(t *testing.T) {
@@ -42,6 +66,105 @@ func TestGetSecretJwkKey(t *testing.T) {
 
 	maskinportenClient := fixtures.MinimalMaskinportenClient()
 	assert.Equal(t, secrets.MaskinportenJwkKey, clients.GetSecretJwkKey(maskinportenClient))
+
+	ansattportenClient := fixtures.MinimalAnsattportenClient()
+	assert.Equal(t, secrets.AnsattportenJwkKey, clients.GetSecretJwkKey(ansattportenClient))
+}
+
+func TestGetKeyType(t *testing.T) {
+	cfg := &config.Config{}
+
+	client := fixtures.MinimalIDPortenClient()
+	keyType, err := clients.GetKeyType(client, cfg)
+	assert.NoError(t, err)
+	assert.Equal(t, crypto.DefaultKeyType, keyType, "should use default key type if none configured")
+
+	cfg.DigDir.Common.KeyType = string(crypto.KeyTypeRSA4096)
+	keyType, err = clients.GetKeyType(client, cfg)
+	assert.NoError(t, err)
+	assert.Equal(t, crypto.KeyTypeRSA4096, keyType, "should use key type from config")
+
+	client.SetAnnotations(map[string]string{clients.AnnotationKeyType: "EC-P256"})
+	keyType, err = clients.GetKeyType(client, cfg)
+	assert.NoError(t, err)
+	assert.Equal(t, crypto.KeyTypeECP256, keyType, "annotation should override config")
+
+	client.SetAnnotations(map[string]string{clients.AnnotationKeyType: "DSA"})
+	_, err = clients.GetKeyType(client, cfg)
+	assert.Error(t, err, "should reject unsupported key type in annotation")
+}
+
+func TestGetSecretFormats(t *testing.T) {
+	cfg := &config.Config{}
+
+	client := fixtures.MinimalIDPortenClient()
+	formats, err := clients.GetSecretFormats(client, cfg)
+	assert.NoError(t, err)
+	assert.Empty(t, formats, "should have no additional formats if none configured")
+
+	cfg.DigDir.Common.SecretFormats = "pem"
+	formats, err = clients.GetSecretFormats(client, cfg)
+	assert.NoError(t, err)
+	assert.Equal(t, []crypto.SecretFormat{crypto.SecretFormatPEM}, formats, "should use formats from config")
+
+	client.SetAnnotations(map[string]string{clients.AnnotationSecretFormats: "pkcs12,pem"})
+	formats, err = clients.GetSecretFormats(client, cfg)
+	assert.NoError(t, err)
+	assert.Equal(t, []crypto.SecretFormat{crypto.SecretFormatPKCS12, crypto.SecretFormatPEM}, formats, "annotation should override config")
+
+	client.SetAnnotations(map[string]string{clients.AnnotationSecretFormats: ""})
+	formats, err = clients.GetSecretFormats(client, cfg)
+	assert.NoError(t, err)
+	assert.Empty(t, formats, "empty annotation should disable formats from config")
+
+	client.SetAnnotations(map[string]string{clients.AnnotationSecretFormats: "jks"})
+	_, err = clients.GetSecretFormats(client, cfg)
+	assert.Error(t, err, "should reject unsupported format in annotation")
+}
+
+func TestGetIdentity(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.DigDir.Identities = map[string]config.Identity{
+		"sister": {
+			Namespaces:        []string{"sister-namespace"},
+			AllowedNamespaces: []string{"shared-namespace"},
+		},
+		"other": {
+			AllowedNamespaces: []string{"*"},
+		},
+	}
+
+	for _, tt := range []struct {
+		name       string
+		namespace  string
+		annotation string
+		want       string
+		wantErr    bool
+	}{
+		{name: "default identity", namespace: "test-namespace", want: config.DefaultIdentity},
+		{name: "identity assigned to namespace", namespace: "sister-namespace", want: "sister"},
+		{name: "annotation selects default identity", namespace: "sister-namespace", annotation: config.DefaultIdentity, want: config.DefaultIdentity},
+		{name: "annotation selects allowed identity", namespace: "shared-namespace", annotation: "sister", want: "sister"},
+		{name: "annotation selects identity allowed in all namespaces", namespace: "test-namespace", annotation: "other", want: "other"},
+		{name: "annotation selects identity not allowed in namespace", namespace: "test-namespace", annotation: "sister", wantErr: true},
+		{name: "annotation selects unknown identity", namespace: "test-namespace", annotation: "unknown", wantErr: true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			client := fixtures.MinimalIDPortenClient()
+			client.SetNamespace(tt.namespace)
+			if tt.annotation != "" {
+				client.SetAnnotations(map[string]string{clients.AnnotationIdentity: tt.annotation})
+			}
+
+			identity, err := clients.GetIdentity(client, cfg)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, identity)
+		})
+	}
 }
 
 func TestIsUpToDate(t *testing.T) {
@@ -122,6 +245,24 @@ func TestIsUpToDate(t *testing.T) {
 	})
 }
 
+func TestSetExposedScopesACL(t *testing.T) {
+	client := fixtures.MinimalMaskinportenClient()
+
+	err := clients.SetExposedScopesACL(client, []scopes.ACL{
+		{Scope: "nav:b", Consumers: []scopes.ACLConsumer{{Orgno: "111111111", State: types.ScopeStateApproved}}},
+		{Scope: "nav:a", Consumers: []scopes.ACLConsumer{{Orgno: "222222222", State: scopes.ConsumerStateInvalid}}},
+	})
+	assert.NoError(t, err)
+	assert.JSONEq(t, `[
+		{"scope": "nav:a", "consumers": [{"orgno": "222222222", "state": "INVALID"}]},
+		{"scope": "nav:b", "consumers": [{"orgno": "111111111", "state": "APPROVED"}]}
+	]`, client.GetAnnotations()[clients.AnnotationExposedScopesACL])
+
+	err = clients.SetExposedScopesACL(client, nil)
+	assert.NoError(t, err)
+	assert.NotContains(t, client.GetAnnotations(), clients.AnnotationExposedScopesACL)
+}
+
 func TestToClientRegistration_IDPortenClient(t *testing.T) {
 	client := fixtures.MinimalIDPortenClient()
 	cluster := "test-cluster"
@@ -188,6 +329,41 @@ func TestToClientRegistration_IDPortenClient(t *testing.T) {
 	})
 }
 
+func TestToClientRegistration_AnsattportenClient(t *testing.T) {
+	client := fixtures.MinimalAnsattportenClient()
+	cfg := makeConfig("test-cluster")
+	registration := clients.ToClientRegistration(client, cfg)
+
+	assert.Equal(t, 3600, registration.AccessTokenLifetime)
+	assert.Equal(t, types.ApplicationTypeWeb, registration.ApplicationType)
+	assert.Equal(t, 7200, registration.AuthorizationLifeTime)
+	assert.Equal(t, "https://some-client-uri", registration.ClientURI)
+	assert.Equal(t, "some-client-name", registration.ClientName)
+	assert.Equal(t, "test-cluster:test-namespace:test-app", registration.Description)
+	assert.True(t, registration.FrontchannelLogoutSessionRequired)
+	assert.ElementsMatch(t, []types.GrantType{types.GrantTypeAuthorizationCode, types.GrantTypeRefreshToken}, registration.GrantTypes)
+	assert.Equal(t, types.IntegrationTypeAnsattporten, registration.IntegrationType)
+	assert.Equal(t, []string{"https://some-client-uri"}, registration.PostLogoutRedirectURIs)
+	assert.Equal(t, []string{"https://test.com"}, registration.RedirectURIs)
+	assert.Equal(t, 7200, registration.RefreshTokenLifetime)
+	assert.Equal(t, types.RefreshTokenUsageOneTime, registration.RefreshTokenUsage)
+	assert.Equal(t, []string{"openid", "profile"}, registration.Scopes)
+	assert.False(t, registration.SSODisabled)
+	assert.Equal(t, types.TokenEndpointAuthMethodPrivateKeyJwt, registration.TokenEndpointAuthMethod)
+
+	t.Run("spec overrides defaults", func(t *testing.T) {
+		client := fixtures.MinimalAnsattportenClient()
+		client.Spec.ClientName = "my-client"
+		client.Spec.Scopes = []string{"openid"}
+		client.Spec.SSODisabled = new(true)
+		registration := clients.ToClientRegistration(client, cfg)
+
+		assert.Equal(t, "my-client", registration.ClientName)
+		assert.Equal(t, []string{"openid"}, registration.Scopes)
+		assert.True(t, registration.SSODisabled)
+	})
+}
+
 func TestToClientRegistration_MaskinportenClient(t *testing.T) {
 	client := fixtures.MinimalMaskinportenClient()
 	cluster := "test-cluster"
@@ -322,7 +498,8 @@ func TestToScopeRegistration(t *testing.T) {
 	cfg := makeConfig(cluster)
 
 	assertDefaults := func(t *testing.T, registration types.ScopeRegistration) {
-		assert.Equal(t, "test-product - test-cluster:test-namespace:test-app", registration.Description)
+		assert.Equal(t, "test-product", registration.Description)
+		assert.Equal(t, "Managed by digdirator: test-cluster:test-namespace:test-app", registration.LongDescription)
 		assert.Equal(t, "test-product:test-scope", registration.Subscope)
 		assert.True(t, registration.Active)
 		assert.ElementsMatch(t, []string{"maskinporten"}, registration.AllowedIntegrationType)
@@ -343,7 +520,8 @@ func TestToScopeRegistration(t *testing.T) {
 			Product: "test-product",
 		}
 		client.Spec.Scopes.ExposedScopes = []naisiov1.ExposedScope{scope}
-		registration := clients.ToScopeRegistration(client, scope, cfg)
+		registration, err := clients.ToScopeRegistration(client, scope, cfg)
+		require.NoError(t, err)
 
 		assertDefaults(t, registration)
 		assert.False(t, registration.AccessibleForAll)
@@ -359,7 +537,8 @@ func TestToScopeRegistration(t *testing.T) {
 			AccessibleForAll: new(true),
 		}
 		client.Spec.Scopes.ExposedScopes = []naisiov1.ExposedScope{scope}
-		registration := clients.ToScopeRegistration(client, scope, cfg)
+		registration, err := clients.ToScopeRegistration(client, scope, cfg)
+		require.NoError(t, err)
 
 		assertDefaults(t, registration)
 		assert.True(t, registration.AccessibleForAll)
@@ -375,7 +554,8 @@ func TestToScopeRegistration(t *testing.T) {
 			DelegationSource: new("altinn"),
 		}
 		client.Spec.Scopes.ExposedScopes = []naisiov1.ExposedScope{scope}
-		registration := clients.ToScopeRegistration(client, scope, cfg)
+		registration, err := clients.ToScopeRegistration(client, scope, cfg)
+		require.NoError(t, err)
 
 		assertDefaults(t, registration)
 		assert.False(t, registration.AccessibleForAll)
@@ -391,11 +571,68 @@ func TestToScopeRegistration(t *testing.T) {
 			Visibility: new("private"),
 		}
 		client.Spec.Scopes.ExposedScopes = []naisiov1.ExposedScope{scope}
-		registration := clients.ToScopeRegistration(client, scope, cfg)
+		registration, err := clients.ToScopeRegistration(client, scope, cfg)
+		require.NoError(t, err)
 
 		assertDefaults(t, registration)
 		assert.Equal(t, types.ScopeVisibilityPrivate, registration.Visibility)
 	})
+
+	t.Run("description templates", func(t *testing.T) {
+		cfg := makeConfig(cluster)
+		cfg.DigDir.Maskinporten.Default.ScopeDescriptionTemplate = template.Must(scopes.ParseDescriptionTemplate("{{ .Product }} API"))
+		cfg.DigDir.Maskinporten.Default.ScopeLongDescriptionTemplate = template.Must(scopes.ParseDescriptionTemplate("Exposed by {{ .Resource }} as {{ .Subscope }}"))
+
+		scope := naisiov1.ExposedScope{Enabled: true, Name: "test-scope", Product: "test-product"}
+		registration, err := clients.ToScopeRegistration(client, scope, cfg)
+		require.NoError(t, err)
+		assert.Equal(t, "test-product API", registration.Description)
+		assert.Equal(t, "Exposed by test-app as test-product:test-scope\n\nManaged by digdirator: test-cluster:test-namespace:test-app", registration.LongDescription)
+	})
+
+	t.Run("descriptions from annotation", func(t *testing.T) {
+		client := fixtures.MinimalMaskinportenClient()
+		client.SetAnnotations(map[string]string{
+			clients.AnnotationExposedScopesDescriptions: `{"test-product:test-scope": {"description": "Test scope", "longDescription": "Lots of details"}}`,
+		})
+
+		scope := naisiov1.ExposedScope{Enabled: true, Name: "test-scope", Product: "test-product"}
+		registration, err := clients.ToScopeRegistration(client, scope, cfg)
+		require.NoError(t, err)
+		assert.Equal(t, "Test scope", registration.Description)
+		assert.Equal(t, "Lots of details\n\nManaged by digdirator: test-cluster:test-namespace:test-app", registration.LongDescription)
+
+		other := naisiov1.ExposedScope{Enabled: true, Name: "other-scope", Product: "test-product"}
+		registration, err = clients.ToScopeRegistration(client, other, cfg)
+		require.NoError(t, err)
+		assert.Equal(t, "test-product", registration.Description)
+	})
+
+	t.Run("maskinporten scope", func(t *testing.T) {
+		instance := &digdirv1alpha1.MaskinportenScope{
+			ObjectMeta: metav1.ObjectMeta{Name: "test-app", Namespace: "test-namespace"},
+			Spec: digdirv1alpha1.MaskinportenScopeSpec{
+				Enabled: true,
+				Name:    "test-scope",
+				Product: "test-product",
+			},
+		}
+		registration, err := clients.ToScopeRegistration(instance, instance.ExposedScope(), cfg)
+		require.NoError(t, err)
+		assertDefaults(t, registration)
+
+		instance.Spec.Description = "Test scope"
+		instance.Spec.LongDescription = "Lots of details"
+		registration, err = clients.ToScopeRegistration(instance, instance.ExposedScope(), cfg)
+		require.NoError(t, err)
+		assert.Equal(t, "Test scope", registration.Description)
+		assert.Equal(t, "Lots of details\n\nManaged by digdirator: test-cluster:test-namespace:test-app", registration.LongDescription)
+
+		key, ok := clients.ScopeOwnerKey(registration, cluster)
+		assert.True(t, ok)
+		assert.Equal(t, "test-namespace", key.Namespace)
+		assert.Equal(t, "test-app", key.Name)
+	})
 }
 
 func makeConfig(clusterName string) *config.Config {
@@ -422,3 +659,145 @@ func makeConfig(clusterName string) *config.Config {
 		},
 	}
 }
+
+func TestOwnerKey(t *testing.T) {
+	for _, tt := range []struct {
+		name        string
+		description string
+		want        client.ObjectKey
+		wantOK      bool
+	}{
+		{
+			name:        "matching cluster",
+			description: "test-cluster:test-namespace:test-app",
+			want:        client.ObjectKey{Namespace: "test-namespace", Name: "test-app"},
+			wantOK:      true,
+		},
+		{
+			name:        "other cluster",
+			description: "other-cluster:test-namespace:test-app",
+		},
+		{
+			name:        "missing name",
+			description: "test-cluster:test-namespace:",
+		},
+		{
+			name:        "too many parts",
+			description: "test-cluster:test-namespace:test-app:extra",
+		},
+		{
+			name:        "not a resource name",
+			description: "some manually registered client",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			key, ok := clients.OwnerKey(types.ClientRegistration{Description: tt.description}, "test-cluster")
+			assert.Equal(t, tt.wantOK, ok)
+			assert.Equal(t, tt.want, key)
+		})
+	}
+
+	t.Run("round trip", func(t *testing.T) {
+		instance := fixtures.MinimalIDPortenClient()
+		registration := clients.ToClientRegistration(instance, &config.Config{ClusterName: "test-cluster"})
+
+		key, ok := clients.OwnerKey(registration, "test-cluster")
+		assert.True(t, ok)
+		assert.Equal(t, "test-namespace", key.Namespace)
+		assert.Equal(t, "test-app", key.Name)
+	})
+}
+
+func TestScopeOwnerKey(t *testing.T) {
+	for _, tt := range []struct {
+		name            string
+		description     string
+		longDescription string
+		want            client.ObjectKey
+		wantOK          bool
+	}{
+		{
+			name:        "matching cluster",
+			description: "product - test-cluster:test-namespace:test-app",
+			want:        client.ObjectKey{Namespace: "test-namespace", Name: "test-app"},
+			wantOK:      true,
+		},
+		{
+			name:        "product with separator",
+			description: "some - product - test-cluster:test-namespace:test-app",
+			want:        client.ObjectKey{Namespace: "test-namespace", Name: "test-app"},
+			wantOK:      true,
+		},
+		{
+			name:        "other cluster",
+			description: "product - other-cluster:test-namespace:test-app",
+		},
+		{
+			name:        "missing product",
+			description: "test-cluster:test-namespace:test-app",
+		},
+		{
+			name:        "not a resource name",
+			description: "some manually registered scope",
+		},
+		{
+			name:            "owner marker",
+			description:     "Some scope",
+			longDescription: "Details\n\nManaged by digdirator: test-cluster:test-namespace:test-app",
+			want:            client.ObjectKey{Namespace: "test-namespace", Name: "test-app"},
+			wantOK:          true,
+		},
+		{
+			name:            "owner marker takes precedence over description",
+			description:     "product - test-cluster:other-namespace:other-app",
+			longDescription: "Managed by digdirator: test-cluster:test-namespace:test-app",
+			want:            client.ObjectKey{Namespace: "test-namespace", Name: "test-app"},
+			wantOK:          true,
+		},
+		{
+			name:            "owner marker in other cluster",
+			description:     "Some scope",
+			longDescription: "Managed by digdirator: other-cluster:test-namespace:test-app",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			key, ok := clients.ScopeOwnerKey(types.ScopeRegistration{Description: tt.description, LongDescription: tt.longDescription}, "test-cluster")
+			assert.Equal(t, tt.wantOK, ok)
+			assert.Equal(t, tt.want, key)
+		})
+	}
+
+	t.Run("round trip", func(t *testing.T) {
+		instance := fixtures.MinimalMaskinportenClient()
+		registration, err := clients.ToScopeRegistration(instance, naisiov1.ExposedScope{Product: "product", Name: "scope"}, &config.Config{ClusterName: "test-cluster"})
+		require.NoError(t, err)
+
+		key, ok := clients.ScopeOwnerKey(registration, "test-cluster")
+		assert.True(t, ok)
+		assert.Equal(t, "test-namespace", key.Namespace)
+		assert.Equal(t, "test-app", key.Name)
+	})
+}
+
+func TestNewInstanceFor(t *testing.T) {
+	for _, integrationType := range []types.IntegrationType{
+		types.IntegrationTypeIDPorten,
+		types.IntegrationTypeApiKlient,
+		types.IntegrationTypeKrr,
+	} {
+		instance, ok := clients.NewInstanceFor(integrationType)
+		assert.True(t, ok)
+		assert.IsType(t, &naisiov1.IDPortenClient{}, instance)
+	}
+
+	instance, ok := clients.NewInstanceFor(types.IntegrationTypeMaskinporten)
+	assert.True(t, ok)
+	assert.IsType(t, &naisiov1.MaskinportenClient{}, instance)
+
+	instance, ok = clients.NewInstanceFor(types.IntegrationTypeAnsattporten)
+	assert.True(t, ok)
+	assert.IsType(t, &digdirv1alpha1.AnsattportenClient{}, instance)
+
+	_, ok = clients.NewInstanceFor(types.IntegrationTypeUnknown)
+	assert.False(t, ok)
+}
@@ -3,6 +3,8 @@ package clients
 import (
 	nais_io_v1 "github.com/nais/liberator/pkg/apis/nais.io/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	digdirv1alpha1 "github.com/nais/digdirator/api/v1alpha1"
 )
 
 const (
@@ -10,6 +12,7 @@ const (
 	TypeLabelKey               string = "type"
 	IDPortenTypeLabelValue     string = "digdirator.nais.io"
 	MaskinportenTypeLabelValue string = "maskinporten.digdirator.nais.io"
+	AnsattportenTypeLabelValue string = "ansattporten.digdirator.nais.io"
 )
 
 func MakeLabels(instance Instance) map[string]string {
@@ -18,6 +21,8 @@ func MakeLabels(instance Instance) map[string]string {
 		return idPortenLabels(v)
 	case *nais_io_v1.MaskinportenClient:
 		return maskinportenLabels(v)
+	case *digdirv1alpha1.AnsattportenClient:
+		return ansattportenLabels(v)
 	}
 	return nil
 }
@@ -35,3 +40,10 @@ func idPortenLabels(instance metav1.Object) map[string]string {
 		TypeLabelKey: IDPortenTypeLabelValue,
 	}
 }
+
+func ansattportenLabels(instance metav1.Object) map[string]string {
+	return map[string]string{
+		AppLabelKey:  instance.GetName(),
+		TypeLabelKey: AnsattportenTypeLabelValue,
+	}
+}
@@ -30,3 +30,14 @@ func TestMakeLabels_MaskinportenClient(t *testing.T) {
 		clients.TypeLabelKey: clients.MaskinportenTypeLabelValue,
 	}, actual)
 }
+
+func TestMakeLabels_AnsattportenClient(t *testing.T) {
+	client := fixtures.MinimalAnsattportenClient()
+
+	actual := clients.MakeLabels(client)
+
+	assert.Equal(t, map[string]string{
+		clients.AppLabelKey:  client.GetName(),
+		clients.TypeLabelKey: clients.AnsattportenTypeLabelValue,
+	}, actual)
+}
@@ -0,0 +1,52 @@
+package clients
+
+import (
+	"strings"
+
+	naisiov1 "github.com/nais/liberator/pkg/apis/nais.io/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	digdirv1alpha1 "github.com/nais/digdirator/api/v1alpha1"
+	"github.com/nais/digdirator/pkg/digdir/scopes"
+	"github.com/nais/digdirator/pkg/digdir/types"
+)
+
+// OwnerKey returns the key of the resource that the given client registration was created for.
+// The key is derived from the registration's description, i.e. the uniform resource name of the resource.
+// Registrations created for other clusters, or not created by digdirator at all, return false.
+func OwnerKey(registration types.ClientRegistration, clusterName string) (client.ObjectKey, bool) {
+	return ownerKey(registration.Description, clusterName)
+}
+
+// ScopeOwnerKey returns the key of the MaskinportenClient or MaskinportenScope that the given scope registration was created for.
+// The key is derived from the uniform resource name in the scope's owner marker, see scopes.Owner.
+// Scopes created for other clusters, or not created by digdirator at all, return false.
+func ScopeOwnerKey(registration types.ScopeRegistration, clusterName string) (client.ObjectKey, bool) {
+	resourceName, ok := scopes.Owner(registration)
+	if !ok {
+		return client.ObjectKey{}, false
+	}
+	return ownerKey(resourceName, clusterName)
+}
+
+func ownerKey(resourceName, clusterName string) (client.ObjectKey, bool) {
+	parts := strings.Split(resourceName, ":")
+	if len(parts) != 3 || parts[0] != clusterName || parts[1] == "" || parts[2] == "" {
+		return client.ObjectKey{}, false
+	}
+
+	return client.ObjectKey{Namespace: parts[1], Name: parts[2]}, true
+}
+
+// NewInstanceFor returns an empty instance of the resource kind that manages clients with the given integration type.
+func NewInstanceFor(integrationType types.IntegrationType) (Instance, bool) {
+	switch integrationType {
+	case types.IntegrationTypeIDPorten, types.IntegrationTypeApiKlient, types.IntegrationTypeKrr:
+		return &naisiov1.IDPortenClient{}, true
+	case types.IntegrationTypeMaskinporten:
+		return &naisiov1.MaskinportenClient{}, true
+	case types.IntegrationTypeAnsattporten:
+		return &digdirv1alpha1.AnsattportenClient{}, true
+	}
+	return nil, false
+}
@@ -12,8 +12,12 @@ import (
 	"slices"
 	"sort"
 	"strings"
+	"text/template"
+	"time"
 
 	"github.com/go-viper/mapstructure/v2"
+	"github.com/nais/digdirator/pkg/crypto"
+	"github.com/nais/digdirator/pkg/digdir/scopes"
 	"github.com/nais/digdirator/pkg/digdir/types"
 	"github.com/nais/liberator/pkg/oauth"
 	flag "github.com/spf13/pflag"
@@ -23,34 +27,93 @@ import (
 var log *slog.Logger
 
 type Config struct {
-	MetricsAddr    string         `json:"metrics-address"`
-	ClusterName    string         `json:"cluster-name"`
-	DigDir         DigDir         `json:"digdir"`
-	Features       Features       `json:"features"`
-	LeaderElection LeaderElection `json:"leader-election"`
-	LogLevel       string         `json:"log-level"`
+	MetricsAddr             string           `json:"metrics-address"`
+	ClusterName             string           `json:"cluster-name"`
+	DigDir                  DigDir           `json:"digdir"`
+	DryRun                  bool             `json:"dry-run"`
+	Features                Features         `json:"features"`
+	GarbageCollector        GarbageCollector `json:"garbage-collector"`
+	LeaderElection          LeaderElection   `json:"leader-election"`
+	LogLevel                string           `json:"log-level"`
+	MaxConcurrentReconciles int              `json:"max-concurrent-reconciles"`
+	SecretTemplates         SecretTemplates  `json:"secret-templates"`
+	Tracing                 Tracing          `json:"tracing"`
 }
 
 type DigDir struct {
-	Admin        Admin        `json:"admin"`
-	IDPorten     IDPorten     `json:"idporten"`
-	Maskinporten Maskinporten `json:"maskinporten"`
-	Common       DigDirCommon `json:"common"`
+	Admin          Admin               `json:"admin"`
+	Ansattporten   Ansattporten        `json:"ansattporten"`
+	CircuitBreaker CircuitBreaker      `json:"circuit-breaker"`
+	ClientIndex    ClientIndex         `json:"client-index"`
+	IDPorten       IDPorten            `json:"idporten"`
+	Maskinporten   Maskinporten        `json:"maskinporten"`
+	Common         DigDirCommon        `json:"common"`
+	Identities     map[string]Identity `json:"identities"`
+	RateLimit      RateLimit           `json:"rate-limit"`
 }
 
 type DigDirCommon struct {
-	AccessTokenLifetime int    `json:"access-token-lifetime"`
-	ClientName          string `json:"client-name"`
-	ClientURI           string `json:"client-uri"`
-	SessionLifetime     int    `json:"session-lifetime"`
+	AccessTokenLifetime int           `json:"access-token-lifetime"`
+	ClientName          string        `json:"client-name"`
+	ClientURI           string        `json:"client-uri"`
+	KeyType             string        `json:"key-type"`
+	MaxKeyOverlap       time.Duration `json:"max-key-overlap"`
+	SecretFormats       string        `json:"secret-formats"`
+	SessionLifetime     int           `json:"session-lifetime"`
 }
 
 type Admin struct {
-	BaseURL    string `json:"base-url"`
-	ClientID   string `json:"client-id"`
-	CertChain  string `json:"cert-chain"`
-	KMSKeyPath string `json:"kms-key-path"`
-	Scopes     string `json:"scopes"`
+	BaseURL          string `json:"base-url"`
+	ClientID         string `json:"client-id"`
+	CertChain        string `json:"cert-chain"`
+	KeyFile          string `json:"key-file"`
+	KeyFileAlgorithm string `json:"key-file-algorithm"`
+	KeyFilePassword  string `json:"key-file-password"`
+	KMSKeyPath       string `json:"kms-key-path"`
+	Scopes           string `json:"scopes"`
+	Signer           string `json:"signer"`
+}
+
+// Identity is an additional admin identity for the DigDir self-service API, used to register clients on behalf of another
+// organization with its own business certificate. Identities can only be configured in the configuration file.
+// All identities share the base URL of the default identity. The scopes and signer are inherited unless set, the credentials never are.
+type Identity struct {
+	Admin `json:",squash"`
+	// Namespaces use the identity for all their resources, unless a resource selects another identity.
+	Namespaces []string `json:"namespaces"`
+	// AllowedNamespaces may select the identity for single resources with an annotation, in addition to Namespaces.
+	// The wildcard "*" allows all namespaces.
+	AllowedNamespaces []string `json:"allowed-namespaces"`
+}
+
+// Allows returns true if resources in the namespace may use the identity.
+func (i Identity) Allows(namespace string) bool {
+	return slices.Contains(i.Namespaces, namespace) ||
+		slices.Contains(i.AllowedNamespaces, namespace) ||
+		slices.Contains(i.AllowedNamespaces, "*")
+}
+
+// CircuitBreaker configures when requests to the DigDir self-service API are suspended due to an outage.
+type CircuitBreaker struct {
+	FailureThreshold int           `json:"failure-threshold"`
+	Cooldown         time.Duration `json:"cooldown"`
+}
+
+// ClientIndex configures the in-memory index of client registrations in DigDir.
+type ClientIndex struct {
+	TTL time.Duration `json:"ttl"`
+}
+
+// RateLimit configures the client-side limits for requests to the DigDir self-service API.
+type RateLimit struct {
+	RequestsPerSecond float64 `json:"requests-per-second"`
+	Burst             int     `json:"burst"`
+	MaxInFlight       int     `json:"max-in-flight"`
+}
+
+type Ansattporten struct {
+	WellKnownURL string `json:"well-known-url"`
+	Metadata     oauth.MetadataOpenID
 }
 
 type IDPorten struct {
@@ -66,13 +129,75 @@ type Maskinporten struct {
 }
 
 type MaskinportenDefault struct {
-	ClientScope string `json:"client-scope"`
-	ScopePrefix string `json:"scope-prefix"`
+	ClientScope          string `json:"client-scope"`
+	ScopePrefix          string `json:"scope-prefix"`
+	ScopeDescription     string `json:"scope-description"`
+	ScopeLongDescription string `json:"scope-long-description"`
+	// ScopeDescriptionTemplate and ScopeLongDescriptionTemplate are parsed from the above when the config is validated.
+	ScopeDescriptionTemplate     *template.Template `json:"-"`
+	ScopeLongDescriptionTemplate *template.Template `json:"-"`
+}
+
+// SecretTemplates add or rename keys in the secrets generated for clients. Templates can only be configured in the configuration file.
+type SecretTemplates struct {
+	// SecretTemplate applies to clients in all namespaces.
+	SecretTemplate `json:",squash"`
+	// Namespaces have templates that are applied in addition to the cluster-wide templates, and take precedence for the same keys.
+	Namespaces map[string]SecretTemplate `json:"namespaces"`
+}
+
+type SecretTemplate struct {
+	Keys   []SecretKeyTemplate `json:"keys"`
+	Rename []SecretKeyRename   `json:"rename"`
+}
+
+// SecretKeyTemplate adds a key with a value rendered from a text/template.
+type SecretKeyTemplate struct {
+	Name     string `json:"name"`
+	Template string `json:"template"`
+	// Parsed is parsed from the template at startup, see secrets.ParseTemplates.
+	Parsed *template.Template `json:"-"`
+}
+
+// SecretKeyRename writes a generated key with another name.
+type SecretKeyRename struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// For returns the templates that apply to clients in the namespace.
+func (s SecretTemplates) For(namespace string) SecretTemplate {
+	override := s.Namespaces[namespace]
+
+	keys := slices.Clone(s.Keys)
+	for _, key := range override.Keys {
+		keys = slices.DeleteFunc(keys, func(k SecretKeyTemplate) bool { return k.Name == key.Name })
+		keys = append(keys, key)
+	}
+
+	rename := slices.Clone(s.Rename)
+	for _, r := range override.Rename {
+		rename = slices.DeleteFunc(rename, func(other SecretKeyRename) bool { return other.From == r.From })
+		rename = append(rename, r)
+	}
+
+	return SecretTemplate{Keys: keys, Rename: rename}
 }
 
 type Features struct {
-	IDPorten     bool `json:"idporten"`
-	Maskinporten bool `json:"maskinporten"`
+	Ansattporten       bool `json:"ansattporten"`
+	IDPorten           bool `json:"idporten"`
+	Maskinporten       bool `json:"maskinporten"`
+	MaskinportenScopes bool `json:"maskinporten-scopes"`
+}
+
+type GarbageCollector struct {
+	Enabled          bool          `json:"enabled"`
+	Delete           bool          `json:"delete"`
+	GracePeriod      time.Duration `json:"grace-period"`
+	ScopeGracePeriod time.Duration `json:"scope-grace-period"`
+	Interval         time.Duration `json:"interval"`
+	Namespace        string        `json:"namespace"`
 }
 
 type LeaderElection struct {
@@ -80,30 +205,71 @@ type LeaderElection struct {
 	Namespace string `json:"namespace"`
 }
 
+type Tracing struct {
+	Enabled bool `json:"enabled"`
+}
+
 const (
 	LogLevel                = "log-level"
 	MetricsAddress          = "metrics-address"
 	ClusterName             = "cluster-name"
+	DryRun                  = "dry-run"
 	LeaderElectionEnabled   = "leader-election.enabled"
 	LeaderElectionNamespace = "leader-election.namespace"
+	MaxConcurrentReconciles = "max-concurrent-reconciles"
+	TracingEnabled          = "tracing.enabled"
+
+	DigDirAdminBaseURL          = "digdir.admin.base-url"
+	DigDirAdminClientID         = "digdir.admin.client-id"
+	DigDirAdminCertChain        = "digdir.admin.cert-chain"
+	DigDirAdminKeyFile          = "digdir.admin.key-file"
+	DigDirAdminKeyFileAlgorithm = "digdir.admin.key-file-algorithm"
+	DigDirAdminKeyFilePassword  = "digdir.admin.key-file-password"
+	DigDirAdminKmsKeyPath       = "digdir.admin.kms-key-path"
+	DigDirAdminScopes           = "digdir.admin.scopes"
+	DigDirAdminSigner           = "digdir.admin.signer"
+
+	DigDirAnsattportenWellKnownURL                = "digdir.ansattporten.well-known-url"
+	DigDirCircuitBreakerFailureThreshold          = "digdir.circuit-breaker.failure-threshold"
+	DigDirCircuitBreakerCooldown                  = "digdir.circuit-breaker.cooldown"
+	DigDirClientIndexTTL                          = "digdir.client-index.ttl"
+	DigDirCommonClientName                        = "digdir.common.client-name"
+	DigDirCommonClientURI                         = "digdir.common.client-uri"
+	DigDirCommonAccessTokenLifetime               = "digdir.common.access-token-lifetime"
+	DigDirCommonKeyType                           = "digdir.common.key-type"
+	DigDirCommonMaxKeyOverlap                     = "digdir.common.max-key-overlap"
+	DigDirCommonSecretFormats                     = "digdir.common.secret-formats"
+	DigDirCommonSessionLifetime                   = "digdir.common.session-lifetime"
+	DigDirIDPortenWellKnownURL                    = "digdir.idporten.well-known-url"
+	DigDirMaskinportenDefaultClientScope          = "digdir.maskinporten.default.client-scope"
+	DigDirMaskinportenDefaultScopePrefix          = "digdir.maskinporten.default.scope-prefix"
+	DigDirMaskinportenDefaultScopeDescription     = "digdir.maskinporten.default.scope-description"
+	DigDirMaskinportenDefaultScopeLongDescription = "digdir.maskinporten.default.scope-long-description"
+	DigDirMaskinportenWellKnownURL                = "digdir.maskinporten.well-known-url"
+	DigDirRateLimitRequestsPerSecond              = "digdir.rate-limit.requests-per-second"
+	DigDirRateLimitBurst                          = "digdir.rate-limit.burst"
+	DigDirRateLimitMaxInFlight                    = "digdir.rate-limit.max-in-flight"
+
+	FeaturesAnsattporten       = "features.ansattporten"
+	FeaturesIDPorten           = "features.idporten"
+	FeaturesMaskinporten       = "features.maskinporten"
+	FeaturesMaskinportenScopes = "features.maskinporten-scopes"
+
+	GarbageCollectorEnabled          = "garbage-collector.enabled"
+	GarbageCollectorDelete           = "garbage-collector.delete"
+	GarbageCollectorGracePeriod      = "garbage-collector.grace-period"
+	GarbageCollectorScopeGracePeriod = "garbage-collector.scope-grace-period"
+	GarbageCollectorInterval         = "garbage-collector.interval"
+	GarbageCollectorNamespace        = "garbage-collector.namespace"
+)
+
+// DefaultIdentity is the name of the admin identity configured with the digdir.admin options.
+const DefaultIdentity = "default"
 
-	DigDirAdminBaseURL    = "digdir.admin.base-url"
-	DigDirAdminClientID   = "digdir.admin.client-id"
-	DigDirAdminCertChain  = "digdir.admin.cert-chain"
-	DigDirAdminKmsKeyPath = "digdir.admin.kms-key-path"
-	DigDirAdminScopes     = "digdir.admin.scopes"
-
-	DigDirCommonClientName               = "digdir.common.client-name"
-	DigDirCommonClientURI                = "digdir.common.client-uri"
-	DigDirCommonAccessTokenLifetime      = "digdir.common.access-token-lifetime"
-	DigDirCommonSessionLifetime          = "digdir.common.session-lifetime"
-	DigDirIDPortenWellKnownURL           = "digdir.idporten.well-known-url"
-	DigDirMaskinportenDefaultClientScope = "digdir.maskinporten.default.client-scope"
-	DigDirMaskinportenDefaultScopePrefix = "digdir.maskinporten.default.scope-prefix"
-	DigDirMaskinportenWellKnownURL       = "digdir.maskinporten.well-known-url"
-
-	FeaturesIDPorten     = "features.idporten"
-	FeaturesMaskinporten = "features.maskinporten"
+// Supported values for DigDirAdminSigner.
+const (
+	AdminSignerKMS  = "kms"
+	AdminSignerFile = "file"
 )
 
 func init() {
@@ -121,28 +287,58 @@ func init() {
 
 	flag.String(MetricsAddress, ":8080", "The address the metric endpoint binds to.")
 	flag.String(ClusterName, "", "The cluster in which this application should run.")
+	flag.Bool(DryRun, false, "Toggle for dry-run mode. Changes to DigDir are planned and reported instead of performed, and secrets are left untouched. Deleted resources keep their finalizer until dry-run mode is disabled.")
 	flag.Bool(LeaderElectionEnabled, false, "Toggle for enabling leader election.")
 	flag.String(LeaderElectionNamespace, "", "Namespace for the leader election resource. Needed if not running in-cluster (e.g. locally). If empty, will default to the same namespace as the running application.")
 	flag.String(LogLevel, "info", "Log level for digdirator.")
+	flag.Int(MaxConcurrentReconciles, 1, "Maximum number of resources reconciled in parallel by each controller.")
+	flag.Bool(TracingEnabled, false, "Toggle for exporting traces with OTLP. The exporter is configured with the standard OTEL_* environment variables.")
 
 	flag.String(DigDirAdminBaseURL, "", "Base URL endpoint for interacting with DigDir self service API")
 	flag.String(DigDirAdminClientID, "", "Client ID / issuer for JWT assertion when authenticating with DigDir self service API.")
 	flag.String(DigDirAdminScopes, "idporten:dcr.write idporten:dcr.read idporten:scopes.write", "List of space-separated scopes for JWT assertion when authenticating with DigDir self service API.")
 	flag.String(DigDirAdminKmsKeyPath, "projects/<project-id>/locations/<location>/keyRings/<key-ring-name>/cryptoKeys/<key-name>/cryptoKeyVersions/<key-version>", "Resource path to Google KMS key used to sign JWT assertion.")
 	flag.String(DigDirAdminCertChain, "", "Full certificate chain in PEM format for business certificate used to sign JWT assertion.")
+	flag.String(DigDirAdminSigner, AdminSignerKMS, fmt.Sprintf("Signer for the JWT assertion, one of [%s, %s].", AdminSignerKMS, AdminSignerFile))
+	flag.String(DigDirAdminKeyFile, "", "Path to file containing the private key for the business certificate, either in PEM format or as a PKCS#12 keystore. Used with the 'file' signer. PEM files may also contain the certificate chain.")
+	flag.String(DigDirAdminKeyFileAlgorithm, "", "JWS algorithm for signing with the private key in the key file, e.g. PS256 for RSA keys. Defaults to RS256 for RSA keys and the ES algorithm matching the curve for EC keys.")
+	flag.String(DigDirAdminKeyFilePassword, "", "Password for the PKCS#12 keystore in the key file.")
+
+	flag.String(DigDirAnsattportenWellKnownURL, "", "URL to Ansattporten well-known discovery metadata document.")
+
+	flag.Int(DigDirCircuitBreakerFailureThreshold, 5, "Number of consecutive server errors or timeouts from DigDir before requests are suspended. Set to 0 to disable.")
+	flag.Duration(DigDirCircuitBreakerCooldown, 1*time.Minute, "Duration that requests to DigDir are suspended before DigDir is probed again.")
+	flag.Duration(DigDirClientIndexTTL, 5*time.Minute, "Duration that client registrations listed from DigDir are used for lookups before they are listed again. Set to 0 to disable the index.")
 
 	flag.String(DigDirCommonClientName, "ARBEIDS- OG VELFERDSETATEN", "Default name for all provisioned clients. Appears in the login prompt for ID-porten.")
 	flag.String(DigDirCommonClientURI, "https://www.nav.no", "Default client URI for all provisioned clients. Appears in the back-button for the login prompt for ID-porten.")
 	flag.Int(DigDirCommonAccessTokenLifetime, 3600, "Default lifetime (in seconds) for access tokens for all clients.")
+	flag.String(DigDirCommonKeyType, string(crypto.DefaultKeyType), fmt.Sprintf("Default key type for generated client JWKs, one of %v. Can be overridden per resource with the %q annotation.", crypto.KeyTypes, "digdir.nais.io/key-type"))
+	flag.Duration(DigDirCommonMaxKeyOverlap, 0, "Maximum duration that a previous key is kept registered after the key is rotated, even if it is still used by pods. Set to 0 to keep previous keys until they are no longer used.")
+	flag.String(DigDirCommonSecretFormats, "", fmt.Sprintf("Comma-separated list of additional formats for the client key in generated secrets, any of %v. Can be overridden per resource with the %q annotation.", crypto.SecretFormats, "digdir.nais.io/secret-formats"))
 	flag.Int(DigDirCommonSessionLifetime, 7200, "Default lifetime (in seconds) for sessions (authorization and refresh token lifetime) for all clients.")
 
 	flag.String(DigDirIDPortenWellKnownURL, "", "URL to ID-porten well-known discovery metadata document.")
 	flag.String(DigDirMaskinportenDefaultClientScope, "nav:test/api", "Default scope for provisioned Maskinporten clients, if none specified in spec.")
 	flag.String(DigDirMaskinportenDefaultScopePrefix, "nav", "Default scope prefix for provisioned Maskinporten scopes.")
+	flag.String(DigDirMaskinportenDefaultScopeDescription, "{{ .Product }}", "Go template for the description of provisioned Maskinporten scopes, unless set for the scope.")
+	flag.String(DigDirMaskinportenDefaultScopeLongDescription, "", "Go template for the long description of provisioned Maskinporten scopes, unless set for the scope.")
 	flag.String(DigDirMaskinportenWellKnownURL, "", "URL to Maskinporten well-known discovery metadata document.")
+	flag.Float64(DigDirRateLimitRequestsPerSecond, 10, "Maximum sustained rate of requests per second to the DigDir self-service API. Set to 0 to disable.")
+	flag.Int(DigDirRateLimitBurst, 20, "Maximum burst of requests to the DigDir self-service API above the sustained rate.")
+	flag.Int(DigDirRateLimitMaxInFlight, 10, "Maximum number of concurrent requests to the DigDir self-service API. Set to 0 to disable.")
 
+	flag.Bool(FeaturesAnsattporten, false, "Feature toggle for ansattporten")
 	flag.Bool(FeaturesMaskinporten, false, "Feature toggle for maskinporten")
 	flag.Bool(FeaturesIDPorten, true, "Feature toggle for idporten")
+	flag.Bool(FeaturesMaskinportenScopes, false, "Feature toggle for the MaskinportenScope resource. Requires the MaskinportenScope CRD to be installed.")
+
+	flag.Bool(GarbageCollectorEnabled, false, "Toggle for periodically detecting clients and exposed scopes in DigDir that belong to this cluster, but have no matching resource.")
+	flag.Bool(GarbageCollectorDelete, false, "Toggle for deleting orphaned clients and deactivating orphaned scopes in DigDir after their grace period. If disabled, orphans are only reported.")
+	flag.Duration(GarbageCollectorGracePeriod, 7*24*time.Hour, "Duration that a client must have been continuously orphaned before it is deleted.")
+	flag.Duration(GarbageCollectorScopeGracePeriod, 7*24*time.Hour, "Duration that an exposed scope must have been continuously orphaned before it is deactivated, which revokes access for all consumers.")
+	flag.Duration(GarbageCollectorInterval, 1*time.Hour, "Interval between each search for orphaned clients.")
+	flag.String(GarbageCollectorNamespace, "", "Namespace for the ConfigMap that tracks clients preserved on deletion. If empty, will default to the same namespace as the running application.")
 }
 
 // Print out all configuration options except secret stuff.
@@ -163,7 +359,7 @@ func (c Config) Print(redacted []string) {
 	}
 }
 
-func (c Config) Validate(required []string) error {
+func (c *Config) Validate(required []string) error {
 	present := func(key string) bool {
 		for _, requiredKey := range required {
 			if requiredKey == key {
@@ -193,9 +389,169 @@ func (c Config) Validate(required []string) error {
 		return fmt.Errorf("parsing %q: %w", DigDirAdminBaseURL, err)
 	}
 
+	if !slices.Contains([]string{AdminSignerKMS, AdminSignerFile}, c.DigDir.Admin.Signer) {
+		return fmt.Errorf("%q must be one of [%s, %s], got %q", DigDirAdminSigner, AdminSignerKMS, AdminSignerFile, c.DigDir.Admin.Signer)
+	}
+
+	if err := c.DigDir.validateIdentities(); err != nil {
+		return err
+	}
+
+	if _, err := crypto.ParseKeyType(c.DigDir.Common.KeyType); err != nil {
+		return fmt.Errorf("parsing %q: %w", DigDirCommonKeyType, err)
+	}
+
+	if _, err := crypto.ParseSecretFormats(c.DigDir.Common.SecretFormats); err != nil {
+		return fmt.Errorf("parsing %q: %w", DigDirCommonSecretFormats, err)
+	}
+
+	var err error
+	defaults := &c.DigDir.Maskinporten.Default
+	if defaults.ScopeDescriptionTemplate, err = scopes.ParseDescriptionTemplate(defaults.ScopeDescription); err != nil {
+		return fmt.Errorf("parsing %q: %w", DigDirMaskinportenDefaultScopeDescription, err)
+	}
+
+	if defaults.ScopeLongDescriptionTemplate, err = scopes.ParseDescriptionTemplate(defaults.ScopeLongDescription); err != nil {
+		return fmt.Errorf("parsing %q: %w", DigDirMaskinportenDefaultScopeLongDescription, err)
+	}
+
+	if c.Features.MaskinportenScopes && !c.Features.Maskinporten {
+		return fmt.Errorf("%q requires %q", FeaturesMaskinportenScopes, FeaturesMaskinporten)
+	}
+
+	if c.MaxConcurrentReconciles < 1 {
+		return fmt.Errorf("%q must be at least 1, got %d", MaxConcurrentReconciles, c.MaxConcurrentReconciles)
+	}
+
+	if c.DigDir.CircuitBreaker.FailureThreshold > 0 && c.DigDir.CircuitBreaker.Cooldown <= 0 {
+		return fmt.Errorf("%q must be positive, got %s", DigDirCircuitBreakerCooldown, c.DigDir.CircuitBreaker.Cooldown)
+	}
+
+	if c.DigDir.Common.MaxKeyOverlap < 0 {
+		return fmt.Errorf("%q must not be negative, got %s", DigDirCommonMaxKeyOverlap, c.DigDir.Common.MaxKeyOverlap)
+	}
+
+	if c.DigDir.ClientIndex.TTL < 0 {
+		return fmt.Errorf("%q must not be negative, got %s", DigDirClientIndexTTL, c.DigDir.ClientIndex.TTL)
+	}
+
+	if c.DigDir.RateLimit.RequestsPerSecond < 0 {
+		return fmt.Errorf("%q must not be negative, got %v", DigDirRateLimitRequestsPerSecond, c.DigDir.RateLimit.RequestsPerSecond)
+	}
+
+	if c.DigDir.RateLimit.Burst < 0 {
+		return fmt.Errorf("%q must not be negative, got %d", DigDirRateLimitBurst, c.DigDir.RateLimit.Burst)
+	}
+
+	if c.DigDir.RateLimit.MaxInFlight < 0 {
+		return fmt.Errorf("%q must not be negative, got %d", DigDirRateLimitMaxInFlight, c.DigDir.RateLimit.MaxInFlight)
+	}
+
+	if c.GarbageCollector.Enabled && c.GarbageCollector.Interval <= 0 {
+		return fmt.Errorf("%q must be positive, got %s", GarbageCollectorInterval, c.GarbageCollector.Interval)
+	}
+
+	if c.GarbageCollector.GracePeriod < 0 {
+		return fmt.Errorf("%q must not be negative, got %s", GarbageCollectorGracePeriod, c.GarbageCollector.GracePeriod)
+	}
+
+	if c.GarbageCollector.ScopeGracePeriod < 0 {
+		return fmt.Errorf("%q must not be negative, got %s", GarbageCollectorScopeGracePeriod, c.GarbageCollector.ScopeGracePeriod)
+	}
+
+	return nil
+}
+
+func (d DigDir) validateIdentities() error {
+	namespaces := make(map[string]string)
+
+	for _, name := range d.IdentityNames() {
+		if name == DefaultIdentity {
+			continue
+		}
+
+		identity := d.Identities[name]
+		admin, _ := d.Identity(name)
+		key := func(option string) string {
+			return fmt.Sprintf("digdir.identities.%s.%s", name, option)
+		}
+
+		if admin.ClientID == "" {
+			return fmt.Errorf("%q must be set", key("client-id"))
+		}
+
+		switch admin.Signer {
+		case AdminSignerKMS:
+			if admin.KMSKeyPath == "" || admin.CertChain == "" {
+				return fmt.Errorf("%q and %q must be set with the %s signer", key("kms-key-path"), key("cert-chain"), AdminSignerKMS)
+			}
+		case AdminSignerFile:
+			if admin.KeyFile == "" {
+				return fmt.Errorf("%q must be set with the %s signer", key("key-file"), AdminSignerFile)
+			}
+		default:
+			return fmt.Errorf("%q must be one of [%s, %s], got %q", key("signer"), AdminSignerKMS, AdminSignerFile, admin.Signer)
+		}
+
+		for _, namespace := range identity.Namespaces {
+			if other, found := namespaces[namespace]; found {
+				return fmt.Errorf("namespace %q is assigned to both identity %q and %q", namespace, other, name)
+			}
+			namespaces[namespace] = name
+		}
+	}
+
+	if _, found := d.Identities[DefaultIdentity]; found {
+		return fmt.Errorf("identity name %q is reserved for the %q options", DefaultIdentity, "digdir.admin")
+	}
+
 	return nil
 }
 
+// IdentityNames returns the names of all admin identities in sorted order, including the default identity.
+func (d DigDir) IdentityNames() []string {
+	names := []string{DefaultIdentity}
+	for name := range d.Identities {
+		if name != DefaultIdentity {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names[1:])
+	return names
+}
+
+// Identity returns the admin options for the named identity, with options that are not set inherited from the default identity.
+func (d DigDir) Identity(name string) (Admin, bool) {
+	if name == DefaultIdentity {
+		return d.Admin, true
+	}
+
+	identity, found := d.Identities[name]
+	if !found {
+		return Admin{}, false
+	}
+
+	admin := identity.Admin
+	admin.BaseURL = d.Admin.BaseURL
+	if admin.Scopes == "" {
+		admin.Scopes = d.Admin.Scopes
+	}
+	if admin.Signer == "" {
+		admin.Signer = d.Admin.Signer
+	}
+	return admin, true
+}
+
+// IdentityFor returns the name of the identity that resources in the namespace use, unless they select another identity.
+func (d DigDir) IdentityFor(namespace string) string {
+	for name, identity := range d.Identities {
+		if slices.Contains(identity.Namespaces, namespace) {
+			return name
+		}
+	}
+	return DefaultIdentity
+}
+
 func (c Config) WithProviderMetadata(ctx context.Context) (*Config, error) {
 	maskinportenMetadata, err := oauth.NewMetadataOAuth(ctx, c.DigDir.Maskinporten.WellKnownURL)
 	if err != nil {
@@ -218,6 +574,14 @@ func (c Config) WithProviderMetadata(ctx context.Context) (*Config, error) {
 		c.DigDir.IDPorten.Metadata = *idportenMetadata
 	}
 
+	if c.Features.Ansattporten {
+		ansattportenMetadata, err := oauth.NewMetadataOpenID(ctx, c.DigDir.Ansattporten.WellKnownURL)
+		if err != nil {
+			return nil, fmt.Errorf("resolving Ansattporten metadata from %q: %w", c.DigDir.Ansattporten.WellKnownURL, err)
+		}
+		c.DigDir.Ansattporten.Metadata = *ansattportenMetadata
+	}
+
 	return &c, nil
 }
 
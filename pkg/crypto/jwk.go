@@ -2,6 +2,7 @@ package crypto
 
 import (
 	"fmt"
+	"slices"
 
 	"github.com/go-jose/go-jose/v4"
 	"github.com/google/uuid"
@@ -13,19 +14,20 @@ var ErrNoPreviousJwkFound = fmt.Errorf("no previous JWK found in secrets")
 
 const (
 	KeyUseSignature string = "sig"
-	KeyAlgorithm    string = "RS256"
+	// KeyAlgorithm is the algorithm used with RSA keys.
+	KeyAlgorithm string = "RS256"
 )
 
-func GenerateJwk() (*jose.JSONWebKey, error) {
-	privateKey, err := GenerateRSAKey()
+func GenerateJwk(keyType KeyType) (*jose.JSONWebKey, error) {
+	privateKey, err := keyType.generate()
 	if err != nil {
-		return nil, fmt.Errorf("generating RSA key for JWK: %w", err)
+		return nil, fmt.Errorf("generating %s key for JWK: %w", keyType, err)
 	}
 	jwk := &jose.JSONWebKey{
 		Key:       privateKey,
 		KeyID:     uuid.New().String(),
 		Use:       KeyUseSignature,
-		Algorithm: KeyAlgorithm,
+		Algorithm: keyType.Algorithm(),
 	}
 	return jwk, nil
 }
@@ -49,3 +51,35 @@ func GetPreviousJwkFromSecret(managedSecrets kubernetes.SecretLists, secretKey s
 
 	return nil, ErrNoPreviousJwkFound
 }
+
+// GetJwkFromSecret returns the private JWK in the secret.
+func GetJwkFromSecret(secret corev1.Secret, secretKey string) (*jose.JSONWebKey, error) {
+	key, err := getJWKFromSecret(secret, secretKey)
+	if err != nil {
+		return nil, fmt.Errorf("getting jwk from secret: %w", err)
+	}
+	if key == nil {
+		return nil, ErrNoPreviousJwkFound
+	}
+	if key.IsPublic() {
+		return nil, fmt.Errorf("jwk %q in secret is not a private key", key.KeyID)
+	}
+	return key, nil
+}
+
+// GetRegisteredJwkFromSecrets returns the registered JWK in the newest of the managed secrets that holds one.
+// Secrets with a missing, invalid or unregistered JWK are skipped.
+func GetRegisteredJwkFromSecrets(managedSecrets kubernetes.SecretLists, secretKey string, keyIDs []string) (*jose.JSONWebKey, error) {
+	candidates := slices.Concat(managedSecrets.Used.Items, managedSecrets.Unused.Items)
+	slices.SortStableFunc(candidates, func(a, b corev1.Secret) int {
+		return b.CreationTimestamp.Time.Compare(a.CreationTimestamp.Time)
+	})
+
+	for _, secret := range candidates {
+		key, err := GetJwkFromSecret(secret, secretKey)
+		if err == nil && slices.Contains(keyIDs, key.KeyID) {
+			return key, nil
+		}
+	}
+	return nil, ErrNoPreviousJwkFound
+}
@@ -0,0 +1,86 @@
+package crypto_test
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/go-jose/go-jose/v4"
+	"github.com/nais/liberator/pkg/kubernetes"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/nais/digdirator/pkg/crypto"
+	"github.com/nais/digdirator/pkg/secrets"
+)
+
+func TestGetJwkFromSecrets(t *testing.T) {
+	now := time.Now()
+	secret := func(age time.Duration, jwk any) corev1.Secret {
+		data := map[string][]byte{}
+		switch v := jwk.(type) {
+		case *jose.JSONWebKey:
+			b, err := json.Marshal(v)
+			require.NoError(t, err)
+			data[secrets.IDPortenJwkKey] = b
+		case string:
+			data[secrets.IDPortenJwkKey] = []byte(v)
+		}
+		return corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{CreationTimestamp: metav1.NewTime(now.Add(-age))},
+			Data:       data,
+		}
+	}
+
+	oldest, err := crypto.GenerateJwk(crypto.DefaultKeyType)
+	require.NoError(t, err)
+	older, err := crypto.GenerateJwk(crypto.DefaultKeyType)
+	require.NoError(t, err)
+	unregistered, err := crypto.GenerateJwk(crypto.DefaultKeyType)
+	require.NoError(t, err)
+	public := older.Public()
+
+	managed := kubernetes.SecretLists{
+		Used: corev1.SecretList{Items: []corev1.Secret{
+			secret(3*time.Hour, oldest),
+			secret(2*time.Hour, older),
+			secret(time.Minute, unregistered),
+			secret(time.Second, "not a jwk"),
+		}},
+		Unused: corev1.SecretList{Items: []corev1.Secret{
+			secret(time.Hour, &public),
+			secret(0, nil),
+		}},
+	}
+	keyIDs := []string{oldest.KeyID, older.KeyID}
+
+	t.Run("newest registered private key", func(t *testing.T) {
+		actual, err := crypto.GetRegisteredJwkFromSecrets(managed, secrets.IDPortenJwkKey, keyIDs)
+		require.NoError(t, err)
+		assert.Equal(t, older.KeyID, actual.KeyID)
+		assert.False(t, actual.IsPublic())
+	})
+
+	t.Run("no registered keys", func(t *testing.T) {
+		_, err := crypto.GetRegisteredJwkFromSecrets(managed, secrets.IDPortenJwkKey, []string{"other"})
+		assert.ErrorIs(t, err, crypto.ErrNoPreviousJwkFound)
+	})
+
+	t.Run("single secret", func(t *testing.T) {
+		actual, err := crypto.GetJwkFromSecret(secret(0, unregistered), secrets.IDPortenJwkKey)
+		require.NoError(t, err)
+		assert.Equal(t, unregistered.KeyID, actual.KeyID)
+
+		_, err = crypto.GetJwkFromSecret(secret(0, nil), secrets.IDPortenJwkKey)
+		assert.ErrorIs(t, err, crypto.ErrNoPreviousJwkFound)
+
+		_, err = crypto.GetJwkFromSecret(secret(0, &public), secrets.IDPortenJwkKey)
+		assert.ErrorContains(t, err, "not a private key")
+
+		_, err = crypto.GetJwkFromSecret(secret(0, "not a jwk"), secrets.IDPortenJwkKey)
+		assert.Error(t, err)
+		assert.NotErrorIs(t, err, crypto.ErrNoPreviousJwkFound)
+	})
+}
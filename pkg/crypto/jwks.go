@@ -69,5 +69,8 @@ func getJWKFromSecret(secret v1.Secret, key string) (*jose.JSONWebKey, error) {
 	if err := jwk.UnmarshalJSON(jwkBytes); err != nil {
 		return nil, fmt.Errorf("unmarshalling JWK from secret")
 	}
+	if jwk.Algorithm == "" {
+		jwk.Algorithm = algorithmForKey(jwk.Key)
+	}
 	return &jwk, nil
 }
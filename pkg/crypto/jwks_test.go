@@ -44,7 +44,7 @@ func TestMergeJwks(t *testing.T) {
 			},
 		},
 	}
-	newJwk, err := crypto.GenerateJwk()
+	newJwk, err := crypto.GenerateJwk(crypto.DefaultKeyType)
 	assert.NoError(t, err)
 
 	jwks, err := crypto.MergeJwks(*newJwk, secretsInUse, secrets.IDPortenJwkKey)
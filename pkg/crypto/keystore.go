@@ -0,0 +1,57 @@
+package crypto
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/go-jose/go-jose/v4"
+)
+
+var certificateNotAfter = time.Date(9999, time.December, 31, 23, 59, 59, 0, time.UTC)
+
+// GenerateKeystorePassword returns a random password for a keystore.
+func GenerateKeystorePassword() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("generating password: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// SelfSignedCertificate returns a certificate for the public key of the JWK, signed by its private key.
+// Keystores hold private keys along with a certificate, which is named by the key ID.
+func SelfSignedCertificate(jwk jose.JSONWebKey) (*x509.Certificate, error) {
+	if jwk.IsPublic() {
+		return nil, fmt.Errorf("JWK %q has no private key", jwk.KeyID)
+	}
+
+	signer, ok := jwk.Key.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("unsupported private key type %T", jwk.Key)
+	}
+
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, fmt.Errorf("generating serial number: %w", err)
+	}
+
+	// the certificate only carries the public key, so it does not expire (RFC 5280, section 4.1.2.5)
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: jwk.KeyID},
+		NotBefore:    time.Now().Add(-time.Minute),
+		NotAfter:     certificateNotAfter,
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, signer.Public(), signer)
+	if err != nil {
+		return nil, err
+	}
+	return x509.ParseCertificate(der)
+}
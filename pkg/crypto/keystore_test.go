@@ -0,0 +1,45 @@
+package crypto_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/nais/digdirator/pkg/crypto"
+)
+
+func TestSelfSignedCertificate(t *testing.T) {
+	for _, keyType := range []crypto.KeyType{crypto.KeyTypeRSA2048, crypto.KeyTypeECP256, crypto.KeyTypeECP384} {
+		t.Run(string(keyType), func(t *testing.T) {
+			jwk, err := crypto.GenerateJwk(keyType)
+			require.NoError(t, err)
+
+			cert, err := crypto.SelfSignedCertificate(*jwk)
+			require.NoError(t, err)
+			assert.Equal(t, jwk.Public().Key, cert.PublicKey)
+			assert.Equal(t, jwk.KeyID, cert.Subject.CommonName)
+			assert.True(t, cert.NotAfter.After(time.Now().AddDate(100, 0, 0)), "certificate should not expire")
+			assert.NoError(t, cert.CheckSignature(cert.SignatureAlgorithm, cert.RawTBSCertificate, cert.Signature))
+		})
+	}
+
+	t.Run("public key", func(t *testing.T) {
+		jwk, err := crypto.GenerateJwk(crypto.DefaultKeyType)
+		require.NoError(t, err)
+
+		_, err = crypto.SelfSignedCertificate(jwk.Public())
+		assert.Error(t, err)
+	})
+}
+
+func TestGenerateKeystorePassword(t *testing.T) {
+	password, err := crypto.GenerateKeystorePassword()
+	require.NoError(t, err)
+	assert.Len(t, password, 43)
+
+	other, err := crypto.GenerateKeystorePassword()
+	require.NoError(t, err)
+	assert.NotEqual(t, password, other)
+}
@@ -0,0 +1,111 @@
+package crypto
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/go-jose/go-jose/v4"
+)
+
+// KeyType is the type and size of the private keys generated for client JWKs.
+type KeyType string
+
+const (
+	KeyTypeRSA2048 KeyType = "RSA-2048"
+	KeyTypeRSA3072 KeyType = "RSA-3072"
+	KeyTypeRSA4096 KeyType = "RSA-4096"
+	KeyTypeECP256  KeyType = "EC-P256"
+	KeyTypeECP384  KeyType = "EC-P384"
+
+	DefaultKeyType = KeyTypeRSA2048
+)
+
+var KeyTypes = []KeyType{
+	KeyTypeRSA2048,
+	KeyTypeRSA3072,
+	KeyTypeRSA4096,
+	KeyTypeECP256,
+	KeyTypeECP384,
+}
+
+func ParseKeyType(s string) (KeyType, error) {
+	if s == "" {
+		return DefaultKeyType, nil
+	}
+
+	keyType := KeyType(strings.ToUpper(s))
+	if !slices.Contains(KeyTypes, keyType) {
+		return "", fmt.Errorf("unsupported key type %q, must be one of %v", s, KeyTypes)
+	}
+	return keyType, nil
+}
+
+// Algorithm returns the JWS algorithm used with keys of this type.
+func (k KeyType) Algorithm() string {
+	switch k {
+	case KeyTypeECP256:
+		return string(jose.ES256)
+	case KeyTypeECP384:
+		return string(jose.ES384)
+	default:
+		return KeyAlgorithm
+	}
+}
+
+func (k KeyType) generate() (crypto.Signer, error) {
+	switch k {
+	case KeyTypeRSA2048:
+		return generateRSAKey(2048)
+	case KeyTypeRSA3072:
+		return generateRSAKey(3072)
+	case KeyTypeRSA4096:
+		return generateRSAKey(4096)
+	case KeyTypeECP256:
+		return generateECKey(elliptic.P256())
+	case KeyTypeECP384:
+		return generateECKey(elliptic.P384())
+	default:
+		return nil, fmt.Errorf("unsupported key type %q", k)
+	}
+}
+
+func generateECKey(curve elliptic.Curve) (*ecdsa.PrivateKey, error) {
+	privateKey, err := ecdsa.GenerateKey(curve, rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("generating EC keypair: %w", err)
+	}
+	return privateKey, nil
+}
+
+// algorithmForKey returns the JWS algorithm matching the given key, for keys that do not specify one.
+func algorithmForKey(key any) string {
+	switch k := key.(type) {
+	case *ecdsa.PrivateKey:
+		return algorithmForCurve(k.Curve)
+	case *ecdsa.PublicKey:
+		return algorithmForCurve(k.Curve)
+	case *rsa.PrivateKey, *rsa.PublicKey:
+		return KeyAlgorithm
+	default:
+		return ""
+	}
+}
+
+func algorithmForCurve(curve elliptic.Curve) string {
+	switch curve {
+	case elliptic.P256():
+		return string(jose.ES256)
+	case elliptic.P384():
+		return string(jose.ES384)
+	case elliptic.P521():
+		return string(jose.ES512)
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,161 @@
+package crypto_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"testing"
+
+	"github.com/go-jose/go-jose/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/nais/digdirator/pkg/crypto"
+	"github.com/nais/digdirator/pkg/secrets"
+)
+
+func TestParseKeyType(t *testing.T) {
+	for _, test := range []struct {
+		input   string
+		want    crypto.KeyType
+		wantErr bool
+	}{
+		{input: "", want: crypto.DefaultKeyType},
+		{input: "RSA-2048", want: crypto.KeyTypeRSA2048},
+		{input: "rsa-4096", want: crypto.KeyTypeRSA4096},
+		{input: "EC-P256", want: crypto.KeyTypeECP256},
+		{input: "ec-p384", want: crypto.KeyTypeECP384},
+		{input: "RSA-1024", wantErr: true},
+		{input: "EC-P521", wantErr: true},
+	} {
+		t.Run(test.input, func(t *testing.T) {
+			actual, err := crypto.ParseKeyType(test.input)
+			if test.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, test.want, actual)
+		})
+	}
+}
+
+func TestGenerateJwk(t *testing.T) {
+	for _, test := range []struct {
+		keyType crypto.KeyType
+		alg     jose.SignatureAlgorithm
+		check   func(t *testing.T, key any)
+	}{
+		{keyType: crypto.KeyTypeRSA2048, alg: jose.RS256, check: rsaKeyWithSize(2048)},
+		{keyType: crypto.KeyTypeRSA3072, alg: jose.RS256, check: rsaKeyWithSize(3072)},
+		{keyType: crypto.KeyTypeRSA4096, alg: jose.RS256, check: rsaKeyWithSize(4096)},
+		{keyType: crypto.KeyTypeECP256, alg: jose.ES256, check: ecKeyWithCurve(elliptic.P256())},
+		{keyType: crypto.KeyTypeECP384, alg: jose.ES384, check: ecKeyWithCurve(elliptic.P384())},
+	} {
+		t.Run(string(test.keyType), func(t *testing.T) {
+			jwk, err := crypto.GenerateJwk(test.keyType)
+			require.NoError(t, err)
+
+			assert.NotEmpty(t, jwk.KeyID)
+			assert.Equal(t, crypto.KeyUseSignature, jwk.Use)
+			assert.Equal(t, string(test.alg), jwk.Algorithm)
+			assert.True(t, jwk.Valid())
+			test.check(t, jwk.Key)
+
+			public := jwk.Public()
+			assert.Equal(t, string(test.alg), public.Algorithm, "public JWK should keep the algorithm")
+			assert.True(t, public.IsPublic())
+		})
+	}
+}
+
+func TestMergeJwks_MixedKeyTypes(t *testing.T) {
+	existing, err := crypto.GenerateJwk(crypto.KeyTypeRSA2048)
+	require.NoError(t, err)
+	existingJson, err := existing.MarshalJSON()
+	require.NoError(t, err)
+
+	secretsInUse := corev1.SecretList{
+		Items: []corev1.Secret{
+			{Data: map[string][]byte{secrets.IDPortenJwkKey: existingJson}},
+		},
+	}
+
+	newJwk, err := crypto.GenerateJwk(crypto.KeyTypeECP256)
+	require.NoError(t, err)
+
+	jwks, err := crypto.MergeJwks(*newJwk, secretsInUse, secrets.IDPortenJwkKey)
+	require.NoError(t, err)
+	require.Len(t, jwks.Keys, 2)
+
+	assert.Equal(t, newJwk.KeyID, jwks.Keys[0].KeyID)
+	assert.Equal(t, string(jose.ES256), jwks.Keys[0].Algorithm)
+	assert.IsType(t, &ecdsa.PublicKey{}, jwks.Keys[0].Key)
+
+	assert.Equal(t, existing.KeyID, jwks.Keys[1].KeyID)
+	assert.Equal(t, string(jose.RS256), jwks.Keys[1].Algorithm)
+	assert.IsType(t, &rsa.PublicKey{}, jwks.Keys[1].Key)
+}
+
+func TestMergeJwks_DefaultsMissingAlgorithm(t *testing.T) {
+	existing, err := crypto.GenerateJwk(crypto.KeyTypeECP384)
+	require.NoError(t, err)
+	existing.Algorithm = ""
+	existingJson, err := existing.MarshalJSON()
+	require.NoError(t, err)
+
+	secretsInUse := corev1.SecretList{
+		Items: []corev1.Secret{
+			{Data: map[string][]byte{secrets.MaskinportenJwkKey: existingJson}},
+		},
+	}
+
+	newJwk, err := crypto.GenerateJwk(crypto.DefaultKeyType)
+	require.NoError(t, err)
+
+	jwks, err := crypto.MergeJwks(*newJwk, secretsInUse, secrets.MaskinportenJwkKey)
+	require.NoError(t, err)
+	require.Len(t, jwks.Keys, 2)
+	assert.Equal(t, string(jose.ES384), jwks.Keys[1].Algorithm)
+}
+
+func rsaKeyWithSize(bits int) func(t *testing.T, key any) {
+	return func(t *testing.T, key any) {
+		rsaKey, ok := key.(*rsa.PrivateKey)
+		require.True(t, ok, "key should be RSA")
+		assert.Equal(t, bits, rsaKey.N.BitLen())
+	}
+}
+
+func ecKeyWithCurve(curve elliptic.Curve) func(t *testing.T, key any) {
+	return func(t *testing.T, key any) {
+		ecKey, ok := key.(*ecdsa.PrivateKey)
+		require.True(t, ok, "key should be EC")
+		assert.Equal(t, curve, ecKey.Curve)
+	}
+}
+
+func TestParseSecretFormats(t *testing.T) {
+	for _, test := range []struct {
+		input   string
+		want    []crypto.SecretFormat
+		wantErr bool
+	}{
+		{input: "", want: []crypto.SecretFormat{}},
+		{input: "pem", want: []crypto.SecretFormat{crypto.SecretFormatPEM}},
+		{input: "PKCS12, pem", want: []crypto.SecretFormat{crypto.SecretFormatPKCS12, crypto.SecretFormatPEM}},
+		{input: "pem,,pem", want: []crypto.SecretFormat{crypto.SecretFormatPEM}},
+		{input: "jks", wantErr: true},
+	} {
+		t.Run(test.input, func(t *testing.T) {
+			actual, err := crypto.ParseSecretFormats(test.input)
+			if test.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, test.want, actual)
+		})
+	}
+}
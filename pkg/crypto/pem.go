@@ -0,0 +1,31 @@
+package crypto
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+
+	"github.com/go-jose/go-jose/v4"
+)
+
+// PrivateKeyPEM returns the private key of the JWK as a PEM-encoded PKCS#8 structure.
+func PrivateKeyPEM(jwk jose.JSONWebKey) ([]byte, error) {
+	if jwk.IsPublic() {
+		return nil, fmt.Errorf("JWK %q has no private key", jwk.KeyID)
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(jwk.Key)
+	if err != nil {
+		return nil, fmt.Errorf("marshalling private key: %w", err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), nil
+}
+
+// PublicKeyPEM returns the public key of the JWK as a PEM-encoded PKIX structure.
+func PublicKeyPEM(jwk jose.JSONWebKey) ([]byte, error) {
+	der, err := x509.MarshalPKIXPublicKey(jwk.Public().Key)
+	if err != nil {
+		return nil, fmt.Errorf("marshalling public key: %w", err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), nil
+}
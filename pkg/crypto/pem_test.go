@@ -0,0 +1,58 @@
+package crypto_test
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/nais/digdirator/pkg/crypto"
+)
+
+func TestPrivateKeyPEM(t *testing.T) {
+	jwk, err := crypto.GenerateJwk(crypto.KeyTypeECP256)
+	require.NoError(t, err)
+
+	data, err := crypto.PrivateKeyPEM(*jwk)
+	require.NoError(t, err)
+
+	block, rest := pem.Decode(data)
+	require.NotNil(t, block)
+	assert.Empty(t, rest)
+	assert.Equal(t, "PRIVATE KEY", block.Type)
+
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	require.NoError(t, err)
+	assertSamePrivateKey(t, jwk.Key, key)
+
+	_, err = crypto.PrivateKeyPEM(jwk.Public())
+	assert.Error(t, err)
+}
+
+func TestPublicKeyPEM(t *testing.T) {
+	jwk, err := crypto.GenerateJwk(crypto.DefaultKeyType)
+	require.NoError(t, err)
+
+	data, err := crypto.PublicKeyPEM(*jwk)
+	require.NoError(t, err)
+
+	block, rest := pem.Decode(data)
+	require.NotNil(t, block)
+	assert.Empty(t, rest)
+	assert.Equal(t, "PUBLIC KEY", block.Type)
+
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	require.NoError(t, err)
+	assert.Equal(t, jwk.Public().Key, key)
+}
+
+// assertSamePrivateKey compares the encoded keys, as parsed keys may differ in precomputed values.
+func assertSamePrivateKey(t *testing.T, expected, actual any) {
+	expectedDer, err := x509.MarshalPKCS8PrivateKey(expected)
+	require.NoError(t, err)
+	actualDer, err := x509.MarshalPKCS8PrivateKey(actual)
+	require.NoError(t, err)
+	assert.Equal(t, expectedDer, actualDer)
+}
@@ -7,7 +7,11 @@ import (
 )
 
 func GenerateRSAKey() (*rsa.PrivateKey, error) {
-	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	return generateRSAKey(2048)
+}
+
+func generateRSAKey(bits int) (*rsa.PrivateKey, error) {
+	privateKey, err := rsa.GenerateKey(rand.Reader, bits)
 	if err != nil {
 		return nil, fmt.Errorf("generating RSA keypair: %w", err)
 	}
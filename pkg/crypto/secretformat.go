@@ -0,0 +1,42 @@
+package crypto
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// SecretFormat is an additional format that the client key is written to secrets in, besides the JWK.
+type SecretFormat string
+
+const (
+	// SecretFormatPEM writes the PKCS#8 private key and the PKIX public key as PEM.
+	SecretFormatPEM SecretFormat = "pem"
+	// SecretFormatPKCS12 writes a password-protected PKCS#12 keystore with the private key and a self-signed certificate.
+	SecretFormatPKCS12 SecretFormat = "pkcs12"
+)
+
+var SecretFormats = []SecretFormat{
+	SecretFormatPEM,
+	SecretFormatPKCS12,
+}
+
+// ParseSecretFormats parses a comma-separated list of secret formats, ignoring blank entries and duplicates.
+func ParseSecretFormats(formats string) ([]SecretFormat, error) {
+	result := make([]SecretFormat, 0)
+	for s := range strings.SplitSeq(formats, ",") {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+
+		format := SecretFormat(strings.ToLower(s))
+		if !slices.Contains(SecretFormats, format) {
+			return nil, fmt.Errorf("unsupported secret format %q, must be one of %v", s, SecretFormats)
+		}
+		if !slices.Contains(result, format) {
+			result = append(result, format)
+		}
+	}
+	return result, nil
+}
@@ -13,6 +13,9 @@ import (
 	"github.com/go-jose/go-jose/v4/jwt"
 	"github.com/google/uuid"
 	"github.com/nais/digdirator/pkg/crypto"
+	"github.com/nais/digdirator/pkg/metrics"
+	"github.com/nais/digdirator/pkg/retry"
+	"github.com/nais/digdirator/pkg/tracing"
 )
 
 const (
@@ -22,6 +25,7 @@ const (
 
 type TokenResponse struct {
 	AccessToken string `json:"access_token"`
+	ExpiresIn   int    `json:"expires_in"`
 }
 
 type customClaims struct {
@@ -29,7 +33,10 @@ type customClaims struct {
 	Scope string `json:"scope"`
 }
 
-func (c Client) getAuthToken(ctx context.Context) (*TokenResponse, error) {
+func (c Client) getAuthToken(ctx context.Context) (_ *TokenResponse, err error) {
+	ctx, span := tracing.Start(ctx, "digdir."+opGetToken, tracing.AttributeIdentity.String(c.Identity))
+	defer tracing.End(span, &err)
+
 	token, err := crypto.GenerateJwt(c.Signer, c.claims())
 	if err != nil {
 		return nil, fmt.Errorf("generating JWT: %w", err)
@@ -42,19 +49,23 @@ func (c Client) getAuthToken(ctx context.Context) (*TokenResponse, error) {
 		return nil, err
 	}
 
+	// errors are classified for retries by the caller, see Client.request
+	start := time.Now()
 	resp, err := c.HttpClient.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("doing request: %w", err)
+		metrics.ObserveDigDirRequest(c.Identity, opGetToken, http.MethodPost, 0, attemptFrom(ctx), time.Since(start))
+		return nil, retry.Network(fmt.Errorf("doing request: %w", err))
 	}
 	defer resp.Body.Close()
 
 	body, err := io.ReadAll(resp.Body)
+	metrics.ObserveDigDirRequest(c.Identity, opGetToken, http.MethodPost, resp.StatusCode, attemptFrom(ctx), time.Since(start))
 	if err != nil {
-		return nil, fmt.Errorf("reading response: %w", err)
+		return nil, retry.Network(fmt.Errorf("reading response: %w", err))
 	}
 
 	if resp.StatusCode >= 400 {
-		return nil, fmt.Errorf("invalid status %s: %s", resp.Status, body)
+		return nil, retry.HTTPResponse(newError(resp, body), resp)
 	}
 
 	tokenResponse := &TokenResponse{}
@@ -69,14 +80,14 @@ func (c Client) claims() customClaims {
 
 	return customClaims{
 		Claims: jwt.Claims{
-			Issuer:    c.Config.DigDir.Admin.ClientID,
+			Issuer:    c.admin.ClientID,
 			Audience:  []string{c.Config.DigDir.Maskinporten.Metadata.Issuer},
 			Expiry:    jwt.NewNumericDate(now.Add(2 * time.Minute)),
 			NotBefore: jwt.NewNumericDate(now),
 			IssuedAt:  jwt.NewNumericDate(now),
 			ID:        uuid.New().String(),
 		},
-		Scope: c.Config.DigDir.Admin.Scopes,
+		Scope: c.admin.Scopes,
 	}
 }
 
@@ -0,0 +1,141 @@
+package digdir
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/nais/digdirator/pkg/config"
+	"github.com/nais/digdirator/pkg/metrics"
+)
+
+var ErrCircuitOpen = errors.New("circuit breaker is open: DigDir is unavailable")
+
+type breakerState string
+
+const (
+	breakerClosed   breakerState = "closed"
+	breakerOpen     breakerState = "open"
+	breakerHalfOpen breakerState = "half_open"
+)
+
+// breaker is a circuit breaker for requests to DigDir.
+// It opens after a number of consecutive server errors or timeouts, and rejects all requests until the cooldown has passed.
+// After the cooldown, a single probe request is let through. The breaker closes if the probe succeeds, and opens again otherwise.
+// A zero failure threshold disables the breaker.
+type breaker struct {
+	threshold int
+	cooldown  time.Duration
+	now       func() time.Time
+
+	mu       sync.Mutex
+	state    breakerState
+	failures int
+	openedAt time.Time
+	probing  bool
+}
+
+func newBreaker(cfg config.CircuitBreaker) *breaker {
+	b := &breaker{
+		threshold: cfg.FailureThreshold,
+		cooldown:  cfg.Cooldown,
+		now:       time.Now,
+		state:     breakerClosed,
+	}
+	metrics.SetCircuitBreakerState(string(b.state))
+	return b
+}
+
+// allow returns ErrCircuitOpen if a request must not be sent.
+// Every allowed request must be followed by a call to record with its outcome.
+func (b *breaker) allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case breakerOpen:
+		if b.now().Sub(b.openedAt) < b.cooldown {
+			return ErrCircuitOpen
+		}
+		b.setState(breakerHalfOpen)
+		b.probing = true
+		return nil
+	case breakerHalfOpen:
+		if b.probing {
+			return ErrCircuitOpen
+		}
+		b.probing = true
+		return nil
+	default:
+		return nil
+	}
+}
+
+// record updates the breaker with the outcome of an allowed request.
+func (b *breaker) record(err error) {
+	if b.threshold <= 0 {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.probing = false
+	if errors.Is(err, context.Canceled) {
+		return
+	}
+
+	if !isOutage(err) {
+		b.failures = 0
+		b.setState(breakerClosed)
+		return
+	}
+
+	b.failures++
+	if b.state == breakerHalfOpen || b.failures >= b.threshold {
+		b.openedAt = b.now()
+		b.setState(breakerOpen)
+	}
+}
+
+// unavailable returns true if requests are currently rejected, along with the time until the next probe may be sent.
+func (b *breaker) unavailable() (time.Duration, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case breakerOpen:
+		remaining := b.cooldown - b.now().Sub(b.openedAt)
+		return remaining, remaining > 0
+	case breakerHalfOpen:
+		// the outcome of the probe is unknown, so we back off as if the breaker is open
+		return b.cooldown, b.probing
+	default:
+		return 0, false
+	}
+}
+
+func (b *breaker) setState(state breakerState) {
+	if b.state == state {
+		return
+	}
+	b.state = state
+	metrics.SetCircuitBreakerState(string(state))
+}
+
+// isOutage returns true if the error indicates that DigDir is unavailable, i.e. a server error or a timeout.
+func isOutage(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	var digdirErr *Error
+	if errors.As(err, &digdirErr) {
+		return errors.Is(digdirErr, ErrServer)
+	}
+
+	var netErr net.Error
+	return errors.As(err, &netErr) || errors.Is(err, context.DeadlineExceeded)
+}
@@ -0,0 +1,97 @@
+package digdir
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/nais/digdirator/pkg/config"
+	"github.com/nais/digdirator/pkg/metrics"
+)
+
+func TestBreaker(t *testing.T) {
+	serverErr := &Error{Err: ErrServer, StatusCode: http.StatusBadGateway}
+	clientErr := &Error{Err: ErrClient, StatusCode: http.StatusBadRequest}
+
+	newTestBreaker := func() (*breaker, *time.Time) {
+		now := time.Now()
+		b := newBreaker(config.CircuitBreaker{FailureThreshold: 3, Cooldown: time.Minute})
+		b.now = func() time.Time { return now }
+		return b, &now
+	}
+
+	fail := func(t *testing.T, b *breaker, err error, times int) {
+		for range times {
+			require.NoError(t, b.allow())
+			b.record(err)
+		}
+	}
+
+	t.Run("opens after consecutive outages", func(t *testing.T) {
+		b, _ := newTestBreaker()
+
+		fail(t, b, serverErr, 2)
+		fail(t, b, nil, 1)
+		fail(t, b, serverErr, 2)
+		_, unavailable := b.unavailable()
+		assert.False(t, unavailable)
+
+		fail(t, b, fmt.Errorf("doing request: %w", context.DeadlineExceeded), 1)
+		retryAfter, unavailable := b.unavailable()
+		assert.True(t, unavailable)
+		assert.Equal(t, time.Minute, retryAfter)
+		assert.ErrorIs(t, b.allow(), ErrCircuitOpen)
+		assert.Equal(t, float64(1), testutil.ToFloat64(metrics.DigDirCircuitBreakerState.WithLabelValues(string(breakerOpen))))
+	})
+
+	t.Run("client errors are not outages", func(t *testing.T) {
+		b, _ := newTestBreaker()
+
+		fail(t, b, clientErr, 5)
+		fail(t, b, context.Canceled, 5)
+		_, unavailable := b.unavailable()
+		assert.False(t, unavailable)
+	})
+
+	t.Run("closes after successful probe", func(t *testing.T) {
+		b, now := newTestBreaker()
+		fail(t, b, serverErr, 3)
+
+		*now = now.Add(time.Minute)
+		require.NoError(t, b.allow())
+		assert.ErrorIs(t, b.allow(), ErrCircuitOpen, "only a single probe is allowed")
+		_, unavailable := b.unavailable()
+		assert.True(t, unavailable)
+
+		b.record(nil)
+		_, unavailable = b.unavailable()
+		assert.False(t, unavailable)
+		assert.NoError(t, b.allow())
+	})
+
+	t.Run("opens again after failed probe", func(t *testing.T) {
+		b, now := newTestBreaker()
+		fail(t, b, serverErr, 3)
+
+		*now = now.Add(time.Minute)
+		fail(t, b, serverErr, 1)
+
+		retryAfter, unavailable := b.unavailable()
+		assert.True(t, unavailable)
+		assert.Equal(t, time.Minute, retryAfter)
+	})
+
+	t.Run("zero threshold disables the breaker", func(t *testing.T) {
+		b := newBreaker(config.CircuitBreaker{})
+
+		fail(t, b, serverErr, 10)
+		_, unavailable := b.unavailable()
+		assert.False(t, unavailable)
+	})
+}
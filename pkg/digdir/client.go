@@ -9,17 +9,43 @@ import (
 	"io"
 	"net/http"
 	"net/url"
+	"slices"
 	"time"
 
 	cache "github.com/Code-Hex/go-generics-cache"
 	"github.com/go-jose/go-jose/v4"
 	"github.com/nais/liberator/pkg/apis/nais.io/v1"
 	"github.com/nais/liberator/pkg/kubernetes"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.41.0"
 
 	"github.com/nais/digdirator/pkg/clients"
 	"github.com/nais/digdirator/pkg/config"
 	"github.com/nais/digdirator/pkg/digdir/types"
+	"github.com/nais/digdirator/pkg/metrics"
 	"github.com/nais/digdirator/pkg/retry"
+	"github.com/nais/digdirator/pkg/tracing"
+)
+
+// Names of the logical operations that requests are labelled with in metrics.
+const (
+	opGetToken             = "get_token"
+	opRegisterClient       = "register_client"
+	opListClients          = "list_clients"
+	opGetClient            = "get_client"
+	opUpdateClient         = "update_client"
+	opDeleteClient         = "delete_client"
+	opGetKeys              = "get_keys"
+	opRegisterKeys         = "register_keys"
+	opListAccessibleScopes = "list_accessible_scopes"
+	opListOpenScopes       = "list_open_scopes"
+	opListScopes           = "list_scopes"
+	opRegisterScope        = "register_scope"
+	opUpdateScope          = "update_scope"
+	opDeleteScope          = "delete_scope"
+	opGetScopeACL          = "get_scope_acl"
+	opAddToScopeACL        = "add_to_scope_acl"
+	opRemoveFromScopeACL   = "remove_from_scope_acl"
 )
 
 const (
@@ -31,8 +57,6 @@ const (
 var (
 	ErrServer = errors.New("ServerError")
 	ErrClient = errors.New("ClientError")
-
-	scopeCache = cache.New[string, bool]()
 )
 
 type Error struct {
@@ -50,21 +74,81 @@ func (in *Error) Unwrap() error {
 	return in.Err
 }
 
+func newError(resp *http.Response, body []byte) *Error {
+	err := ErrClient
+	if resp.StatusCode >= 500 {
+		err = ErrServer
+	}
+
+	return &Error{
+		Err:        err,
+		Message:    string(body),
+		Status:     resp.Status,
+		StatusCode: resp.StatusCode,
+	}
+}
+
 type Client struct {
 	HttpClient *http.Client
 	Signer     jose.Signer
 	Config     *config.Config
+	// Identity is the name of the admin identity that the client authenticates as.
+	Identity string
+	admin    config.Admin
+	tokens   *tokenSource
+	limiter  *limiter
+	breaker  *breaker
+	index    *registrationIndex
+	// scopeAccess caches whether the organization of the identity can access a scope, keyed by scope name.
+	scopeAccess *cache.Cache[string, bool]
 }
 
-func NewClient(config *config.Config, httpClient *http.Client, signer jose.Signer) (Client, error) {
-	return Client{
-		Config:     config,
-		HttpClient: httpClient,
-		Signer:     signer,
-	}, nil
+// NewClient returns a client that authenticates as the default admin identity.
+func NewClient(cfg *config.Config, httpClient *http.Client, signer jose.Signer) (Client, error) {
+	c := Client{
+		Config:      cfg,
+		HttpClient:  httpClient,
+		Signer:      signer,
+		Identity:    config.DefaultIdentity,
+		admin:       cfg.DigDir.Admin,
+		limiter:     newLimiter(cfg.DigDir.RateLimit),
+		breaker:     newBreaker(cfg.DigDir.CircuitBreaker),
+		index:       newRegistrationIndex(cfg.DigDir.ClientIndex),
+		scopeAccess: cache.New[string, bool](),
+	}
+	c.tokens = newTokenSource(c.getAuthToken)
+	return c, nil
+}
+
+// ForIdentity returns a client that authenticates as the named admin identity, signing its assertions with the given signer.
+// All identities use the same API, so the rate limiter and circuit breaker are shared with c. Each identity only sees
+// the clients and scopes of its own organization, so the token cache, registration index and scope access are not.
+func (c Client) ForIdentity(name string, signer jose.Signer) (Client, error) {
+	admin, ok := c.Config.DigDir.Identity(name)
+	if !ok {
+		return Client{}, fmt.Errorf("unknown identity %q", name)
+	}
+
+	identity := Client{
+		Config:      c.Config,
+		HttpClient:  c.HttpClient,
+		Signer:      signer,
+		Identity:    name,
+		admin:       admin,
+		limiter:     c.limiter,
+		breaker:     c.breaker,
+		index:       newRegistrationIndex(c.Config.DigDir.ClientIndex),
+		scopeAccess: cache.New[string, bool](),
+	}
+	identity.tokens = newTokenSource(identity.getAuthToken)
+	return identity, nil
 }
 
 func (c Client) Register(ctx context.Context, payload types.ClientRegistration) (*types.ClientRegistration, error) {
+	if plan := PlanFrom(ctx); plan != nil {
+		return plan.registerClient(payload), nil
+	}
+
 	endpoint := c.endpoint("clients")
 	registration := &types.ClientRegistration{}
 
@@ -73,28 +157,125 @@ func (c Client) Register(ctx context.Context, payload types.ClientRegistration)
 		return nil, fmt.Errorf("marshal payload: %w", err)
 	}
 
-	if err := c.request(ctx, http.MethodPost, endpoint, jsonPayload, registration); err != nil {
+	if err := c.request(ctx, opRegisterClient, http.MethodPost, endpoint, jsonPayload, registration); err != nil {
 		return nil, err
 	}
 
+	c.index.put(*registration)
 	return registration, nil
 }
 
+// GetRegistration returns the registration in DigDir that matches the desired instance, if any.
+// If the registration index is enabled, it is only used to discover the client ID, and the registration is then fetched by ID,
+// so that it can be compared to the desired state. Otherwise, DigDir offers no way to filter the list of clients,
+// so the list is decoded as it is read until a match is found.
 func (c Client) GetRegistration(desired clients.Instance, ctx context.Context, clusterName string) (*types.ClientRegistration, error) {
-	endpoint := c.endpoint("clients")
-	clientRegistrations := make([]types.ClientRegistration, 0)
+	var registration *types.ClientRegistration
+	var err error
 
-	if err := c.request(ctx, http.MethodGet, endpoint, nil, &clientRegistrations); err != nil {
+	if c.index.enabled() {
+		registration, err = c.lookup(ctx, desired, clusterName)
+	} else {
+		registration, err = c.find(ctx, desired, clusterName)
+	}
+	if err != nil || registration == nil {
 		return nil, err
 	}
 
-	for _, actual := range clientRegistrations {
-		if clientMatches(actual, desired, clusterName) {
-			desired.GetStatus().ClientID = actual.ClientID
-			return &actual, nil
+	desired.GetStatus().ClientID = registration.ClientID
+	return registration, nil
+}
+
+// lookup returns the matching registration, fetched by the client ID in the status or found in the registration index.
+// The index is refreshed first if it has expired.
+func (c Client) lookup(ctx context.Context, desired clients.Instance, clusterName string) (*types.ClientRegistration, error) {
+	if desired.GetStatus() != nil && desired.GetStatus().ClientID != "" {
+		registration, err := c.get(ctx, desired.GetStatus().ClientID)
+		if err != nil || registration != nil {
+			return registration, err
+		}
+		// the client in the status no longer exists, but another client may have been registered for the resource since
+	}
+
+	if c.index.expired() {
+		if err := c.index.refresh(ctx, c.List); err != nil {
+			return nil, err
 		}
 	}
-	return nil, nil
+
+	registration, ok := c.index.find(kubernetes.UniformResourceName(desired, clusterName), clients.GetIntegrationType(desired))
+	if !ok {
+		return nil, nil
+	}
+	return c.get(ctx, registration.ClientID)
+}
+
+// get returns the registration with the given client ID, or nil if it doesn't exist. The registration index is updated with the result.
+func (c Client) get(ctx context.Context, clientID string) (*types.ClientRegistration, error) {
+	endpoint := c.endpoint("clients", clientID)
+	registration := &types.ClientRegistration{}
+
+	if err := c.request(ctx, opGetClient, http.MethodGet, endpoint, nil, registration, tracing.AttributeClientID.String(clientID)); err != nil {
+		var digdirErr *Error
+		if errors.As(err, &digdirErr) && digdirErr.StatusCode == http.StatusNotFound {
+			// the client was deleted outside of digdirator since the index was last refreshed
+			c.index.remove(clientID)
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	c.index.put(*registration)
+	return registration, nil
+}
+
+// find returns the first matching registration in the list of clients, without decoding the rest of the list.
+// If the client ID in the status is not found, the first registration with a matching description is returned instead.
+func (c Client) find(ctx context.Context, desired clients.Instance, clusterName string) (*types.ClientRegistration, error) {
+	endpoint := c.endpoint("clients")
+
+	var match, fallback *types.ClientRegistration
+	stream := arrayStream[types.ClientRegistration]{
+		yield: func(actual types.ClientRegistration) bool {
+			if clientMatches(actual, desired, clusterName) {
+				match = &actual
+				return false
+			}
+			if fallback == nil && descriptionMatches(actual, desired, clusterName) {
+				fallback = &actual
+			}
+			return true
+		},
+	}
+
+	if err := c.request(ctx, opListClients, http.MethodGet, endpoint, nil, stream); err != nil {
+		return nil, err
+	}
+	if match == nil {
+		return fallback, nil
+	}
+	return match, nil
+}
+
+func (c Client) List(ctx context.Context) ([]types.ClientRegistration, error) {
+	endpoint := c.endpoint("clients")
+
+	var clientRegistrations []types.ClientRegistration
+	stream := arrayStream[types.ClientRegistration]{
+		start: func() {
+			clientRegistrations = make([]types.ClientRegistration, 0)
+		},
+		yield: func(registration types.ClientRegistration) bool {
+			clientRegistrations = append(clientRegistrations, registration)
+			return true
+		},
+	}
+
+	if err := c.request(ctx, opListClients, http.MethodGet, endpoint, nil, stream); err != nil {
+		return nil, err
+	}
+
+	return clientRegistrations, nil
 }
 
 func (c Client) Exists(ctx context.Context, desired clients.Instance, clusterName string) (bool, error) {
@@ -107,6 +288,10 @@ func (c Client) Exists(ctx context.Context, desired clients.Instance, clusterNam
 }
 
 func (c Client) Update(ctx context.Context, payload types.ClientRegistration, clientID string) (*types.ClientRegistration, error) {
+	if plan := PlanFrom(ctx); plan != nil {
+		return plan.updateClient(payload, clientID), nil
+	}
+
 	endpoint := c.endpoint("clients", clientID)
 	registration := &types.ClientRegistration{}
 
@@ -115,31 +300,53 @@ func (c Client) Update(ctx context.Context, payload types.ClientRegistration, cl
 		return nil, fmt.Errorf("marshal payload: %w", err)
 	}
 
-	if err := c.request(ctx, http.MethodPut, endpoint, jsonPayload, registration); err != nil {
+	if err := c.request(ctx, opUpdateClient, http.MethodPut, endpoint, jsonPayload, registration, tracing.AttributeClientID.String(clientID)); err != nil {
+		var digdirErr *Error
+		if errors.As(err, &digdirErr) && digdirErr.StatusCode == http.StatusNotFound {
+			// the client was deleted outside of digdirator since the index was last refreshed
+			c.index.remove(clientID)
+		}
 		return nil, err
 	}
+
+	c.index.put(*registration)
 	return registration, nil
 }
 
 func (c Client) Delete(ctx context.Context, clientID string) error {
+	if plan := PlanFrom(ctx); plan != nil {
+		plan.deleteClient(clientID)
+		return nil
+	}
+
 	endpoint := c.endpoint("clients", clientID)
-	if err := c.request(ctx, http.MethodDelete, endpoint, nil, nil); err != nil {
+	if err := c.request(ctx, opDeleteClient, http.MethodDelete, endpoint, nil, nil, tracing.AttributeClientID.String(clientID)); err != nil {
 		return err
 	}
+
+	c.index.remove(clientID)
 	return nil
 }
 
 func (c Client) GetKeys(ctx context.Context, clientID string) (*types.JwksResponse, error) {
+	if plan := PlanFrom(ctx); plan != nil && plan.isPlannedClient(clientID) {
+		return &types.JwksResponse{}, nil
+	}
+
 	endpoint := c.endpoint("clients", clientID, "jwks")
 	response := &types.JwksResponse{}
 
-	if err := c.request(ctx, http.MethodGet, endpoint, nil, response); err != nil {
+	if err := c.request(ctx, opGetKeys, http.MethodGet, endpoint, nil, response, tracing.AttributeClientID.String(clientID)); err != nil {
 		return nil, err
 	}
 	return response, nil
 }
 
 func (c Client) RegisterKeys(ctx context.Context, clientID string, payload *jose.JSONWebKeySet) (*types.JwksResponse, error) {
+	if plan := PlanFrom(ctx); plan != nil {
+		return plan.registerKeys(clientID, payload), nil
+	}
+
 	endpoint := c.endpoint("clients", clientID, "jwks")
 	response := &types.JwksResponse{}
 
@@ -147,7 +354,7 @@ func (c Client) RegisterKeys(ctx context.Context, clientID string, payload *jose
 	if err != nil {
 		return nil, fmt.Errorf("marshal payload: %w", err)
 	}
-	if err := c.request(ctx, http.MethodPost, endpoint, jsonPayload, response); err != nil {
+	if err := c.request(ctx, opRegisterKeys, http.MethodPost, endpoint, jsonPayload, response, tracing.AttributeClientID.String(clientID)); err != nil {
 		return nil, err
 	}
 	return response, nil
@@ -155,7 +362,7 @@ func (c Client) RegisterKeys(ctx context.Context, clientID string, payload *jose
 
 // CanAccessScope checks if the authenticated organization can access given scope.
 func (c Client) CanAccessScope(ctx context.Context, scope nais_io_v1.ConsumedScope) (bool, error) {
-	if access, ok := scopeCache.Get(scope.Name); ok {
+	if access, ok := c.scopeAccess.Get(scope.Name); ok {
 		return access, nil
 	}
 
@@ -169,7 +376,7 @@ func (c Client) CanAccessScope(ctx context.Context, scope nais_io_v1.ConsumedSco
 		return false, fmt.Errorf("get open scopes: %w", err)
 	}
 
-	if access, ok := scopeCache.Get(scope.Name); ok {
+	if access, ok := c.scopeAccess.Get(scope.Name); ok {
 		return access, nil
 	}
 
@@ -181,13 +388,13 @@ func (c Client) GetAccessibleScopes(ctx context.Context) ([]types.Scope, error)
 	endpoint := c.endpoint("scopes", "access", "all")
 
 	s := make([]types.Scope, 0)
-	if err := c.request(ctx, http.MethodGet, endpoint, nil, &s); err != nil {
+	if err := c.request(ctx, opListAccessibleScopes, http.MethodGet, endpoint, nil, &s); err != nil {
 		return nil, err
 	}
 
 	for _, scope := range s {
 		// cache with reasonable expiration time to prevent stale data
-		scopeCache.Set(scope.Scope, scope.IsAccessible(), cache.WithExpiration(10*time.Minute))
+		c.scopeAccess.Set(scope.Scope, scope.IsAccessible(), cache.WithExpiration(10*time.Minute))
 	}
 
 	return s, nil
@@ -198,22 +405,56 @@ func (c Client) GetOpenScopes(ctx context.Context) ([]types.ScopeRegistration, e
 	endpoint := c.endpoint("scopes", "all") + "?accessible_for_all=true"
 
 	s := make([]types.ScopeRegistration, 0)
-	if err := c.request(ctx, http.MethodGet, endpoint, nil, &s); err != nil {
+	if err := c.request(ctx, opListOpenScopes, http.MethodGet, endpoint, nil, &s); err != nil {
 		return nil, err
 	}
 
 	for _, scope := range s {
-		scopeCache.Set(scope.Name, scope.AccessibleForAll)
+		c.scopeAccess.Set(scope.Name, scope.AccessibleForAll)
 	}
 
 	return s, nil
 }
 
+// ScopeFilter selects the scopes owned by the authenticated organization that are returned by ListScopes.
+type ScopeFilter struct {
+	// IncludeInactive includes scopes that have been deactivated.
+	IncludeInactive bool
+	// Subscopes restricts the result to the given subscopes, if not empty.
+	Subscopes []string
+}
+
+func (f ScopeFilter) matches(scope types.ScopeRegistration) bool {
+	return len(f.Subscopes) == 0 || slices.Contains(f.Subscopes, scope.Subscope)
+}
+
+// GetScopes returns all scopes owned by the authenticated organization, including inactive scopes.
 func (c Client) GetScopes(ctx context.Context) ([]types.ScopeRegistration, error) {
-	endpoint := c.endpoint("scopes") + "?inactive=true"
-	scopes := make([]types.ScopeRegistration, 0)
+	return c.ListScopes(ctx, ScopeFilter{IncludeInactive: true})
+}
 
-	if err := c.request(ctx, http.MethodGet, endpoint, nil, &scopes); err != nil {
+// ListScopes returns the scopes owned by the authenticated organization that match the filter.
+// Inactive scopes are filtered by DigDir, while the remaining scopes are filtered as the response is decoded.
+func (c Client) ListScopes(ctx context.Context, filter ScopeFilter) ([]types.ScopeRegistration, error) {
+	endpoint := c.endpoint("scopes")
+	if filter.IncludeInactive {
+		endpoint += "?inactive=true"
+	}
+
+	var scopes []types.ScopeRegistration
+	stream := arrayStream[types.ScopeRegistration]{
+		start: func() {
+			scopes = make([]types.ScopeRegistration, 0)
+		},
+		yield: func(scope types.ScopeRegistration) bool {
+			if filter.matches(scope) {
+				scopes = append(scopes, scope)
+			}
+			return true
+		},
+	}
+
+	if err := c.request(ctx, opListScopes, http.MethodGet, endpoint, nil, stream); err != nil {
 		return nil, err
 	}
 
@@ -221,6 +462,10 @@ func (c Client) GetScopes(ctx context.Context) ([]types.ScopeRegistration, error
 }
 
 func (c Client) RegisterScope(ctx context.Context, payload types.ScopeRegistration) (*types.ScopeRegistration, error) {
+	if plan := PlanFrom(ctx); plan != nil {
+		return plan.registerScope(payload), nil
+	}
+
 	endpoint := c.endpoint("scopes")
 	registration := &types.ScopeRegistration{}
 	jsonPayload, err := json.Marshal(payload)
@@ -228,13 +473,17 @@ func (c Client) RegisterScope(ctx context.Context, payload types.ScopeRegistrati
 		return nil, fmt.Errorf("marshal payload: %w", err)
 	}
 
-	if err := c.request(ctx, http.MethodPost, endpoint, jsonPayload, registration); err != nil {
+	if err := c.request(ctx, opRegisterScope, http.MethodPost, endpoint, jsonPayload, registration, tracing.AttributeScope.String(payload.Prefix+":"+payload.Subscope)); err != nil {
 		return nil, err
 	}
 	return registration, nil
 }
 
 func (c Client) UpdateScope(ctx context.Context, payload types.ScopeRegistration, scope string) (*types.ScopeRegistration, error) {
+	if plan := PlanFrom(ctx); plan != nil {
+		return plan.updateScope(payload, scope), nil
+	}
+
 	endpoint := c.endpoint("scopes") + "?scope=" + url.QueryEscape(scope)
 	registration := &types.ScopeRegistration{}
 
@@ -243,7 +492,7 @@ func (c Client) UpdateScope(ctx context.Context, payload types.ScopeRegistration
 		return nil, fmt.Errorf("marshal payload: %w", err)
 	}
 
-	if err := c.request(ctx, http.MethodPut, endpoint, jsonPayload, registration); err != nil {
+	if err := c.request(ctx, opUpdateScope, http.MethodPut, endpoint, jsonPayload, registration, tracing.AttributeScope.String(scope)); err != nil {
 		return nil, err
 	}
 
@@ -251,109 +500,160 @@ func (c Client) UpdateScope(ctx context.Context, payload types.ScopeRegistration
 }
 
 func (c Client) DeleteScope(ctx context.Context, scope string) (*types.ScopeRegistration, error) {
+	if plan := PlanFrom(ctx); plan != nil {
+		return plan.deleteScope(scope), nil
+	}
+
 	endpoint := c.endpoint("scopes") + "?scope=" + url.QueryEscape(scope)
 	actualScopesRegistration := &types.ScopeRegistration{}
 
-	if err := c.request(ctx, http.MethodDelete, endpoint, nil, &actualScopesRegistration); err != nil {
+	if err := c.request(ctx, opDeleteScope, http.MethodDelete, endpoint, nil, &actualScopesRegistration, tracing.AttributeScope.String(scope)); err != nil {
 		return nil, err
 	}
 	return actualScopesRegistration, nil
 }
 
 func (c Client) GetScopeACL(ctx context.Context, scope string) (*[]types.ConsumerRegistration, error) {
+	if plan := PlanFrom(ctx); plan != nil && plan.isPlannedScope(scope) {
+		return &[]types.ConsumerRegistration{}, nil
+	}
+
 	endpoint := c.endpoint("scopes", "access") + "?scope=" + url.QueryEscape(scope)
 	registration := &[]types.ConsumerRegistration{}
-	if err := c.request(ctx, http.MethodGet, endpoint, nil, registration); err != nil {
+	if err := c.request(ctx, opGetScopeACL, http.MethodGet, endpoint, nil, registration, tracing.AttributeScope.String(scope)); err != nil {
 		return nil, err
 	}
 	return registration, nil
 }
 
 func (c Client) AddToScopeACL(ctx context.Context, scope, consumerOrgno string) (*types.ConsumerRegistration, error) {
+	if plan := PlanFrom(ctx); plan != nil {
+		return plan.addToScopeACL(scope, consumerOrgno), nil
+	}
+
 	endpoint := c.endpoint("scopes", "access", consumerOrgno) + "?scope=" + url.QueryEscape(scope)
 	registration := &types.ConsumerRegistration{}
 
-	if err := c.request(ctx, http.MethodPut, endpoint, nil, registration); err != nil {
+	if err := c.request(ctx, opAddToScopeACL, http.MethodPut, endpoint, nil, registration, tracing.AttributeScope.String(scope)); err != nil {
 		return nil, err
 	}
 	return registration, nil
 }
 
 func (c Client) DeactivateConsumer(ctx context.Context, scope, consumerOrgno string) (*types.ConsumerRegistration, error) {
+	if plan := PlanFrom(ctx); plan != nil {
+		return plan.removeFromScopeACL(scope, consumerOrgno), nil
+	}
+
 	endpoint := c.endpoint("scopes", "access", consumerOrgno) + "?scope=" + url.QueryEscape(scope)
 	registration := &types.ConsumerRegistration{}
 
-	if err := c.request(ctx, http.MethodDelete, endpoint, []byte{}, registration); err != nil {
+	if err := c.request(ctx, opRemoveFromScopeACL, http.MethodDelete, endpoint, []byte{}, registration, tracing.AttributeScope.String(scope)); err != nil {
 		return nil, err
 	}
 	return registration, nil
 }
 
 func (c Client) endpoint(path ...string) string {
-	return c.Config.DigDir.Admin.ApiV1URL().JoinPath(path...).String()
+	return c.admin.ApiV1URL().JoinPath(path...).String()
+}
+
+// Unavailable returns true if requests to DigDir are rejected by the circuit breaker, along with the time until DigDir is probed again.
+func (c Client) Unavailable() (time.Duration, bool) {
+	return c.breaker.unavailable()
 }
 
-func (c Client) request(ctx context.Context, method string, endpoint string, payload []byte, unmarshalTarget any) error {
+func (c Client) request(ctx context.Context, operation, method, endpoint string, payload []byte, unmarshalTarget any, attrs ...attribute.KeyValue) (err error) {
+	ctx, span := tracing.Start(ctx, "digdir."+operation, append(attrs, semconv.HTTPRequestMethodKey.String(method), tracing.AttributeIdentity.String(c.Identity))...)
+	defer tracing.End(span, &err)
+
+	attempt := 0
 	retryable := func(ctx context.Context) error {
-		ctx, cancel := context.WithTimeout(ctx, httpRequestTimeout)
-		defer cancel()
+		attempt++
+		ctx = withAttempt(ctx, attempt)
 
-		token, err := c.getAuthToken(ctx)
+		release, err := c.limiter.acquire(ctx)
 		if err != nil {
-			return fmt.Errorf("get auth token: %w", err)
+			return fmt.Errorf("waiting for rate limiter: %w", err)
 		}
+		defer release()
 
-		req, err := http.NewRequestWithContext(ctx, method, endpoint, bytes.NewBuffer(payload))
-		if err != nil {
-			return fmt.Errorf("creating %s request: %w", method, err)
+		if err := c.breaker.allow(); err != nil {
+			return err
 		}
-		req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", token.AccessToken))
-		req.Header.Add("Content-Type", "application/json")
 
-		resp, err := c.HttpClient.Do(req)
-		if err != nil {
-			return fmt.Errorf("doing %s request to %s: %w", method, endpoint, err)
-		}
+		err = c.do(ctx, operation, method, endpoint, payload, unmarshalTarget)
+		c.breaker.record(err)
+		return err
+	}
 
-		defer func(Body io.ReadCloser) {
-			_ = Body.Close()
-		}(resp.Body)
+	err = retry.Fibonacci(retryInitialDelay).
+		WithMaxAttempts(retryMaxAttempts).
+		Do(ctx, retryable)
+	span.SetAttributes(semconv.HTTPRequestResendCount(attempt - 1))
+	return err
+}
 
-		body, err := io.ReadAll(resp.Body)
-		if err != nil {
-			return fmt.Errorf("reading response: %w", err)
-		}
+// do sends a single request, and classifies any errors for retries.
+func (c Client) do(ctx context.Context, operation, method, endpoint string, payload []byte, unmarshalTarget any) error {
+	ctx, cancel := context.WithTimeout(ctx, httpRequestTimeout)
+	defer cancel()
 
-		if resp.StatusCode >= 400 && resp.StatusCode < 500 {
-			err = &Error{
-				Err:        ErrClient,
-				Message:    string(body),
-				Status:     resp.Status,
-				StatusCode: resp.StatusCode,
-			}
-		} else if resp.StatusCode >= 500 {
-			err = &Error{
-				Err:        ErrServer,
-				Message:    string(body),
-				Status:     resp.Status,
-				StatusCode: resp.StatusCode,
-			}
-		}
+	token, err := c.tokens.Token(ctx)
+	if err != nil {
+		return fmt.Errorf("get auth token: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, bytes.NewBuffer(payload))
+	if err != nil {
+		return fmt.Errorf("creating %s request: %w", method, err)
+	}
+	req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", token.AccessToken))
+	req.Header.Add("Content-Type", "application/json")
+
+	start := time.Now()
+	resp, err := c.HttpClient.Do(req)
+	if err != nil {
+		metrics.ObserveDigDirRequest(c.Identity, operation, method, 0, attemptFrom(ctx), time.Since(start))
+		return retry.Network(fmt.Errorf("doing %s request to %s: %w", method, endpoint, err))
+	}
+
+	defer func(Body io.ReadCloser) {
+		_ = Body.Close()
+	}(resp.Body)
+
+	if resp.StatusCode >= 400 {
+		body, err := io.ReadAll(resp.Body)
+		metrics.ObserveDigDirRequest(c.Identity, operation, method, resp.StatusCode, attemptFrom(ctx), time.Since(start))
 		if err != nil {
-			return retry.RetryableError(err)
+			return retry.Network(fmt.Errorf("reading response: %w", err))
 		}
 
-		if unmarshalTarget != nil {
-			if err := json.Unmarshal(body, &unmarshalTarget); err != nil {
-				return fmt.Errorf("unmarshalling: %w", err)
-			}
+		if resp.StatusCode == http.StatusUnauthorized {
+			// the cached token may have been revoked or expired early; force a refresh on the next attempt
+			c.tokens.Invalidate(token)
+			return retry.RetryableError(newError(resp, body))
 		}
-		return nil
+		return retry.HTTPResponse(newError(resp, body), resp)
 	}
 
-	return retry.Fibonacci(retryInitialDelay).
-		WithMaxAttempts(retryMaxAttempts).
-		Do(ctx, retryable)
+	err = decodeResponse(resp.Body, unmarshalTarget)
+	metrics.ObserveDigDirRequest(c.Identity, operation, method, resp.StatusCode, attemptFrom(ctx), time.Since(start))
+	return err
+}
+
+type attemptKey struct{}
+
+// withAttempt attaches the number of the current attempt for a request to the context, for use in metrics.
+func withAttempt(ctx context.Context, attempt int) context.Context {
+	return context.WithValue(ctx, attemptKey{}, attempt)
+}
+
+func attemptFrom(ctx context.Context) int {
+	if attempt, ok := ctx.Value(attemptKey{}).(int); ok {
+		return attempt
+	}
+	return 1
 }
 
 func clientMatches(actual types.ClientRegistration, desired clients.Instance, clusterName string) bool {
@@ -362,7 +662,10 @@ func clientMatches(actual types.ClientRegistration, desired clients.Instance, cl
 	}
 
 	// We don't have an existing client ID, so we'll have to do best-effort matching.
-	descriptionMatches := actual.Description == kubernetes.UniformResourceName(desired, clusterName)
-	integrationTypeMatches := actual.IntegrationType == clients.GetIntegrationType(desired)
-	return descriptionMatches && integrationTypeMatches
+	return descriptionMatches(actual, desired, clusterName)
+}
+
+func descriptionMatches(actual types.ClientRegistration, desired clients.Instance, clusterName string) bool {
+	return actual.Description == kubernetes.UniformResourceName(desired, clusterName) &&
+		actual.IntegrationType == clients.GetIntegrationType(desired)
 }
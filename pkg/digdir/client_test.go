@@ -0,0 +1,257 @@
+package digdir_test
+
+import (
+	"testing"
+	"time"
+
+	naisiov1 "github.com/nais/liberator/pkg/apis/nais.io/v1"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/nais/digdirator/pkg/config"
+	"github.com/nais/digdirator/pkg/digdir"
+	"github.com/nais/digdirator/pkg/digdir/types"
+	"github.com/nais/digdirator/pkg/metrics"
+	"github.com/nais/digdirator/pkg/tracing"
+)
+
+func TestClient_RequestMetrics(t *testing.T) {
+	_, client := setupFake(t)
+
+	count := func(operation, method, statusClass string) float64 {
+		return testutil.ToFloat64(metrics.DigDirRequestsCount.WithLabelValues(config.DefaultIdentity, operation, method, statusClass, "1"))
+	}
+	tokens := count("get_token", "POST", "2xx")
+	registered := count("register_client", "POST", "2xx")
+	rejected := count("register_client", "POST", "4xx")
+
+	_, err := client.Register(t.Context(), types.ClientRegistration{
+		ClientName:      "valid",
+		Description:     "test-cluster:test-namespace:valid",
+		IntegrationType: types.IntegrationTypeMaskinporten,
+	})
+	require.NoError(t, err)
+
+	// invalid redirect URIs fail immediately
+	_, err = client.Register(t.Context(), types.ClientRegistration{
+		ClientName:      "invalid",
+		Description:     "test-cluster:test-namespace:invalid",
+		IntegrationType: types.IntegrationTypeIDPorten,
+	})
+	var digdirErr *digdir.Error
+	require.ErrorAs(t, err, &digdirErr)
+
+	assert.Equal(t, tokens+1, count("get_token", "POST", "2xx"))
+	assert.Equal(t, registered+1, count("register_client", "POST", "2xx"))
+	assert.Equal(t, rejected+1, count("register_client", "POST", "4xx"))
+	assert.Zero(t, testutil.ToFloat64(metrics.DigDirRequestsCount.WithLabelValues(config.DefaultIdentity, "register_client", "POST", "4xx", "2")))
+}
+
+func TestClient_RequestSpans(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+
+	_, client := setupFake(t)
+
+	ctx, parent := tracing.Start(t.Context(), "test")
+	registration, err := client.Register(ctx, types.ClientRegistration{
+		ClientName:      "test",
+		Description:     "test-cluster:test-namespace:test",
+		IntegrationType: types.IntegrationTypeMaskinporten,
+	})
+	require.NoError(t, err)
+
+	_, err = client.GetKeys(ctx, "unknown")
+	require.Error(t, err)
+	parent.End()
+
+	spans := make(map[string]sdktrace.ReadOnlySpan)
+	for _, span := range recorder.Ended() {
+		spans[span.Name()] = span
+	}
+	require.Contains(t, spans, "digdir.get_token")
+	require.Contains(t, spans, "digdir.register_client")
+	require.Contains(t, spans, "digdir.get_keys")
+
+	register := spans["digdir.register_client"]
+	assert.Equal(t, parent.SpanContext().SpanID(), register.Parent().SpanID())
+	assert.Contains(t, register.Attributes(), attribute.String("http.request.method", "POST"))
+	assert.Equal(t, codes.Unset, register.Status().Code)
+	assert.NotEmpty(t, registration.ClientID)
+
+	getKeys := spans["digdir.get_keys"]
+	assert.Contains(t, getKeys.Attributes(), tracing.AttributeClientID.String("unknown"))
+	assert.Equal(t, codes.Error, getKeys.Status().Code)
+}
+
+func TestClient_ForIdentity(t *testing.T) {
+	srv, client := setupFake(t, func(cfg *config.Config) {
+		cfg.DigDir.Identities = map[string]config.Identity{
+			"sister": {Admin: config.Admin{ClientID: "sister-admin"}},
+		}
+	})
+	srv.SetOrgno("sister-admin", "999999999")
+
+	_, err := client.ForIdentity("unknown", client.Signer)
+	assert.Error(t, err)
+
+	sister, err := client.ForIdentity("sister", client.Signer)
+	require.NoError(t, err)
+	assert.Equal(t, "sister", sister.Identity)
+
+	registered := func() float64 {
+		return testutil.ToFloat64(metrics.DigDirRequestsCount.WithLabelValues("sister", "register_client", "POST", "2xx", "1"))
+	}
+	before := registered()
+
+	registration, err := sister.Register(t.Context(), types.ClientRegistration{
+		ClientName:      "sister",
+		Description:     "test-cluster:test-namespace:sister",
+		IntegrationType: types.IntegrationTypeMaskinporten,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "999999999", registration.ClientOrgno)
+	assert.Equal(t, before+1, registered())
+
+	listed, err := sister.List(t.Context())
+	require.NoError(t, err)
+	assert.Len(t, listed, 1)
+
+	listed, err = client.List(t.Context())
+	require.NoError(t, err)
+	assert.Empty(t, listed, "clients of other identities should not be visible")
+	assert.Equal(t, 2, srv.IssuedTokens(), "each identity should have its own token")
+}
+
+func TestClient_GetRegistration(t *testing.T) {
+	instance := func(name, clientID string) *naisiov1.MaskinportenClient {
+		return &naisiov1.MaskinportenClient{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "test-namespace"},
+			Status:     naisiov1.DigdiratorStatus{ClientID: clientID},
+		}
+	}
+	listed := func() float64 {
+		return testutil.ToFloat64(metrics.DigDirRequestsCount.WithLabelValues(config.DefaultIdentity, "list_clients", "GET", "2xx", "1"))
+	}
+
+	for _, tt := range []struct {
+		name string
+		ttl  time.Duration
+		// lists is the number of times that clients are listed by the lookups below
+		lists float64
+	}{
+		{name: "without index", lists: 6},
+		{name: "with index", ttl: time.Hour, lists: 1},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			_, client := setupFake(t, func(cfg *config.Config) {
+				cfg.DigDir.ClientIndex.TTL = tt.ttl
+				cfg.DigDir.Identities = map[string]config.Identity{
+					"portal": {Admin: config.Admin{ClientID: "portal"}},
+				}
+			})
+			// changes through another identity of the same organization are not seen by the client's index
+			portal, err := client.ForIdentity("portal", client.Signer)
+			require.NoError(t, err)
+
+			registered := make(map[string]*types.ClientRegistration)
+			for _, name := range []string{"a", "b"} {
+				registration, err := client.Register(t.Context(), types.ClientRegistration{
+					ClientName:      name,
+					Description:     "test-cluster:test-namespace:" + name,
+					IntegrationType: types.IntegrationTypeMaskinporten,
+				})
+				require.NoError(t, err)
+				registered[name] = registration
+			}
+			before := listed()
+
+			a := instance("a", "")
+			registration, err := client.GetRegistration(a, t.Context(), "test-cluster")
+			require.NoError(t, err)
+			require.NotNil(t, registration)
+			assert.Equal(t, registered["a"].ClientID, registration.ClientID)
+			assert.Equal(t, registered["a"].ClientID, a.Status.ClientID)
+
+			registration, err = client.GetRegistration(instance("b", registered["b"].ClientID), t.Context(), "test-cluster")
+			require.NoError(t, err)
+			require.NotNil(t, registration)
+			assert.Equal(t, "b", registration.ClientName)
+
+			changed := *registration
+			changed.ClientName = "changed in portal"
+			_, err = portal.Update(t.Context(), changed, changed.ClientID)
+			require.NoError(t, err)
+
+			registration, err = client.GetRegistration(instance("b", ""), t.Context(), "test-cluster")
+			require.NoError(t, err)
+			require.NotNil(t, registration)
+			assert.Equal(t, "changed in portal", registration.ClientName, "registration should not be served from the index")
+
+			registration, err = client.GetRegistration(instance("unknown", ""), t.Context(), "test-cluster")
+			require.NoError(t, err)
+			assert.Nil(t, registration)
+
+			require.NoError(t, client.Delete(t.Context(), registered["a"].ClientID))
+			registration, err = client.GetRegistration(instance("a", registered["a"].ClientID), t.Context(), "test-cluster")
+			require.NoError(t, err)
+			assert.Nil(t, registration)
+
+			recreated, err := client.Register(t.Context(), types.ClientRegistration{
+				ClientName:      "a",
+				Description:     "test-cluster:test-namespace:a",
+				IntegrationType: types.IntegrationTypeMaskinporten,
+			})
+			require.NoError(t, err)
+			stale := instance("a", registered["a"].ClientID)
+			registration, err = client.GetRegistration(stale, t.Context(), "test-cluster")
+			require.NoError(t, err)
+			require.NotNil(t, registration, "registration should be found by description when the client ID in the status is not found")
+			assert.Equal(t, recreated.ClientID, registration.ClientID)
+			assert.Equal(t, recreated.ClientID, stale.Status.ClientID)
+
+			assert.Equal(t, before+tt.lists, listed())
+		})
+	}
+}
+
+func TestClient_ListScopes(t *testing.T) {
+	srv, client := setupFake(t)
+	srv.AddScope(types.ScopeRegistration{Prefix: "nav", Subscope: "test/active", Active: true})
+	srv.AddScope(types.ScopeRegistration{Prefix: "nav", Subscope: "test/other", Active: true})
+	srv.AddScope(types.ScopeRegistration{Prefix: "nav", Subscope: "test/inactive", Active: false})
+
+	subscopes := func(scopes []types.ScopeRegistration) []string {
+		result := make([]string, 0)
+		for _, scope := range scopes {
+			result = append(result, scope.Subscope)
+		}
+		return result
+	}
+
+	for _, tt := range []struct {
+		name   string
+		filter digdir.ScopeFilter
+		want   []string
+	}{
+		{name: "active", filter: digdir.ScopeFilter{}, want: []string{"test/active", "test/other"}},
+		{name: "all", filter: digdir.ScopeFilter{IncludeInactive: true}, want: []string{"test/active", "test/other", "test/inactive"}},
+		{name: "subscopes", filter: digdir.ScopeFilter{Subscopes: []string{"test/other", "test/inactive"}}, want: []string{"test/other"}},
+		{name: "inactive subscopes", filter: digdir.ScopeFilter{IncludeInactive: true, Subscopes: []string{"test/inactive"}}, want: []string{"test/inactive"}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			scopes, err := client.ListScopes(t.Context(), tt.filter)
+			require.NoError(t, err)
+			assert.ElementsMatch(t, tt.want, subscopes(scopes))
+		})
+	}
+}
@@ -0,0 +1,162 @@
+package fake
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+
+	"github.com/google/uuid"
+
+	"github.com/nais/digdirator/pkg/digdir/types"
+)
+
+var integrationTypes = []types.IntegrationType{
+	types.IntegrationTypeAnsattporten,
+	types.IntegrationTypeApiKlient,
+	types.IntegrationTypeIDPorten,
+	types.IntegrationTypeKrr,
+	types.IntegrationTypeMaskinporten,
+}
+
+// listClients returns the clients owned by the authenticated organization.
+func (s *Server) listClients(w http.ResponseWriter, r *http.Request) {
+	result := make([]types.ClientRegistration, 0)
+	for _, client := range s.clientList() {
+		if client.ClientOrgno == orgnoFrom(r) {
+			result = append(result, client)
+		}
+	}
+	respond(w, http.StatusOK, result)
+}
+
+func (s *Server) createClient(w http.ResponseWriter, r *http.Request) {
+	payload := types.ClientRegistration{}
+	if err := decode(r, &payload); err != nil {
+		respondError(w, http.StatusBadRequest, "invalid_request", "decoding client: %v", err)
+		return
+	}
+
+	if err := s.validateClient(payload, orgnoFrom(r)); err != nil {
+		respondError(w, http.StatusBadRequest, "invalid_client_metadata", "%v", err)
+		return
+	}
+
+	payload.ClientID = uuid.New().String()
+	payload.ClientOrgno = orgnoFrom(r)
+
+	s.clients[payload.ClientID] = &payload
+	s.clientIDs = append(s.clientIDs, payload.ClientID)
+	respond(w, http.StatusCreated, payload)
+}
+
+func (s *Server) getClient(w http.ResponseWriter, r *http.Request) {
+	client, ok := s.ownedClient(w, r)
+	if !ok {
+		return
+	}
+	respond(w, http.StatusOK, client)
+}
+
+func (s *Server) updateClient(w http.ResponseWriter, r *http.Request) {
+	clientID := r.PathValue("id")
+	existing, ok := s.ownedClient(w, r)
+	if !ok {
+		return
+	}
+
+	payload := types.ClientRegistration{}
+	if err := decode(r, &payload); err != nil {
+		respondError(w, http.StatusBadRequest, "invalid_request", "decoding client: %v", err)
+		return
+	}
+
+	if payload.IntegrationType != existing.IntegrationType {
+		respondError(w, http.StatusBadRequest, "invalid_client_metadata", "integration_type cannot be changed (existing: %s, desired: %s)", existing.IntegrationType, payload.IntegrationType)
+		return
+	}
+
+	if err := s.validateClient(payload, existing.ClientOrgno); err != nil {
+		respondError(w, http.StatusBadRequest, "invalid_client_metadata", "%v", err)
+		return
+	}
+
+	payload.ClientID = clientID
+	payload.ClientOrgno = existing.ClientOrgno
+
+	s.clients[clientID] = &payload
+	respond(w, http.StatusOK, payload)
+}
+
+func (s *Server) deleteClient(w http.ResponseWriter, r *http.Request) {
+	clientID := r.PathValue("id")
+	if _, ok := s.ownedClient(w, r); !ok {
+		return
+	}
+
+	delete(s.clients, clientID)
+	delete(s.jwks, clientID)
+	s.clientIDs = slices.DeleteFunc(s.clientIDs, func(id string) bool {
+		return id == clientID
+	})
+	w.WriteHeader(http.StatusOK)
+}
+
+// ownedClient returns the client named by the "id" path parameter, if it exists and is owned by the authenticated organization.
+// Clients owned by other organizations are reported as not found.
+func (s *Server) ownedClient(w http.ResponseWriter, r *http.Request) (*types.ClientRegistration, bool) {
+	clientID := r.PathValue("id")
+	client, ok := s.clients[clientID]
+	if !ok || client.ClientOrgno != orgnoFrom(r) {
+		respondError(w, http.StatusNotFound, "not_found", "client %q not found", clientID)
+		return nil, false
+	}
+	return client, true
+}
+
+func (s *Server) validateClient(client types.ClientRegistration, orgno string) error {
+	if client.ClientName == "" {
+		return fmt.Errorf("client_name is required")
+	}
+
+	if !slices.Contains(integrationTypes, client.IntegrationType) {
+		return fmt.Errorf("unsupported integration_type %q", client.IntegrationType)
+	}
+
+	for _, uri := range append(client.RedirectURIs, client.PostLogoutRedirectURIs...) {
+		if u, err := url.Parse(uri); err != nil || !u.IsAbs() {
+			return fmt.Errorf("invalid redirect URI %q", uri)
+		}
+	}
+
+	requiresRedirect := client.IntegrationType == types.IntegrationTypeIDPorten || client.IntegrationType == types.IntegrationTypeAnsattporten
+	if requiresRedirect && len(client.RedirectURIs) == 0 {
+		return fmt.Errorf("redirect_uris is required for integration_type %q", client.IntegrationType)
+	}
+
+	if client.IntegrationType == types.IntegrationTypeMaskinporten {
+		for _, scope := range client.Scopes {
+			if !s.canAccessScope(scope, orgno) {
+				return fmt.Errorf("organization %s has no access to scope %q", orgno, scope)
+			}
+		}
+	}
+
+	return nil
+}
+
+func (s *Server) clientList() []types.ClientRegistration {
+	result := make([]types.ClientRegistration, 0, len(s.clientIDs))
+	for _, id := range s.clientIDs {
+		result = append(result, *s.clients[id])
+	}
+	return result
+}
+
+// Clients returns all registered clients in registration order.
+func (s *Server) Clients() []types.ClientRegistration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.clientList()
+}
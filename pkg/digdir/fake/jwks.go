@@ -0,0 +1,163 @@
+package fake
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/go-jose/go-jose/v4"
+)
+
+const timestampFormat = "2006-01-02T15:04:05.000Z07:00"
+
+type jwks struct {
+	created     time.Time
+	lastUpdated time.Time
+	keys        []jwk
+}
+
+type jwk struct {
+	key    jose.JSONWebKey
+	expiry time.Time
+}
+
+// MarshalJSON returns the public JWK with the DigDir-specific "exp" field.
+func (k jwk) MarshalJSON() ([]byte, error) {
+	raw, err := k.key.MarshalJSON()
+	if err != nil {
+		return nil, err
+	}
+
+	fields := make(map[string]any)
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		return nil, err
+	}
+	fields["exp"] = k.expiry.Unix()
+
+	return json.Marshal(fields)
+}
+
+func (j *jwks) response() map[string]any {
+	return map[string]any{
+		"created":      j.created.Format(timestampFormat),
+		"last_updated": j.lastUpdated.Format(timestampFormat),
+		"keys":         j.keys,
+	}
+}
+
+func (s *Server) getJwks(w http.ResponseWriter, r *http.Request) {
+	client, ok := s.ownedClient(w, r)
+	if !ok {
+		return
+	}
+	clientID := client.ClientID
+
+	set, ok := s.jwks[clientID]
+	if !ok {
+		respond(w, http.StatusOK, map[string]any{"keys": []jwk{}})
+		return
+	}
+	respond(w, http.StatusOK, set.response())
+}
+
+// registerJwks replaces the client's JWKS. All keys in the set get a fresh expiry.
+func (s *Server) registerJwks(w http.ResponseWriter, r *http.Request) {
+	client, ok := s.ownedClient(w, r)
+	if !ok {
+		return
+	}
+	clientID := client.ClientID
+
+	payload := jose.JSONWebKeySet{}
+	if err := decode(r, &payload); err != nil {
+		respondError(w, http.StatusBadRequest, "invalid_request", "decoding JWKS: %v", err)
+		return
+	}
+
+	if err := s.validateJwks(payload); err != nil {
+		respondError(w, http.StatusBadRequest, "invalid_client_metadata", "%v", err)
+		return
+	}
+
+	now := s.opts.Now()
+	set, ok := s.jwks[clientID]
+	if !ok {
+		set = &jwks{created: now}
+		s.jwks[clientID] = set
+	}
+
+	set.lastUpdated = now
+	set.keys = make([]jwk, 0, len(payload.Keys))
+	for _, key := range payload.Keys {
+		set.keys = append(set.keys, jwk{key: key, expiry: now.Add(s.opts.KeyLifetime)})
+	}
+
+	respond(w, http.StatusCreated, set.response())
+}
+
+func (s *Server) validateJwks(set jose.JSONWebKeySet) error {
+	if len(set.Keys) == 0 {
+		return fmt.Errorf("JWKS must contain at least one key")
+	}
+
+	if len(set.Keys) > s.opts.MaxJwksSize {
+		return fmt.Errorf("JWKS contains %d keys, maximum is %d", len(set.Keys), s.opts.MaxJwksSize)
+	}
+
+	seen := make(map[string]bool)
+	for _, key := range set.Keys {
+		if key.KeyID == "" {
+			return fmt.Errorf("all keys must have a key ID")
+		}
+		if seen[key.KeyID] {
+			return fmt.Errorf("duplicate key ID %q", key.KeyID)
+		}
+		seen[key.KeyID] = true
+
+		if !key.Valid() || !key.IsPublic() {
+			return fmt.Errorf("key %q must be a valid public key", key.KeyID)
+		}
+		if key.Use != "" && key.Use != "sig" {
+			return fmt.Errorf("key %q has unsupported use %q", key.KeyID, key.Use)
+		}
+	}
+
+	return nil
+}
+
+// Keys returns the public keys registered for the given client.
+func (s *Server) Keys(clientID string) []jose.JSONWebKey {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	set, ok := s.jwks[clientID]
+	if !ok {
+		return nil
+	}
+
+	keys := make([]jose.JSONWebKey, 0, len(set.keys))
+	for _, key := range set.keys {
+		keys = append(keys, key.key)
+	}
+	return keys
+}
+
+// SetKeyExpiry overrides the expiry of a registered key, e.g. to simulate keys that are about to expire.
+func (s *Server) SetKeyExpiry(clientID, keyID string, expiry time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	set, ok := s.jwks[clientID]
+	if !ok {
+		return fmt.Errorf("client %q has no registered keys", clientID)
+	}
+
+	for i := range set.keys {
+		if set.keys[i].key.KeyID == keyID {
+			set.keys[i].expiry = expiry
+			return nil
+		}
+	}
+	return fmt.Errorf("key %q not found for client %q", keyID, clientID)
+}
@@ -0,0 +1,329 @@
+package fake
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+
+	"github.com/nais/digdirator/pkg/digdir/types"
+)
+
+var orgnoPattern = regexp.MustCompile(`^\d{9}$`)
+
+type scope struct {
+	registration types.ScopeRegistration
+	// consumers preserves the order in which consumers were first added to the ACL.
+	consumers []string
+	acl       map[string]*types.ConsumerRegistration
+}
+
+func (sc *scope) aclList() []types.ConsumerRegistration {
+	result := make([]types.ConsumerRegistration, 0, len(sc.consumers))
+	for _, orgno := range sc.consumers {
+		result = append(result, *sc.acl[orgno])
+	}
+	return result
+}
+
+// listScopes returns the scopes owned by the authenticated organization.
+func (s *Server) listScopes(w http.ResponseWriter, r *http.Request) {
+	includeInactive := r.URL.Query().Get("inactive") == "true"
+
+	result := make([]types.ScopeRegistration, 0)
+	for _, sc := range s.scopeList() {
+		if sc.registration.OwnerOrgno != orgnoFrom(r) {
+			continue
+		}
+		if !sc.registration.Active && !includeInactive {
+			continue
+		}
+		result = append(result, sc.registration)
+	}
+	respond(w, http.StatusOK, result)
+}
+
+// listOpenScopes returns all active scopes, optionally only those accessible to any organization.
+func (s *Server) listOpenScopes(w http.ResponseWriter, r *http.Request) {
+	onlyAccessibleForAll := r.URL.Query().Get("accessible_for_all") == "true"
+
+	result := make([]types.ScopeRegistration, 0)
+	for _, sc := range s.scopeList() {
+		if !sc.registration.Active {
+			continue
+		}
+		if onlyAccessibleForAll && !sc.registration.AccessibleForAll {
+			continue
+		}
+		result = append(result, sc.registration)
+	}
+	respond(w, http.StatusOK, result)
+}
+
+// listAccessibleScopes returns the scopes that the authenticated organization has been granted access to.
+func (s *Server) listAccessibleScopes(w http.ResponseWriter, r *http.Request) {
+	result := make([]types.Scope, 0)
+	for _, sc := range s.scopeList() {
+		consumer, ok := sc.acl[orgnoFrom(r)]
+		if !ok {
+			continue
+		}
+		result = append(result, types.Scope{
+			ConsumerOrgNo: consumer.ConsumerOrgno,
+			OwnerOrgNo:    consumer.OwnerOrgno,
+			Scope:         consumer.Scope,
+			State:         types.ScopeAccessState(consumer.State),
+		})
+	}
+	respond(w, http.StatusOK, result)
+}
+
+func (s *Server) createScope(w http.ResponseWriter, r *http.Request) {
+	payload := types.ScopeRegistration{}
+	if err := decode(r, &payload); err != nil {
+		respondError(w, http.StatusBadRequest, "invalid_request", "decoding scope: %v", err)
+		return
+	}
+
+	if err := validateScope(payload); err != nil {
+		respondError(w, http.StatusBadRequest, "invalid_request", "%v", err)
+		return
+	}
+
+	name := scopeName(payload)
+	if _, exists := s.scopes[name]; exists {
+		respondError(w, http.StatusConflict, "invalid_request", "scope %q already exists", name)
+		return
+	}
+
+	payload.Name = name
+	payload.OwnerOrgno = orgnoFrom(r)
+	payload.Active = true
+	s.addScope(payload)
+
+	respond(w, http.StatusCreated, payload)
+}
+
+func (s *Server) updateScope(w http.ResponseWriter, r *http.Request) {
+	sc, ok := s.ownedScope(w, r)
+	if !ok {
+		return
+	}
+
+	payload := types.ScopeRegistration{}
+	if err := decode(r, &payload); err != nil {
+		respondError(w, http.StatusBadRequest, "invalid_request", "decoding scope: %v", err)
+		return
+	}
+
+	if err := validateScope(payload); err != nil {
+		respondError(w, http.StatusBadRequest, "invalid_request", "%v", err)
+		return
+	}
+
+	if scopeName(payload) != sc.registration.Name {
+		respondError(w, http.StatusBadRequest, "invalid_request", "prefix and subscope cannot be changed for scope %q", sc.registration.Name)
+		return
+	}
+
+	payload.Name = sc.registration.Name
+	payload.OwnerOrgno = sc.registration.OwnerOrgno
+	sc.registration = payload
+
+	respond(w, http.StatusOK, sc.registration)
+}
+
+// deleteScope deactivates the scope. Deactivated scopes can be re-activated by an update.
+func (s *Server) deleteScope(w http.ResponseWriter, r *http.Request) {
+	sc, ok := s.ownedScope(w, r)
+	if !ok {
+		return
+	}
+
+	sc.registration.Active = false
+	respond(w, http.StatusOK, sc.registration)
+}
+
+func (s *Server) getScopeACL(w http.ResponseWriter, r *http.Request) {
+	sc, ok := s.ownedScope(w, r)
+	if !ok {
+		return
+	}
+
+	respond(w, http.StatusOK, sc.aclList())
+}
+
+func (s *Server) addToScopeACL(w http.ResponseWriter, r *http.Request) {
+	sc, ok := s.ownedScope(w, r)
+	if !ok {
+		return
+	}
+
+	orgno := r.PathValue("orgno")
+	if !orgnoPattern.MatchString(orgno) {
+		respondError(w, http.StatusBadRequest, "invalid_request", "invalid consumer organization number %q", orgno)
+		return
+	}
+
+	respond(w, http.StatusOK, s.setScopeAccess(sc, orgno, types.ScopeStateApproved))
+}
+
+// removeFromScopeACL denies the consumer access to the scope. The consumer is kept in the ACL with the DENIED state.
+func (s *Server) removeFromScopeACL(w http.ResponseWriter, r *http.Request) {
+	sc, ok := s.ownedScope(w, r)
+	if !ok {
+		return
+	}
+
+	orgno := r.PathValue("orgno")
+	if _, found := sc.acl[orgno]; !found {
+		respondError(w, http.StatusNotFound, "not_found", "consumer %q not found in ACL for scope %q", orgno, sc.registration.Name)
+		return
+	}
+
+	respond(w, http.StatusOK, s.setScopeAccess(sc, orgno, types.ScopeStateDenied))
+}
+
+// ownedScope returns the scope named by the "scope" query parameter, if it exists and is owned by the authenticated organization.
+func (s *Server) ownedScope(w http.ResponseWriter, r *http.Request) (*scope, bool) {
+	name := r.URL.Query().Get("scope")
+	sc, ok := s.scopes[name]
+	if !ok {
+		respondError(w, http.StatusNotFound, "not_found", "scope %q not found", name)
+		return nil, false
+	}
+
+	if sc.registration.OwnerOrgno != orgnoFrom(r) {
+		respondError(w, http.StatusForbidden, "access_denied", "scope %q is not owned by organization %s", name, orgnoFrom(r))
+		return nil, false
+	}
+
+	return sc, true
+}
+
+func (s *Server) canAccessScope(name, orgno string) bool {
+	sc, ok := s.scopes[name]
+	if !ok || !sc.registration.Active {
+		return false
+	}
+
+	if sc.registration.OwnerOrgno == orgno || sc.registration.AccessibleForAll {
+		return true
+	}
+
+	consumer, ok := sc.acl[orgno]
+	return ok && consumer.State == types.ScopeStateApproved
+}
+
+func (s *Server) addScope(registration types.ScopeRegistration) {
+	if existing, ok := s.scopes[registration.Name]; ok {
+		existing.registration = registration
+		return
+	}
+
+	s.scopes[registration.Name] = &scope{
+		registration: registration,
+		acl:          make(map[string]*types.ConsumerRegistration),
+	}
+	s.scopeNames = append(s.scopeNames, registration.Name)
+}
+
+func (s *Server) setScopeAccess(sc *scope, orgno string, state types.State) types.ConsumerRegistration {
+	now := s.opts.Now()
+
+	consumer, found := sc.acl[orgno]
+	if !found {
+		consumer = &types.ConsumerRegistration{
+			ConsumerOrgno: orgno,
+			Created:       now,
+			OwnerOrgno:    sc.registration.OwnerOrgno,
+			Scope:         sc.registration.Name,
+		}
+		sc.acl[orgno] = consumer
+		sc.consumers = append(sc.consumers, orgno)
+	}
+
+	consumer.State = state
+	consumer.LastUpdated = now
+	return *consumer
+}
+
+func (s *Server) scopeList() []*scope {
+	result := make([]*scope, 0, len(s.scopeNames))
+	for _, name := range s.scopeNames {
+		result = append(result, s.scopes[name])
+	}
+	return result
+}
+
+func validateScope(registration types.ScopeRegistration) error {
+	if registration.Prefix == "" {
+		return fmt.Errorf("prefix is required")
+	}
+	if registration.Subscope == "" {
+		return fmt.Errorf("subscope is required")
+	}
+	if registration.Description == "" {
+		return fmt.Errorf("description is required")
+	}
+	if registration.AtMaxAge < 0 {
+		return fmt.Errorf("at_max_age must not be negative")
+	}
+	return nil
+}
+
+func scopeName(registration types.ScopeRegistration) string {
+	return fmt.Sprintf("%s:%s", registration.Prefix, registration.Subscope)
+}
+
+// AddScope registers a scope directly, e.g. to seed scopes owned by other organizations.
+// If the owner is empty, the scope is owned by the authenticated organization.
+func (s *Server) AddScope(registration types.ScopeRegistration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if registration.Name == "" {
+		registration.Name = scopeName(registration)
+	}
+	if registration.OwnerOrgno == "" {
+		registration.OwnerOrgno = s.opts.Orgno
+	}
+	s.addScope(registration)
+}
+
+// SetScopeAccess sets the access state for a consumer of the given scope, regardless of the scope's owner.
+func (s *Server) SetScopeAccess(name, consumerOrgno string, state types.State) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sc, ok := s.scopes[name]
+	if !ok {
+		return fmt.Errorf("scope %q not found", name)
+	}
+
+	s.setScopeAccess(sc, consumerOrgno, state)
+	return nil
+}
+
+// Scopes returns all registered scopes in registration order.
+func (s *Server) Scopes() []types.ScopeRegistration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	result := make([]types.ScopeRegistration, 0, len(s.scopeNames))
+	for _, sc := range s.scopeList() {
+		result = append(result, sc.registration)
+	}
+	return result
+}
+
+// ScopeACL returns the consumers of the given scope.
+func (s *Server) ScopeACL(name string) []types.ConsumerRegistration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sc, ok := s.scopes[name]
+	if !ok {
+		return nil
+	}
+	return sc.aclList()
+}
@@ -0,0 +1,189 @@
+// Package fake provides a stateful, in-memory implementation of the DigDir self-service API and the Maskinporten token endpoint.
+// It is intended for tests and local development, and enforces a subset of the validation done by DigDir.
+package fake
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nais/digdirator/pkg/digdir/types"
+)
+
+const (
+	DefaultOrgno         = "889640782"
+	DefaultMaxJwksSize   = 5
+	DefaultKeyLifetime   = 365 * 24 * time.Hour
+	DefaultTokenLifetime = 120 * time.Second
+)
+
+type Options struct {
+	// Orgno is the organization number of the authenticated admin client, i.e. the owner of all clients and scopes.
+	Orgno string
+	// MaxJwksSize is the maximum number of keys in a client's JWKS.
+	MaxJwksSize int
+	// KeyLifetime is the lifetime of registered client keys, counted from the time of registration.
+	KeyLifetime time.Duration
+	// TokenLifetime is the lifetime of access tokens issued by the token endpoint.
+	TokenLifetime time.Duration
+	// DelegationSources are returned by the delegation sources endpoint.
+	DelegationSources []types.DelegationSource
+	// Now returns the current time. Defaults to time.Now.
+	Now func() time.Time
+}
+
+// Server is an http.Handler serving the fake API. All methods are safe for concurrent use.
+type Server struct {
+	opts Options
+	mux  *http.ServeMux
+
+	mu sync.Mutex
+	// clientIDs preserves the registration order of clients.
+	clientIDs []string
+	clients   map[string]*types.ClientRegistration
+	jwks      map[string]*jwks
+	// scopeNames preserves the registration order of scopes.
+	scopeNames []string
+	scopes     map[string]*scope
+	tokens     map[string]issuedToken
+	// orgnos maps the client IDs of admin clients that belong to other organizations than Options.Orgno to their organization numbers.
+	orgnos map[string]string
+}
+
+func New(opts Options) *Server {
+	if opts.Orgno == "" {
+		opts.Orgno = DefaultOrgno
+	}
+	if opts.MaxJwksSize <= 0 {
+		opts.MaxJwksSize = DefaultMaxJwksSize
+	}
+	if opts.KeyLifetime <= 0 {
+		opts.KeyLifetime = DefaultKeyLifetime
+	}
+	if opts.TokenLifetime <= 0 {
+		opts.TokenLifetime = DefaultTokenLifetime
+	}
+	if opts.DelegationSources == nil {
+		opts.DelegationSources = make([]types.DelegationSource, 0)
+	}
+	if opts.Now == nil {
+		opts.Now = time.Now
+	}
+
+	s := &Server{
+		opts:    opts,
+		mux:     http.NewServeMux(),
+		clients: make(map[string]*types.ClientRegistration),
+		jwks:    make(map[string]*jwks),
+		scopes:  make(map[string]*scope),
+		tokens:  make(map[string]issuedToken),
+		orgnos:  make(map[string]string),
+	}
+	s.routes()
+	return s
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+func (s *Server) routes() {
+	s.mux.HandleFunc("GET /.well-known/oauth-authorization-server", s.metadata)
+	s.mux.HandleFunc("GET /.well-known/openid-configuration", s.metadata)
+	s.mux.HandleFunc("GET /jwks", s.providerJwks)
+	s.mux.HandleFunc("POST /token", s.token)
+
+	s.mux.HandleFunc("GET /api/v1/delegationsources", s.delegationSources)
+
+	s.mux.Handle("GET /api/v1/clients", s.authenticated(s.listClients))
+	s.mux.Handle("POST /api/v1/clients", s.authenticated(s.createClient))
+	s.mux.Handle("GET /api/v1/clients/{id}", s.authenticated(s.getClient))
+	s.mux.Handle("PUT /api/v1/clients/{id}", s.authenticated(s.updateClient))
+	s.mux.Handle("DELETE /api/v1/clients/{id}", s.authenticated(s.deleteClient))
+	s.mux.Handle("GET /api/v1/clients/{id}/jwks", s.authenticated(s.getJwks))
+	s.mux.Handle("POST /api/v1/clients/{id}/jwks", s.authenticated(s.registerJwks))
+
+	s.mux.Handle("GET /api/v1/scopes", s.authenticated(s.listScopes))
+	s.mux.Handle("POST /api/v1/scopes", s.authenticated(s.createScope))
+	s.mux.Handle("PUT /api/v1/scopes", s.authenticated(s.updateScope))
+	s.mux.Handle("DELETE /api/v1/scopes", s.authenticated(s.deleteScope))
+	s.mux.Handle("GET /api/v1/scopes/all", s.authenticated(s.listOpenScopes))
+	s.mux.Handle("GET /api/v1/scopes/access", s.authenticated(s.getScopeACL))
+	s.mux.Handle("GET /api/v1/scopes/access/all", s.authenticated(s.listAccessibleScopes))
+	s.mux.Handle("PUT /api/v1/scopes/access/{orgno}", s.authenticated(s.addToScopeACL))
+	s.mux.Handle("DELETE /api/v1/scopes/access/{orgno}", s.authenticated(s.removeFromScopeACL))
+}
+
+func (s *Server) metadata(w http.ResponseWriter, r *http.Request) {
+	issuer := issuerFor(r)
+	respond(w, http.StatusOK, map[string]string{
+		"issuer":         issuer,
+		"jwks_uri":       issuer + "/jwks",
+		"token_endpoint": issuer + "/token",
+	})
+}
+
+func (s *Server) providerJwks(w http.ResponseWriter, _ *http.Request) {
+	respond(w, http.StatusOK, map[string]any{"keys": []any{}})
+}
+
+func (s *Server) delegationSources(w http.ResponseWriter, _ *http.Request) {
+	respond(w, http.StatusOK, s.opts.DelegationSources)
+}
+
+// authenticated rejects requests without a valid access token issued by the token endpoint.
+// The organization that the token was issued to is attached to the request context, see orgnoFrom.
+func (s *Server) authenticated(next http.HandlerFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, found := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		orgno, valid := s.validToken(token)
+		if !found || !valid {
+			respondError(w, http.StatusUnauthorized, "invalid_token", "missing, invalid or expired access token")
+			return
+		}
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		next(w, r.WithContext(context.WithValue(r.Context(), orgnoKey{}, orgno)))
+	})
+}
+
+type orgnoKey struct{}
+
+// orgnoFrom returns the organization number of the authenticated admin client.
+func orgnoFrom(r *http.Request) string {
+	return r.Context().Value(orgnoKey{}).(string)
+}
+
+func issuerFor(r *http.Request) string {
+	return "http://" + r.Host
+}
+
+func respond(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json;charset=UTF-8")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+func respondError(w http.ResponseWriter, status int, code, format string, args ...any) {
+	respond(w, status, map[string]string{
+		"error":             code,
+		"error_description": fmt.Sprintf(format, args...),
+	})
+}
+
+func decode(r *http.Request, target any) error {
+	return json.NewDecoder(r.Body).Decode(target)
+}
+
+func randomID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,384 @@
+package fake_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/go-jose/go-jose/v4"
+	"github.com/go-jose/go-jose/v4/jwt"
+	naisiov1 "github.com/nais/liberator/pkg/apis/nais.io/v1"
+	"github.com/nais/liberator/pkg/oauth"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/nais/digdirator/pkg/config"
+	"github.com/nais/digdirator/pkg/crypto"
+	"github.com/nais/digdirator/pkg/digdir"
+	"github.com/nais/digdirator/pkg/digdir/fake"
+	"github.com/nais/digdirator/pkg/digdir/types"
+)
+
+func TestClients(t *testing.T) {
+	srv, client, _ := setup(t, fake.Options{})
+	srv.AddScope(types.ScopeRegistration{
+		Prefix:           "other",
+		Subscope:         "open",
+		OwnerOrgno:       "111111111",
+		AccessibleForAll: true,
+		Active:           true,
+	})
+
+	idporten, err := client.Register(t.Context(), types.ClientRegistration{
+		ClientName:      "idporten",
+		IntegrationType: types.IntegrationTypeIDPorten,
+		RedirectURIs:    []string{"https://example.com/callback"},
+	})
+	require.NoError(t, err)
+	assert.NotEmpty(t, idporten.ClientID)
+	assert.Equal(t, fake.DefaultOrgno, idporten.ClientOrgno)
+
+	maskinporten, err := client.Register(t.Context(), types.ClientRegistration{
+		ClientName:      "maskinporten",
+		IntegrationType: types.IntegrationTypeMaskinporten,
+		Scopes:          []string{"other:open"},
+	})
+	require.NoError(t, err)
+	assert.NotEqual(t, idporten.ClientID, maskinporten.ClientID)
+
+	updated, err := client.Update(t.Context(), types.ClientRegistration{
+		ClientName:      "idporten-updated",
+		IntegrationType: types.IntegrationTypeIDPorten,
+		RedirectURIs:    []string{"https://example.com/callback"},
+	}, idporten.ClientID)
+	require.NoError(t, err)
+	assert.Equal(t, idporten.ClientID, updated.ClientID)
+	assert.Equal(t, "idporten-updated", updated.ClientName)
+
+	registered := srv.Clients()
+	require.Len(t, registered, 2)
+	assert.Equal(t, "idporten-updated", registered[0].ClientName)
+	assert.Equal(t, "maskinporten", registered[1].ClientName)
+
+	require.NoError(t, client.Delete(t.Context(), idporten.ClientID))
+	registered = srv.Clients()
+	require.Len(t, registered, 1)
+	assert.Equal(t, maskinporten.ClientID, registered[0].ClientID)
+}
+
+func TestClients_Validation(t *testing.T) {
+	srv, client, token := setup(t, fake.Options{})
+
+	existing, err := client.Register(t.Context(), types.ClientRegistration{
+		ClientName:      "existing",
+		IntegrationType: types.IntegrationTypeMaskinporten,
+	})
+	require.NoError(t, err)
+
+	for _, test := range []struct {
+		name   string
+		method string
+		path   string
+		body   types.ClientRegistration
+		want   int
+	}{
+		{
+			name:   "missing client name",
+			method: http.MethodPost,
+			path:   "/api/v1/clients",
+			body:   types.ClientRegistration{IntegrationType: types.IntegrationTypeMaskinporten},
+			want:   http.StatusBadRequest,
+		},
+		{
+			name:   "unknown integration type",
+			method: http.MethodPost,
+			path:   "/api/v1/clients",
+			body:   types.ClientRegistration{ClientName: "test", IntegrationType: "unknown"},
+			want:   http.StatusBadRequest,
+		},
+		{
+			name:   "ID-porten client without redirect URIs",
+			method: http.MethodPost,
+			path:   "/api/v1/clients",
+			body:   types.ClientRegistration{ClientName: "test", IntegrationType: types.IntegrationTypeIDPorten},
+			want:   http.StatusBadRequest,
+		},
+		{
+			name:   "Maskinporten client with inaccessible scope",
+			method: http.MethodPost,
+			path:   "/api/v1/clients",
+			body:   types.ClientRegistration{ClientName: "test", IntegrationType: types.IntegrationTypeMaskinporten, Scopes: []string{"other:closed"}},
+			want:   http.StatusBadRequest,
+		},
+		{
+			name:   "changing integration type",
+			method: http.MethodPut,
+			path:   "/api/v1/clients/" + existing.ClientID,
+			body:   types.ClientRegistration{ClientName: "existing", IntegrationType: types.IntegrationTypeKrr},
+			want:   http.StatusBadRequest,
+		},
+		{
+			name:   "updating unknown client",
+			method: http.MethodPut,
+			path:   "/api/v1/clients/unknown",
+			body:   types.ClientRegistration{ClientName: "test", IntegrationType: types.IntegrationTypeMaskinporten},
+			want:   http.StatusNotFound,
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			status := do(t, srv, token, test.method, test.path, test.body)
+			assert.Equal(t, test.want, status)
+		})
+	}
+}
+
+func TestJwks(t *testing.T) {
+	now := time.Now().Truncate(time.Second)
+	srv, client, token := setup(t, fake.Options{
+		MaxJwksSize: 2,
+		KeyLifetime: 24 * time.Hour,
+		Now:         func() time.Time { return now },
+	})
+
+	registration, err := client.Register(t.Context(), types.ClientRegistration{
+		ClientName:      "test",
+		IntegrationType: types.IntegrationTypeMaskinporten,
+	})
+	require.NoError(t, err)
+
+	keys, err := client.GetKeys(t.Context(), registration.ClientID)
+	require.NoError(t, err)
+	assert.Empty(t, keys.Keys)
+
+	rsaKey, err := crypto.GenerateJwk(crypto.KeyTypeRSA2048)
+	require.NoError(t, err)
+	ecKey, err := crypto.GenerateJwk(crypto.KeyTypeECP256)
+	require.NoError(t, err)
+
+	response, err := client.RegisterKeys(t.Context(), registration.ClientID, &jose.JSONWebKeySet{
+		Keys: []jose.JSONWebKey{rsaKey.Public(), ecKey.Public()},
+	})
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{rsaKey.KeyID, ecKey.KeyID}, response.KeyIDs())
+	for _, key := range response.Keys {
+		assert.Equal(t, now.Add(24*time.Hour), key.ExpiryTime())
+	}
+
+	err = srv.SetKeyExpiry(registration.ClientID, ecKey.KeyID, now.Add(time.Hour))
+	require.NoError(t, err)
+
+	keys, err = client.GetKeys(t.Context(), registration.ClientID)
+	require.NoError(t, err)
+	require.Len(t, keys.Keys, 2)
+	assert.Equal(t, now.Add(time.Hour), keys.Keys[1].ExpiryTime())
+
+	t.Run("JWKS exceeding max size is rejected", func(t *testing.T) {
+		thirdKey, err := crypto.GenerateJwk(crypto.DefaultKeyType)
+		require.NoError(t, err)
+
+		status := do(t, srv, token, http.MethodPost, "/api/v1/clients/"+registration.ClientID+"/jwks", jose.JSONWebKeySet{
+			Keys: []jose.JSONWebKey{rsaKey.Public(), ecKey.Public(), thirdKey.Public()},
+		})
+		assert.Equal(t, http.StatusBadRequest, status)
+		assert.Len(t, srv.Keys(registration.ClientID), 2, "existing keys should be kept")
+	})
+
+	t.Run("private keys are rejected", func(t *testing.T) {
+		status := do(t, srv, token, http.MethodPost, "/api/v1/clients/"+registration.ClientID+"/jwks", jose.JSONWebKeySet{
+			Keys: []jose.JSONWebKey{*rsaKey},
+		})
+		assert.Equal(t, http.StatusBadRequest, status)
+	})
+
+	t.Run("keys of clients owned by other organizations are not found", func(t *testing.T) {
+		srv.SetOrgno("admin", "111111111")
+		srv.RevokeTokens()
+
+		_, err := client.GetKeys(t.Context(), registration.ClientID)
+		assert.ErrorContains(t, err, "404")
+
+		_, err = client.RegisterKeys(t.Context(), registration.ClientID, &jose.JSONWebKeySet{Keys: []jose.JSONWebKey{rsaKey.Public()}})
+		assert.ErrorContains(t, err, "404")
+		assert.Len(t, srv.Keys(registration.ClientID), 2, "existing keys should be kept")
+	})
+}
+
+func TestScopes(t *testing.T) {
+	srv, client, token := setup(t, fake.Options{})
+
+	registration, err := client.RegisterScope(t.Context(), types.ScopeRegistration{
+		Prefix:      "nav",
+		Subscope:    "arbeid/test/scope",
+		Description: "test",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "nav:arbeid/test/scope", registration.Name)
+	assert.True(t, registration.Active)
+
+	_, err = client.AddToScopeACL(t.Context(), registration.Name, "101010101")
+	require.NoError(t, err)
+	_, err = client.AddToScopeACL(t.Context(), registration.Name, "111111111")
+	require.NoError(t, err)
+
+	removed, err := client.DeactivateConsumer(t.Context(), registration.Name, "101010101")
+	require.NoError(t, err)
+	assert.Equal(t, types.ScopeStateDenied, removed.State)
+
+	acl, err := client.GetScopeACL(t.Context(), registration.Name)
+	require.NoError(t, err)
+	require.Len(t, *acl, 2)
+	assert.Equal(t, types.ScopeStateDenied, (*acl)[0].State)
+	assert.Equal(t, types.ScopeStateApproved, (*acl)[1].State)
+
+	stored := srv.ScopeACL(registration.Name)
+	require.Len(t, stored, 2)
+	assert.Equal(t, "101010101", stored[0].ConsumerOrgno)
+	assert.Equal(t, "111111111", stored[1].ConsumerOrgno)
+
+	deactivated, err := client.DeleteScope(t.Context(), registration.Name)
+	require.NoError(t, err)
+	assert.False(t, deactivated.Active)
+
+	scopes, err := client.GetScopes(t.Context())
+	require.NoError(t, err)
+	require.Len(t, scopes, 1, "inactive scopes should be listed")
+	assert.False(t, scopes[0].Active)
+	assert.Equal(t, scopes, srv.Scopes())
+
+	t.Run("invalid consumer is rejected", func(t *testing.T) {
+		status := do(t, srv, token, http.MethodPut, "/api/v1/scopes/access/invalid?scope="+url.QueryEscape(registration.Name), nil)
+		assert.Equal(t, http.StatusBadRequest, status)
+	})
+
+	t.Run("duplicate scope is rejected", func(t *testing.T) {
+		status := do(t, srv, token, http.MethodPost, "/api/v1/scopes", types.ScopeRegistration{
+			Prefix:      "nav",
+			Subscope:    "arbeid/test/scope",
+			Description: "test",
+		})
+		assert.Equal(t, http.StatusConflict, status)
+	})
+
+	t.Run("scopes owned by other organizations cannot be modified", func(t *testing.T) {
+		srv.AddScope(types.ScopeRegistration{Prefix: "other", Subscope: "scope", OwnerOrgno: "111111111", Active: true})
+
+		status := do(t, srv, token, http.MethodGet, "/api/v1/scopes/access?scope=other:scope", nil)
+		assert.Equal(t, http.StatusForbidden, status)
+	})
+}
+
+func TestCanAccessScope(t *testing.T) {
+	srv, client, _ := setup(t, fake.Options{})
+
+	srv.AddScope(types.ScopeRegistration{Prefix: "fake-approved", Subscope: "scope", OwnerOrgno: "111111111", Active: true})
+	srv.AddScope(types.ScopeRegistration{Prefix: "fake-denied", Subscope: "scope", OwnerOrgno: "111111111", Active: true})
+	srv.AddScope(types.ScopeRegistration{Prefix: "fake-open", Subscope: "scope", OwnerOrgno: "111111111", Active: true, AccessibleForAll: true})
+	require.NoError(t, srv.SetScopeAccess("fake-approved:scope", fake.DefaultOrgno, types.ScopeStateApproved))
+	require.NoError(t, srv.SetScopeAccess("fake-denied:scope", fake.DefaultOrgno, types.ScopeStateDenied))
+
+	for scope, want := range map[string]bool{
+		"fake-approved:scope": true,
+		"fake-denied:scope":   false,
+		"fake-open:scope":     true,
+		"fake-unknown:scope":  false,
+	} {
+		canAccess, err := client.CanAccessScope(t.Context(), naisiov1.ConsumedScope{Name: scope})
+		require.NoError(t, err)
+		assert.Equal(t, want, canAccess, scope)
+	}
+}
+
+func TestToken(t *testing.T) {
+	srv, client, token := setup(t, fake.Options{})
+
+	t.Run("requests without access token are rejected", func(t *testing.T) {
+		status := do(t, srv, "", http.MethodGet, "/api/v1/clients", nil)
+		assert.Equal(t, http.StatusUnauthorized, status)
+	})
+
+	t.Run("requests with issued access token are accepted", func(t *testing.T) {
+		status := do(t, srv, token, http.MethodGet, "/api/v1/clients", nil)
+		assert.Equal(t, http.StatusOK, status)
+	})
+
+	t.Run("client recovers after tokens are revoked", func(t *testing.T) {
+		srv.RevokeTokens()
+
+		_, err := client.GetScopes(t.Context())
+		require.NoError(t, err)
+		assert.Equal(t, 1, srv.IssuedTokens())
+	})
+}
+
+type testServer struct {
+	*fake.Server
+	url string
+}
+
+func setup(t *testing.T, opts fake.Options) (*testServer, digdir.Client, string) {
+	srv := fake.New(opts)
+	httpServer := httptest.NewServer(srv)
+	t.Cleanup(httpServer.Close)
+
+	metadata, err := oauth.NewMetadataOAuth(t.Context(), httpServer.URL+"/.well-known/oauth-authorization-server")
+	require.NoError(t, err)
+
+	cfg := &config.Config{}
+	cfg.DigDir.Admin.BaseURL = httpServer.URL
+	cfg.DigDir.Admin.ClientID = "admin"
+	cfg.DigDir.Admin.Scopes = "idporten:dcr.write idporten:dcr.read idporten:scopes.write"
+	cfg.DigDir.Maskinporten.Metadata = *metadata
+
+	privateKey, err := crypto.GenerateRSAKey()
+	require.NoError(t, err)
+	signer, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.RS256, Key: privateKey}, nil)
+	require.NoError(t, err)
+
+	client, err := digdir.NewClient(cfg, httpServer.Client(), signer)
+	require.NoError(t, err)
+
+	assertion, err := crypto.GenerateJwt(signer, jwt.Claims{
+		Issuer:   "admin",
+		Audience: []string{metadata.Issuer},
+		Expiry:   jwt.NewNumericDate(time.Now().Add(time.Minute)),
+	})
+	require.NoError(t, err)
+
+	resp, err := http.PostForm(metadata.TokenEndpoint, url.Values{
+		"grant_type": []string{"urn:ietf:params:oauth:grant-type:jwt-bearer"},
+		"assertion":  []string{assertion},
+	})
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	token := digdir.TokenResponse{}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&token))
+
+	return &testServer{Server: srv, url: httpServer.URL}, client, token.AccessToken
+}
+
+func do(t *testing.T, srv *testServer, token, method, path string, body any) int {
+	var payload []byte
+	if body != nil {
+		var err error
+		payload, err = json.Marshal(body)
+		require.NoError(t, err)
+	}
+
+	req, err := http.NewRequestWithContext(t.Context(), method, srv.url+path, bytes.NewReader(payload))
+	require.NoError(t, err)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	return resp.StatusCode
+}
@@ -0,0 +1,113 @@
+package fake
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/go-jose/go-jose/v4"
+	"github.com/go-jose/go-jose/v4/jwt"
+)
+
+const grantTypeJwtBearer = "urn:ietf:params:oauth:grant-type:jwt-bearer"
+
+var assertionAlgorithms = []jose.SignatureAlgorithm{
+	jose.RS256, jose.RS384, jose.RS512,
+	jose.PS256, jose.PS384, jose.PS512,
+	jose.ES256, jose.ES384, jose.ES512,
+}
+
+// token issues access tokens for JWT-bearer grants.
+// The claims of the assertion are validated, but its signature is not verified.
+func (s *Server) token(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		respondError(w, http.StatusBadRequest, "invalid_request", "parsing form: %v", err)
+		return
+	}
+
+	if grantType := r.PostForm.Get("grant_type"); grantType != grantTypeJwtBearer {
+		respondError(w, http.StatusBadRequest, "unsupported_grant_type", "unsupported grant type %q", grantType)
+		return
+	}
+
+	assertion, err := jwt.ParseSigned(r.PostForm.Get("assertion"), assertionAlgorithms)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "invalid_grant", "parsing assertion: %v", err)
+		return
+	}
+
+	claims := jwt.Claims{}
+	if err := assertion.UnsafeClaimsWithoutVerification(&claims); err != nil {
+		respondError(w, http.StatusBadRequest, "invalid_grant", "parsing assertion claims: %v", err)
+		return
+	}
+
+	if claims.Issuer == "" {
+		respondError(w, http.StatusBadRequest, "invalid_grant", "assertion is missing issuer")
+		return
+	}
+
+	// the clock of the server may be set in the past of the client's, see Options.Now
+	err = claims.ValidateWithLeeway(jwt.Expected{
+		AnyAudience: jwt.Audience{issuerFor(r)},
+		Time:        s.opts.Now(),
+	}, jwt.DefaultLeeway)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "invalid_grant", "validating assertion: %v", err)
+		return
+	}
+
+	token := randomID()
+
+	s.mu.Lock()
+	orgno := s.opts.Orgno
+	if other, ok := s.orgnos[claims.Issuer]; ok {
+		orgno = other
+	}
+	s.tokens[token] = issuedToken{expiry: s.opts.Now().Add(s.opts.TokenLifetime), orgno: orgno}
+	s.mu.Unlock()
+
+	respond(w, http.StatusOK, map[string]any{
+		"access_token": token,
+		"token_type":   "Bearer",
+		"expires_in":   int(s.opts.TokenLifetime.Seconds()),
+	})
+}
+
+type issuedToken struct {
+	expiry time.Time
+	orgno  string
+}
+
+// validToken returns the organization that the token was issued to, if the token is valid.
+func (s *Server) validToken(token string) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	issued, found := s.tokens[token]
+	return issued.orgno, found && s.opts.Now().Before(issued.expiry)
+}
+
+// SetOrgno makes the admin client with the given client ID authenticate as another organization than Options.Orgno.
+// Each organization only sees its own clients and scopes.
+func (s *Server) SetOrgno(adminClientID, orgno string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.orgnos[adminClientID] = orgno
+}
+
+// RevokeTokens invalidates all issued access tokens.
+func (s *Server) RevokeTokens() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	clear(s.tokens)
+}
+
+// IssuedTokens returns the number of access tokens issued that have not been revoked.
+func (s *Server) IssuedTokens() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return len(s.tokens)
+}
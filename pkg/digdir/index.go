@@ -0,0 +1,166 @@
+package digdir
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+
+	"github.com/nais/digdirator/pkg/config"
+	"github.com/nais/digdirator/pkg/digdir/types"
+)
+
+// registrationIndex is an in-memory index of client registrations by client ID and description, shared by all reconcilers.
+// It is refreshed by listing all clients once the TTL has passed, and kept up to date with the changes made through the
+// client in between. It is only used to discover client IDs, as the registrations may lag behind changes made outside
+// digdirator, e.g. in the DigDir portal, for up to one TTL.
+// A zero TTL disables the index.
+type registrationIndex struct {
+	ttl   time.Duration
+	now   func() time.Time
+	group singleflight.Group
+
+	mu            sync.RWMutex
+	refreshedAt   time.Time
+	byClientID    map[string]types.ClientRegistration
+	byDescription map[string][]string
+	// pending holds changes made while a refresh is in flight, as the listed clients may not include them.
+	// A nil registration means that the client was deleted.
+	pending map[string]*types.ClientRegistration
+}
+
+func newRegistrationIndex(cfg config.ClientIndex) *registrationIndex {
+	return &registrationIndex{
+		ttl: cfg.TTL,
+		now: time.Now,
+	}
+}
+
+func (i *registrationIndex) enabled() bool {
+	return i != nil && i.ttl > 0
+}
+
+func (i *registrationIndex) expired() bool {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	return i.byClientID == nil || i.now().Sub(i.refreshedAt) >= i.ttl
+}
+
+// refresh replaces the index with the registrations returned by list if the index has expired.
+// Concurrent callers share a single refresh.
+func (i *registrationIndex) refresh(ctx context.Context, list func(context.Context) ([]types.ClientRegistration, error)) error {
+	_, err, _ := i.group.Do("refresh", func() (any, error) {
+		if !i.expired() {
+			return nil, nil
+		}
+
+		i.mu.Lock()
+		startedAt := i.now()
+		i.pending = make(map[string]*types.ClientRegistration)
+		i.mu.Unlock()
+
+		registrations, err := list(ctx)
+
+		i.mu.Lock()
+		defer i.mu.Unlock()
+
+		pending := i.pending
+		i.pending = nil
+		if err != nil {
+			return nil, err
+		}
+
+		i.byClientID = make(map[string]types.ClientRegistration, len(registrations))
+		i.byDescription = make(map[string][]string)
+		for _, registration := range registrations {
+			i.set(registration)
+		}
+		for clientID, registration := range pending {
+			i.delete(clientID)
+			if registration != nil {
+				i.set(*registration)
+			}
+		}
+		i.refreshedAt = startedAt
+		return nil, nil
+	})
+	return err
+}
+
+// find returns the first registration with the given description and integration type, in the order that DigDir listed them.
+func (i *registrationIndex) find(description string, integrationType types.IntegrationType) (*types.ClientRegistration, bool) {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	for _, clientID := range i.byDescription[description] {
+		if registration := i.byClientID[clientID]; registration.IntegrationType == integrationType {
+			return &registration, true
+		}
+	}
+	return nil, false
+}
+
+// put adds or replaces a registration after it was changed through the client.
+func (i *registrationIndex) put(registration types.ClientRegistration) {
+	if !i.enabled() || registration.ClientID == "" {
+		return
+	}
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	if i.pending != nil {
+		i.pending[registration.ClientID] = &registration
+	}
+	if i.byClientID != nil {
+		i.delete(registration.ClientID)
+		i.set(registration)
+	}
+}
+
+// remove removes a registration after it was deleted through the client.
+func (i *registrationIndex) remove(clientID string) {
+	if !i.enabled() {
+		return
+	}
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	if i.pending != nil {
+		i.pending[clientID] = nil
+	}
+	if i.byClientID != nil {
+		i.delete(clientID)
+	}
+}
+
+// set adds the registration to the index. The caller must hold the write lock.
+func (i *registrationIndex) set(registration types.ClientRegistration) {
+	i.byClientID[registration.ClientID] = registration
+	i.byDescription[registration.Description] = append(i.byDescription[registration.Description], registration.ClientID)
+}
+
+// delete removes the registration from the index. The caller must hold the write lock.
+func (i *registrationIndex) delete(clientID string) {
+	registration, ok := i.byClientID[clientID]
+	if !ok {
+		return
+	}
+
+	delete(i.byClientID, clientID)
+	clientIDs := i.byDescription[registration.Description]
+	for n, id := range clientIDs {
+		if id == clientID {
+			clientIDs = append(clientIDs[:n:n], clientIDs[n+1:]...)
+			break
+		}
+	}
+	if len(clientIDs) == 0 {
+		delete(i.byDescription, registration.Description)
+	} else {
+		i.byDescription[registration.Description] = clientIDs
+	}
+}
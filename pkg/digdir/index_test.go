@@ -0,0 +1,136 @@
+package digdir
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/nais/digdirator/pkg/config"
+	"github.com/nais/digdirator/pkg/digdir/types"
+)
+
+func TestRegistrationIndex(t *testing.T) {
+	registration := func(clientID, description string, integrationType types.IntegrationType) types.ClientRegistration {
+		return types.ClientRegistration{ClientID: clientID, Description: description, IntegrationType: integrationType}
+	}
+
+	newTestIndex := func(registrations ...types.ClientRegistration) (*registrationIndex, *time.Time, *int) {
+		now := time.Now()
+		lists := 0
+		i := newRegistrationIndex(config.ClientIndex{TTL: time.Minute})
+		i.now = func() time.Time { return now }
+		require.NoError(t, i.refresh(t.Context(), func(context.Context) ([]types.ClientRegistration, error) {
+			lists++
+			return registrations, nil
+		}))
+		return i, &now, &lists
+	}
+
+	t.Run("finds registrations by client ID and description", func(t *testing.T) {
+		i, _, _ := newTestIndex(
+			registration("1", "cluster:ns:app", types.IntegrationTypeIDPorten),
+			registration("2", "cluster:ns:app", types.IntegrationTypeMaskinporten),
+			registration("3", "cluster:ns:app", types.IntegrationTypeMaskinporten),
+		)
+
+		indexed, ok := i.byClientID["2"]
+		require.True(t, ok)
+		assert.Equal(t, types.IntegrationTypeMaskinporten, indexed.IntegrationType)
+
+		found, ok := i.find("cluster:ns:app", types.IntegrationTypeMaskinporten)
+		require.True(t, ok)
+		assert.Equal(t, "2", found.ClientID)
+
+		_, ok = i.byClientID["4"]
+		assert.False(t, ok)
+		_, ok = i.find("cluster:ns:other", types.IntegrationTypeMaskinporten)
+		assert.False(t, ok)
+	})
+
+	t.Run("is refreshed once the TTL has passed", func(t *testing.T) {
+		i, now, lists := newTestIndex()
+		list := func(context.Context) ([]types.ClientRegistration, error) {
+			*lists++
+			return nil, nil
+		}
+
+		*now = now.Add(time.Minute - time.Second)
+		assert.False(t, i.expired())
+		require.NoError(t, i.refresh(t.Context(), list))
+		assert.Equal(t, 1, *lists)
+
+		*now = now.Add(time.Second)
+		assert.True(t, i.expired())
+		require.NoError(t, i.refresh(t.Context(), list))
+		assert.Equal(t, 2, *lists)
+		assert.False(t, i.expired())
+	})
+
+	t.Run("failed refresh keeps the index expired", func(t *testing.T) {
+		i, now, _ := newTestIndex(registration("1", "cluster:ns:app", types.IntegrationTypeIDPorten))
+		*now = now.Add(time.Minute)
+
+		errList := errors.New("unavailable")
+		err := i.refresh(t.Context(), func(context.Context) ([]types.ClientRegistration, error) {
+			return nil, errList
+		})
+		assert.ErrorIs(t, err, errList)
+		assert.True(t, i.expired())
+
+		_, ok := i.byClientID["1"]
+		assert.True(t, ok)
+	})
+
+	t.Run("tracks changes between refreshes", func(t *testing.T) {
+		i, _, _ := newTestIndex(
+			registration("1", "cluster:ns:app", types.IntegrationTypeIDPorten),
+			registration("2", "cluster:ns:other", types.IntegrationTypeIDPorten),
+		)
+
+		i.put(registration("1", "cluster:ns:renamed", types.IntegrationTypeIDPorten))
+		i.put(registration("3", "cluster:ns:app", types.IntegrationTypeIDPorten))
+		i.remove("2")
+
+		found, ok := i.find("cluster:ns:app", types.IntegrationTypeIDPorten)
+		require.True(t, ok)
+		assert.Equal(t, "3", found.ClientID)
+
+		found, ok = i.find("cluster:ns:renamed", types.IntegrationTypeIDPorten)
+		require.True(t, ok)
+		assert.Equal(t, "1", found.ClientID)
+
+		_, ok = i.byClientID["2"]
+		assert.False(t, ok)
+		assert.NotContains(t, i.byDescription, "cluster:ns:other")
+	})
+
+	t.Run("keeps changes made during a refresh", func(t *testing.T) {
+		i, now, _ := newTestIndex()
+		*now = now.Add(time.Minute)
+
+		// the listed clients were read before the changes below were made
+		require.NoError(t, i.refresh(t.Context(), func(context.Context) ([]types.ClientRegistration, error) {
+			listed := []types.ClientRegistration{registration("1", "cluster:ns:app", types.IntegrationTypeIDPorten)}
+			i.put(registration("2", "cluster:ns:new", types.IntegrationTypeIDPorten))
+			i.remove("1")
+			return listed, nil
+		}))
+
+		_, ok := i.byClientID["1"]
+		assert.False(t, ok)
+		_, ok = i.find("cluster:ns:new", types.IntegrationTypeIDPorten)
+		assert.True(t, ok)
+	})
+
+	t.Run("disabled with zero TTL", func(t *testing.T) {
+		i := newRegistrationIndex(config.ClientIndex{})
+		assert.False(t, i.enabled())
+
+		i.put(registration("1", "cluster:ns:app", types.IntegrationTypeIDPorten))
+		assert.Nil(t, i.byClientID)
+	})
+}
@@ -0,0 +1,59 @@
+package digdir
+
+import (
+	"context"
+	"time"
+
+	"golang.org/x/time/rate"
+
+	"github.com/nais/digdirator/pkg/config"
+	"github.com/nais/digdirator/pkg/metrics"
+)
+
+// limiter throttles requests to the DigDir self-service API with a token bucket, and caps the number of requests in flight.
+// A zero rate or in-flight limit disables the respective check.
+type limiter struct {
+	tokens   *rate.Limiter
+	inFlight chan struct{}
+}
+
+func newLimiter(cfg config.RateLimit) *limiter {
+	l := &limiter{
+		tokens: rate.NewLimiter(rate.Inf, 0),
+	}
+	if cfg.RequestsPerSecond > 0 {
+		l.tokens = rate.NewLimiter(rate.Limit(cfg.RequestsPerSecond), max(cfg.Burst, 1))
+	}
+	if cfg.MaxInFlight > 0 {
+		l.inFlight = make(chan struct{}, cfg.MaxInFlight)
+	}
+	return l
+}
+
+// acquire blocks until a request may be sent, or the context is done.
+// The returned function must be called when the request is completed.
+func (l *limiter) acquire(ctx context.Context) (func(), error) {
+	start := time.Now()
+	if err := l.tokens.Wait(ctx); err != nil {
+		return nil, err
+	}
+	metrics.ObserveLimiterWait(metrics.LimiterRate, time.Since(start))
+
+	if l.inFlight == nil {
+		return func() {}, nil
+	}
+
+	start = time.Now()
+	select {
+	case l.inFlight <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	metrics.ObserveLimiterWait(metrics.LimiterInFlight, time.Since(start))
+	metrics.DigDirRequestsInFlight.Inc()
+
+	return func() {
+		metrics.DigDirRequestsInFlight.Dec()
+		<-l.inFlight
+	}, nil
+}
@@ -0,0 +1,79 @@
+package digdir
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/nais/digdirator/pkg/config"
+)
+
+func TestLimiter(t *testing.T) {
+	t.Run("disabled limits never block", func(t *testing.T) {
+		l := newLimiter(config.RateLimit{})
+
+		for range 100 {
+			release, err := l.acquire(t.Context())
+			require.NoError(t, err)
+			defer release()
+		}
+	})
+
+	t.Run("requests above the burst wait for the rate", func(t *testing.T) {
+		l := newLimiter(config.RateLimit{RequestsPerSecond: 20, Burst: 2})
+
+		start := time.Now()
+		for range 4 {
+			release, err := l.acquire(t.Context())
+			require.NoError(t, err)
+			release()
+		}
+		// the first two requests use the burst, the remaining two wait 50ms each
+		assert.GreaterOrEqual(t, time.Since(start), 90*time.Millisecond)
+	})
+
+	t.Run("in-flight requests are capped", func(t *testing.T) {
+		l := newLimiter(config.RateLimit{MaxInFlight: 2})
+
+		var current, peak atomic.Int32
+		var wg sync.WaitGroup
+		for range 10 {
+			wg.Go(func() {
+				release, err := l.acquire(t.Context())
+				assert.NoError(t, err)
+				defer release()
+
+				n := current.Add(1)
+				for {
+					p := peak.Load()
+					if n <= p || peak.CompareAndSwap(p, n) {
+						break
+					}
+				}
+				time.Sleep(10 * time.Millisecond)
+				current.Add(-1)
+			})
+		}
+		wg.Wait()
+
+		assert.EqualValues(t, 2, peak.Load())
+	})
+
+	t.Run("waiting is cancelled with the context", func(t *testing.T) {
+		l := newLimiter(config.RateLimit{MaxInFlight: 1})
+		release, err := l.acquire(t.Context())
+		require.NoError(t, err)
+		defer release()
+
+		ctx, cancel := context.WithTimeout(t.Context(), 10*time.Millisecond)
+		defer cancel()
+
+		_, err = l.acquire(ctx)
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+	})
+}
@@ -0,0 +1,158 @@
+package digdir
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/go-jose/go-jose/v4"
+
+	"github.com/nais/digdirator/pkg/crypto"
+	"github.com/nais/digdirator/pkg/digdir/types"
+)
+
+// Names of the operations that are recorded in a Plan.
+const (
+	OperationRegisterClient     = "RegisterClient"
+	OperationUpdateClient       = "UpdateClient"
+	OperationDeleteClient       = "DeleteClient"
+	OperationRegisterKeys       = "RegisterKeys"
+	OperationRegisterScope      = "RegisterScope"
+	OperationUpdateScope        = "UpdateScope"
+	OperationDeleteScope        = "DeleteScope"
+	OperationAddToScopeACL      = "AddToScopeACL"
+	OperationRemoveFromScopeACL = "RemoveFromScopeACL"
+)
+
+type Operation struct {
+	Name    string          `json:"name"`
+	Summary string          `json:"summary"`
+	Payload json.RawMessage `json:"payload,omitempty"`
+}
+
+// Plan records mutating operations instead of sending them to DigDir.
+// The client uses a plan if one is attached to the request context, see WithPlan.
+// Responses are simulated from the request payloads, and reads of clients and scopes that only exist in the plan return empty results.
+type Plan struct {
+	mu            sync.Mutex
+	operations    []Operation
+	plannedScopes []string
+}
+
+type planKey struct{}
+
+func NewPlan() *Plan {
+	return &Plan{
+		operations:    make([]Operation, 0),
+		plannedScopes: make([]string, 0),
+	}
+}
+
+func WithPlan(ctx context.Context, plan *Plan) context.Context {
+	return context.WithValue(ctx, planKey{}, plan)
+}
+
+// PlanFrom returns the plan attached to the context, if any.
+func PlanFrom(ctx context.Context) *Plan {
+	plan, _ := ctx.Value(planKey{}).(*Plan)
+	return plan
+}
+
+// Operations returns the recorded operations in the order they were planned.
+func (p *Plan) Operations() []Operation {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return slices.Clone(p.operations)
+}
+
+func (p *Plan) record(name string, payload any, format string, args ...any) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	op := Operation{
+		Name:    name,
+		Summary: fmt.Sprintf(format, args...),
+	}
+	if payload != nil {
+		if raw, err := json.Marshal(payload); err == nil {
+			op.Payload = raw
+		}
+	}
+	p.operations = append(p.operations, op)
+}
+
+func (p *Plan) registerClient(payload types.ClientRegistration) *types.ClientRegistration {
+	p.record(OperationRegisterClient, payload, "register %s client %q", payload.IntegrationType, payload.Description)
+	return &payload
+}
+
+func (p *Plan) updateClient(payload types.ClientRegistration, clientID string) *types.ClientRegistration {
+	p.record(OperationUpdateClient, payload, "update client %q", clientID)
+	payload.ClientID = clientID
+	return &payload
+}
+
+func (p *Plan) deleteClient(clientID string) {
+	p.record(OperationDeleteClient, nil, "delete client %q", clientID)
+}
+
+func (p *Plan) registerKeys(clientID string, payload *jose.JSONWebKeySet) *types.JwksResponse {
+	response := &types.JwksResponse{}
+	for _, key := range payload.Keys {
+		response.Keys = append(response.Keys, crypto.DigdirJwk{KeyID: key.KeyID})
+	}
+
+	p.record(OperationRegisterKeys, payload, "register JWKS with key IDs [%s] for client %q", strings.Join(response.KeyIDs(), ", "), clientID)
+	return response
+}
+
+func (p *Plan) registerScope(payload types.ScopeRegistration) *types.ScopeRegistration {
+	payload.Name = fmt.Sprintf("%s:%s", payload.Prefix, payload.Subscope)
+	payload.Active = true
+
+	p.record(OperationRegisterScope, payload, "register scope %q", payload.Name)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.plannedScopes = append(p.plannedScopes, payload.Name)
+
+	return &payload
+}
+
+func (p *Plan) updateScope(payload types.ScopeRegistration, scope string) *types.ScopeRegistration {
+	p.record(OperationUpdateScope, payload, "update scope %q", scope)
+	payload.Name = scope
+	payload.Active = true
+	return &payload
+}
+
+func (p *Plan) deleteScope(scope string) *types.ScopeRegistration {
+	p.record(OperationDeleteScope, nil, "deactivate scope %q", scope)
+	return &types.ScopeRegistration{Name: scope, Active: false}
+}
+
+func (p *Plan) addToScopeACL(scope, consumerOrgno string) *types.ConsumerRegistration {
+	p.record(OperationAddToScopeACL, nil, "grant consumer %q access to scope %q", consumerOrgno, scope)
+	return &types.ConsumerRegistration{Scope: scope, ConsumerOrgno: consumerOrgno, State: types.ScopeStateApproved}
+}
+
+func (p *Plan) removeFromScopeACL(scope, consumerOrgno string) *types.ConsumerRegistration {
+	p.record(OperationRemoveFromScopeACL, nil, "revoke consumer %q access to scope %q", consumerOrgno, scope)
+	return &types.ConsumerRegistration{Scope: scope, ConsumerOrgno: consumerOrgno, State: types.ScopeStateDenied}
+}
+
+// isPlannedClient returns true if the client ID refers to a client that is only registered in the plan.
+func (p *Plan) isPlannedClient(clientID string) bool {
+	return clientID == ""
+}
+
+func (p *Plan) isPlannedScope(scope string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return slices.Contains(p.plannedScopes, scope)
+}
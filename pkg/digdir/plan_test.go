@@ -0,0 +1,115 @@
+package digdir_test
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-jose/go-jose/v4"
+	"github.com/nais/liberator/pkg/oauth"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/nais/digdirator/pkg/config"
+	"github.com/nais/digdirator/pkg/crypto"
+	"github.com/nais/digdirator/pkg/digdir"
+	"github.com/nais/digdirator/pkg/digdir/fake"
+	"github.com/nais/digdirator/pkg/digdir/types"
+)
+
+func TestPlan(t *testing.T) {
+	srv, client := setupFake(t)
+
+	existing, err := client.Register(t.Context(), types.ClientRegistration{
+		ClientName:      "existing",
+		Description:     "test-cluster:test-namespace:existing",
+		IntegrationType: types.IntegrationTypeMaskinporten,
+	})
+	require.NoError(t, err)
+
+	plan := digdir.NewPlan()
+	ctx := digdir.WithPlan(t.Context(), plan)
+
+	registration, err := client.Register(ctx, types.ClientRegistration{
+		ClientName:      "new",
+		Description:     "test-cluster:test-namespace:new",
+		IntegrationType: types.IntegrationTypeMaskinporten,
+	})
+	require.NoError(t, err)
+	assert.Empty(t, registration.ClientID)
+
+	keys, err := client.GetKeys(ctx, registration.ClientID)
+	require.NoError(t, err)
+	assert.Empty(t, keys.Keys)
+
+	jwk, err := crypto.GenerateJwk(crypto.KeyTypeRSA2048)
+	require.NoError(t, err)
+	registered, err := client.RegisterKeys(ctx, registration.ClientID, &jose.JSONWebKeySet{Keys: []jose.JSONWebKey{jwk.Public()}})
+	require.NoError(t, err)
+	assert.Equal(t, []string{jwk.KeyID}, registered.KeyIDs())
+
+	updated, err := client.Update(ctx, types.ClientRegistration{ClientName: "renamed"}, existing.ClientID)
+	require.NoError(t, err)
+	assert.Equal(t, existing.ClientID, updated.ClientID)
+	assert.Equal(t, "renamed", updated.ClientName)
+
+	scope, err := client.RegisterScope(ctx, types.ScopeRegistration{Prefix: "nav", Subscope: "test/api"})
+	require.NoError(t, err)
+	assert.Equal(t, "nav:test/api", scope.Name)
+	assert.True(t, scope.Active)
+
+	acl, err := client.GetScopeACL(ctx, scope.Name)
+	require.NoError(t, err)
+	assert.Empty(t, *acl)
+
+	consumer, err := client.AddToScopeACL(ctx, scope.Name, "123456789")
+	require.NoError(t, err)
+	assert.Equal(t, types.ScopeStateApproved, consumer.State)
+
+	require.NoError(t, client.Delete(ctx, existing.ClientID))
+
+	names := make([]string, 0)
+	for _, op := range plan.Operations() {
+		names = append(names, op.Name)
+	}
+	assert.Equal(t, []string{
+		digdir.OperationRegisterClient,
+		digdir.OperationRegisterKeys,
+		digdir.OperationUpdateClient,
+		digdir.OperationRegisterScope,
+		digdir.OperationAddToScopeACL,
+		digdir.OperationDeleteClient,
+	}, names)
+
+	// nothing is sent to DigDir
+	clients := srv.Clients()
+	require.Len(t, clients, 1)
+	assert.Equal(t, "existing", clients[0].ClientName)
+	assert.Empty(t, srv.Scopes())
+}
+
+func setupFake(t *testing.T, opts ...func(cfg *config.Config)) (*fake.Server, digdir.Client) {
+	srv := fake.New(fake.Options{})
+	httpServer := httptest.NewServer(srv)
+	t.Cleanup(httpServer.Close)
+
+	metadata, err := oauth.NewMetadataOAuth(t.Context(), httpServer.URL+"/.well-known/oauth-authorization-server")
+	require.NoError(t, err)
+
+	cfg := &config.Config{ClusterName: "test-cluster"}
+	cfg.DigDir.Admin.BaseURL = httpServer.URL
+	cfg.DigDir.Admin.ClientID = "admin"
+	cfg.DigDir.Maskinporten.Metadata = *metadata
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	privateKey, err := crypto.GenerateRSAKey()
+	require.NoError(t, err)
+	signer, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.RS256, Key: privateKey}, nil)
+	require.NoError(t, err)
+
+	client, err := digdir.NewClient(cfg, httpServer.Client(), signer)
+	require.NoError(t, err)
+
+	return srv, client
+}
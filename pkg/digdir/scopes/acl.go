@@ -0,0 +1,43 @@
+package scopes
+
+import (
+	"cmp"
+	"slices"
+
+	"github.com/nais/digdirator/pkg/digdir/types"
+)
+
+// ConsumerStateInvalid is the state of a consumer that DigDir refused to add to the ACL, e.g. due to an unknown organization number.
+const ConsumerStateInvalid types.State = "INVALID"
+
+// ACL is the state of each consumer in the access control list of an exposed scope.
+type ACL struct {
+	Scope     string        `json:"scope"`
+	Consumers []ACLConsumer `json:"consumers"`
+}
+
+type ACLConsumer struct {
+	Orgno string      `json:"orgno"`
+	State types.State `json:"state"`
+}
+
+// NewACL returns the ACL for the given scope as currently registered in DigDir.
+func NewACL(scope string, registrations []types.ConsumerRegistration) ACL {
+	acl := ACL{Scope: scope, Consumers: make([]ACLConsumer, 0, len(registrations))}
+	for _, registration := range registrations {
+		acl.Set(registration.ConsumerOrgno, registration.State)
+	}
+	return acl
+}
+
+// Set sets the state of the consumer, adding it to the ACL if not present.
+func (a *ACL) Set(orgno string, state types.State) {
+	i, found := slices.BinarySearchFunc(a.Consumers, orgno, func(c ACLConsumer, orgno string) int {
+		return cmp.Compare(c.Orgno, orgno)
+	})
+	if found {
+		a.Consumers[i].State = state
+		return
+	}
+	a.Consumers = slices.Insert(a.Consumers, i, ACLConsumer{Orgno: orgno, State: state})
+}
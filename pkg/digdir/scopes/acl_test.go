@@ -0,0 +1,31 @@
+package scopes_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/nais/digdirator/pkg/digdir/scopes"
+	"github.com/nais/digdirator/pkg/digdir/types"
+)
+
+func TestACL(t *testing.T) {
+	acl := scopes.NewACL("nav:test/scope", []types.ConsumerRegistration{
+		{ConsumerOrgno: "333333333", State: types.ScopeStateApproved},
+		{ConsumerOrgno: "111111111", State: types.ScopeStateDenied},
+	})
+
+	acl.Set("222222222", types.ScopeStateApproved)
+	acl.Set("111111111", types.ScopeStateApproved)
+	acl.Set("000000000", scopes.ConsumerStateInvalid)
+
+	assert.Equal(t, scopes.ACL{
+		Scope: "nav:test/scope",
+		Consumers: []scopes.ACLConsumer{
+			{Orgno: "000000000", State: scopes.ConsumerStateInvalid},
+			{Orgno: "111111111", State: types.ScopeStateApproved},
+			{Orgno: "222222222", State: types.ScopeStateApproved},
+			{Orgno: "333333333", State: types.ScopeStateApproved},
+		},
+	}, acl)
+}
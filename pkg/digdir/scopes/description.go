@@ -0,0 +1,77 @@
+package scopes
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+
+	"github.com/nais/liberator/pkg/kubernetes"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/nais/digdirator/internal/templates"
+	"github.com/nais/digdirator/pkg/digdir/types"
+)
+
+const (
+	// OwnerMarker precedes the uniform resource name of the resource that owns the scope, on the last line of the scope's long description.
+	OwnerMarker = "Managed by digdirator: "
+	// legacyDescriptionSeparator separates the product from the uniform resource name of the owning resource in the
+	// descriptions of scopes registered before the owner marker was introduced.
+	legacyDescriptionSeparator = " - "
+)
+
+// DescriptionData is the data available to the description templates.
+type DescriptionData struct {
+	// Product and Name are the parts of the subscope, as set for the exposed scope.
+	Product  string
+	Name     string
+	Subscope string
+	// Cluster, Namespace and Resource identify the resource that exposes the scope.
+	Cluster   string
+	Namespace string
+	Resource  string
+}
+
+// ParseDescriptionTemplate parses a text/template for scope descriptions, see DescriptionData for the available fields.
+func ParseDescriptionTemplate(text string) (*template.Template, error) {
+	return templates.Parse[DescriptionData]("description", text, nil)
+}
+
+// RenderDescription renders the parsed description template with the given data. A nil template renders an empty description.
+func RenderDescription(tmpl *template.Template, data DescriptionData) (string, error) {
+	if tmpl == nil {
+		return "", nil
+	}
+
+	var sb strings.Builder
+	if err := tmpl.Execute(&sb, data); err != nil {
+		return "", fmt.Errorf("rendering description template: %w", err)
+	}
+	return strings.TrimSpace(sb.String()), nil
+}
+
+// LongDescription appends the owner marker for the resource to the long description.
+// The marker is kept out of the description, which is what consumers see first when browsing scopes in DigDir.
+func LongDescription(resource metav1.Object, clusterName, longDescription string) string {
+	marker := OwnerMarker + kubernetes.UniformResourceName(resource, clusterName)
+	if longDescription == "" {
+		return marker
+	}
+	return longDescription + "\n\n" + marker
+}
+
+// Owner returns the uniform resource name of the resource that owns the scope.
+// Scopes registered before the owner marker was introduced have the resource name at the end of the description
+// instead, until they are updated.
+func Owner(registration types.ScopeRegistration) (string, bool) {
+	lines := strings.Split(registration.LongDescription, "\n")
+	if last := lines[len(lines)-1]; strings.HasPrefix(last, OwnerMarker) {
+		return strings.TrimPrefix(last, OwnerMarker), true
+	}
+
+	i := strings.LastIndex(registration.Description, legacyDescriptionSeparator)
+	if i < 0 {
+		return "", false
+	}
+	return registration.Description[i+len(legacyDescriptionSeparator):], true
+}
@@ -0,0 +1,108 @@
+package scopes_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/nais/digdirator/pkg/digdir/scopes"
+	"github.com/nais/digdirator/pkg/digdir/types"
+)
+
+func TestParseDescriptionTemplate(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		text    string
+		wantErr bool
+	}{
+		{name: "empty", text: ""},
+		{name: "plain text", text: "Some API"},
+		{name: "known fields", text: "{{ .Product }} ({{ .Subscope }}) exposed by {{ .Namespace }}/{{ .Resource }} in {{ .Cluster }}"},
+		{name: "syntax error", text: "{{ .Product", wantErr: true},
+		{name: "unknown field", text: "{{ .Team }}", wantErr: true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := scopes.ParseDescriptionTemplate(tt.text)
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestRenderDescription(t *testing.T) {
+	data := scopes.DescriptionData{
+		Product:   "arbeid",
+		Name:      "some.scope",
+		Subscope:  "arbeid:some.scope",
+		Cluster:   "test-cluster",
+		Namespace: "test-namespace",
+		Resource:  "test-app",
+	}
+
+	tmpl, err := scopes.ParseDescriptionTemplate("{{ .Product }}: {{ .Name }} ")
+	require.NoError(t, err)
+
+	description, err := scopes.RenderDescription(tmpl, data)
+	require.NoError(t, err)
+	assert.Equal(t, "arbeid: some.scope", description)
+
+	description, err = scopes.RenderDescription(nil, data)
+	require.NoError(t, err)
+	assert.Empty(t, description)
+}
+
+func TestLongDescription(t *testing.T) {
+	meta := &metav1.ObjectMeta{Name: "test-app", Namespace: "test-namespace"}
+
+	assert.Equal(t, "Managed by digdirator: test-cluster:test-namespace:test-app", scopes.LongDescription(meta, "test-cluster", ""))
+	assert.Equal(t, "Some API\n\nManaged by digdirator: test-cluster:test-namespace:test-app", scopes.LongDescription(meta, "test-cluster", "Some API"))
+}
+
+func TestOwner(t *testing.T) {
+	for _, tt := range []struct {
+		name         string
+		registration types.ScopeRegistration
+		want         string
+		wantOK       bool
+	}{
+		{
+			name:         "owner marker",
+			registration: types.ScopeRegistration{Description: "Some API", LongDescription: "Managed by digdirator: test-cluster:test-namespace:test-app"},
+			want:         "test-cluster:test-namespace:test-app",
+			wantOK:       true,
+		},
+		{
+			name:         "owner marker after long description",
+			registration: types.ScopeRegistration{Description: "Some API", LongDescription: "Lots of details\n\nManaged by digdirator: test-cluster:test-namespace:test-app"},
+			want:         "test-cluster:test-namespace:test-app",
+			wantOK:       true,
+		},
+		{
+			name:         "owner marker takes precedence over legacy description",
+			registration: types.ScopeRegistration{Description: "arbeid - test-cluster:test-namespace:old-app", LongDescription: "Managed by digdirator: test-cluster:test-namespace:test-app"},
+			want:         "test-cluster:test-namespace:test-app",
+			wantOK:       true,
+		},
+		{
+			name:         "legacy description",
+			registration: types.ScopeRegistration{Description: "arbeid - test-cluster:test-namespace:test-app"},
+			want:         "test-cluster:test-namespace:test-app",
+			wantOK:       true,
+		},
+		{
+			name:         "manually registered",
+			registration: types.ScopeRegistration{Description: "Some API", LongDescription: "Lots of details"},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			owner, ok := scopes.Owner(tt.registration)
+			assert.Equal(t, tt.wantOK, ok)
+			assert.Equal(t, tt.want, owner)
+		})
+	}
+}
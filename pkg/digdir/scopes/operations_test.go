@@ -62,15 +62,18 @@ func TestScopeFiltering(t *testing.T) {
 	// with legacy scopes used on-prem
 	// description: cluster:namespace:app.scope/api
 	// subscope: scope/api
-	scopeRegistration1 := clients.ToScopeRegistration(client, client.Spec.Scopes.ExposedScopes[0], cfg)
-	assert.Equal(t, "arbeid - test-cluster:test-namespace:test-app", scopeRegistration1.Description)
+	scopeRegistration1, err := clients.ToScopeRegistration(client, client.Spec.Scopes.ExposedScopes[0], cfg)
+	require.NoError(t, err)
+	assert.Equal(t, "arbeid", scopeRegistration1.Description)
+	assert.Equal(t, "Managed by digdirator: test-cluster:test-namespace:test-app", scopeRegistration1.LongDescription)
 	assert.Equal(t, "arbeid/test/existingscope", scopeRegistration1.Subscope)
 
 	// Second case new format
 	// description: cluster:team:app.scope
 	// subscope: team:app.scope
-	scopeRegistration2 := clients.ToScopeRegistration(client, client.Spec.Scopes.ExposedScopes[1], cfg)
-	assert.Equal(t, scopes.Description(&meta, "test-cluster", "arbeid"), scopeRegistration2.Description)
+	scopeRegistration2, err := clients.ToScopeRegistration(client, client.Spec.Scopes.ExposedScopes[1], cfg)
+	require.NoError(t, err)
+	assert.Equal(t, scopes.LongDescription(&meta, "test-cluster", ""), scopeRegistration2.LongDescription)
 	assert.Equal(t, "arbeid:test.existingscope2", scopeRegistration2.Subscope)
 
 	existingRegistrations := make([]types.ScopeRegistration, 0)
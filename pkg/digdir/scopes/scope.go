@@ -5,7 +5,6 @@ import (
 	"strings"
 
 	naisiov1 "github.com/nais/liberator/pkg/apis/nais.io/v1"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
 	"github.com/nais/digdirator/pkg/digdir/types"
 )
@@ -75,15 +74,6 @@ func (s Scope) ToString() string {
 	return fmt.Sprintf("%s:%s", s.ScopeRegistration.Prefix, s.ScopeRegistration.Subscope)
 }
 
-// Description generates the Maskinporten scope description.
-// TODO: this should ideally be configurable in the future Scope resource.
-func Description(resource metav1.Object, clusterName, product string) string {
-	team := resource.GetNamespace()
-	app := resource.GetName()
-
-	return fmt.Sprintf("%s - %s:%s:%s", product, clusterName, team, app)
-}
-
 // Subscope generates the Maskinporten subscope name.
 // Format: `<product><separator><name>`
 //
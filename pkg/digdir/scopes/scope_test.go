@@ -8,7 +8,6 @@ import (
 
 	naisiov1 "github.com/nais/liberator/pkg/apis/nais.io/v1"
 	"github.com/stretchr/testify/assert"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/utils/ptr"
 
 	"github.com/nais/digdirator/pkg/clients"
@@ -205,19 +204,6 @@ func TestDesiredExposedScopeHasChanges(t *testing.T) {
 	assert.True(t, result)
 }
 
-func TestDescription(t *testing.T) {
-	product := "arbeid"
-	clusterName := "test-cluster"
-	meta := &metav1.ObjectMeta{
-		Name:      "test-app",
-		Namespace: "test-namespace",
-	}
-
-	expected := "arbeid - test-cluster:test-namespace:test-app"
-	actual := scopes.Description(meta, clusterName, product)
-	assert.Equal(t, expected, actual)
-}
-
 func TestSubscope(t *testing.T) {
 	for _, tt := range []struct {
 		name  string
@@ -0,0 +1,82 @@
+package digdir
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+
+	"github.com/nais/digdirator/pkg/retry"
+)
+
+// streamDecoder is implemented by response targets that decode the response body as it is read,
+// instead of reading the whole body into memory first.
+type streamDecoder interface {
+	decodeStream(dec *json.Decoder) error
+}
+
+// arrayStream decodes a JSON array one element at a time.
+// The request is retried from the start if reading the body fails, so start is called before each attempt to reset
+// any state built up by yield. Decoding stops early without error once yield returns false.
+type arrayStream[T any] struct {
+	start func()
+	yield func(T) bool
+}
+
+func (s arrayStream[T]) decodeStream(dec *json.Decoder) error {
+	if s.start != nil {
+		s.start()
+	}
+
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if delim, ok := tok.(json.Delim); !ok || delim != '[' {
+		return &json.UnmarshalTypeError{Value: fmt.Sprintf("%v", tok), Type: reflect.TypeFor[[]T]()}
+	}
+
+	for dec.More() {
+		var element T
+		if err := dec.Decode(&element); err != nil {
+			return err
+		}
+		if !s.yield(element) {
+			return nil
+		}
+	}
+
+	_, err = dec.Token()
+	return err
+}
+
+// decodeResponse decodes the response body into the target, if any.
+// Malformed responses fail immediately, while errors from reading the body are retried.
+func decodeResponse(body io.Reader, target any) error {
+	var err error
+	switch t := target.(type) {
+	case nil:
+		return nil
+	case streamDecoder:
+		err = t.decodeStream(json.NewDecoder(body))
+	default:
+		var data []byte
+		data, err = io.ReadAll(body)
+		if err != nil {
+			return retry.Network(fmt.Errorf("reading response: %w", err))
+		}
+		err = json.Unmarshal(data, target)
+	}
+	if err == nil {
+		return nil
+	}
+
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	// an empty body is malformed rather than truncated, which is reported as io.ErrUnexpectedEOF
+	if errors.As(err, &syntaxErr) || errors.As(err, &typeErr) || err == io.EOF {
+		return fmt.Errorf("unmarshalling: %w", err)
+	}
+	return retry.Network(fmt.Errorf("reading response: %w", err))
+}
@@ -0,0 +1,95 @@
+package digdir
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/nais/digdirator/pkg/retry"
+)
+
+func TestDecodeResponse(t *testing.T) {
+	collect := func(limit int) (*[]string, arrayStream[string]) {
+		var elements []string
+		return &elements, arrayStream[string]{
+			start: func() {
+				elements = make([]string, 0)
+			},
+			yield: func(element string) bool {
+				elements = append(elements, element)
+				return len(elements) < limit
+			},
+		}
+	}
+
+	// attempts returns the number of attempts made to decode the body, i.e. 1 if the error is permanent
+	attempts := func(body func() io.Reader, target any) (int, error) {
+		attempts := 0
+		err := retry.Fibonacci(time.Millisecond).
+			WithMaxAttempts(2).
+			Do(t.Context(), func(context.Context) error {
+				attempts++
+				return decodeResponse(body(), target)
+			})
+		return attempts, err
+	}
+
+	t.Run("streams all elements", func(t *testing.T) {
+		elements, stream := collect(10)
+		require.NoError(t, decodeResponse(strings.NewReader(`["a", "b", "c"]`), stream))
+		assert.Equal(t, []string{"a", "b", "c"}, *elements)
+	})
+
+	t.Run("stops early without reading the rest", func(t *testing.T) {
+		elements, stream := collect(2)
+		body := io.MultiReader(strings.NewReader(`["a", "b", `), unreadable{})
+		require.NoError(t, decodeResponse(body, stream))
+		assert.Equal(t, []string{"a", "b"}, *elements)
+	})
+
+	t.Run("restarts when retried", func(t *testing.T) {
+		elements, stream := collect(10)
+		n, err := attempts(func() io.Reader {
+			return io.MultiReader(strings.NewReader(`["a", "b"`), iotest.ErrReader(io.ErrUnexpectedEOF))
+		}, stream)
+		assert.Error(t, err)
+		assert.Equal(t, 3, n)
+		assert.Equal(t, []string{"a", "b"}, *elements)
+	})
+
+	for _, tt := range []struct {
+		name   string
+		body   string
+		stream bool
+	}{
+		{name: "object instead of array", body: `{"a": "b"}`, stream: true},
+		{name: "invalid element", body: `["a", 1]`, stream: true},
+		{name: "invalid syntax", body: `["a" "b"]`, stream: true},
+		{name: "incomplete array", body: `["a", "b"`, stream: true},
+		{name: "empty body", body: ``, stream: true},
+		{name: "invalid object", body: `{"a": `},
+	} {
+		t.Run("fails immediately for "+tt.name, func(t *testing.T) {
+			var target any = &map[string]string{}
+			if tt.stream {
+				_, target = collect(10)
+			}
+			n, err := attempts(func() io.Reader { return strings.NewReader(tt.body) }, target)
+			assert.Error(t, err)
+			assert.Equal(t, 1, n)
+		})
+	}
+}
+
+// unreadable is a reader that fails the test if read.
+type unreadable struct{}
+
+func (unreadable) Read([]byte) (int, error) {
+	panic("read past the end of the stream")
+}
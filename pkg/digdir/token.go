@@ -0,0 +1,89 @@
+package digdir
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// tokenExpiryLeeway is subtracted from the token lifetime so that a cached token is refreshed shortly before it expires.
+const tokenExpiryLeeway = 30 * time.Second
+
+type tokenFetcher func(ctx context.Context) (*TokenResponse, error)
+
+// tokenSource caches the admin access token for the DigDir self-service API.
+// Concurrent refreshes are collapsed into a single request to the token endpoint.
+type tokenSource struct {
+	fetch tokenFetcher
+	group singleflight.Group
+
+	mu     sync.Mutex
+	token  *TokenResponse
+	expiry time.Time
+}
+
+func newTokenSource(fetch tokenFetcher) *tokenSource {
+	return &tokenSource{fetch: fetch}
+}
+
+// Token returns a cached token if it is still valid, otherwise it fetches a new token.
+func (t *tokenSource) Token(ctx context.Context) (*TokenResponse, error) {
+	if token, ok := t.cached(); ok {
+		return token, nil
+	}
+
+	result, err, _ := t.group.Do("token", func() (any, error) {
+		if token, ok := t.cached(); ok {
+			return token, nil
+		}
+
+		// the fetch is shared by all waiting callers, so it should not be cancelled by any single caller
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), httpRequestTimeout)
+		defer cancel()
+
+		token, err := t.fetch(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		t.store(token)
+		return token, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return result.(*TokenResponse), nil
+}
+
+// Invalidate discards the cached token, but only if it is the given token.
+// This prevents a stale rejection from discarding a token that was refreshed in the meantime.
+func (t *tokenSource) Invalidate(token *TokenResponse) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.token != nil && token != nil && t.token.AccessToken == token.AccessToken {
+		t.token = nil
+		t.expiry = time.Time{}
+	}
+}
+
+func (t *tokenSource) cached() (*TokenResponse, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.token == nil || !time.Now().Before(t.expiry) {
+		return nil, false
+	}
+	return t.token, true
+}
+
+func (t *tokenSource) store(token *TokenResponse) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.token = token
+	t.expiry = time.Now().Add(time.Duration(token.ExpiresIn)*time.Second - tokenExpiryLeeway)
+}
@@ -0,0 +1,102 @@
+package digdir
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTokenSource(t *testing.T) {
+	newFetcher := func(expiresIn int, delay time.Duration) (tokenFetcher, *atomic.Int32) {
+		calls := &atomic.Int32{}
+		return func(ctx context.Context) (*TokenResponse, error) {
+			n := calls.Add(1)
+			time.Sleep(delay)
+			return &TokenResponse{
+				AccessToken: fmt.Sprintf("token-%d", n),
+				ExpiresIn:   expiresIn,
+			}, nil
+		}, calls
+	}
+
+	t.Run("token is cached until shortly before expiry", func(t *testing.T) {
+		fetch, calls := newFetcher(120, 0)
+		source := newTokenSource(fetch)
+
+		first, err := source.Token(t.Context())
+		require.NoError(t, err)
+		second, err := source.Token(t.Context())
+		require.NoError(t, err)
+
+		assert.Equal(t, "token-1", first.AccessToken)
+		assert.Equal(t, first, second)
+		assert.EqualValues(t, 1, calls.Load())
+	})
+
+	t.Run("token within expiry leeway is refreshed", func(t *testing.T) {
+		fetch, calls := newFetcher(int(tokenExpiryLeeway.Seconds()), 0)
+		source := newTokenSource(fetch)
+
+		_, err := source.Token(t.Context())
+		require.NoError(t, err)
+		token, err := source.Token(t.Context())
+		require.NoError(t, err)
+
+		assert.Equal(t, "token-2", token.AccessToken)
+		assert.EqualValues(t, 2, calls.Load())
+	})
+
+	t.Run("concurrent refreshes are collapsed", func(t *testing.T) {
+		fetch, calls := newFetcher(120, 50*time.Millisecond)
+		source := newTokenSource(fetch)
+
+		var wg sync.WaitGroup
+		for range 10 {
+			wg.Go(func() {
+				token, err := source.Token(t.Context())
+				assert.NoError(t, err)
+				assert.Equal(t, "token-1", token.AccessToken)
+			})
+		}
+		wg.Wait()
+
+		assert.EqualValues(t, 1, calls.Load())
+	})
+
+	t.Run("invalidate discards the cached token", func(t *testing.T) {
+		fetch, calls := newFetcher(120, 0)
+		source := newTokenSource(fetch)
+
+		first, err := source.Token(t.Context())
+		require.NoError(t, err)
+
+		source.Invalidate(first)
+
+		second, err := source.Token(t.Context())
+		require.NoError(t, err)
+		assert.Equal(t, "token-2", second.AccessToken)
+		assert.EqualValues(t, 2, calls.Load())
+	})
+
+	t.Run("invalidate with a stale token keeps the refreshed token", func(t *testing.T) {
+		fetch, calls := newFetcher(120, 0)
+		source := newTokenSource(fetch)
+
+		stale := &TokenResponse{AccessToken: "stale"}
+		current, err := source.Token(t.Context())
+		require.NoError(t, err)
+
+		source.Invalidate(stale)
+
+		token, err := source.Token(t.Context())
+		require.NoError(t, err)
+		assert.Equal(t, current, token)
+		assert.EqualValues(t, 1, calls.Load())
+	})
+}
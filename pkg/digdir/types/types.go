@@ -1,6 +1,8 @@
 package types
 
 import (
+	"cmp"
+	"slices"
 	"time"
 
 	"github.com/nais/digdirator/pkg/crypto"
@@ -26,6 +28,7 @@ const (
 type IntegrationType string
 
 const (
+	IntegrationTypeAnsattporten IntegrationType = "ansattporten"
 	IntegrationTypeApiKlient    IntegrationType = "api_klient"
 	IntegrationTypeIDPorten     IntegrationType = "idporten"
 	IntegrationTypeMaskinporten IntegrationType = "maskinporten"
@@ -71,6 +74,43 @@ type ClientRegistration struct {
 	TokenEndpointAuthMethod           TokenEndpointAuthMethod `json:"token_endpoint_auth_method"`
 }
 
+// Diff returns the JSON names of the fields in the desired registration that differ from the existing registration.
+// The client ID is not compared, nor are optional fields that are left out of the desired registration, as an update doesn't change them.
+// Lists are compared regardless of order.
+func (c ClientRegistration) Diff(desired ClientRegistration) []string {
+	changed := make([]string, 0)
+	compare := func(field string, equal bool) {
+		if !equal {
+			changed = append(changed, field)
+		}
+	}
+
+	compare("access_token_lifetime", c.AccessTokenLifetime == desired.AccessTokenLifetime)
+	compare("application_type", c.ApplicationType == desired.ApplicationType)
+	compare("authorization_lifetime", c.AuthorizationLifeTime == desired.AuthorizationLifeTime)
+	compare("client_name", c.ClientName == desired.ClientName)
+	compare("client_orgno", desired.ClientOrgno == "" || c.ClientOrgno == desired.ClientOrgno)
+	compare("client_uri", desired.ClientURI == "" || c.ClientURI == desired.ClientURI)
+	compare("description", c.Description == desired.Description)
+	compare("frontchannel_logout_session_required", c.FrontchannelLogoutSessionRequired == desired.FrontchannelLogoutSessionRequired)
+	compare("frontchannel_logout_uri", desired.FrontchannelLogoutURI == "" || c.FrontchannelLogoutURI == desired.FrontchannelLogoutURI)
+	compare("grant_types", equalUnordered(c.GrantTypes, desired.GrantTypes))
+	compare("integration_type", c.IntegrationType == desired.IntegrationType)
+	compare("post_logout_redirect_uris", equalUnordered(c.PostLogoutRedirectURIs, desired.PostLogoutRedirectURIs))
+	compare("redirect_uris", equalUnordered(c.RedirectURIs, desired.RedirectURIs))
+	compare("refresh_token_lifetime", desired.RefreshTokenLifetime == 0 || c.RefreshTokenLifetime == desired.RefreshTokenLifetime)
+	compare("refresh_token_usage", c.RefreshTokenUsage == desired.RefreshTokenUsage)
+	compare("scopes", equalUnordered(c.Scopes, desired.Scopes))
+	compare("sso_disabled", c.SSODisabled == desired.SSODisabled)
+	compare("token_endpoint_auth_method", c.TokenEndpointAuthMethod == desired.TokenEndpointAuthMethod)
+
+	return changed
+}
+
+func equalUnordered[T cmp.Ordered](a, b []T) bool {
+	return slices.Equal(slices.Sorted(slices.Values(a)), slices.Sorted(slices.Values(b)))
+}
+
 type JwksResponse struct {
 	Created     string `json:"created"`
 	LastUpdated string `json:"last_updated"`
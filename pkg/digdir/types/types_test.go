@@ -0,0 +1,77 @@
+package types_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/nais/digdirator/pkg/digdir/types"
+)
+
+func TestClientRegistration_Diff(t *testing.T) {
+	existing := types.ClientRegistration{
+		AccessTokenLifetime:     3600,
+		ClientID:                "some-client-id",
+		ClientName:              "some-client",
+		ClientOrgno:             "123456789",
+		Description:             "test-cluster:test-namespace:some-app",
+		GrantTypes:              []types.GrantType{types.GrantTypeAuthorizationCode, types.GrantTypeRefreshToken},
+		IntegrationType:         types.IntegrationTypeIDPorten,
+		RedirectURIs:            []string{"https://a.example.com/callback", "https://b.example.com/callback"},
+		RefreshTokenLifetime:    7200,
+		Scopes:                  []string{"openid", "profile"},
+		TokenEndpointAuthMethod: types.TokenEndpointAuthMethodPrivateKeyJwt,
+	}
+
+	for _, tt := range []struct {
+		name   string
+		mutate func(desired *types.ClientRegistration)
+		want   []string
+	}{
+		{
+			name:   "identical",
+			mutate: func(desired *types.ClientRegistration) {},
+			want:   []string{},
+		},
+		{
+			name: "client id and omitted optional fields are ignored",
+			mutate: func(desired *types.ClientRegistration) {
+				desired.ClientID = ""
+				desired.ClientOrgno = ""
+				desired.RefreshTokenLifetime = 0
+			},
+			want: []string{},
+		},
+		{
+			name: "lists are compared regardless of order",
+			mutate: func(desired *types.ClientRegistration) {
+				desired.GrantTypes = []types.GrantType{types.GrantTypeRefreshToken, types.GrantTypeAuthorizationCode}
+				desired.RedirectURIs = []string{"https://b.example.com/callback", "https://a.example.com/callback"}
+			},
+			want: []string{},
+		},
+		{
+			name: "nil and empty lists are equal",
+			mutate: func(desired *types.ClientRegistration) {
+				desired.PostLogoutRedirectURIs = []string{}
+			},
+			want: []string{},
+		},
+		{
+			name: "changed fields",
+			mutate: func(desired *types.ClientRegistration) {
+				desired.AccessTokenLifetime = 60
+				desired.ClientOrgno = "987654321"
+				desired.RedirectURIs = []string{"https://a.example.com/callback"}
+				desired.Scopes = []string{"openid", "profile", "email"}
+			},
+			want: []string{"access_token_lifetime", "client_orgno", "redirect_uris", "scopes"},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			desired := existing
+			tt.mutate(&desired)
+			assert.Equal(t, tt.want, existing.Diff(desired))
+		})
+	}
+}
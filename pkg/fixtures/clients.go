@@ -1,6 +1,7 @@
 package fixtures
 
 import (
+	digdirv1alpha1 "github.com/nais/digdirator/api/v1alpha1"
 	"github.com/nais/digdirator/controllers/common"
 	naisiov1 "github.com/nais/liberator/pkg/apis/nais.io/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -29,6 +30,27 @@ func MinimalIDPortenClient() *naisiov1.IDPortenClient {
 	}
 }
 
+func MinimalAnsattportenClient() *digdirv1alpha1.AnsattportenClient {
+	return &digdirv1alpha1.AnsattportenClient{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:       "test-app",
+			Namespace:  "test-namespace",
+			Generation: 1,
+		},
+		Spec: digdirv1alpha1.AnsattportenClientSpec{
+			RedirectURIs: []naisiov1.IDPortenURI{
+				"https://test.com",
+			},
+			SecretName: "test",
+		},
+		Status: naisiov1.DigdiratorStatus{
+			SynchronizationState: common.EventSynchronized,
+			ClientID:             "test-ansattporten",
+			ObservedGeneration:   new(int64(1)),
+		},
+	}
+}
+
 func MinimalMaskinportenClient() *naisiov1.MaskinportenClient {
 	return &naisiov1.MaskinportenClient{
 		ObjectMeta: metav1.ObjectMeta{
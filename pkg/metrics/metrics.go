@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"log/slog"
+	"strconv"
 	"time"
 
 	naisiov1 "github.com/nais/liberator/pkg/apis/nais.io/v1"
@@ -12,13 +13,28 @@ import (
 	corev1 "k8s.io/api/core/v1"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
+	digdirv1alpha1 "github.com/nais/digdirator/api/v1alpha1"
 	"github.com/nais/digdirator/pkg/clients"
+	"github.com/nais/digdirator/pkg/config"
 	"github.com/nais/digdirator/pkg/digdir/types"
 	"github.com/nais/digdirator/pkg/retry"
 )
 
 const (
-	labelNamespace = "namespace"
+	labelAttempt     = "attempt"
+	labelIdentity    = "identity"
+	labelLimiter     = "limiter"
+	labelMethod      = "method"
+	labelNamespace   = "namespace"
+	labelOperation   = "operation"
+	labelState       = "state"
+	labelStatusClass = "status_class"
+)
+
+// Values for the limiter label.
+const (
+	LimiterRate     = "rate"
+	LimiterInFlight = "in_flight"
 )
 
 var log *slog.Logger
@@ -84,6 +100,92 @@ var (
 		},
 		[]string{labelNamespace},
 	)
+	IDPortenClientsOrphanedTotal = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "idporten_client_orphaned_total",
+			Help: "Total number of idporten clients in DigDir without a matching resource in this cluster",
+		},
+	)
+	IDPortenClientsOrphanDeletedCount = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "idporten_client_orphan_deleted_count",
+			Help: "Number of orphaned idporten clients successfully deleted",
+		},
+		[]string{labelNamespace},
+	)
+	AnsattportenClientsTotal = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "ansattporten_client_total",
+		},
+	)
+	AnsattportenSecretsTotal = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "ansattporten_client_secrets_total",
+			Help: "Total number of ansattporten client secrets",
+		},
+	)
+	AnsattportenClientsCreatedCount = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "ansattporten_client_created_count",
+			Help: "Number of ansattporten clients created successfully",
+		},
+		[]string{labelNamespace},
+	)
+	AnsattportenClientsUpdatedCount = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "ansattporten_client_updated_count",
+			Help: "Number of ansattporten clients updated successfully",
+		},
+		[]string{labelNamespace},
+	)
+	AnsattportenClientsRotatedCount = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "ansattporten_client_rotated_count",
+			Help: "Number of ansattporten clients successfully rotated credentials",
+		},
+		[]string{labelNamespace},
+	)
+	AnsattportenClientsProcessedCount = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "ansattporten_client_processed_count",
+			Help: "Number of ansattporten clients processed successfully",
+		},
+		[]string{labelNamespace},
+	)
+	AnsattportenClientsFailedProcessingCount = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "ansattporten_client_failed_processing_count",
+			Help: "Number of ansattporten clients that failed processing",
+		},
+		[]string{labelNamespace},
+	)
+	AnsattportenClientsFailedInvalidConfigCount = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "ansattporten_client_failed_invalid_config_count",
+			Help: "Number of ansattporten clients that failed processing due to invalid configuration",
+		},
+		[]string{labelNamespace},
+	)
+	AnsattportenClientsDeletedCount = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "ansattporten_client_deleted_count",
+			Help: "Number of ansattporten clients successfully deleted",
+		},
+		[]string{labelNamespace},
+	)
+	AnsattportenClientsOrphanedTotal = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "ansattporten_client_orphaned_total",
+			Help: "Total number of ansattporten clients in DigDir without a matching resource in this cluster",
+		},
+	)
+	AnsattportenClientsOrphanDeletedCount = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "ansattporten_client_orphan_deleted_count",
+			Help: "Number of orphaned ansattporten clients successfully deleted",
+		},
+		[]string{labelNamespace},
+	)
 	MaskinportenClientsTotal = prometheus.NewGauge(
 		prometheus.GaugeOpts{
 			Name: "maskinporten_client_total",
@@ -144,6 +246,19 @@ var (
 		},
 		[]string{labelNamespace},
 	)
+	MaskinportenClientsOrphanedTotal = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "maskinporten_client_orphaned_total",
+			Help: "Total number of maskinporten clients in DigDir without a matching resource in this cluster",
+		},
+	)
+	MaskinportenClientsOrphanDeletedCount = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "maskinporten_client_orphan_deleted_count",
+			Help: "Number of orphaned maskinporten clients successfully deleted",
+		},
+		[]string{labelNamespace},
+	)
 	MaskinportenExposedScopesTotal = prometheus.NewGauge(
 		prometheus.GaugeOpts{
 			Name: "maskinporten_exposed_scope_total",
@@ -180,6 +295,12 @@ var (
 		},
 		[]string{labelNamespace},
 	)
+	MaskinportenScopesOrphanedTotal = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "maskinporten_scope_orphaned_total",
+			Help: "Total number of active maskinporten scopes in DigDir that are no longer exposed by a resource in this cluster",
+		},
+	)
 	MaskinportenScopesReactivatedCount = prometheus.NewCounterVec(
 		prometheus.CounterOpts{
 			Name: "maskinporten_scope_reactivated_count",
@@ -208,6 +329,49 @@ var (
 		},
 		[]string{labelNamespace},
 	)
+	DigDirPlannedOperationsTotal = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "digdir_planned_operations_total",
+			Help: "Total number of operations against DigDir that are planned in dry-run mode",
+		},
+		[]string{labelOperation},
+	)
+	DigDirLimiterWaitSeconds = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "digdir_limiter_wait_seconds",
+			Help:    "Time spent waiting on the client-side limiters before sending a request to DigDir",
+			Buckets: []float64{0.001, 0.01, 0.1, 0.5, 1, 2.5, 5, 10, 30},
+		},
+		[]string{labelLimiter},
+	)
+	DigDirRequestsInFlight = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "digdir_requests_in_flight",
+			Help: "Number of requests to DigDir currently in flight",
+		},
+	)
+	DigDirRequestDurationSeconds = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "digdir_request_duration_seconds",
+			Help:    "Duration of requests to DigDir, including the token endpoint",
+			Buckets: prometheus.DefBuckets,
+		},
+		[]string{labelIdentity, labelOperation, labelMethod, labelStatusClass, labelAttempt},
+	)
+	DigDirRequestsCount = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "digdir_request_count",
+			Help: "Number of requests to DigDir, including the token endpoint",
+		},
+		[]string{labelIdentity, labelOperation, labelMethod, labelStatusClass, labelAttempt},
+	)
+	DigDirCircuitBreakerState = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "digdir_circuit_breaker_state",
+			Help: "Current state of the circuit breaker for requests to DigDir, set to 1 for the current state",
+		},
+		[]string{labelState},
+	)
 )
 
 var AllMetrics = []prometheus.Collector{
@@ -220,6 +384,19 @@ var AllMetrics = []prometheus.Collector{
 	IDPortenClientsUpdatedCount,
 	IDPortenClientsRotatedCount,
 	IDPortenClientsDeletedCount,
+	IDPortenClientsOrphanedTotal,
+	IDPortenClientsOrphanDeletedCount,
+	AnsattportenClientsTotal,
+	AnsattportenSecretsTotal,
+	AnsattportenClientsProcessedCount,
+	AnsattportenClientsFailedProcessingCount,
+	AnsattportenClientsFailedInvalidConfigCount,
+	AnsattportenClientsCreatedCount,
+	AnsattportenClientsUpdatedCount,
+	AnsattportenClientsRotatedCount,
+	AnsattportenClientsDeletedCount,
+	AnsattportenClientsOrphanedTotal,
+	AnsattportenClientsOrphanDeletedCount,
 	MaskinportenClientsTotal,
 	MaskinportenSecretsTotal,
 	MaskinportenClientsProcessedCount,
@@ -228,16 +405,25 @@ var AllMetrics = []prometheus.Collector{
 	MaskinportenClientsUpdatedCount,
 	MaskinportenClientsRotatedCount,
 	MaskinportenClientsDeletedCount,
+	MaskinportenClientsOrphanedTotal,
+	MaskinportenClientsOrphanDeletedCount,
 	MaskinportenExposedScopesTotal,
 	MaskinportenExternalScopesConsumedTotal,
 	MaskinportenScopeConsumersTotal,
 	MaskinportenScopesCreatedCount,
 	MaskinportenScopesUpdatedCount,
 	MaskinportenScopesDeletedCount,
+	MaskinportenScopesOrphanedTotal,
 	MaskinportenScopesReactivatedCount,
 	MaskinportenScopesConsumersCreatedCount,
 	MaskinportenScopesConsumersUpdatedCount,
 	MaskinportenScopesConsumersDeletedCount,
+	DigDirPlannedOperationsTotal,
+	DigDirLimiterWaitSeconds,
+	DigDirRequestsInFlight,
+	DigDirCircuitBreakerState,
+	DigDirRequestDurationSeconds,
+	DigDirRequestsCount,
 }
 
 var AllCounters = []*prometheus.CounterVec{
@@ -247,6 +433,15 @@ var AllCounters = []*prometheus.CounterVec{
 	IDPortenClientsUpdatedCount,
 	IDPortenClientsRotatedCount,
 	IDPortenClientsDeletedCount,
+	IDPortenClientsOrphanDeletedCount,
+	AnsattportenClientsProcessedCount,
+	AnsattportenClientsFailedProcessingCount,
+	AnsattportenClientsFailedInvalidConfigCount,
+	AnsattportenClientsCreatedCount,
+	AnsattportenClientsUpdatedCount,
+	AnsattportenClientsRotatedCount,
+	AnsattportenClientsDeletedCount,
+	AnsattportenClientsOrphanDeletedCount,
 	MaskinportenClientsProcessedCount,
 	MaskinportenClientsFailedProcessingCount,
 	MaskinportenClientsFailedInvalidConfigCount,
@@ -254,6 +449,7 @@ var AllCounters = []*prometheus.CounterVec{
 	MaskinportenClientsUpdatedCount,
 	MaskinportenClientsRotatedCount,
 	MaskinportenClientsDeletedCount,
+	MaskinportenClientsOrphanDeletedCount,
 	MaskinportenScopesCreatedCount,
 	MaskinportenScopesUpdatedCount,
 	MaskinportenScopesDeletedCount,
@@ -273,6 +469,8 @@ func IncClientsProcessed(instance clients.Instance) {
 		incWithNamespaceLabel(IDPortenClientsProcessedCount, instance.GetNamespace())
 	case *naisiov1.MaskinportenClient:
 		incWithNamespaceLabel(MaskinportenClientsProcessedCount, instance.GetNamespace())
+	case *digdirv1alpha1.AnsattportenClient:
+		incWithNamespaceLabel(AnsattportenClientsProcessedCount, instance.GetNamespace())
 	}
 }
 
@@ -282,6 +480,8 @@ func IncClientsFailedProcessing(instance clients.Instance) {
 		incWithNamespaceLabel(IDPortenClientsFailedProcessingCount, instance.GetNamespace())
 	case *naisiov1.MaskinportenClient:
 		incWithNamespaceLabel(MaskinportenClientsFailedProcessingCount, instance.GetNamespace())
+	case *digdirv1alpha1.AnsattportenClient:
+		incWithNamespaceLabel(AnsattportenClientsFailedProcessingCount, instance.GetNamespace())
 	}
 }
 
@@ -291,6 +491,8 @@ func IncClientsFailedInvalidConfig(instance clients.Instance) {
 		incWithNamespaceLabel(IDPortenClientsFailedInvalidConfigCount, instance.GetNamespace())
 	case *naisiov1.MaskinportenClient:
 		incWithNamespaceLabel(MaskinportenClientsFailedInvalidConfigCount, instance.GetNamespace())
+	case *digdirv1alpha1.AnsattportenClient:
+		incWithNamespaceLabel(AnsattportenClientsFailedInvalidConfigCount, instance.GetNamespace())
 	}
 }
 
@@ -300,6 +502,8 @@ func IncClientsCreated(instance clients.Instance) {
 		incWithNamespaceLabel(IDPortenClientsCreatedCount, instance.GetNamespace())
 	case *naisiov1.MaskinportenClient:
 		incWithNamespaceLabel(MaskinportenClientsCreatedCount, instance.GetNamespace())
+	case *digdirv1alpha1.AnsattportenClient:
+		incWithNamespaceLabel(AnsattportenClientsCreatedCount, instance.GetNamespace())
 	}
 }
 
@@ -309,6 +513,8 @@ func IncClientsUpdated(instance clients.Instance) {
 		incWithNamespaceLabel(IDPortenClientsUpdatedCount, instance.GetNamespace())
 	case *naisiov1.MaskinportenClient:
 		incWithNamespaceLabel(MaskinportenClientsUpdatedCount, instance.GetNamespace())
+	case *digdirv1alpha1.AnsattportenClient:
+		incWithNamespaceLabel(AnsattportenClientsUpdatedCount, instance.GetNamespace())
 	}
 }
 
@@ -318,6 +524,8 @@ func IncClientsRotated(instance clients.Instance) {
 		incWithNamespaceLabel(IDPortenClientsRotatedCount, instance.GetNamespace())
 	case *naisiov1.MaskinportenClient:
 		incWithNamespaceLabel(MaskinportenClientsRotatedCount, instance.GetNamespace())
+	case *digdirv1alpha1.AnsattportenClient:
+		incWithNamespaceLabel(AnsattportenClientsRotatedCount, instance.GetNamespace())
 	}
 }
 
@@ -327,40 +535,98 @@ func IncClientsDeleted(instance clients.Instance) {
 		incWithNamespaceLabel(IDPortenClientsDeletedCount, instance.GetNamespace())
 	case *naisiov1.MaskinportenClient:
 		incWithNamespaceLabel(MaskinportenClientsDeletedCount, instance.GetNamespace())
+	case *digdirv1alpha1.AnsattportenClient:
+		incWithNamespaceLabel(AnsattportenClientsDeletedCount, instance.GetNamespace())
 	}
 }
 
-func IncScopesCreated(instance clients.Instance) {
+func IncOrphansDeleted(instance clients.Instance) {
 	switch instance.(type) {
+	case *naisiov1.IDPortenClient:
+		incWithNamespaceLabel(IDPortenClientsOrphanDeletedCount, instance.GetNamespace())
 	case *naisiov1.MaskinportenClient:
+		incWithNamespaceLabel(MaskinportenClientsOrphanDeletedCount, instance.GetNamespace())
+	case *digdirv1alpha1.AnsattportenClient:
+		incWithNamespaceLabel(AnsattportenClientsOrphanDeletedCount, instance.GetNamespace())
+	}
+}
+
+func ObserveLimiterWait(limiter string, duration time.Duration) {
+	DigDirLimiterWaitSeconds.WithLabelValues(limiter).Observe(duration.Seconds())
+}
+
+// ObserveDigDirRequest records a single attempt of a request to DigDir by the given admin identity.
+// A zero status code means that no response was received.
+func ObserveDigDirRequest(identity, operation, method string, statusCode, attempt int, duration time.Duration) {
+	statusClass := "error"
+	if statusCode > 0 {
+		statusClass = fmt.Sprintf("%dxx", statusCode/100)
+	}
+
+	labels := []string{identity, operation, method, statusClass, strconv.Itoa(attempt)}
+	DigDirRequestDurationSeconds.WithLabelValues(labels...).Observe(duration.Seconds())
+	DigDirRequestsCount.WithLabelValues(labels...).Inc()
+}
+
+func SetCircuitBreakerState(state string) {
+	DigDirCircuitBreakerState.Reset()
+	DigDirCircuitBreakerState.WithLabelValues(state).Set(1)
+}
+
+// SetOrphans sets the total number of orphaned clients from the given orphaned instances.
+func SetOrphans(orphans []clients.Instance) {
+	var idporten, maskinporten, ansattporten int
+	for _, instance := range orphans {
+		switch instance.(type) {
+		case *naisiov1.IDPortenClient:
+			idporten++
+		case *naisiov1.MaskinportenClient:
+			maskinporten++
+		case *digdirv1alpha1.AnsattportenClient:
+			ansattporten++
+		}
+	}
+	IDPortenClientsOrphanedTotal.Set(float64(idporten))
+	AnsattportenClientsOrphanedTotal.Set(float64(ansattporten))
+	MaskinportenClientsOrphanedTotal.Set(float64(maskinporten))
+}
+
+// SetOrphanedScopes sets the total number of orphaned maskinporten scopes.
+func SetOrphanedScopes(count int) {
+	MaskinportenScopesOrphanedTotal.Set(float64(count))
+}
+
+func IncScopesCreated(instance clients.Instance) {
+	switch instance.(type) {
+	case *naisiov1.MaskinportenClient, *digdirv1alpha1.MaskinportenScope:
 		incWithNamespaceLabel(MaskinportenScopesCreatedCount, instance.GetNamespace())
 	}
 }
 
 func IncScopesUpdated(instance clients.Instance) {
 	switch instance.(type) {
-	case *naisiov1.MaskinportenClient:
+	case *naisiov1.MaskinportenClient, *digdirv1alpha1.MaskinportenScope:
 		incWithNamespaceLabel(MaskinportenScopesUpdatedCount, instance.GetNamespace())
 	}
 }
 
 func IncScopesDeleted(instance clients.Instance) {
 	switch instance.(type) {
-	case *naisiov1.MaskinportenClient:
+	case *naisiov1.MaskinportenClient, *digdirv1alpha1.MaskinportenScope:
 		incWithNamespaceLabel(MaskinportenScopesDeletedCount, instance.GetNamespace())
 	}
 }
 
 func IncScopesReactivated(instance clients.Instance) {
 	switch instance.(type) {
-	case *naisiov1.MaskinportenClient:
+	case *naisiov1.MaskinportenClient, *digdirv1alpha1.MaskinportenScope:
 		incWithNamespaceLabel(MaskinportenScopesDeletedCount, instance.GetNamespace())
 	}
 }
 
 func IncScopesConsumersCreatedOrUpdated(instance clients.Instance, state types.State) {
 	switch instance.(type) {
-	case *naisiov1.MaskinportenClient:
+	case *naisiov1.MaskinportenClient, *digdirv1alpha1.MaskinportenScope:
 		if state == types.ScopeStateDenied {
 			incWithNamespaceLabel(MaskinportenScopesConsumersUpdatedCount, instance.GetNamespace())
 		} else {
@@ -371,23 +637,33 @@ func IncScopesConsumersCreatedOrUpdated(instance clients.Instance, state types.S
 
 func IncScopesConsumersDeleted(instance clients.Instance) {
 	switch instance.(type) {
-	case *naisiov1.MaskinportenClient:
+	case *naisiov1.MaskinportenClient, *digdirv1alpha1.MaskinportenScope:
 		incWithNamespaceLabel(MaskinportenScopesConsumersDeletedCount, instance.GetNamespace())
 	}
 }
 
+// SetPlannedOperations sets the total number of planned operations, keyed by operation name.
+func SetPlannedOperations(counts map[string]int) {
+	DigDirPlannedOperationsTotal.Reset()
+	for operation, count := range counts {
+		DigDirPlannedOperationsTotal.WithLabelValues(operation).Set(float64(count))
+	}
+}
+
 type Metrics interface {
 	Refresh(ctx context.Context)
 }
 
 type metrics struct {
-	reader client.Reader
+	reader   client.Reader
+	features config.Features
 }
 
-func New(reader client.Reader) Metrics {
+func New(reader client.Reader, features config.Features) Metrics {
 	log = slog.Default().With("subsystem", "metrics")
 	return metrics{
-		reader: reader,
+		reader:   reader,
+		features: features,
 	}
 }
 
@@ -429,6 +705,9 @@ func (m metrics) Refresh(ctx context.Context) {
 	var maskinportenSecretList corev1.SecretList
 	var maskinportenClientsList naisiov1.MaskinportenClientList
 
+	var ansattportenSecretList corev1.SecretList
+	var ansattportenClientsList digdirv1alpha1.AnsattportenClientList
+
 	m.InitWithNamespaceLabels()
 
 	t := time.NewTicker(exp)
@@ -458,6 +737,23 @@ func (m metrics) Refresh(ctx context.Context) {
 		MaskinportenClientsTotal.Set(float64(len(maskinportenClientsList.Items)))
 
 		setTotalForMaskinportenScopes(maskinportenClientsList.Items)
+
+		// the AnsattportenClient CRD is only installed when the feature is enabled
+		if !m.features.Ansattporten {
+			continue
+		}
+
+		if err = m.reader.List(ctx, &ansattportenSecretList, client.MatchingLabels{
+			clients.TypeLabelKey: clients.AnsattportenTypeLabelValue,
+		}); err != nil {
+			log.Error("failed to list ansattporten secrets", "error", err)
+		}
+		AnsattportenSecretsTotal.Set(float64(len(ansattportenSecretList.Items)))
+
+		if err = m.reader.List(ctx, &ansattportenClientsList); err != nil {
+			log.Error("failed to list ansattporten clients", "error", err)
+		}
+		AnsattportenClientsTotal.Set(float64(len(ansattportenClientsList.Items)))
 	}
 }
 
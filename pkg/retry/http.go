@@ -0,0 +1,71 @@
+package retry
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/sethvargo/go-retry"
+)
+
+// maxRetryAfter caps the delay requested by a server, so that a single request can't stall for too long.
+const maxRetryAfter = 1 * time.Minute
+
+type retryAfterError struct {
+	err   error
+	delay time.Duration
+}
+
+func (e *retryAfterError) Error() string {
+	return e.err.Error()
+}
+
+func (e *retryAfterError) Unwrap() error {
+	return e.err
+}
+
+// RetryAfter marks the error as retryable after the given delay, which overrides the next delay of the backoff.
+func RetryAfter(err error, delay time.Duration) error {
+	return retry.RetryableError(&retryAfterError{err: err, delay: min(delay, maxRetryAfter)})
+}
+
+// HTTPResponse classifies the error for a failed HTTP response by its status code:
+//   - 429 Too Many Requests and 503 Service Unavailable are retried after the delay in the Retry-After header, if any.
+//   - 408 Request Timeout and the remaining 5xx statuses are retried with backoff.
+//   - Any other status, e.g. 400, 403, 404, 409 or 422, is permanent and fails immediately.
+func HTTPResponse(err error, resp *http.Response) error {
+	switch code := resp.StatusCode; {
+	case code == http.StatusTooManyRequests, code == http.StatusServiceUnavailable:
+		if delay, ok := parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()); ok {
+			return RetryAfter(err, delay)
+		}
+		return RetryableError(err)
+	case code == http.StatusRequestTimeout, code >= 500:
+		return RetryableError(err)
+	default:
+		return err
+	}
+}
+
+// Network classifies an error from sending a request or reading its response, e.g. a timeout or a reset connection.
+// These are assumed to be transient and are retried with backoff.
+func Network(err error) error {
+	return RetryableError(err)
+}
+
+// parseRetryAfter parses the Retry-After header, which is either a number of seconds or an HTTP date.
+func parseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return time.Duration(max(seconds, 0)) * time.Second, true
+	}
+
+	if date, err := http.ParseTime(value); err == nil {
+		return max(date.Sub(now), 0), true
+	}
+
+	return 0, false
+}
@@ -0,0 +1,93 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHTTPResponse(t *testing.T) {
+	errFailed := errors.New("request failed")
+
+	for _, tt := range []struct {
+		status     int
+		retryAfter string
+		attempts   int
+		delay      time.Duration
+	}{
+		{status: http.StatusBadRequest, attempts: 1},
+		{status: http.StatusForbidden, attempts: 1},
+		{status: http.StatusNotFound, attempts: 1},
+		{status: http.StatusConflict, attempts: 1},
+		{status: http.StatusUnprocessableEntity, attempts: 1},
+		{status: http.StatusRequestTimeout, attempts: 3},
+		{status: http.StatusInternalServerError, attempts: 3},
+		{status: http.StatusBadGateway, attempts: 3},
+		{status: http.StatusTooManyRequests, attempts: 3},
+		{status: http.StatusTooManyRequests, retryAfter: "1", attempts: 3, delay: 2 * time.Second},
+		{status: http.StatusServiceUnavailable, retryAfter: "1", attempts: 3, delay: 2 * time.Second},
+	} {
+		t.Run(http.StatusText(tt.status)+" "+tt.retryAfter, func(t *testing.T) {
+			resp := &http.Response{StatusCode: tt.status, Header: http.Header{}}
+			if tt.retryAfter != "" {
+				resp.Header.Set("Retry-After", tt.retryAfter)
+			}
+
+			attempts := 0
+			slept := time.Duration(0)
+			b := Fibonacci(time.Millisecond).WithMaxAttempts(2)
+			b.sleep = func(d time.Duration) { slept += d }
+			err := b.Do(t.Context(), func(ctx context.Context) error {
+				attempts++
+				return HTTPResponse(errFailed, resp)
+			})
+
+			assert.ErrorIs(t, err, errFailed)
+			assert.Equal(t, tt.attempts, attempts)
+			assert.GreaterOrEqual(t, slept, tt.delay)
+		})
+	}
+}
+
+func TestNetwork(t *testing.T) {
+	attempts := 0
+	err := Fibonacci(time.Millisecond).
+		WithMaxAttempts(2).
+		Do(t.Context(), func(ctx context.Context) error {
+			attempts++
+			if attempts < 3 {
+				return Network(errors.New("connection reset by peer"))
+			}
+			return nil
+		})
+
+	assert.NoError(t, err)
+	assert.Equal(t, 3, attempts)
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2026, time.January, 1, 12, 0, 0, 0, time.UTC)
+
+	for _, tt := range []struct {
+		value string
+		delay time.Duration
+		ok    bool
+	}{
+		{value: ""},
+		{value: "soon"},
+		{value: "120", delay: 2 * time.Minute, ok: true},
+		{value: "-1", delay: 0, ok: true},
+		{value: "Thu, 01 Jan 2026 12:00:30 GMT", delay: 30 * time.Second, ok: true},
+		{value: "Thu, 01 Jan 2026 11:59:00 GMT", delay: 0, ok: true},
+	} {
+		t.Run(tt.value, func(t *testing.T) {
+			delay, ok := parseRetryAfter(tt.value, now)
+			assert.Equal(t, tt.ok, ok)
+			assert.Equal(t, tt.delay, delay)
+		})
+	}
+}
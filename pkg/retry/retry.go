@@ -2,6 +2,7 @@ package retry
 
 import (
 	"context"
+	"errors"
 	"time"
 
 	"github.com/sethvargo/go-retry"
@@ -9,6 +10,8 @@ import (
 
 type Backoff struct {
 	b retry.Backoff
+	// sleep replaces the delays between attempts if set, so that tests don't have to wait for them.
+	sleep func(time.Duration)
 }
 
 func RetryableError(err error) error {
@@ -36,6 +39,32 @@ func (in Backoff) WithMaxDuration(timeout time.Duration) Backoff {
 	return in
 }
 
+// Do calls f until it succeeds, returns an error that isn't retryable, or the backoff stops.
+// Errors from RetryAfter replace the next delay of the backoff.
 func (in Backoff) Do(ctx context.Context, f retry.RetryFunc) error {
-	return retry.Do(ctx, in.b, f)
+	var retryAfter time.Duration
+	b := retry.BackoffFunc(func() (time.Duration, bool) {
+		next, stop := in.b.Next()
+		if stop {
+			return 0, true
+		}
+		if retryAfter > 0 {
+			next, retryAfter = retryAfter, 0
+		}
+		if in.sleep != nil {
+			in.sleep(next)
+			return 0, false
+		}
+		return next, false
+	})
+
+	return retry.Do(ctx, b, func(ctx context.Context) error {
+		err := f(ctx)
+
+		var target *retryAfterError
+		if errors.As(err, &target) {
+			retryAfter = target.delay
+		}
+		return err
+	})
 }
@@ -2,19 +2,45 @@ package secrets
 
 // Keys for outputting data to secrets
 const (
-	IDPortenClientIDKey      = "IDPORTEN_CLIENT_ID"
-	IDPortenJwkKey           = "IDPORTEN_CLIENT_JWK"
-	IDPortenRedirectURIKey   = "IDPORTEN_REDIRECT_URI"
-	IDPortenWellKnownURLKey  = "IDPORTEN_WELL_KNOWN_URL"
-	IDPortenIssuerKey        = "IDPORTEN_ISSUER"
-	IDPortenJwksUriKey       = "IDPORTEN_JWKS_URI"
-	IDPortenTokenEndpointKey = "IDPORTEN_TOKEN_ENDPOINT"
+	AnsattportenClientIDKey         = "ANSATTPORTEN_CLIENT_ID"
+	AnsattportenJwkKey              = "ANSATTPORTEN_CLIENT_JWK"
+	AnsattportenRedirectURIKey      = "ANSATTPORTEN_REDIRECT_URI"
+	AnsattportenWellKnownURLKey     = "ANSATTPORTEN_WELL_KNOWN_URL"
+	AnsattportenIssuerKey           = "ANSATTPORTEN_ISSUER"
+	AnsattportenJwksUriKey          = "ANSATTPORTEN_JWKS_URI"
+	AnsattportenTokenEndpointKey    = "ANSATTPORTEN_TOKEN_ENDPOINT"
+	AnsattportenKeyIDKey            = "ANSATTPORTEN_CLIENT_KEY_ID"
+	AnsattportenPrivateKeyKey       = "ANSATTPORTEN_CLIENT_PRIVATE_KEY"
+	AnsattportenPublicKeyKey        = "ANSATTPORTEN_CLIENT_PUBLIC_KEY"
+	AnsattportenKeystoreKey         = "ANSATTPORTEN_CLIENT_KEYSTORE"
+	AnsattportenKeystorePasswordKey = "ANSATTPORTEN_CLIENT_KEYSTORE_PASSWORD"
 
-	MaskinportenClientIDKey      = "MASKINPORTEN_CLIENT_ID"
-	MaskinportenJwkKey           = "MASKINPORTEN_CLIENT_JWK"
-	MaskinportenScopesKey        = "MASKINPORTEN_SCOPES"
-	MaskinportenWellKnownURLKey  = "MASKINPORTEN_WELL_KNOWN_URL"
-	MaskinportenIssuerKey        = "MASKINPORTEN_ISSUER"
-	MaskinportenJwksUriKey       = "MASKINPORTEN_JWKS_URI"
-	MaskinportenTokenEndpointKey = "MASKINPORTEN_TOKEN_ENDPOINT"
+	IDPortenClientIDKey         = "IDPORTEN_CLIENT_ID"
+	IDPortenJwkKey              = "IDPORTEN_CLIENT_JWK"
+	IDPortenRedirectURIKey      = "IDPORTEN_REDIRECT_URI"
+	IDPortenWellKnownURLKey     = "IDPORTEN_WELL_KNOWN_URL"
+	IDPortenIssuerKey           = "IDPORTEN_ISSUER"
+	IDPortenJwksUriKey          = "IDPORTEN_JWKS_URI"
+	IDPortenTokenEndpointKey    = "IDPORTEN_TOKEN_ENDPOINT"
+	IDPortenKeyIDKey            = "IDPORTEN_CLIENT_KEY_ID"
+	IDPortenPrivateKeyKey       = "IDPORTEN_CLIENT_PRIVATE_KEY"
+	IDPortenPublicKeyKey        = "IDPORTEN_CLIENT_PUBLIC_KEY"
+	IDPortenKeystoreKey         = "IDPORTEN_CLIENT_KEYSTORE"
+	IDPortenKeystorePasswordKey = "IDPORTEN_CLIENT_KEYSTORE_PASSWORD"
+
+	MaskinportenClientIDKey         = "MASKINPORTEN_CLIENT_ID"
+	MaskinportenJwkKey              = "MASKINPORTEN_CLIENT_JWK"
+	MaskinportenScopesKey           = "MASKINPORTEN_SCOPES"
+	MaskinportenWellKnownURLKey     = "MASKINPORTEN_WELL_KNOWN_URL"
+	MaskinportenIssuerKey           = "MASKINPORTEN_ISSUER"
+	MaskinportenJwksUriKey          = "MASKINPORTEN_JWKS_URI"
+	MaskinportenTokenEndpointKey    = "MASKINPORTEN_TOKEN_ENDPOINT"
+	MaskinportenKeyIDKey            = "MASKINPORTEN_CLIENT_KEY_ID"
+	MaskinportenPrivateKeyKey       = "MASKINPORTEN_CLIENT_PRIVATE_KEY"
+	MaskinportenPublicKeyKey        = "MASKINPORTEN_CLIENT_PUBLIC_KEY"
+	MaskinportenKeystoreKey         = "MASKINPORTEN_CLIENT_KEYSTORE"
+	MaskinportenKeystorePasswordKey = "MASKINPORTEN_CLIENT_KEYSTORE_PASSWORD"
+
+	// MaskinportenExposedScopesKey is only written to the client metadata, see MaskinportenClientMetadata.
+	MaskinportenExposedScopesKey = "MASKINPORTEN_EXPOSED_SCOPES"
 )
@@ -0,0 +1,104 @@
+package secrets
+
+import (
+	libcrypto "crypto"
+	"fmt"
+
+	"github.com/go-jose/go-jose/v4"
+	"software.sslmate.com/src/go-pkcs12"
+
+	"github.com/nais/digdirator/pkg/crypto"
+)
+
+// KeyFormats selects the additional formats that the client key is written to the secret in, besides the JWK.
+type KeyFormats struct {
+	Formats []crypto.SecretFormat
+	// Existing is the data of the current secret, if any.
+	// Its keystore is reused if it holds the same key, as the keystore differs each time it is created.
+	Existing map[string][]byte
+}
+
+// addTo adds the client key in the selected formats to the secret data, along with the key ID needed to use keys outside a JWK.
+func (f KeyFormats) addTo(data map[string]string, keys Keys, jwk jose.JSONWebKey) error {
+	if len(f.Formats) == 0 {
+		return nil
+	}
+
+	data[keys.KeyID] = jwk.KeyID
+
+	for _, format := range f.Formats {
+		switch format {
+		case crypto.SecretFormatPEM:
+			privateKey, err := crypto.PrivateKeyPEM(jwk)
+			if err != nil {
+				return err
+			}
+			publicKey, err := crypto.PublicKeyPEM(jwk)
+			if err != nil {
+				return err
+			}
+			data[keys.PrivateKey] = string(privateKey)
+			data[keys.PublicKey] = string(publicKey)
+		case crypto.SecretFormatPKCS12:
+			keystore, password, err := f.keystore(keys, jwk)
+			if err != nil {
+				return err
+			}
+			data[keys.Keystore] = string(keystore)
+			data[keys.KeystorePassword] = password
+		default:
+			return fmt.Errorf("unsupported secret format %q", format)
+		}
+	}
+	return nil
+}
+
+func (f KeyFormats) keystore(keys Keys, jwk jose.JSONWebKey) ([]byte, string, error) {
+	keystore := f.Existing[keys.Keystore]
+	password := f.Existing[keys.KeystorePassword]
+	if string(f.Existing[keys.KeyID]) == jwk.KeyID && keystoreHolds(keystore, string(password), jwk) {
+		return keystore, string(password), nil
+	}
+
+	newPassword, err := crypto.GenerateKeystorePassword()
+	if err != nil {
+		return nil, "", fmt.Errorf("generating keystore password: %w", err)
+	}
+
+	cert, err := crypto.SelfSignedCertificate(jwk)
+	if err != nil {
+		return nil, "", fmt.Errorf("creating certificate: %w", err)
+	}
+
+	// the private key and certificate are encrypted with PBES2 (AES-256) and the keystore is authenticated with HMAC-SHA256.
+	// Its password is random, so the default iteration count suffices.
+	newKeystore, err := pkcs12.Modern2023.Encode(jwk.Key, cert, nil, newPassword)
+	if err != nil {
+		return nil, "", fmt.Errorf("creating keystore: %w", err)
+	}
+	return newKeystore, newPassword, nil
+}
+
+// keystoreHolds returns whether the keystore can be opened with the password, and holds the private key of the JWK along
+// with a certificate for it. Otherwise, the keystore or password has been changed and must be replaced.
+func keystoreHolds(keystore []byte, password string, jwk jose.JSONWebKey) bool {
+	if len(keystore) == 0 || len(password) == 0 {
+		return false
+	}
+
+	privateKey, cert, _, err := pkcs12.DecodeChain(keystore, password)
+	if err != nil {
+		return false
+	}
+
+	key, ok := privateKey.(interface {
+		Equal(libcrypto.PrivateKey) bool
+	})
+	if !ok || !key.Equal(jwk.Key) {
+		return false
+	}
+	publicKey, ok := cert.PublicKey.(interface {
+		Equal(libcrypto.PublicKey) bool
+	})
+	return ok && publicKey.Equal(jwk.Public().Key)
+}
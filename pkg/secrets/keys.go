@@ -0,0 +1,81 @@
+package secrets
+
+import "slices"
+
+// Keys are the names of the keys in the secrets of a type of client.
+// Keys that do not apply to the type of client are empty.
+type Keys struct {
+	ClientID      string
+	JWK           string
+	RedirectURI   string
+	Scopes        string
+	WellKnownURL  string
+	Issuer        string
+	JwksURI       string
+	TokenEndpoint string
+
+	KeyID            string
+	PrivateKey       string
+	PublicKey        string
+	Keystore         string
+	KeystorePassword string
+}
+
+var (
+	AnsattportenKeys = Keys{
+		ClientID:         AnsattportenClientIDKey,
+		JWK:              AnsattportenJwkKey,
+		RedirectURI:      AnsattportenRedirectURIKey,
+		WellKnownURL:     AnsattportenWellKnownURLKey,
+		Issuer:           AnsattportenIssuerKey,
+		JwksURI:          AnsattportenJwksUriKey,
+		TokenEndpoint:    AnsattportenTokenEndpointKey,
+		KeyID:            AnsattportenKeyIDKey,
+		PrivateKey:       AnsattportenPrivateKeyKey,
+		PublicKey:        AnsattportenPublicKeyKey,
+		Keystore:         AnsattportenKeystoreKey,
+		KeystorePassword: AnsattportenKeystorePasswordKey,
+	}
+	IDPortenKeys = Keys{
+		ClientID:         IDPortenClientIDKey,
+		JWK:              IDPortenJwkKey,
+		RedirectURI:      IDPortenRedirectURIKey,
+		WellKnownURL:     IDPortenWellKnownURLKey,
+		Issuer:           IDPortenIssuerKey,
+		JwksURI:          IDPortenJwksUriKey,
+		TokenEndpoint:    IDPortenTokenEndpointKey,
+		KeyID:            IDPortenKeyIDKey,
+		PrivateKey:       IDPortenPrivateKeyKey,
+		PublicKey:        IDPortenPublicKeyKey,
+		Keystore:         IDPortenKeystoreKey,
+		KeystorePassword: IDPortenKeystorePasswordKey,
+	}
+	MaskinportenKeys = Keys{
+		ClientID:         MaskinportenClientIDKey,
+		JWK:              MaskinportenJwkKey,
+		Scopes:           MaskinportenScopesKey,
+		WellKnownURL:     MaskinportenWellKnownURLKey,
+		Issuer:           MaskinportenIssuerKey,
+		JwksURI:          MaskinportenJwksUriKey,
+		TokenEndpoint:    MaskinportenTokenEndpointKey,
+		KeyID:            MaskinportenKeyIDKey,
+		PrivateKey:       MaskinportenPrivateKeyKey,
+		PublicKey:        MaskinportenPublicKeyKey,
+		Keystore:         MaskinportenKeystoreKey,
+		KeystorePassword: MaskinportenKeystorePasswordKey,
+	}
+)
+
+// all returns the names of all keys that apply to the type of client.
+func (k Keys) all() []string {
+	keys := []string{
+		k.ClientID, k.JWK, k.RedirectURI, k.Scopes, k.WellKnownURL, k.Issuer, k.JwksURI, k.TokenEndpoint,
+		k.KeyID, k.PrivateKey, k.PublicKey, k.Keystore, k.KeystorePassword,
+	}
+	return slices.DeleteFunc(keys, func(key string) bool { return key == "" })
+}
+
+// readBack returns the keys that digdirator reads from existing secrets, which must keep their names.
+func (k Keys) readBack() []string {
+	return []string{k.JWK, k.KeyID, k.Keystore, k.KeystorePassword}
+}
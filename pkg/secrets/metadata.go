@@ -0,0 +1,86 @@
+package secrets
+
+import (
+	"fmt"
+	"strings"
+
+	nais_io_v1 "github.com/nais/liberator/pkg/apis/nais.io/v1"
+
+	digdirv1alpha1 "github.com/nais/digdirator/api/v1alpha1"
+	"github.com/nais/digdirator/pkg/config"
+	"github.com/nais/digdirator/pkg/digdir/scopes"
+)
+
+// AnsattportenClientMetadata returns the public values from the client's secret data, with the keys that are generated for the secret.
+// Secret templates are not applied, as they may render private values.
+// The metadata is written to a ConfigMap, so that it can be read without access to the client key.
+func AnsattportenClientMetadata(in *digdirv1alpha1.AnsattportenClient, config *config.Config) (map[string]string, error) {
+	if err := config.DigDir.Ansattporten.Metadata.Validate(config.DigDir.Ansattporten.WellKnownURL); err != nil {
+		return nil, fmt.Errorf("validating Ansattporten metadata: %w", err)
+	}
+
+	return map[string]string{
+		AnsattportenWellKnownURLKey:  config.DigDir.Ansattporten.WellKnownURL,
+		AnsattportenRedirectURIKey:   ansattportenRedirectURI(in),
+		AnsattportenIssuerKey:        config.DigDir.Ansattporten.Metadata.Issuer,
+		AnsattportenJwksUriKey:       config.DigDir.Ansattporten.Metadata.JwksURI,
+		AnsattportenTokenEndpointKey: config.DigDir.Ansattporten.Metadata.TokenEndpoint,
+	}, nil
+}
+
+// IDPortenClientMetadata returns the public values from the client's secret data, see AnsattportenClientMetadata.
+func IDPortenClientMetadata(in *nais_io_v1.IDPortenClient, config *config.Config) (map[string]string, error) {
+	if err := config.DigDir.IDPorten.Metadata.Validate(config.DigDir.IDPorten.WellKnownURL); err != nil {
+		return nil, fmt.Errorf("validating ID-porten metadata: %w", err)
+	}
+
+	return map[string]string{
+		IDPortenWellKnownURLKey:  config.DigDir.IDPorten.WellKnownURL,
+		IDPortenRedirectURIKey:   idportenRedirectURI(in),
+		IDPortenIssuerKey:        config.DigDir.IDPorten.Metadata.Issuer,
+		IDPortenJwksUriKey:       config.DigDir.IDPorten.Metadata.JwksURI,
+		IDPortenTokenEndpointKey: config.DigDir.IDPorten.Metadata.TokenEndpoint,
+	}, nil
+}
+
+// MaskinportenClientMetadata returns the public values from the client's secret data, see AnsattportenClientMetadata.
+// It also contains the fully qualified names of the enabled scopes that the client exposes.
+func MaskinportenClientMetadata(in *nais_io_v1.MaskinportenClient, config *config.Config) (map[string]string, error) {
+	if err := config.DigDir.Maskinporten.Metadata.Validate(config.DigDir.Maskinporten.WellKnownURL); err != nil {
+		return nil, fmt.Errorf("validating Maskinporten metadata: %w", err)
+	}
+
+	exposedScopes := make([]string, 0)
+	for _, scope := range in.Spec.Scopes.ExposedScopes {
+		if scope.Enabled {
+			exposedScopes = append(exposedScopes, config.DigDir.Maskinporten.Default.ScopePrefix+":"+scopes.Subscope(scope))
+		}
+	}
+
+	return map[string]string{
+		MaskinportenWellKnownURLKey:  config.DigDir.Maskinporten.WellKnownURL,
+		MaskinportenIssuerKey:        config.DigDir.Maskinporten.Metadata.Issuer,
+		MaskinportenJwksUriKey:       config.DigDir.Maskinporten.Metadata.JwksURI,
+		MaskinportenTokenEndpointKey: config.DigDir.Maskinporten.Metadata.TokenEndpoint,
+		MaskinportenExposedScopesKey: strings.Join(exposedScopes, " "),
+	}, nil
+}
+
+func ansattportenRedirectURI(in *digdirv1alpha1.AnsattportenClient) string {
+	if len(in.Spec.RedirectURIs) > 0 {
+		return string(in.Spec.RedirectURIs[0])
+	}
+	return ""
+}
+
+func idportenRedirectURI(in *nais_io_v1.IDPortenClient) string {
+	if in.Spec.RedirectURI != "" {
+		return string(in.Spec.RedirectURI)
+	}
+
+	if len(in.Spec.RedirectURIs) > 0 {
+		return string(in.Spec.RedirectURIs[0])
+	}
+
+	return ""
+}
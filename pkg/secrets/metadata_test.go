@@ -0,0 +1,70 @@
+package secrets_test
+
+import (
+	"testing"
+
+	naisiov1 "github.com/nais/liberator/pkg/apis/nais.io/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/nais/digdirator/pkg/fixtures"
+	"github.com/nais/digdirator/pkg/secrets"
+)
+
+func TestIDPortenClientMetadata(t *testing.T) {
+	client := fixtures.MinimalIDPortenClient()
+
+	actual, err := secrets.IDPortenClientMetadata(client, makeConfig())
+	require.NoError(t, err)
+
+	assert.Equal(t, map[string]string{
+		secrets.IDPortenWellKnownURLKey:  "https://idporten.example.com/.well-known/openid-configuration",
+		secrets.IDPortenRedirectURIKey:   "https://test.com",
+		secrets.IDPortenIssuerKey:        "https://idporten.example.com/",
+		secrets.IDPortenJwksUriKey:       "https://idporten.example.com/jwk",
+		secrets.IDPortenTokenEndpointKey: "https://idporten.example.com/token",
+	}, actual)
+}
+
+func TestAnsattportenClientMetadata(t *testing.T) {
+	client := fixtures.MinimalAnsattportenClient()
+
+	actual, err := secrets.AnsattportenClientMetadata(client, makeConfig())
+	require.NoError(t, err)
+
+	assert.Equal(t, map[string]string{
+		secrets.AnsattportenWellKnownURLKey:  "https://ansattporten.example.com/.well-known/openid-configuration",
+		secrets.AnsattportenRedirectURIKey:   "https://test.com",
+		secrets.AnsattportenIssuerKey:        "https://ansattporten.example.com/",
+		secrets.AnsattportenJwksUriKey:       "https://ansattporten.example.com/jwk",
+		secrets.AnsattportenTokenEndpointKey: "https://ansattporten.example.com/token",
+	}, actual)
+}
+
+func TestMaskinportenClientMetadata(t *testing.T) {
+	client := fixtures.MinimalMaskinportenClient()
+	client.Spec.Scopes = naisiov1.MaskinportenScope{
+		ConsumedScopes: []naisiov1.ConsumedScope{
+			{Name: "scope:one"},
+		},
+		ExposedScopes: []naisiov1.ExposedScope{
+			{Name: "first", Product: "arbeid", Enabled: true},
+			{Name: "second", Product: "arbeid", Enabled: false},
+			{Name: "some/scope", Product: "arbeid", Enabled: true},
+		},
+	}
+
+	cfg := makeConfig()
+	cfg.DigDir.Maskinporten.Default.ScopePrefix = "nav"
+
+	actual, err := secrets.MaskinportenClientMetadata(client, cfg)
+	require.NoError(t, err)
+
+	assert.Equal(t, map[string]string{
+		secrets.MaskinportenWellKnownURLKey:  "https://maskinporten.example.com/.well-known/oauth-authorization-server",
+		secrets.MaskinportenIssuerKey:        "https://maskinporten.example.com/",
+		secrets.MaskinportenJwksUriKey:       "https://maskinporten.example.com/jwk",
+		secrets.MaskinportenTokenEndpointKey: "https://maskinporten.example.com/token",
+		secrets.MaskinportenExposedScopesKey: "nav:arbeid:first nav:arbeid/some/scope",
+	}, actual)
+}
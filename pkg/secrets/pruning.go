@@ -0,0 +1,78 @@
+package secrets
+
+import (
+	"slices"
+	"time"
+
+	"github.com/nais/liberator/pkg/kubernetes"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// Pruning sorts the managed secrets of a client into those to keep, and those whose keys should be revoked.
+type Pruning struct {
+	// Keep holds the current secret, and the previous secrets that are still referenced by pods or workloads.
+	Keep []corev1.Secret
+	// Unused holds the previous secrets that are no longer referenced.
+	Unused []corev1.Secret
+	// Expired holds the previous secrets that are still referenced, but have overlapped with the current secret for longer than the maximum overlap.
+	// Their keys are revoked, but the secrets are only deleted once they are no longer referenced, as pods may not start without them.
+	Expired []corev1.Secret
+	// NextExpiry is when the previous secrets that are kept expire, or zero if they never do.
+	NextExpiry time.Time
+}
+
+// Prune sorts the managed secrets, where current is the name of the secret for the current key.
+// Previous secrets expire once maxOverlap has passed since the current secret was created. They never expire if maxOverlap is 0,
+// or if the current secret has not been created yet.
+func Prune(managed kubernetes.SecretLists, current string, maxOverlap time.Duration, now time.Time) Pruning {
+	isCurrent := func(secret corev1.Secret) bool {
+		return secret.GetName() == current
+	}
+
+	pruning := Pruning{
+		Keep:    make([]corev1.Secret, 0),
+		Unused:  make([]corev1.Secret, 0),
+		Expired: make([]corev1.Secret, 0),
+	}
+
+	var expiry time.Time
+	all := slices.Concat(managed.Used.Items, managed.Unused.Items)
+	if i := slices.IndexFunc(all, isCurrent); i >= 0 && maxOverlap > 0 {
+		expiry = all[i].GetCreationTimestamp().Add(maxOverlap)
+	}
+
+	for _, secret := range managed.Used.Items {
+		switch {
+		case isCurrent(secret):
+			pruning.Keep = append(pruning.Keep, secret)
+		case !expiry.IsZero() && !now.Before(expiry):
+			pruning.Expired = append(pruning.Expired, secret)
+		default:
+			pruning.Keep = append(pruning.Keep, secret)
+			pruning.NextExpiry = expiry
+		}
+	}
+
+	for _, secret := range managed.Unused.Items {
+		if isCurrent(secret) {
+			pruning.Keep = append(pruning.Keep, secret)
+		} else {
+			pruning.Unused = append(pruning.Unused, secret)
+		}
+	}
+
+	return pruning
+}
+
+// Revocable returns the secrets whose keys should be revoked, i.e. those that are unused or expired.
+func (p Pruning) Revocable() []corev1.Secret {
+	return slices.Concat(p.Unused, p.Expired)
+}
+
+// SecretLists returns the secrets as used and unused, where the expired secrets are considered unused.
+func (p Pruning) SecretLists() kubernetes.SecretLists {
+	return kubernetes.SecretLists{
+		Used:   corev1.SecretList{Items: p.Keep},
+		Unused: corev1.SecretList{Items: p.Revocable()},
+	}
+}
@@ -0,0 +1,85 @@
+package secrets_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/nais/liberator/pkg/kubernetes"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/nais/digdirator/pkg/secrets"
+)
+
+func TestPrune(t *testing.T) {
+	now := time.Now()
+	secret := func(name string, age time.Duration) corev1.Secret {
+		return corev1.Secret{ObjectMeta: metav1.ObjectMeta{
+			Name:              name,
+			CreationTimestamp: metav1.NewTime(now.Add(-age)),
+		}}
+	}
+	names := func(secrets []corev1.Secret) []string {
+		result := make([]string, 0)
+		for _, s := range secrets {
+			result = append(result, s.Name)
+		}
+		return result
+	}
+
+	managed := kubernetes.SecretLists{
+		Used: corev1.SecretList{Items: []corev1.Secret{
+			secret("current", time.Hour),
+			secret("previous", 2*time.Hour),
+		}},
+		Unused: corev1.SecretList{Items: []corev1.Secret{
+			secret("old", 3*time.Hour),
+		}},
+	}
+
+	t.Run("without maximum overlap", func(t *testing.T) {
+		actual := secrets.Prune(managed, "current", 0, now)
+		assert.Equal(t, []string{"current", "previous"}, names(actual.Keep))
+		assert.Equal(t, []string{"old"}, names(actual.Unused))
+		assert.Empty(t, actual.Expired)
+		assert.True(t, actual.NextExpiry.IsZero())
+	})
+
+	t.Run("within maximum overlap", func(t *testing.T) {
+		actual := secrets.Prune(managed, "current", 2*time.Hour, now)
+		assert.Equal(t, []string{"current", "previous"}, names(actual.Keep))
+		assert.Empty(t, actual.Expired)
+		assert.WithinDuration(t, now.Add(time.Hour), actual.NextExpiry, time.Second)
+	})
+
+	t.Run("after maximum overlap", func(t *testing.T) {
+		actual := secrets.Prune(managed, "current", 30*time.Minute, now)
+		assert.Equal(t, []string{"current"}, names(actual.Keep))
+		assert.Equal(t, []string{"previous"}, names(actual.Expired))
+		assert.Equal(t, []string{"old", "previous"}, names(actual.Revocable()))
+		assert.True(t, actual.NextExpiry.IsZero())
+
+		lists := actual.SecretLists()
+		assert.Equal(t, []string{"current"}, names(lists.Used.Items))
+		assert.Equal(t, []string{"old", "previous"}, names(lists.Unused.Items))
+	})
+
+	t.Run("current secret is unused", func(t *testing.T) {
+		managed := kubernetes.SecretLists{
+			Used:   corev1.SecretList{Items: []corev1.Secret{secret("previous", 2*time.Hour)}},
+			Unused: corev1.SecretList{Items: []corev1.Secret{secret("current", 2*time.Hour)}},
+		}
+		actual := secrets.Prune(managed, "current", time.Hour, now)
+		assert.Equal(t, []string{"current"}, names(actual.Keep))
+		assert.Equal(t, []string{"previous"}, names(actual.Expired))
+		assert.Empty(t, actual.Unused)
+	})
+
+	t.Run("current secret does not exist", func(t *testing.T) {
+		actual := secrets.Prune(managed, "new", time.Minute, now)
+		assert.Equal(t, []string{"current", "previous"}, names(actual.Keep))
+		assert.Equal(t, []string{"old"}, names(actual.Unused))
+		assert.Empty(t, actual.Expired)
+	})
+}
@@ -7,43 +7,65 @@ import (
 	"github.com/go-jose/go-jose/v4"
 	nais_io_v1 "github.com/nais/liberator/pkg/apis/nais.io/v1"
 
+	digdirv1alpha1 "github.com/nais/digdirator/api/v1alpha1"
 	"github.com/nais/digdirator/pkg/config"
 )
 
-func IDPortenClientSecretData(in *nais_io_v1.IDPortenClient, jwk jose.JSONWebKey, config *config.Config) (map[string]string, error) {
+func AnsattportenClientSecretData(in *digdirv1alpha1.AnsattportenClient, jwk jose.JSONWebKey, config *config.Config, formats KeyFormats) (map[string]string, error) {
 	jwkJson, err := jwk.MarshalJSON()
 	if err != nil {
 		return nil, fmt.Errorf("marshalling JWK: %w", err)
 	}
 
-	redirectURI := func() string {
-		if in.Spec.RedirectURI != "" {
-			return string(in.Spec.RedirectURI)
-		}
+	if err := config.DigDir.Ansattporten.Metadata.Validate(config.DigDir.Ansattporten.WellKnownURL); err != nil {
+		return nil, fmt.Errorf("validating Ansattporten metadata: %w", err)
+	}
 
-		if len(in.Spec.RedirectURIs) > 0 {
-			return string(in.Spec.RedirectURIs[0])
-		}
+	data := map[string]string{
+		AnsattportenJwkKey:           string(jwkJson),
+		AnsattportenWellKnownURLKey:  config.DigDir.Ansattporten.WellKnownURL,
+		AnsattportenClientIDKey:      in.GetStatus().ClientID,
+		AnsattportenRedirectURIKey:   ansattportenRedirectURI(in),
+		AnsattportenIssuerKey:        config.DigDir.Ansattporten.Metadata.Issuer,
+		AnsattportenJwksUriKey:       config.DigDir.Ansattporten.Metadata.JwksURI,
+		AnsattportenTokenEndpointKey: config.DigDir.Ansattporten.Metadata.TokenEndpoint,
+	}
 
-		return ""
+	if err := formats.addTo(data, AnsattportenKeys, jwk); err != nil {
+		return nil, fmt.Errorf("adding key formats: %w", err)
+	}
+
+	return data, nil
+}
+
+func IDPortenClientSecretData(in *nais_io_v1.IDPortenClient, jwk jose.JSONWebKey, config *config.Config, formats KeyFormats) (map[string]string, error) {
+	jwkJson, err := jwk.MarshalJSON()
+	if err != nil {
+		return nil, fmt.Errorf("marshalling JWK: %w", err)
 	}
 
 	if err := config.DigDir.IDPorten.Metadata.Validate(config.DigDir.IDPorten.WellKnownURL); err != nil {
 		return nil, fmt.Errorf("validating ID-porten metadata: %w", err)
 	}
 
-	return map[string]string{
+	data := map[string]string{
 		IDPortenJwkKey:           string(jwkJson),
 		IDPortenWellKnownURLKey:  config.DigDir.IDPorten.WellKnownURL,
 		IDPortenClientIDKey:      in.GetStatus().ClientID,
-		IDPortenRedirectURIKey:   redirectURI(),
+		IDPortenRedirectURIKey:   idportenRedirectURI(in),
 		IDPortenIssuerKey:        config.DigDir.IDPorten.Metadata.Issuer,
 		IDPortenJwksUriKey:       config.DigDir.IDPorten.Metadata.JwksURI,
 		IDPortenTokenEndpointKey: config.DigDir.IDPorten.Metadata.TokenEndpoint,
-	}, nil
+	}
+
+	if err := formats.addTo(data, IDPortenKeys, jwk); err != nil {
+		return nil, fmt.Errorf("adding key formats: %w", err)
+	}
+
+	return data, nil
 }
 
-func MaskinportenClientSecretData(in *nais_io_v1.MaskinportenClient, jwk jose.JSONWebKey, config *config.Config) (map[string]string, error) {
+func MaskinportenClientSecretData(in *nais_io_v1.MaskinportenClient, jwk jose.JSONWebKey, config *config.Config, formats KeyFormats) (map[string]string, error) {
 	jwkJson, err := jwk.MarshalJSON()
 	if err != nil {
 		return nil, fmt.Errorf("marshalling JWK: %w", err)
@@ -58,7 +80,7 @@ func MaskinportenClientSecretData(in *nais_io_v1.MaskinportenClient, jwk jose.JS
 		return nil, fmt.Errorf("validating Maskinporten metadata: %w", err)
 	}
 
-	return map[string]string{
+	data := map[string]string{
 		MaskinportenJwkKey:           string(jwkJson),
 		MaskinportenWellKnownURLKey:  config.DigDir.Maskinporten.WellKnownURL,
 		MaskinportenClientIDKey:      in.GetStatus().ClientID,
@@ -66,5 +88,11 @@ func MaskinportenClientSecretData(in *nais_io_v1.MaskinportenClient, jwk jose.JS
 		MaskinportenIssuerKey:        config.DigDir.Maskinporten.Metadata.Issuer,
 		MaskinportenJwksUriKey:       config.DigDir.Maskinporten.Metadata.JwksURI,
 		MaskinportenTokenEndpointKey: config.DigDir.Maskinporten.Metadata.TokenEndpoint,
-	}, nil
+	}
+
+	if err := formats.addTo(data, MaskinportenKeys, jwk); err != nil {
+		return nil, fmt.Errorf("adding key formats: %w", err)
+	}
+
+	return data, nil
 }
@@ -2,11 +2,15 @@ package secrets_test
 
 import (
 	"encoding/json"
+	"maps"
 	"testing"
 
+	"github.com/go-jose/go-jose/v4"
 	naisiov1 "github.com/nais/liberator/pkg/apis/nais.io/v1"
 	"github.com/nais/liberator/pkg/oauth"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"software.sslmate.com/src/go-pkcs12"
 
 	"github.com/nais/digdirator/pkg/config"
 	"github.com/nais/digdirator/pkg/crypto"
@@ -17,12 +21,12 @@ import (
 func TestIDPortenClientSecretData(t *testing.T) {
 	client := fixtures.MinimalIDPortenClient()
 
-	jwk, err := crypto.GenerateJwk()
+	jwk, err := crypto.GenerateJwk(crypto.DefaultKeyType)
 	assert.NoError(t, err)
 
 	cfg := makeConfig()
 
-	stringData, err := secrets.IDPortenClientSecretData(client, *jwk, cfg)
+	stringData, err := secrets.IDPortenClientSecretData(client, *jwk, cfg, secrets.KeyFormats{})
 	assert.NoError(t, err, "should not error")
 
 	t.Run("StringData should contain expected fields and values", func(t *testing.T) {
@@ -65,6 +69,61 @@ func TestIDPortenClientSecretData(t *testing.T) {
 	})
 }
 
+func TestAnsattportenClientSecretData(t *testing.T) {
+	client := fixtures.MinimalAnsattportenClient()
+
+	jwk, err := crypto.GenerateJwk(crypto.DefaultKeyType)
+	assert.NoError(t, err)
+
+	cfg := makeConfig()
+
+	stringData, err := secrets.AnsattportenClientSecretData(client, *jwk, cfg, secrets.KeyFormats{})
+	assert.NoError(t, err, "should not error")
+
+	t.Run("StringData should contain expected fields and values", func(t *testing.T) {
+		t.Run("Secret Data should contain "+secrets.AnsattportenJwkKey, func(t *testing.T) {
+			expected, err := json.Marshal(jwk)
+			assert.NoError(t, err)
+			assert.Equal(t, string(expected), stringData[secrets.AnsattportenJwkKey])
+		})
+
+		for _, test := range []struct {
+			key      string
+			expected string
+		}{
+			{
+				key:      secrets.AnsattportenClientIDKey,
+				expected: "test-ansattporten",
+			},
+			{
+				key:      secrets.AnsattportenRedirectURIKey,
+				expected: "https://test.com",
+			},
+			{
+				key:      secrets.AnsattportenWellKnownURLKey,
+				expected: "https://ansattporten.example.com/.well-known/openid-configuration",
+			},
+			{
+				key:      secrets.AnsattportenIssuerKey,
+				expected: "https://ansattporten.example.com/",
+			},
+			{
+				key:      secrets.AnsattportenJwksUriKey,
+				expected: "https://ansattporten.example.com/jwk",
+			},
+			{
+				key:      secrets.AnsattportenTokenEndpointKey,
+				expected: "https://ansattporten.example.com/token",
+			},
+		} {
+			t.Run("Secret Data should contain "+test.key, func(t *testing.T) {
+				assert.NotEmpty(t, stringData[test.key])
+				assert.Equal(t, test.expected, stringData[test.key])
+			})
+		}
+	})
+}
+
 func TestMaskinportenClientSecretData(t *testing.T) {
 	client := fixtures.MinimalMaskinportenClient()
 	client.Spec.Scopes = naisiov1.MaskinportenScope{
@@ -74,12 +133,12 @@ func TestMaskinportenClientSecretData(t *testing.T) {
 		},
 	}
 
-	jwk, err := crypto.GenerateJwk()
+	jwk, err := crypto.GenerateJwk(crypto.DefaultKeyType)
 	assert.NoError(t, err)
 
 	cfg := makeConfig()
 
-	stringData, err := secrets.MaskinportenClientSecretData(client, *jwk, cfg)
+	stringData, err := secrets.MaskinportenClientSecretData(client, *jwk, cfg, secrets.KeyFormats{})
 	assert.NoError(t, err, "should not error")
 
 	t.Run("StringData should contain expected fields and values", func(t *testing.T) {
@@ -126,9 +185,134 @@ func TestMaskinportenClientSecretData(t *testing.T) {
 	})
 }
 
+func TestMaskinportenClientSecretData_KeyFormats(t *testing.T) {
+	client := fixtures.MinimalMaskinportenClient()
+	cfg := makeConfig()
+
+	jwk, err := crypto.GenerateJwk(crypto.DefaultKeyType)
+	require.NoError(t, err)
+
+	t.Run("no formats should only contain the JWK", func(t *testing.T) {
+		stringData, err := secrets.MaskinportenClientSecretData(client, *jwk, cfg, secrets.KeyFormats{})
+		require.NoError(t, err)
+
+		for _, key := range []string{
+			secrets.MaskinportenKeyIDKey,
+			secrets.MaskinportenPrivateKeyKey,
+			secrets.MaskinportenPublicKeyKey,
+			secrets.MaskinportenKeystoreKey,
+			secrets.MaskinportenKeystorePasswordKey,
+		} {
+			assert.NotContains(t, stringData, key)
+		}
+	})
+
+	formats := secrets.KeyFormats{Formats: []crypto.SecretFormat{crypto.SecretFormatPEM, crypto.SecretFormatPKCS12}}
+	stringData, err := secrets.MaskinportenClientSecretData(client, *jwk, cfg, formats)
+	require.NoError(t, err)
+
+	t.Run("Secret Data should contain "+secrets.MaskinportenKeyIDKey, func(t *testing.T) {
+		assert.Equal(t, jwk.KeyID, stringData[secrets.MaskinportenKeyIDKey])
+	})
+
+	t.Run("Secret Data should contain PEM keys", func(t *testing.T) {
+		privateKey, err := crypto.PrivateKeyPEM(*jwk)
+		require.NoError(t, err)
+		assert.Equal(t, string(privateKey), stringData[secrets.MaskinportenPrivateKeyKey])
+
+		publicKey, err := crypto.PublicKeyPEM(*jwk)
+		require.NoError(t, err)
+		assert.Equal(t, string(publicKey), stringData[secrets.MaskinportenPublicKeyKey])
+	})
+
+	t.Run("Secret Data should contain keystore", func(t *testing.T) {
+		privateKey, cert, _, err := pkcs12.DecodeChain([]byte(stringData[secrets.MaskinportenKeystoreKey]), stringData[secrets.MaskinportenKeystorePasswordKey])
+		require.NoError(t, err)
+		assert.Equal(t, jwk.KeyID, cert.Subject.CommonName)
+
+		privateKeyPEM, err := crypto.PrivateKeyPEM(jose.JSONWebKey{Key: privateKey})
+		require.NoError(t, err)
+		assert.Equal(t, stringData[secrets.MaskinportenPrivateKeyKey], string(privateKeyPEM))
+
+		_, _, _, err = pkcs12.DecodeChain([]byte(stringData[secrets.MaskinportenKeystoreKey]), "wrong")
+		assert.ErrorIs(t, err, pkcs12.ErrIncorrectPassword)
+	})
+
+	existing := make(map[string][]byte)
+	for key, value := range stringData {
+		existing[key] = []byte(value)
+	}
+
+	t.Run("existing keystore for the same key should be kept", func(t *testing.T) {
+		formats.Existing = existing
+		actual, err := secrets.MaskinportenClientSecretData(client, *jwk, cfg, formats)
+		require.NoError(t, err)
+		assert.Equal(t, stringData[secrets.MaskinportenKeystoreKey], actual[secrets.MaskinportenKeystoreKey])
+		assert.Equal(t, stringData[secrets.MaskinportenKeystorePasswordKey], actual[secrets.MaskinportenKeystorePasswordKey])
+	})
+
+	t.Run("existing keystore with a changed password should be replaced", func(t *testing.T) {
+		tampered := maps.Clone(existing)
+		tampered[secrets.MaskinportenKeystorePasswordKey] = []byte("changed")
+
+		formats.Existing = tampered
+		actual, err := secrets.MaskinportenClientSecretData(client, *jwk, cfg, formats)
+		require.NoError(t, err)
+		assert.NotEqual(t, "changed", actual[secrets.MaskinportenKeystorePasswordKey])
+
+		_, cert, _, err := pkcs12.DecodeChain([]byte(actual[secrets.MaskinportenKeystoreKey]), actual[secrets.MaskinportenKeystorePasswordKey])
+		require.NoError(t, err)
+		assert.Equal(t, jwk.KeyID, cert.Subject.CommonName)
+	})
+
+	t.Run("existing keystore holding another key should be replaced", func(t *testing.T) {
+		otherJwk, err := crypto.GenerateJwk(crypto.DefaultKeyType)
+		require.NoError(t, err)
+		other, err := secrets.MaskinportenClientSecretData(client, *otherJwk, cfg, secrets.KeyFormats{Formats: formats.Formats})
+		require.NoError(t, err)
+
+		tampered := maps.Clone(existing)
+		tampered[secrets.MaskinportenKeystoreKey] = []byte(other[secrets.MaskinportenKeystoreKey])
+		tampered[secrets.MaskinportenKeystorePasswordKey] = []byte(other[secrets.MaskinportenKeystorePasswordKey])
+
+		formats.Existing = tampered
+		actual, err := secrets.MaskinportenClientSecretData(client, *jwk, cfg, formats)
+		require.NoError(t, err)
+		assert.NotEqual(t, other[secrets.MaskinportenKeystoreKey], actual[secrets.MaskinportenKeystoreKey])
+
+		privateKey, _, _, err := pkcs12.DecodeChain([]byte(actual[secrets.MaskinportenKeystoreKey]), actual[secrets.MaskinportenKeystorePasswordKey])
+		require.NoError(t, err)
+		privateKeyPEM, err := crypto.PrivateKeyPEM(jose.JSONWebKey{Key: privateKey})
+		require.NoError(t, err)
+		assert.Equal(t, stringData[secrets.MaskinportenPrivateKeyKey], string(privateKeyPEM))
+	})
+
+	t.Run("existing keystore for another key should be replaced", func(t *testing.T) {
+		newJwk, err := crypto.GenerateJwk(crypto.DefaultKeyType)
+		require.NoError(t, err)
+
+		formats.Existing = existing
+		actual, err := secrets.MaskinportenClientSecretData(client, *newJwk, cfg, formats)
+		require.NoError(t, err)
+		assert.NotEqual(t, stringData[secrets.MaskinportenKeystorePasswordKey], actual[secrets.MaskinportenKeystorePasswordKey])
+
+		_, cert, _, err := pkcs12.DecodeChain([]byte(actual[secrets.MaskinportenKeystoreKey]), actual[secrets.MaskinportenKeystorePasswordKey])
+		require.NoError(t, err)
+		assert.Equal(t, newJwk.KeyID, cert.Subject.CommonName)
+	})
+}
+
 func makeConfig() *config.Config {
 	return &config.Config{
 		DigDir: config.DigDir{
+			Ansattporten: config.Ansattporten{
+				WellKnownURL: "https://ansattporten.example.com/.well-known/openid-configuration",
+				Metadata: oauth.MetadataOpenID{
+					Issuer:        "https://ansattporten.example.com/",
+					JwksURI:       "https://ansattporten.example.com/jwk",
+					TokenEndpoint: "https://ansattporten.example.com/token",
+				},
+			},
 			IDPorten: config.IDPorten{
 				WellKnownURL: "https://idporten.example.com/.well-known/openid-configuration",
 				Metadata: oauth.MetadataOpenID{
@@ -0,0 +1,180 @@
+package secrets
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"text/template"
+
+	"github.com/go-jose/go-jose/v4"
+	"k8s.io/apimachinery/pkg/util/validation"
+
+	"github.com/nais/digdirator/internal/templates"
+	"github.com/nais/digdirator/pkg/config"
+)
+
+// TemplateData is the data available to secret key templates.
+type TemplateData struct {
+	ClientID string
+	// JWK is the client's private JWK as JSON, and KeyID is its key ID.
+	JWK   string
+	KeyID string
+	// RedirectURI is the first redirect URI of ID-porten and Ansattporten clients.
+	RedirectURI string
+	// Scopes are the scopes consumed by Maskinporten clients.
+	Scopes []string
+	// WellKnownURL is the URL to the provider's metadata document, and the other fields are properties from it.
+	WellKnownURL  string
+	Issuer        string
+	JwksURI       string
+	TokenEndpoint string
+}
+
+var templateFuncs = template.FuncMap{
+	"join": strings.Join,
+}
+
+// ParseTemplates parses the templates for keys in place, and validates the cluster-wide templates and the templates for each namespace.
+func ParseTemplates(templates *config.SecretTemplates) error {
+	if err := parseKeyTemplates(templates.Keys); err != nil {
+		return err
+	}
+	for _, namespace := range slices.Sorted(maps.Keys(templates.Namespaces)) {
+		if err := parseKeyTemplates(templates.Namespaces[namespace].Keys); err != nil {
+			return fmt.Errorf("namespace %q: %w", namespace, err)
+		}
+	}
+
+	if err := validateTemplate(templates.For("")); err != nil {
+		return err
+	}
+
+	for _, namespace := range slices.Sorted(maps.Keys(templates.Namespaces)) {
+		if err := validateTemplate(templates.For(namespace)); err != nil {
+			return fmt.Errorf("namespace %q: %w", namespace, err)
+		}
+	}
+	return nil
+}
+
+// ApplyTemplates renames keys in the secret data and adds keys rendered from templates, in that order.
+// Added keys take precedence over generated keys with the same name. The templates must be parsed, see ParseTemplates.
+func ApplyTemplates(tmpl config.SecretTemplate, keys Keys, jwk jose.JSONWebKey, data map[string]string) (map[string]string, error) {
+	if len(tmpl.Keys) == 0 && len(tmpl.Rename) == 0 {
+		return data, nil
+	}
+
+	result := maps.Clone(data)
+	for _, rename := range tmpl.Rename {
+		if value, found := result[rename.From]; found {
+			delete(result, rename.From)
+			result[rename.To] = value
+		}
+	}
+
+	values := templateData(keys, jwk, data)
+	for _, key := range tmpl.Keys {
+		if key.Parsed == nil {
+			return nil, fmt.Errorf("template for key %q is not parsed", key.Name)
+		}
+
+		var sb strings.Builder
+		if err := key.Parsed.Execute(&sb, values); err != nil {
+			return nil, fmt.Errorf("rendering template for key %q: %w", key.Name, err)
+		}
+		result[key.Name] = sb.String()
+	}
+
+	return result, nil
+}
+
+func templateData(keys Keys, jwk jose.JSONWebKey, data map[string]string) TemplateData {
+	value := func(key string) string {
+		if key == "" {
+			return ""
+		}
+		return data[key]
+	}
+
+	return TemplateData{
+		ClientID:      value(keys.ClientID),
+		JWK:           value(keys.JWK),
+		KeyID:         jwk.KeyID,
+		RedirectURI:   value(keys.RedirectURI),
+		Scopes:        strings.Fields(value(keys.Scopes)),
+		WellKnownURL:  value(keys.WellKnownURL),
+		Issuer:        value(keys.Issuer),
+		JwksURI:       value(keys.JwksURI),
+		TokenEndpoint: value(keys.TokenEndpoint),
+	}
+}
+
+// parseKeyTemplates parses the templates for the keys, see TemplateData for the available fields.
+func parseKeyTemplates(keys []config.SecretKeyTemplate) error {
+	for i, key := range keys {
+		t, err := templates.Parse[TemplateData](key.Name, key.Template, templateFuncs)
+		if err != nil {
+			return fmt.Errorf("parsing template for key %q: %w", key.Name, err)
+		}
+		keys[i].Parsed = t
+	}
+	return nil
+}
+
+func validateTemplate(tmpl config.SecretTemplate) error {
+	allKeys := []Keys{AnsattportenKeys, IDPortenKeys, MaskinportenKeys}
+
+	generated := make([]string, 0)
+	readBack := make([]string, 0)
+	for _, keys := range allKeys {
+		generated = append(generated, keys.all()...)
+		readBack = append(readBack, keys.readBack()...)
+	}
+
+	for _, rename := range tmpl.Rename {
+		if !slices.Contains(generated, rename.From) {
+			return fmt.Errorf("key %q cannot be renamed as it is not generated by digdirator", rename.From)
+		}
+		if slices.Contains(readBack, rename.From) {
+			return fmt.Errorf("key %q cannot be renamed as it is read from existing secrets, add a key with a template instead", rename.From)
+		}
+		if slices.Contains(readBack, rename.To) {
+			return fmt.Errorf("key %q cannot be renamed to %q as it is read from existing secrets", rename.From, rename.To)
+		}
+		if err := validateKey(rename.To); err != nil {
+			return err
+		}
+	}
+
+	// keys of different types of clients are never in the same secret, and may be renamed to the same name
+	for _, keys := range allKeys {
+		targets := make(map[string]string)
+		for _, rename := range tmpl.Rename {
+			if !slices.Contains(keys.all(), rename.From) {
+				continue
+			}
+			if other, found := targets[rename.To]; found {
+				return fmt.Errorf("keys %q and %q are both renamed to %q", other, rename.From, rename.To)
+			}
+			targets[rename.To] = rename.From
+		}
+	}
+
+	for _, key := range tmpl.Keys {
+		if err := validateKey(key.Name); err != nil {
+			return err
+		}
+		if slices.Contains(readBack, key.Name) {
+			return fmt.Errorf("key %q cannot be replaced by a template as it is read from existing secrets", key.Name)
+		}
+	}
+	return nil
+}
+
+func validateKey(key string) error {
+	if errs := validation.IsConfigMapKey(key); len(errs) > 0 {
+		return fmt.Errorf("invalid secret key %q: %s", key, strings.Join(errs, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,160 @@
+package secrets_test
+
+import (
+	"testing"
+
+	naisiov1 "github.com/nais/liberator/pkg/apis/nais.io/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/nais/digdirator/pkg/config"
+	"github.com/nais/digdirator/pkg/crypto"
+	"github.com/nais/digdirator/pkg/fixtures"
+	"github.com/nais/digdirator/pkg/secrets"
+)
+
+func TestApplyTemplates(t *testing.T) {
+	client := fixtures.MinimalMaskinportenClient()
+	client.Spec.Scopes.ConsumedScopes = []naisiov1.ConsumedScope{
+		{Name: "scope:one"},
+		{Name: "scope:two"},
+	}
+
+	jwk, err := crypto.GenerateJwk(crypto.DefaultKeyType)
+	require.NoError(t, err)
+
+	data, err := secrets.MaskinportenClientSecretData(client, *jwk, makeConfig(), secrets.KeyFormats{})
+	require.NoError(t, err)
+
+	templates := config.SecretTemplates{
+		SecretTemplate: config.SecretTemplate{
+			Keys: []config.SecretKeyTemplate{
+				{Name: "AUTH_CLIENT_ID", Template: "{{ .ClientID }}"},
+				{Name: "AUTH_SCOPES", Template: `{{ join .Scopes "," }}`},
+				{Name: "AUTH_KID", Template: "{{ .KeyID }}"},
+			},
+			Rename: []config.SecretKeyRename{
+				{From: secrets.MaskinportenTokenEndpointKey, To: "AUTH_TOKEN_URL"},
+			},
+		},
+		Namespaces: map[string]config.SecretTemplate{
+			"other-namespace": {
+				Keys: []config.SecretKeyTemplate{
+					{Name: "AUTH_CLIENT_ID", Template: "client-{{ .ClientID }}"},
+				},
+				Rename: []config.SecretKeyRename{
+					{From: secrets.MaskinportenTokenEndpointKey, To: "TOKEN_ENDPOINT"},
+				},
+			},
+		},
+	}
+	require.NoError(t, secrets.ParseTemplates(&templates))
+
+	t.Run("cluster-wide templates", func(t *testing.T) {
+		actual, err := secrets.ApplyTemplates(templates.For(client.GetNamespace()), secrets.MaskinportenKeys, *jwk, data)
+		require.NoError(t, err)
+
+		assert.Equal(t, "test-maskinporten", actual["AUTH_CLIENT_ID"])
+		assert.Equal(t, "scope:one,scope:two", actual["AUTH_SCOPES"])
+		assert.Equal(t, jwk.KeyID, actual["AUTH_KID"])
+		assert.Equal(t, "https://maskinporten.example.com/token", actual["AUTH_TOKEN_URL"])
+		assert.NotContains(t, actual, secrets.MaskinportenTokenEndpointKey, "renamed key should be removed")
+		assert.Equal(t, data[secrets.MaskinportenJwkKey], actual[secrets.MaskinportenJwkKey], "other keys should be kept")
+		assert.Contains(t, data, secrets.MaskinportenTokenEndpointKey, "data should not be modified")
+	})
+
+	t.Run("namespace templates take precedence", func(t *testing.T) {
+		actual, err := secrets.ApplyTemplates(templates.For("other-namespace"), secrets.MaskinportenKeys, *jwk, data)
+		require.NoError(t, err)
+
+		assert.Equal(t, "client-test-maskinporten", actual["AUTH_CLIENT_ID"])
+		assert.Equal(t, "scope:one,scope:two", actual["AUTH_SCOPES"])
+		assert.Equal(t, "https://maskinporten.example.com/token", actual["TOKEN_ENDPOINT"])
+		assert.NotContains(t, actual, "AUTH_TOKEN_URL")
+	})
+
+	t.Run("unparsed templates", func(t *testing.T) {
+		_, err := secrets.ApplyTemplates(config.SecretTemplate{
+			Keys: []config.SecretKeyTemplate{{Name: "AUTH_CLIENT_ID", Template: "{{ .ClientID }}"}},
+		}, secrets.MaskinportenKeys, *jwk, data)
+		assert.ErrorContains(t, err, "is not parsed")
+	})
+
+	t.Run("no templates", func(t *testing.T) {
+		actual, err := secrets.ApplyTemplates(config.SecretTemplate{}, secrets.MaskinportenKeys, *jwk, data)
+		require.NoError(t, err)
+		assert.Equal(t, data, actual)
+	})
+}
+
+func TestParseTemplates(t *testing.T) {
+	t.Run("same name for different types of clients", func(t *testing.T) {
+		err := secrets.ParseTemplates(&config.SecretTemplates{SecretTemplate: config.SecretTemplate{
+			Rename: []config.SecretKeyRename{
+				{From: secrets.IDPortenClientIDKey, To: "CLIENT_ID"},
+				{From: secrets.MaskinportenClientIDKey, To: "CLIENT_ID"},
+			},
+		}})
+		assert.NoError(t, err)
+	})
+
+	for _, test := range []struct {
+		name      string
+		templates config.SecretTemplates
+		wantErr   string
+	}{
+		{
+			name: "unknown field",
+			templates: config.SecretTemplates{SecretTemplate: config.SecretTemplate{
+				Keys: []config.SecretKeyTemplate{{Name: "CLIENT_SECRET", Template: "{{ .ClientSecret }}"}},
+			}},
+			wantErr: `parsing template for key "CLIENT_SECRET"`,
+		},
+		{
+			name: "invalid key",
+			templates: config.SecretTemplates{SecretTemplate: config.SecretTemplate{
+				Keys: []config.SecretKeyTemplate{{Name: "CLIENT ID", Template: "{{ .ClientID }}"}},
+			}},
+			wantErr: `invalid secret key "CLIENT ID"`,
+		},
+		{
+			name: "renamed JWK",
+			templates: config.SecretTemplates{SecretTemplate: config.SecretTemplate{
+				Rename: []config.SecretKeyRename{{From: secrets.IDPortenJwkKey, To: "JWK"}},
+			}},
+			wantErr: `key "IDPORTEN_CLIENT_JWK" cannot be renamed`,
+		},
+		{
+			name: "template for keystore",
+			templates: config.SecretTemplates{SecretTemplate: config.SecretTemplate{
+				Keys: []config.SecretKeyTemplate{{Name: secrets.MaskinportenKeystoreKey, Template: "{{ .JWK }}"}},
+			}},
+			wantErr: `key "MASKINPORTEN_CLIENT_KEYSTORE" cannot be replaced`,
+		},
+		{
+			name: "unknown key renamed",
+			templates: config.SecretTemplates{SecretTemplate: config.SecretTemplate{
+				Rename: []config.SecretKeyRename{{From: "IDPORTEN_CLIENT", To: "CLIENT_ID"}},
+			}},
+			wantErr: `key "IDPORTEN_CLIENT" cannot be renamed as it is not generated`,
+		},
+		{
+			name: "duplicate rename in namespace",
+			templates: config.SecretTemplates{
+				SecretTemplate: config.SecretTemplate{
+					Rename: []config.SecretKeyRename{{From: secrets.IDPortenClientIDKey, To: "CLIENT_ID"}},
+				},
+				Namespaces: map[string]config.SecretTemplate{
+					"team": {Rename: []config.SecretKeyRename{{From: secrets.IDPortenIssuerKey, To: "CLIENT_ID"}}},
+				},
+			},
+			wantErr: `namespace "team": keys "IDPORTEN_CLIENT_ID" and "IDPORTEN_ISSUER" are both renamed to "CLIENT_ID"`,
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			err := secrets.ParseTemplates(&test.templates)
+			require.Error(t, err)
+			assert.ErrorContains(t, err, test.wantErr)
+		})
+	}
+}
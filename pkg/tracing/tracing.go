@@ -0,0 +1,78 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.41.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	serviceName = "digdirator"
+	tracerName  = "github.com/nais/digdirator"
+)
+
+// Attribute keys shared by spans across packages.
+const (
+	AttributeClientID      = attribute.Key("digdir.client_id")
+	AttributeCorrelationID = attribute.Key("digdirator.correlation_id")
+	AttributeIdentity      = attribute.Key("digdirator.identity")
+	AttributeScope         = attribute.Key("digdir.scope")
+)
+
+// Setup configures a global tracer provider that exports spans with OTLP over gRPC.
+// The exporter is configured with the standard environment variables, e.g. OTEL_EXPORTER_OTLP_ENDPOINT.
+// If tracing is disabled, the global no-op tracer provider is left in place.
+// The returned function flushes and stops the exporter.
+func Setup(ctx context.Context, enabled bool) (func(context.Context) error, error) {
+	if !enabled {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	exporter, err := otlptracegrpc.New(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("creating OTLP trace exporter: %w", err)
+	}
+
+	// the service name may be overridden with OTEL_SERVICE_NAME
+	res, err := resource.New(ctx,
+		resource.WithAttributes(semconv.ServiceName(serviceName)),
+		resource.WithFromEnv(),
+		resource.WithTelemetrySDK(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("creating resource: %w", err)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+	)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	return provider.Shutdown, nil
+}
+
+// Start starts a span with the global tracer provider, which is a no-op unless tracing is set up.
+func Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(tracerName).Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// End records the error, if any, and ends the span.
+// It is meant to be deferred with a pointer to a named error result.
+func End(span trace.Span, err *error) {
+	if err != nil && *err != nil {
+		span.RecordError(*err)
+		span.SetStatus(codes.Error, (*err).Error())
+	}
+	span.End()
+}